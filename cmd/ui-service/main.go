@@ -12,6 +12,10 @@ import (
 
 	"github.com/bwburch/inflight-ui-service/internal/api"
 	"github.com/bwburch/inflight-ui-service/internal/config"
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/bwburch/inflight-ui-service/internal/storage/templates"
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -77,6 +81,18 @@ func main() {
 
 	logger.Info("Redis connection established")
 
+	// Subcommands run against the database and exit instead of serving
+	if flag.Arg(0) == "policy" {
+		code := runPolicyCommand(ctx, flag.Args()[1:], cfg, db, redisClient, logger)
+		redisClient.Close()
+		db.Close()
+		os.Exit(code)
+	}
+
+	if cfg.SCIM.Enabled && cfg.SCIM.BearerToken == "" {
+		logger.Fatal("SCIM is enabled but no bearer token is configured")
+	}
+
 	// Run migrations if enabled
 	if cfg.Migrations.AutoRun {
 		if err := runMigrations(db, cfg.Migrations.Path, logger); err != nil {
@@ -84,8 +100,18 @@ func main() {
 		}
 	}
 
+	// Upsert the permissions declared in code
+	if err := syncPermissions(ctx, db, logger); err != nil {
+		logger.Fatalf("Failed to sync permissions: %v", err)
+	}
+
+	// Bring templates saved under an older configuration schema up to date
+	if err := upgradeTemplates(ctx, db, logger); err != nil {
+		logger.Fatalf("Failed to upgrade templates: %v", err)
+	}
+
 	// Create and start server
-	server := api.NewServer(db, redisClient, logger)
+	server := api.NewServer(cfg, db, redisClient, logger)
 
 	// Graceful shutdown
 	go func() {
@@ -105,6 +131,42 @@ func main() {
 	}
 }
 
+func syncPermissions(ctx context.Context, db *sql.DB, logger *logrus.Logger) error {
+	result, err := rbac.NewPermissionStore(db).Sync(ctx, permissions.All())
+	if err != nil {
+		return err
+	}
+
+	if len(result.Created) > 0 || len(result.Updated) > 0 {
+		logger.WithFields(logrus.Fields{
+			"created": result.Created,
+			"updated": result.Updated,
+		}).Info("Permission registry synced")
+	}
+	if len(result.Undeclared) > 0 {
+		logger.WithField("permissions", result.Undeclared).Warn("Permissions in the database are no longer declared in code")
+	}
+	return nil
+}
+
+func upgradeTemplates(ctx context.Context, db *sql.DB, logger *logrus.Logger) error {
+	result, err := templates.NewStore(db).UpgradeConfigurations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Upgraded) > 0 {
+		logger.WithFields(logrus.Fields{
+			"templates":      result.Upgraded,
+			"schema_version": templateschema.CurrentVersion,
+		}).Info("Template configurations upgraded")
+	}
+	for id, reason := range result.Failed {
+		logger.WithField("template_id", id).Warnf("Template configuration can't be upgraded: %v", reason)
+	}
+	return nil
+}
+
 func runMigrations(db *sql.DB, migrationsPath string, logger *logrus.Logger) error {
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bwburch/inflight-ui-service/internal/cache"
+	"github.com/bwburch/inflight-ui-service/internal/config"
+	"github.com/bwburch/inflight-ui-service/internal/policy"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const policyUsage = `usage: ui-service [-config path] policy <plan|apply> -file policy.yaml [-prune] [-exit-code]`
+
+// Exit codes of the policy subcommand
+const (
+	exitOK      = 0
+	exitFailed  = 1  // The policy could not be loaded, planned or applied
+	exitChanges = 2  // plan -exit-code found changes
+	exitUsage   = 64 // Bad arguments (EX_USAGE)
+)
+
+// runPolicyCommand implements the policy subcommand and returns the exit code.
+// plan prints the changes apply would make; apply makes them in one transaction.
+func runPolicyCommand(ctx context.Context, args []string, cfg *config.Config, db *sql.DB, redisClient *redis.Client, logger *logrus.Logger) int {
+	if len(args) == 0 || (args[0] != "plan" && args[0] != "apply") {
+		fmt.Fprintln(os.Stderr, policyUsage)
+		return exitUsage
+	}
+	mode := args[0]
+
+	flags := flag.NewFlagSet("policy "+mode, flag.ContinueOnError)
+	file := flags.String("file", "", "Path to the policy file (required)")
+	prune := flags.Bool("prune", false, "Delete custom roles and permissions missing from the policy")
+	exitCode := flags.Bool("exit-code", false, "plan: exit with status 2 when there are changes")
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, policyUsage)
+		return exitUsage
+	}
+
+	doc, err := policy.LoadFile(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+
+	store := rbac.NewPolicyStore(db)
+	store.SetRequireVerifiedEmail(cfg.Auth.RequireEmailVerification)
+	if cfg.Cache.Enabled {
+		// Bumps the shared cache version so running replicas reload policies
+		store.SetInvalidator(cache.NewPermissionCache(redisClient, logger, cache.Options{
+			Size:     cfg.Cache.Size,
+			LocalTTL: cfg.Cache.LocalTTL,
+			RedisTTL: cfg.Cache.RedisTTL,
+		}))
+	}
+	opts := policy.Options{Prune: *prune}
+
+	if mode == "plan" {
+		plan, err := store.Plan(ctx, doc, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailed
+		}
+		plan.Write(os.Stdout)
+		if *exitCode && !plan.Empty() {
+			return exitChanges
+		}
+		return exitOK
+	}
+
+	plan, err := store.Apply(ctx, doc, opts, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apply failed, nothing was changed: %v\n", err)
+		return exitFailed
+	}
+	plan.Write(os.Stdout)
+	if !plan.Empty() {
+		fmt.Println("Applied.")
+	}
+	return exitOK
+}
@@ -0,0 +1,439 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/notify"
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/labstack/echo/v4"
+)
+
+type AccessRequestsHandler struct {
+	accessStore   *rbac.AccessRequestStore
+	roleStore     *rbac.RoleStore
+	userRoleStore *rbac.UserRoleStore
+	notifier      notify.Notifier
+}
+
+func NewAccessRequestsHandler(accessStore *rbac.AccessRequestStore, roleStore *rbac.RoleStore, userRoleStore *rbac.UserRoleStore, notifier notify.Notifier) *AccessRequestsHandler {
+	return &AccessRequestsHandler{
+		accessStore:   accessStore,
+		roleStore:     roleStore,
+		userRoleStore: userRoleStore,
+		notifier:      notifier,
+	}
+}
+
+// ============================================================================
+// Access Request Endpoints
+// ============================================================================
+
+// CreateRequest requests a role for a limited time
+// POST /api/v1/auth/access-requests
+func (h *AccessRequestsHandler) CreateRequest(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var input struct {
+		RoleID          int    `json:"role_id"`
+		DurationMinutes int    `json:"duration_minutes"`
+		Justification   string `json:"justification"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	input.Justification = strings.TrimSpace(input.Justification)
+	if input.Justification == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "justification is required")
+	}
+
+	duration := time.Duration(input.DurationMinutes) * time.Minute
+	if duration <= 0 || duration > rbac.MaxAccessDuration {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("duration_minutes must be between 1 and %d", int(rbac.MaxAccessDuration/time.Minute)))
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, err := h.roleStore.GetByID(ctx, input.RoleID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "role not found")
+	}
+
+	req, err := h.accessStore.Create(ctx, user.ID, role.ID, duration, input.Justification)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create access request")
+	}
+
+	// Let the approvers know; delivery failures don't fail the request
+	approvers, err := h.accessStore.ListApprovers(ctx, role.ID)
+	if err == nil {
+		for _, approver := range approvers {
+			if approver.UserID == user.ID {
+				continue
+			}
+			if err := h.notifier.Send(ctx, notify.Message{
+				To:      approver.Email,
+				Subject: fmt.Sprintf("Access request: %s wants %s", user.Username, role.Name),
+				Body: fmt.Sprintf("%s requested the %s role for %d minutes.\n\nJustification: %s\n\nRequest #%d",
+					user.Username, role.Name, input.DurationMinutes, req.Justification, req.ID),
+			}); err != nil {
+				c.Logger().Warn("failed to notify approver:", err)
+			}
+		}
+	}
+
+	return c.JSON(http.StatusCreated, req)
+}
+
+// ListRequests lists access requests, filterable by status, user_id and role_id.
+// Users without users.manage_roles only see their own requests and those they can approve.
+// GET /api/v1/auth/access-requests
+func (h *AccessRequestsHandler) ListRequests(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	filter := rbac.AccessRequestFilter{Status: c.QueryParam("status")}
+	switch filter.Status {
+	case "", rbac.AccessPending, rbac.AccessApproved, rbac.AccessDenied, rbac.AccessCancelled:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid status")
+	}
+
+	var err error
+	if v := c.QueryParam("user_id"); v != "" {
+		if filter.UserID, err = strconv.Atoi(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+		}
+	}
+	if v := c.QueryParam("role_id"); v != "" {
+		if filter.RoleID, err = strconv.Atoi(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+		}
+	}
+
+	canManage, err := auth.HasPermission(c, h.userRoleStore, permissions.UsersManageRoles)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+	}
+	if !canManage {
+		filter.VisibleTo = user.ID
+	}
+
+	requests, err := h.accessStore.List(ctx, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch access requests")
+	}
+
+	if requests == nil {
+		requests = []rbac.AccessRequest{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"requests": requests,
+		"total":    len(requests),
+	})
+}
+
+// GetRequest retrieves an access request
+// GET /api/v1/auth/access-requests/:id
+func (h *AccessRequestsHandler) GetRequest(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request ID")
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	req, err := h.accessStore.Get(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "access request not found")
+	}
+
+	if req.UserID != user.ID {
+		allowed, err := h.canDecide(c, req.RoleID)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return echo.NewHTTPError(http.StatusNotFound, "access request not found")
+		}
+	}
+
+	return c.JSON(http.StatusOK, req)
+}
+
+// ApproveRequest approves a pending request, granting the role until it expires
+// POST /api/v1/auth/access-requests/:id/approve
+func (h *AccessRequestsHandler) ApproveRequest(c echo.Context) error {
+	return h.decide(c, true)
+}
+
+// DenyRequest denies a pending request
+// POST /api/v1/auth/access-requests/:id/deny
+func (h *AccessRequestsHandler) DenyRequest(c echo.Context) error {
+	return h.decide(c, false)
+}
+
+// CancelRequest withdraws the current user's pending request
+// POST /api/v1/auth/access-requests/:id/cancel
+func (h *AccessRequestsHandler) CancelRequest(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request ID")
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	req, err := h.accessStore.Cancel(ctx, id, user.ID)
+	if err != nil {
+		return accessRequestError(err, "failed to cancel access request")
+	}
+
+	return c.JSON(http.StatusOK, req)
+}
+
+// ListExpiredGrants reports approved requests whose granted role has lapsed
+// GET /api/v1/auth/access-requests/expired
+func (h *AccessRequestsHandler) ListExpiredGrants(c echo.Context) error {
+	requests, err := h.accessStore.ListExpiredGrants(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch expired grants")
+	}
+
+	if requests == nil {
+		requests = []rbac.AccessRequest{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"requests": requests,
+		"total":    len(requests),
+	})
+}
+
+// ============================================================================
+// Approver Endpoints
+// ============================================================================
+
+// ListApprovers retrieves the designated approvers for a role
+// GET /api/v1/auth/roles/:id/approvers
+func (h *AccessRequestsHandler) ListApprovers(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	approvers, err := h.accessStore.ListApprovers(c.Request().Context(), roleID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch approvers")
+	}
+
+	if approvers == nil {
+		approvers = []rbac.Approver{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"role_id":   roleID,
+		"approvers": approvers,
+		"total":     len(approvers),
+	})
+}
+
+// AddApprover designates a user as an approver for a role
+// POST /api/v1/auth/roles/:id/approvers
+func (h *AccessRequestsHandler) AddApprover(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	var input struct {
+		UserID int `json:"user_id"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	ctx := c.Request().Context()
+
+	// An approver grants the role on approval, so the actor must be able to grant it
+	if err := h.userRoleStore.CheckCanGrant(ctx, user.ID, roleID, nil); err != nil {
+		return safeguardError(err, "failed to add approver")
+	}
+
+	if err := h.accessStore.AddApprover(ctx, roleID, input.UserID, user.ID); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "role or user not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to add approver")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "approver added"})
+}
+
+// RemoveApprover removes a user from a role's approvers
+// DELETE /api/v1/auth/roles/:id/approvers/:userId
+func (h *AccessRequestsHandler) RemoveApprover(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+	}
+
+	if err := h.accessStore.RemoveApprover(c.Request().Context(), roleID, userID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove approver")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "approver removed"})
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+// decide approves or denies a request on behalf of a designated approver or admin
+func (h *AccessRequestsHandler) decide(c echo.Context, approve bool) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request ID")
+	}
+
+	var input struct {
+		Note string `json:"note"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	existing, err := h.accessStore.Get(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "access request not found")
+	}
+
+	allowed, err := h.canDecide(c, existing.RoleID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return echo.NewHTTPError(http.StatusForbidden, "not an approver for this role")
+	}
+
+	var req *rbac.AccessRequest
+	if approve {
+		req, err = h.accessStore.Approve(ctx, id, user.ID, input.Note)
+	} else {
+		req, err = h.accessStore.Deny(ctx, id, user.ID, input.Note)
+	}
+	if err != nil {
+		return accessRequestError(err, "failed to decide access request")
+	}
+
+	return c.JSON(http.StatusOK, req)
+}
+
+// canDecide reports whether the current user is a global admin or a
+// designated approver for the role
+func (h *AccessRequestsHandler) canDecide(c echo.Context, roleID int) (bool, error) {
+	ctx := c.Request().Context()
+	user := auth.GetUserFromContext(c)
+
+	policy, err := h.userRoleStore.LoadPolicy(ctx, user.ID)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+	}
+	if policy.Admin {
+		return true, nil
+	}
+
+	isApprover, err := h.accessStore.IsApprover(ctx, roleID, user.ID)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+	}
+	return isApprover, nil
+}
+
+func accessRequestError(err error, fallback string) error {
+	var sod *rbac.SoDViolationError
+	if errors.As(err, &sod) {
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	var escalation *rbac.EscalationError
+	if errors.As(err, &escalation) {
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	switch err {
+	case sql.ErrNoRows:
+		return echo.NewHTTPError(http.StatusNotFound, "access request not found")
+	case rbac.ErrRequestNotPending, rbac.ErrEmailNotVerified:
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case rbac.ErrSelfApproval:
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, fallback)
+}
+
+// ============================================================================
+// Route Registration
+// ============================================================================
+
+func (h *AccessRequestsHandler) RegisterRoutes(e *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	canManage := auth.RequirePermission(h.userRoleStore, permissions.UsersManageRoles)
+
+	// Any authenticated user can request access; approvers are checked per request
+	e.GET("/access-requests", h.ListRequests, authMiddleware)
+	e.POST("/access-requests", h.CreateRequest, authMiddleware)
+	e.GET("/access-requests/expired", h.ListExpiredGrants, authMiddleware, canManage)
+	e.GET("/access-requests/:id", h.GetRequest, authMiddleware)
+	e.POST("/access-requests/:id/approve", h.ApproveRequest, authMiddleware)
+	e.POST("/access-requests/:id/deny", h.DenyRequest, authMiddleware)
+	e.POST("/access-requests/:id/cancel", h.CancelRequest, authMiddleware)
+
+	// Designated approvers
+	e.GET("/roles/:id/approvers", h.ListApprovers, authMiddleware)
+	e.POST("/roles/:id/approvers", h.AddApprover, authMiddleware, canManage)
+	e.DELETE("/roles/:id/approvers/:userId", h.RemoveApprover, authMiddleware, canManage)
+}
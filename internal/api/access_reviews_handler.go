@@ -0,0 +1,444 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/notify"
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/labstack/echo/v4"
+)
+
+type AccessReviewsHandler struct {
+	reviewStore   *rbac.AccessReviewStore
+	roleStore     *rbac.RoleStore
+	groupStore    *rbac.GroupStore
+	userRoleStore *rbac.UserRoleStore
+	notifier      notify.Notifier
+}
+
+func NewAccessReviewsHandler(reviewStore *rbac.AccessReviewStore, roleStore *rbac.RoleStore, groupStore *rbac.GroupStore, userRoleStore *rbac.UserRoleStore, notifier notify.Notifier) *AccessReviewsHandler {
+	return &AccessReviewsHandler{
+		reviewStore:   reviewStore,
+		roleStore:     roleStore,
+		groupStore:    groupStore,
+		userRoleStore: userRoleStore,
+		notifier:      notifier,
+	}
+}
+
+// ============================================================================
+// Campaign Endpoints
+// ============================================================================
+
+// CreateCampaign opens an access review of every user and group assignment of
+// a role, or of a group's roles and every assignment held by its members
+// POST /api/v1/auth/access-reviews
+func (h *AccessReviewsHandler) CreateCampaign(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var input struct {
+		Name             string     `json:"name"`
+		Description      string     `json:"description"`
+		RoleID           int        `json:"role_id"`
+		GroupID          int        `json:"group_id"`
+		DueAt            *time.Time `json:"due_at"`
+		ExpireUnreviewed bool       `json:"expire_unreviewed"`
+		ReviewerIDs      []int      `json:"reviewer_ids"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "campaign name is required")
+	}
+	if (input.RoleID == 0) == (input.GroupID == 0) {
+		return echo.NewHTTPError(http.StatusBadRequest, "exactly one of role_id and group_id is required")
+	}
+	if input.GroupID != 0 && len(input.ReviewerIDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "reviewer_ids is required for a group campaign")
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	if input.RoleID != 0 {
+		if _, err := h.roleStore.GetByID(ctx, input.RoleID); err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "role not found")
+		}
+	} else {
+		if _, err := h.groupStore.GetByID(ctx, input.GroupID); err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "group not found")
+		}
+	}
+
+	campaign, err := h.reviewStore.CreateCampaign(ctx, rbac.NewReviewCampaign{
+		Name:             input.Name,
+		Description:      input.Description,
+		RoleID:           input.RoleID,
+		GroupID:          input.GroupID,
+		DueAt:            input.DueAt,
+		ExpireUnreviewed: input.ExpireUnreviewed,
+		ReviewerIDs:      input.ReviewerIDs,
+	}, user.ID)
+	if err != nil {
+		return accessReviewError(err, "failed to create access review")
+	}
+
+	// Let the reviewers know; delivery failures don't fail the request
+	for _, reviewer := range campaign.Reviewers {
+		due := ""
+		if campaign.DueAt != nil {
+			due = fmt.Sprintf(" by %s", campaign.DueAt.Format(time.RFC1123))
+		}
+		if err := h.notifier.Send(ctx, notify.Message{
+			To:      reviewer.Email,
+			Subject: fmt.Sprintf("Access review: %s", campaign.Name),
+			Body: fmt.Sprintf("You have been asked to review %d role assignments%s.\n\nAccess review #%d",
+				campaign.Total, due, campaign.ID),
+		}); err != nil {
+			c.Logger().Warn("failed to notify reviewer:", err)
+		}
+	}
+
+	return c.JSON(http.StatusCreated, campaign)
+}
+
+// ListCampaigns lists access reviews, filterable by status.
+// Users without users.view only see the campaigns they review.
+// GET /api/v1/auth/access-reviews
+func (h *AccessReviewsHandler) ListCampaigns(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	filter := rbac.ReviewCampaignFilter{Status: c.QueryParam("status")}
+	switch filter.Status {
+	case "", rbac.ReviewOpen, rbac.ReviewClosed:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid status")
+	}
+
+	canView, err := auth.HasPermission(c, h.userRoleStore, permissions.UsersView)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+	}
+	if !canView {
+		filter.ReviewerID = user.ID
+	}
+
+	campaigns, err := h.reviewStore.ListCampaigns(ctx, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch access reviews")
+	}
+
+	if campaigns == nil {
+		campaigns = []rbac.ReviewCampaign{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"campaigns": campaigns,
+		"total":     len(campaigns),
+	})
+}
+
+// GetCampaign retrieves an access review with its items, filterable by decision
+// GET /api/v1/auth/access-reviews/:id
+func (h *AccessReviewsHandler) GetCampaign(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	campaign, err := h.visibleCampaign(c)
+	if err != nil {
+		return err
+	}
+
+	decision := c.QueryParam("decision")
+	switch decision {
+	case "", rbac.ReviewPending, rbac.ReviewCertified, rbac.ReviewRevoked, rbac.ReviewExpired:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid decision")
+	}
+
+	items, err := h.reviewStore.ListItems(ctx, campaign.ID, decision)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch review items")
+	}
+
+	if items == nil {
+		items = []rbac.ReviewItem{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"campaign": campaign,
+		"items":    items,
+	})
+}
+
+// CertifyItem records that a reviewed assignment is still needed
+// POST /api/v1/auth/access-reviews/:id/items/:itemId/certify
+func (h *AccessReviewsHandler) CertifyItem(c echo.Context) error {
+	return h.decide(c, rbac.ReviewCertified)
+}
+
+// RevokeItem removes a reviewed assignment
+// POST /api/v1/auth/access-reviews/:id/items/:itemId/revoke
+func (h *AccessReviewsHandler) RevokeItem(c echo.Context) error {
+	return h.decide(c, rbac.ReviewRevoked)
+}
+
+// CloseCampaign ends an access review, expiring unreviewed assignments if the
+// campaign was opened with expire_unreviewed
+// POST /api/v1/auth/access-reviews/:id/close
+func (h *AccessReviewsHandler) CloseCampaign(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid access review ID")
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	campaign, err := h.reviewStore.CloseCampaign(c.Request().Context(), id, user.ID)
+	if err != nil {
+		return accessReviewError(err, "failed to close access review")
+	}
+
+	return c.JSON(http.StatusOK, campaign)
+}
+
+// ExportCampaign downloads an access review and every decision as evidence,
+// as JSON (default) or CSV (?format=csv)
+// GET /api/v1/auth/access-reviews/:id/export
+func (h *AccessReviewsHandler) ExportCampaign(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid access review ID")
+	}
+
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "csv" {
+		return echo.NewHTTPError(http.StatusBadRequest, "format must be json or csv")
+	}
+
+	campaign, err := h.reviewStore.GetCampaign(ctx, id)
+	if err != nil {
+		return accessReviewError(err, "failed to fetch access review")
+	}
+
+	items, err := h.reviewStore.ListItems(ctx, id, "")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch review items")
+	}
+
+	filename := fmt.Sprintf("access-review-%d", id)
+	if format != "csv" {
+		if items == nil {
+			items = []rbac.ReviewItem{}
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"campaign":    campaign,
+			"items":       items,
+			"exported_at": time.Now().UTC(),
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+	c.Response().WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(c.Response())
+	w.Write([]string{
+		"campaign_id", "campaign", "item_id", "user_id", "username", "email", "group_id", "group", "role",
+		"resource_type", "resource_id",
+		"assigned_at", "expires_at", "decision", "decided_by", "decided_at", "note",
+	})
+	for _, it := range items {
+		w.Write([]string{
+			strconv.Itoa(campaign.ID), campaign.Name, strconv.Itoa(it.ID), csvInt(it.UserID), csvString(it.Username),
+			csvString(it.Email), csvInt(it.GroupID), csvString(it.GroupName), it.RoleName, csvString(it.ResourceType), csvString(it.ResourceID),
+			csvTime(it.AssignedAt), csvTime(it.ExpiresAt), it.Decision, csvString(it.DecidedByName), csvTime(it.DecidedAt),
+			csvString(it.Note),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+// decide certifies or revokes an item on behalf of a reviewer or role manager
+func (h *AccessReviewsHandler) decide(c echo.Context, decision string) error {
+	ctx := c.Request().Context()
+
+	itemID, err := strconv.Atoi(c.Param("itemId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid item ID")
+	}
+
+	var input struct {
+		Note string `json:"note"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	campaign, err := h.visibleCampaign(c)
+	if err != nil {
+		return err
+	}
+
+	user := auth.GetUserFromContext(c)
+	allowed, err := h.canReview(c, campaign.ID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return echo.NewHTTPError(http.StatusForbidden, "not a reviewer for this access review")
+	}
+
+	if decision == rbac.ReviewRevoked {
+		err = h.reviewStore.Revoke(ctx, campaign.ID, itemID, user.ID, input.Note)
+	} else {
+		err = h.reviewStore.Certify(ctx, campaign.ID, itemID, user.ID, input.Note)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "review item not found")
+		}
+		return accessReviewError(err, "failed to record review decision")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "assignment " + decision})
+}
+
+// visibleCampaign loads the :id campaign, reporting it as not found to users
+// who neither hold users.view nor review it
+func (h *AccessReviewsHandler) visibleCampaign(c echo.Context) (*rbac.ReviewCampaign, error) {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid access review ID")
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	campaign, err := h.reviewStore.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, accessReviewError(err, "failed to fetch access review")
+	}
+
+	canView, err := auth.HasPermission(c, h.userRoleStore, permissions.UsersView)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+	}
+	if !canView {
+		isReviewer, err := h.reviewStore.IsReviewer(ctx, id, user.ID)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+		}
+		if !isReviewer {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "access review not found")
+		}
+	}
+
+	return campaign, nil
+}
+
+// canReview reports whether the current user may manage roles or is a
+// designated reviewer for the campaign
+func (h *AccessReviewsHandler) canReview(c echo.Context, campaignID int) (bool, error) {
+	canManage, err := auth.HasPermission(c, h.userRoleStore, permissions.UsersManageRoles)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+	}
+	if canManage {
+		return true, nil
+	}
+
+	user := auth.GetUserFromContext(c)
+	isReviewer, err := h.reviewStore.IsReviewer(c.Request().Context(), campaignID, user.ID)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+	}
+	return isReviewer, nil
+}
+
+func accessReviewError(err error, fallback string) error {
+	switch err {
+	case sql.ErrNoRows:
+		return echo.NewHTTPError(http.StatusNotFound, "access review not found")
+	case rbac.ErrCampaignClosed, rbac.ErrAlreadyReviewed, rbac.ErrLastAdmin:
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case rbac.ErrSelfReview:
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case rbac.ErrNoReviewers:
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, fallback)
+}
+
+func csvString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func csvInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ============================================================================
+// Route Registration
+// ============================================================================
+
+func (h *AccessReviewsHandler) RegisterRoutes(e *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	canView := auth.RequirePermission(h.userRoleStore, permissions.UsersView)
+	canManage := auth.RequirePermission(h.userRoleStore, permissions.UsersManageRoles)
+
+	// Reviewers are checked per campaign
+	e.GET("/access-reviews", h.ListCampaigns, authMiddleware)
+	e.POST("/access-reviews", h.CreateCampaign, authMiddleware, canManage)
+	e.GET("/access-reviews/:id", h.GetCampaign, authMiddleware)
+	e.POST("/access-reviews/:id/items/:itemId/certify", h.CertifyItem, authMiddleware)
+	e.POST("/access-reviews/:id/items/:itemId/revoke", h.RevokeItem, authMiddleware)
+	e.POST("/access-reviews/:id/close", h.CloseCampaign, authMiddleware, canManage)
+	e.GET("/access-reviews/:id/export", h.ExportCampaign, authMiddleware, canView)
+}
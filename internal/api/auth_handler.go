@@ -1,33 +1,47 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/notify"
 	"github.com/bwburch/inflight-ui-service/internal/storage/sessions"
 	"github.com/bwburch/inflight-ui-service/internal/storage/users"
+	"github.com/bwburch/inflight-ui-service/internal/storage/verifications"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	userStore    *users.Store
-	sessionStore *sessions.Store
+	userStore         *users.Store
+	sessionStore      *sessions.Store
+	verificationStore *verifications.Store
+	notifier          notify.Notifier
+	verificationURL   string
 }
 
-func NewAuthHandler(userStore *users.Store, sessionStore *sessions.Store) *AuthHandler {
+func NewAuthHandler(userStore *users.Store, sessionStore *sessions.Store, verificationStore *verifications.Store, notifier notify.Notifier, verificationURL string) *AuthHandler {
 	return &AuthHandler{
-		userStore:    userStore,
-		sessionStore: sessionStore,
+		userStore:         userStore,
+		sessionStore:      sessionStore,
+		verificationStore: verificationStore,
+		notifier:          notifier,
+		verificationURL:   verificationURL,
 	}
 }
 
 // Login authenticates a user and creates a session
+// The username field accepts either the username or the email address
 // POST /api/v1/auth/login
 func (h *AuthHandler) Login(c echo.Context) error {
 	var input struct {
 		Username string `json:"username"`
+		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 
@@ -35,15 +49,20 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	login := input.Username
+	if login == "" {
+		login = input.Email
+	}
+
 	// Validation
-	if input.Username == "" || input.Password == "" {
+	if login == "" || input.Password == "" {
 		return echo.NewHTTPError(http.StatusBadRequest, "username and password are required")
 	}
 
 	ctx := c.Request().Context()
 
-	// Find user by username (we need to add this method to users.Store)
-	user, err := h.userStore.GetByUsername(ctx, input.Username)
+	// Find user by username or email (case-insensitive)
+	user, err := h.userStore.GetByLogin(ctx, login)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "authentication failed")
 	}
@@ -136,3 +155,103 @@ func (h *AuthHandler) Me(c echo.Context) error {
 		"user": user,
 	})
 }
+
+// SendVerification emails a verification link to the current user
+// POST /api/v1/auth/verify-email/send
+func (h *AuthHandler) SendVerification(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "not authenticated")
+	}
+
+	return h.sendVerification(c, user)
+}
+
+// SendUserVerification (re)sends a verification link to another user
+// POST /api/v1/users/:id/verify-email (requires users.edit)
+func (h *AuthHandler) SendUserVerification(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+	}
+
+	user, err := h.userStore.Get(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch user")
+	}
+	if user == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "user not found")
+	}
+
+	return h.sendVerification(c, user)
+}
+
+// VerifyEmail completes email verification using a token from the link
+// POST /api/v1/auth/verify-email
+func (h *AuthHandler) VerifyEmail(c echo.Context) error {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.Token == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "token is required")
+	}
+
+	ctx := c.Request().Context()
+
+	token, err := h.verificationStore.Consume(ctx, input.Token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "verification failed")
+	}
+	if token == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired verification token")
+	}
+
+	// Fails if the address changed after the token was issued
+	if err := h.userStore.MarkEmailVerified(ctx, token.UserID, token.Email); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired verification token")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"email":   token.Email,
+	})
+}
+
+func (h *AuthHandler) sendVerification(c echo.Context, user *users.User) error {
+	if user.EmailVerifiedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "email address is already verified")
+	}
+
+	ctx := c.Request().Context()
+
+	token, err := h.verificationStore.Create(ctx, user.ID, user.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create verification token")
+	}
+
+	if err := h.notifyVerification(ctx, user, token); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to send verification email")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"email":      user.Email,
+		"expires_at": token.ExpiresAt,
+	})
+}
+
+func (h *AuthHandler) notifyVerification(ctx context.Context, user *users.User, token *verifications.Token) error {
+	link := h.verificationURL + "?token=" + url.QueryEscape(token.Token)
+
+	return h.notifier.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s, confirm your email address by opening %s (expires %s).",
+			user.Username, link, token.ExpiresAt.Format(time.RFC1123)),
+	})
+}
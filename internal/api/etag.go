@@ -0,0 +1,46 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Resources with a version counter (quick templates, roles) expose it as a
+// strong ETag. Reads honour If-None-Match; PUT and DELETE require If-Match.
+const (
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// etag formats a version counter as an entity tag
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// matchesETag reports whether an If-Match or If-None-Match value lists the
+// version's tag or is "*". Weak tags compare by their opaque part.
+func matchesETag(header string, version int) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// setETag sets the response's ETag to the version
+func setETag(c echo.Context, version int) {
+	c.Response().Header().Set(headerETag, etag(version))
+}
+
+// notModified sets the ETag and reports whether the client's If-None-Match
+// already holds this version, so the read can answer 304
+func notModified(c echo.Context, version int) bool {
+	setETag(c, version)
+	header := c.Request().Header.Get(headerIfNoneMatch)
+	return header != "" && matchesETag(header, version)
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/labstack/echo/v4"
+)
+
+type GroupsHandler struct {
+	groupStore    *rbac.GroupStore
+	userRoleStore *rbac.UserRoleStore
+}
+
+func NewGroupsHandler(groupStore *rbac.GroupStore, userRoleStore *rbac.UserRoleStore) *GroupsHandler {
+	return &GroupsHandler{
+		groupStore:    groupStore,
+		userRoleStore: userRoleStore,
+	}
+}
+
+// ============================================================================
+// Group Endpoints
+// ============================================================================
+
+// ListGroups retrieves all groups
+// GET /api/v1/auth/groups
+func (h *GroupsHandler) ListGroups(c echo.Context) error {
+	groups, err := h.groupStore.List(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch groups")
+	}
+
+	if groups == nil {
+		groups = []rbac.Group{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"groups": groups,
+		"total":  len(groups),
+	})
+}
+
+// GetGroup retrieves a group with its members and roles
+// GET /api/v1/auth/groups/:id
+func (h *GroupsHandler) GetGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid group ID")
+	}
+
+	group, err := h.groupStore.GetByID(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "group not found")
+	}
+
+	members, err := h.groupStore.GetMembers(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch group members")
+	}
+
+	roles, err := h.groupStore.GetRoles(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch group roles")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"group":   group,
+		"members": members,
+		"roles":   roles,
+	})
+}
+
+// CreateGroup creates a new group
+// POST /api/v1/auth/groups
+func (h *GroupsHandler) CreateGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "group name is required")
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	group, err := h.groupStore.Create(ctx, input.Name, input.Description, user.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return echo.NewHTTPError(http.StatusConflict, "a group with this name already exists")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create group")
+	}
+
+	return c.JSON(http.StatusCreated, group)
+}
+
+// UpdateGroup updates a group's name and description
+// PUT /api/v1/auth/groups/:id
+func (h *GroupsHandler) UpdateGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid group ID")
+	}
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "group name is required")
+	}
+
+	if err := h.groupStore.Update(ctx, id, input.Name, input.Description); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "group not found")
+		}
+		if isUniqueViolation(err) {
+			return echo.NewHTTPError(http.StatusConflict, "a group with this name already exists")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update group")
+	}
+
+	group, _ := h.groupStore.GetByID(ctx, id)
+	return c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup deletes a group
+// DELETE /api/v1/auth/groups/:id
+func (h *GroupsHandler) DeleteGroup(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid group ID")
+	}
+
+	if err := h.groupStore.Delete(c.Request().Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "group not found")
+		}
+		return safeguardError(err, "failed to delete group")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "group deleted"})
+}
+
+// ============================================================================
+// Membership Endpoints
+// ============================================================================
+
+// AddMember adds a user to a group
+// POST /api/v1/auth/groups/:id/members
+func (h *GroupsHandler) AddMember(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid group ID")
+	}
+
+	var input struct {
+		UserID int `json:"user_id"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	// Joining a group confers its roles, so the actor must be able to grant each
+	roles, err := h.groupStore.GetRoles(ctx, groupID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch group roles")
+	}
+	for _, role := range roles {
+		if err := h.userRoleStore.CheckCanGrant(ctx, user.ID, role.RoleID, nil); err != nil {
+			return safeguardError(err, "failed to add member")
+		}
+	}
+
+	if err := h.groupStore.AddMember(ctx, groupID, input.UserID, user.ID); err != nil {
+		var sod *rbac.SoDViolationError
+		if err == rbac.ErrEmailNotVerified || errors.As(err, &sod) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to add member")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "member added"})
+}
+
+// RemoveMember removes a user from a group
+// DELETE /api/v1/auth/groups/:id/members/:userId
+func (h *GroupsHandler) RemoveMember(c echo.Context) error {
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid group ID")
+	}
+
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+	}
+
+	if err := h.groupStore.RemoveMember(c.Request().Context(), groupID, userID); err != nil {
+		return safeguardError(err, "failed to remove member")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "member removed"})
+}
+
+// GetUserGroups retrieves the groups a user belongs to
+// GET /api/v1/auth/users/:id/groups
+func (h *GroupsHandler) GetUserGroups(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+	}
+
+	groups, err := h.groupStore.GetUserGroups(c.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch user groups")
+	}
+
+	if groups == nil {
+		groups = []rbac.Group{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"user_id": userID,
+		"groups":  groups,
+		"total":   len(groups),
+	})
+}
+
+// ============================================================================
+// Group Role Endpoints
+// ============================================================================
+
+// AssignRoleToGroup assigns a role to a group, optionally expiring
+// POST /api/v1/auth/groups/:id/roles
+func (h *GroupsHandler) AssignRoleToGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid group ID")
+	}
+
+	var input struct {
+		RoleID    int        `json:"role_id"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	if err := h.userRoleStore.CheckCanGrant(ctx, user.ID, input.RoleID, nil); err != nil {
+		return safeguardError(err, "failed to assign role")
+	}
+
+	if err := h.groupStore.AssignRole(ctx, groupID, input.RoleID, user.ID, input.ExpiresAt); err != nil {
+		if err == rbac.ErrEmailNotVerified {
+			return echo.NewHTTPError(http.StatusConflict, "all group members must have a verified email before assigning this role")
+		}
+		var sod *rbac.SoDViolationError
+		if errors.As(err, &sod) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to assign role")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "role assigned"})
+}
+
+// RemoveRoleFromGroup removes a role from a group
+// DELETE /api/v1/auth/groups/:id/roles/:roleId
+func (h *GroupsHandler) RemoveRoleFromGroup(c echo.Context) error {
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid group ID")
+	}
+
+	roleID, err := strconv.Atoi(c.Param("roleId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	if err := h.groupStore.RemoveRole(c.Request().Context(), groupID, roleID); err != nil {
+		return safeguardError(err, "failed to remove role")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "role removed"})
+}
+
+// ============================================================================
+// Route Registration
+// ============================================================================
+
+func (h *GroupsHandler) RegisterRoutes(e *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	canView := auth.RequirePermission(h.userRoleStore, permissions.UsersView)
+	canManage := auth.RequirePermission(h.userRoleStore, permissions.UsersManageRoles)
+
+	e.GET("/groups", h.ListGroups, authMiddleware, canView)
+	e.GET("/groups/:id", h.GetGroup, authMiddleware, canView)
+	e.POST("/groups", h.CreateGroup, authMiddleware, canManage)
+	e.PUT("/groups/:id", h.UpdateGroup, authMiddleware, canManage)
+	e.DELETE("/groups/:id", h.DeleteGroup, authMiddleware, canManage)
+
+	// Membership (members inherit the group's roles)
+	e.POST("/groups/:id/members", h.AddMember, authMiddleware, canManage)
+	e.DELETE("/groups/:id/members/:userId", h.RemoveMember, authMiddleware, canManage)
+	e.GET("/users/:id/groups", h.GetUserGroups, authMiddleware, canView)
+
+	// Group role assignments
+	e.POST("/groups/:id/roles", h.AssignRoleToGroup, authMiddleware, canManage)
+	e.DELETE("/groups/:id/roles/:roleId", h.RemoveRoleFromGroup, authMiddleware, canManage)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/bwburch/inflight-ui-service/internal/policy"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/labstack/echo/v4"
+)
+
+// maxPolicySize bounds the policy document accepted by the API
+const maxPolicySize = 1 << 20
+
+type PolicyHandler struct {
+	policyStore   *rbac.PolicyStore
+	userRoleStore *rbac.UserRoleStore
+}
+
+func NewPolicyHandler(policyStore *rbac.PolicyStore, userRoleStore *rbac.UserRoleStore) *PolicyHandler {
+	return &PolicyHandler{
+		policyStore:   policyStore,
+		userRoleStore: userRoleStore,
+	}
+}
+
+// ============================================================================
+// Policy Endpoints
+// ============================================================================
+
+// PlanPolicy diffs a policy document (YAML or JSON body) against the database
+// POST /api/v1/auth/policy/plan?prune=true
+func (h *PolicyHandler) PlanPolicy(c echo.Context) error {
+	doc, err := readPolicy(c)
+	if err != nil {
+		return err
+	}
+
+	plan, err := h.policyStore.Plan(c.Request().Context(), doc, policyOptions(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, plan)
+}
+
+// ApplyPolicy reconciles the database with a policy document (YAML or JSON body)
+// POST /api/v1/auth/policy/apply?prune=true
+func (h *PolicyHandler) ApplyPolicy(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	doc, err := readPolicy(c)
+	if err != nil {
+		return err
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	opts := policyOptions(c)
+	if opts.Prune {
+		allowed, err := auth.HasPermission(c, h.userRoleStore, permissions.RolesDelete)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+		}
+		if !allowed {
+			return echo.NewHTTPError(http.StatusForbidden, "pruning requires roles.delete")
+		}
+	}
+
+	plan, err := h.policyStore.Apply(ctx, doc, opts, user.ID)
+	if err != nil {
+		var sod *rbac.SoDViolationError
+		if err == rbac.ErrLastAdmin || err == rbac.ErrLockout || errors.As(err, &sod) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		var escalation *rbac.EscalationError
+		if errors.As(err, &escalation) {
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, plan)
+}
+
+func readPolicy(c echo.Context) (*policy.Document, error) {
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxPolicySize+1))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "failed to read policy")
+	}
+	if len(data) > maxPolicySize {
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "policy document is too large")
+	}
+
+	// YAML is a superset of JSON, so either content type parses
+	doc, err := policy.Parse(data)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return doc, nil
+}
+
+func policyOptions(c echo.Context) policy.Options {
+	return policy.Options{Prune: c.QueryParam("prune") == "true"}
+}
+
+// ============================================================================
+// Route Registration
+// ============================================================================
+
+func (h *PolicyHandler) RegisterRoutes(e *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	canView := auth.RequirePermission(h.userRoleStore, permissions.RolesView)
+	canEditRoles := auth.RequirePermission(h.userRoleStore, permissions.RolesEdit)
+	canCreateRoles := auth.RequirePermission(h.userRoleStore, permissions.RolesCreate)
+	canManageUsers := auth.RequirePermission(h.userRoleStore, permissions.UsersManageRoles)
+
+	e.POST("/policy/plan", h.PlanPolicy, authMiddleware, canView)
+	e.POST("/policy/apply", h.ApplyPolicy, authMiddleware, canCreateRoles, canEditRoles, canManageUsers)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/storage/privacy"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/bwburch/inflight-ui-service/internal/storage/sessions"
+	"github.com/bwburch/inflight-ui-service/internal/storage/users"
+	"github.com/labstack/echo/v4"
+)
+
+// PrivacyHandler serves data subject requests: personal data export and erasure
+type PrivacyHandler struct {
+	privacyStore  *privacy.Store
+	userStore     *users.Store
+	userRoleStore *rbac.UserRoleStore
+	auditStore    *rbac.AuditStore
+	sessionStore  *sessions.Store
+}
+
+func NewPrivacyHandler(privacyStore *privacy.Store, userStore *users.Store, userRoleStore *rbac.UserRoleStore, auditStore *rbac.AuditStore, sessionStore *sessions.Store) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyStore:  privacyStore,
+		userStore:     userStore,
+		userRoleStore: userRoleStore,
+		auditStore:    auditStore,
+		sessionStore:  sessionStore,
+	}
+}
+
+// ExportMyData returns a downloadable JSON archive of the current user's data
+// GET /api/v1/me/export
+func (h *PrivacyHandler) ExportMyData(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "not authenticated")
+	}
+
+	roles, err := h.userRoleStore.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch roles")
+	}
+
+	permissions, err := h.userRoleStore.GetUserPermissions(ctx, user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch permissions")
+	}
+
+	content, err := h.privacyStore.GetUserContent(ctx, user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch user content")
+	}
+
+	auditEntries, err := h.auditStore.ListForUser(ctx, user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch audit entries")
+	}
+
+	if roles == nil {
+		roles = []rbac.UserRole{}
+	}
+	if auditEntries == nil {
+		auditEntries = []rbac.AuditEntry{}
+	}
+
+	now := time.Now().UTC()
+	filename := fmt.Sprintf("inflight-data-export-%d-%s.json", user.ID, now.Format("20060102"))
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"exported_at":   now,
+		"profile":       user,
+		"roles":         roles,
+		"permissions":   permissions.Permissions,
+		"templates":     content.Templates,
+		"saved_queries": content.SavedQueries,
+		"preferences":   content.Preferences,
+		"audit_entries": auditEntries,
+	})
+}
+
+// EraseUser anonymises a user's personal data and handles their owned content
+// POST /api/v1/users/:id/erase
+func (h *PrivacyHandler) EraseUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+	}
+
+	var input struct {
+		ContentPolicy string `json:"content_policy"` // delete | reassign | reassign_shared
+		ReassignTo    int    `json:"reassign_to,omitempty"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.ContentPolicy == "" {
+		input.ContentPolicy = privacy.ContentDelete
+	}
+
+	actor := auth.GetUserFromContext(c)
+	if actor == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "not authenticated")
+	}
+
+	// Same protections as DeleteUser
+	if id == 1 {
+		return echo.NewHTTPError(http.StatusForbidden, "cannot erase default admin user")
+	}
+	if id == actor.ID {
+		return echo.NewHTTPError(http.StatusForbidden, "cannot erase your own account")
+	}
+
+	result, err := h.privacyStore.Erase(ctx, id, privacy.ErasurePolicy{
+		Content:    input.ContentPolicy,
+		ReassignTo: input.ReassignTo,
+		ErasedBy:   actor.ID,
+	})
+	if err != nil {
+		switch err {
+		case privacy.ErrUserNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		case privacy.ErrAlreadyErased, rbac.ErrLastAdmin:
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// The account is already disabled; revoking sessions is best effort
+	if err := h.sessionStore.DeleteAllUserSessions(ctx, id); err != nil {
+		c.Logger().Warn("failed to revoke sessions for erased user:", err)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -1,11 +1,17 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"path"
 	"strconv"
 	"time"
 
+	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
 	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
 	"github.com/bwburch/inflight-ui-service/internal/storage/users"
 	"github.com/labstack/echo/v4"
@@ -83,7 +89,8 @@ func (h *RBACHandler) ListRoles(c echo.Context) error {
 	})
 }
 
-// GetRole retrieves a role by ID with its permissions
+// GetRole retrieves a role by ID with its direct and inherited permissions.
+// The role's version is served as the ETag; a matching If-None-Match gets 304.
 // GET /api/v1/auth/roles/:id
 func (h *RBACHandler) GetRole(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -98,19 +105,105 @@ func (h *RBACHandler) GetRole(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "role not found")
 	}
 
+	if notModified(c, role.Version) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	body, err := h.roleRepresentation(ctx, role)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, body)
+}
+
+// roleRepresentation builds the GetRole body for a role
+func (h *RBACHandler) roleRepresentation(ctx context.Context, role *rbac.Role) (map[string]interface{}, error) {
+	id := role.ID
+
 	permissions, err := h.roleStore.GetPermissions(ctx, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch permissions")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch permissions")
+	}
+
+	inherited, err := h.roleStore.GetInheritedPermissions(ctx, id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch inherited permissions")
+	}
+
+	parents, err := h.roleStore.GetParents(ctx, id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch parent roles")
+	}
+
+	rules, err := h.roleStore.GetRules(ctx, id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch permission rules")
+	}
+
+	if permissions == nil {
+		permissions = []rbac.Permission{}
+	}
+	if inherited == nil {
+		inherited = []rbac.InheritedPermission{}
+	}
+	if parents == nil {
+		parents = []rbac.Role{}
+	}
+	if rules == nil {
+		rules = []rbac.RoleRule{}
 	}
 
 	userCount, _ := h.roleStore.GetUserCount(ctx, id)
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"role":             role,
-		"permissions":      permissions,
-		"permission_count": len(permissions),
-		"user_count":       userCount,
-	})
+	return map[string]interface{}{
+		"role":                       role,
+		"parents":                    parents,
+		"permissions":                permissions,
+		"inherited_permissions":      inherited,
+		"rules":                      rules,
+		"permission_count":           len(permissions),
+		"effective_permission_count": len(permissions) + len(inherited),
+		"user_count":                 userCount,
+	}, nil
+}
+
+// checkRoleIfMatch loads the role a write targets and checks the request's
+// If-Match against its version (428 when missing, 412 with the current role
+// when stale)
+func (h *RBACHandler) checkRoleIfMatch(c echo.Context, id int) (*rbac.Role, error) {
+	role, err := h.roleStore.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "role not found")
+	}
+
+	ifMatch := c.Request().Header.Get(headerIfMatch)
+	if ifMatch == "" {
+		return nil, echo.NewHTTPError(http.StatusPreconditionRequired, "If-Match header with the role's ETag is required")
+	}
+	if !matchesETag(ifMatch, role.Version) {
+		return nil, h.rolePreconditionFailed(c, id)
+	}
+	return role, nil
+}
+
+// rolePreconditionFailed answers a write made against a stale version with
+// 412 and the role as GetRole would return it now
+func (h *RBACHandler) rolePreconditionFailed(c echo.Context, id int) error {
+	ctx := c.Request().Context()
+
+	role, err := h.roleStore.GetByID(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "role not found")
+	}
+
+	body, err := h.roleRepresentation(ctx, role)
+	if err != nil {
+		return err
+	}
+	body["error"] = "role was modified since it was read"
+
+	setETag(c, role.Version)
+	return c.JSON(http.StatusPreconditionFailed, body)
 }
 
 // CreateRole creates a new custom role
@@ -139,7 +232,8 @@ func (h *RBACHandler) CreateRole(c echo.Context) error {
 	return c.JSON(http.StatusCreated, role)
 }
 
-// UpdateRole updates a role's name and description
+// UpdateRole updates a role's name and description. If-Match must carry the
+// role's current ETag.
 // PUT /api/v1/auth/roles/:id
 func (h *RBACHandler) UpdateRole(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -158,15 +252,29 @@ func (h *RBACHandler) UpdateRole(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	if err := h.roleStore.Update(ctx, id, input.Name, input.Description); err != nil {
+	current, err := h.checkRoleIfMatch(c, id)
+	if err != nil {
+		return err
+	}
+
+	if err := h.roleStore.Update(ctx, id, input.Name, input.Description, current.Version); err != nil {
+		if err == rbac.ErrVersionMismatch {
+			return h.rolePreconditionFailed(c, id)
+		}
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusForbidden, "cannot update system role")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update role")
 	}
 
 	role, _ := h.roleStore.GetByID(ctx, id)
+	if role != nil {
+		setETag(c, role.Version)
+	}
 	return c.JSON(http.StatusOK, role)
 }
 
-// DeleteRole deletes a custom role
+// DeleteRole deletes a custom role. If-Match must carry the role's current ETag.
 // DELETE /api/v1/auth/roles/:id
 func (h *RBACHandler) DeleteRole(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -176,17 +284,27 @@ func (h *RBACHandler) DeleteRole(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
 	}
 
-	if err := h.roleStore.Delete(ctx, id); err != nil {
+	current, err := h.checkRoleIfMatch(c, id)
+	if err != nil {
+		return err
+	}
+
+	if err := h.roleStore.Delete(ctx, id, current.Version); err != nil {
+		if err == rbac.ErrVersionMismatch {
+			return h.rolePreconditionFailed(c, id)
+		}
 		if err == sql.ErrNoRows {
 			return echo.NewHTTPError(http.StatusForbidden, "cannot delete system role")
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete role")
+		return safeguardError(err, "failed to delete role")
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "role deleted"})
 }
 
-// GrantPermissionToRole grants a permission to a role
+// GrantPermissionToRole grants a permission to a role (optionally only under
+// conditions), or in bulk every permission selected by ID, name or category.
+// If-Match must carry the role's current ETag.
 // POST /api/v1/auth/roles/:id/permissions
 func (h *RBACHandler) GrantPermissionToRole(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -197,27 +315,290 @@ func (h *RBACHandler) GrantPermissionToRole(c echo.Context) error {
 	}
 
 	var input struct {
-		PermissionID int `json:"permission_id"`
+		PermissionID int              `json:"permission_id"`
+		Conditions   *rbac.Conditions `json:"conditions,omitempty"`
+		rbac.PermissionSelector
+	}
+
+	if err := bindStrict(c, &input); err != nil {
+		return err
+	}
+
+	// Get current user ID from context (set by auth middleware)
+	user, ok := c.Get("user").(*users.User)
+	if !ok || user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	if err := validateConditions(input.Conditions); err != nil {
+		return err
+	}
+
+	if input.PermissionSelector.Empty() {
+		current, err := h.checkRoleIfMatch(c, roleID)
+		if err != nil {
+			return err
+		}
+
+		single := rbac.PermissionSelector{IDs: []int{input.PermissionID}}
+		if err := h.checkCanGrantSelected(ctx, user.ID, roleID, single); err != nil {
+			return rolePermissionsError(err, "failed to check permission")
+		}
+
+		if err := h.roleStore.GrantPermission(ctx, roleID, input.PermissionID, user.ID, input.Conditions, current.Version); err != nil {
+			return h.roleChangeError(c, roleID, err, "failed to grant permission")
+		}
+
+		h.setRoleETag(c, roleID)
+		return c.JSON(http.StatusOK, map[string]string{"message": "permission granted"})
+	}
+
+	if input.Conditions != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "conditions can only be set when granting a single permission_id")
+	}
+
+	if input.PermissionID != 0 {
+		input.IDs = append(input.IDs, input.PermissionID)
+	}
+
+	current, err := h.checkRoleIfMatch(c, roleID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkCanGrantSelected(ctx, user.ID, roleID, input.PermissionSelector); err != nil {
+		return rolePermissionsError(err, "failed to check permissions")
+	}
+
+	diff, err := h.roleStore.GrantPermissions(ctx, roleID, input.PermissionSelector, user.ID, current.Version)
+	if err != nil {
+		return h.roleChangeError(c, roleID, err, "failed to grant permissions")
+	}
+
+	return h.bulkPermissionsResult(c, roleID, diff)
+}
+
+// SetRolePermissions atomically replaces a role's direct permissions. The
+// body must name at least one of the selector lists; an empty list removes
+// every direct permission. If-Match must carry the role's current ETag.
+// PUT /api/v1/auth/roles/:id/permissions
+func (h *RBACHandler) SetRolePermissions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	var input rbac.PermissionSelector
+	if err := bindStrict(c, &input); err != nil {
+		return err
+	}
+
+	if input.IDs == nil && input.Names == nil && input.Categories == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "permission_ids, permissions or categories is required (an empty list removes every permission)")
+	}
+
+	user, ok := c.Get("user").(*users.User)
+	if !ok || user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	current, err := h.checkRoleIfMatch(c, roleID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkCanGrantSelected(ctx, user.ID, roleID, input); err != nil {
+		return rolePermissionsError(err, "failed to check permissions")
+	}
+
+	diff, err := h.roleStore.SetPermissions(ctx, roleID, input, user.ID, current.Version)
+	if err != nil {
+		return h.roleChangeError(c, roleID, err, "failed to set permissions")
+	}
+
+	return h.bulkPermissionsResult(c, roleID, diff)
+}
+
+// RevokeRolePermissions revokes every selected permission from a role.
+// If-Match must carry the role's current ETag.
+// POST /api/v1/auth/roles/:id/permissions/revoke
+func (h *RBACHandler) RevokeRolePermissions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	var input rbac.PermissionSelector
+	if err := bindStrict(c, &input); err != nil {
+		return err
+	}
+
+	if input.Empty() {
+		return echo.NewHTTPError(http.StatusBadRequest, "permission_ids, permissions or categories is required")
+	}
+
+	user, ok := c.Get("user").(*users.User)
+	if !ok || user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	current, err := h.checkRoleIfMatch(c, roleID)
+	if err != nil {
+		return err
+	}
+
+	diff, err := h.roleStore.RevokePermissions(ctx, roleID, input, user.ID, current.Version)
+	if err != nil {
+		return h.roleChangeError(c, roleID, err, "failed to revoke permissions")
+	}
+
+	return h.bulkPermissionsResult(c, roleID, diff)
+}
+
+// CloneRole creates a custom role with a copy of another role's permissions
+// POST /api/v1/auth/roles/:id/clone
+func (h *RBACHandler) CloneRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	sourceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
 	}
 
 	if err := c.Bind(&input); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	// Get current user ID from context (set by auth middleware)
+	if input.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "role name is required")
+	}
+
 	user, ok := c.Get("user").(*users.User)
 	if !ok || user == nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
 	}
 
-	if err := h.roleStore.GrantPermission(ctx, roleID, input.PermissionID, user.ID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to grant permission")
+	// The clone starts with everything the source role gives
+	if err := h.userRoleStore.CheckCanGrant(ctx, user.ID, sourceID, nil); err != nil {
+		return safeguardError(err, "failed to check role")
+	}
+
+	role, err := h.roleStore.Clone(ctx, sourceID, input.Name, input.Description, user.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "role not found")
+		}
+		if isUniqueViolation(err) {
+			return echo.NewHTTPError(http.StatusConflict, "a role with that name already exists")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to clone role")
+	}
+
+	return c.JSON(http.StatusCreated, role)
+}
+
+// checkCanGrantSelected returns an *rbac.EscalationError unless the actor
+// holds every selected permission the role doesn't have directly yet
+func (h *RBACHandler) checkCanGrantSelected(ctx context.Context, actorID, roleID int, sel rbac.PermissionSelector) error {
+	role, err := h.roleStore.GetByID(ctx, roleID)
+	if err != nil {
+		return err
+	}
+
+	selected, err := h.roleStore.ResolvePermissions(ctx, sel)
+	if err != nil {
+		return err
+	}
+
+	current, err := h.roleStore.GetPermissions(ctx, roleID)
+	if err != nil {
+		return err
+	}
+	held := make(map[string]bool, len(current))
+	for _, p := range current {
+		held[p.Name] = true
+	}
+
+	var added []string
+	for _, name := range selected {
+		if !held[name] {
+			added = append(added, name)
+		}
+	}
+	return h.userRoleStore.CheckCanGrantPermissions(ctx, actorID, role.Name, added)
+}
+
+// bindStrict decodes a JSON body, rejecting fields the input doesn't declare
+// so a misspelled selector can't be read as an empty one
+func bindStrict(c echo.Context, input interface{}) error {
+	dec := json.NewDecoder(c.Request().Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body: "+err.Error())
+	}
+	return nil
+}
+
+// roleChangeError answers a failed change to a role's permissions, rules or
+// parents, with 412 and the current role if it changed since If-Match was checked
+func (h *RBACHandler) roleChangeError(c echo.Context, roleID int, err error, fallback string) error {
+	if err == rbac.ErrVersionMismatch {
+		return h.rolePreconditionFailed(c, roleID)
+	}
+	return rolePermissionsError(err, fallback)
+}
+
+// bulkPermissionsResult answers a bulk change with its diff and the role's
+// new ETag
+func (h *RBACHandler) bulkPermissionsResult(c echo.Context, roleID int, diff *rbac.PermissionDiff) error {
+	h.setRoleETag(c, roleID)
+	return c.JSON(http.StatusOK, diff)
+}
+
+// setRoleETag sets the ETag of a role after a change to it
+func (h *RBACHandler) setRoleETag(c echo.Context, roleID int) {
+	if role, _ := h.roleStore.GetByID(c.Request().Context(), roleID); role != nil {
+		setETag(c, role.Version)
 	}
+}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "permission granted"})
+func rolePermissionsError(err error, fallback string) error {
+	var unknown *rbac.UnknownPermissionsError
+	switch {
+	case err == sql.ErrNoRows:
+		return echo.NewHTTPError(http.StatusNotFound, "role not found")
+	case errors.As(err, &unknown):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return safeguardError(err, fallback)
 }
 
-// RevokePermissionFromRole revokes a permission from a role
+// safeguardError maps lockout and separation-of-duties errors to 409 and escalation errors to 403
+func safeguardError(err error, fallback string) error {
+	var escalation *rbac.EscalationError
+	var sod *rbac.SoDViolationError
+	switch {
+	case err == rbac.ErrLastAdmin, err == rbac.ErrLockout, errors.As(err, &sod):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.As(err, &escalation):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case err == sql.ErrNoRows:
+		return echo.NewHTTPError(http.StatusNotFound, "role not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, fallback)
+}
+
+// RevokePermissionFromRole revokes a permission from a role. If-Match must
+// carry the role's current ETag.
 // DELETE /api/v1/auth/roles/:id/permissions/:permissionId
 func (h *RBACHandler) RevokePermissionFromRole(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -232,13 +613,185 @@ func (h *RBACHandler) RevokePermissionFromRole(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid permission ID")
 	}
 
-	if err := h.roleStore.RevokePermission(ctx, roleID, permissionID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke permission")
+	current, err := h.checkRoleIfMatch(c, roleID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.roleStore.RevokePermission(ctx, roleID, permissionID, current.Version); err != nil {
+		return h.roleChangeError(c, roleID, err, "failed to revoke permission")
 	}
 
+	h.setRoleETag(c, roleID)
 	return c.JSON(http.StatusOK, map[string]string{"message": "permission revoked"})
 }
 
+// AddRoleRule attaches a wildcard or deny permission pattern to a role.
+// If-Match must carry the role's current ETag.
+// POST /api/v1/auth/roles/:id/rules
+func (h *RBACHandler) AddRoleRule(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	var input struct {
+		Pattern string      `json:"pattern"`
+		Effect  rbac.Effect `json:"effect"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.Effect == "" {
+		input.Effect = rbac.EffectAllow
+	}
+	if input.Effect != rbac.EffectAllow && input.Effect != rbac.EffectDeny {
+		return echo.NewHTTPError(http.StatusBadRequest, "effect must be allow or deny")
+	}
+	if err := rbac.ValidatePattern(input.Pattern); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user, ok := c.Get("user").(*users.User)
+	if !ok || user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, err := h.checkRoleIfMatch(c, roleID)
+	if err != nil {
+		return err
+	}
+
+	// An allow rule grants every permission its pattern matches
+	if input.Effect == rbac.EffectAllow {
+		if err := h.userRoleStore.CheckCanGrantPermissions(ctx, user.ID, role.Name, []string{input.Pattern}); err != nil {
+			return safeguardError(err, "failed to check permission rule")
+		}
+	}
+
+	rule, err := h.roleStore.AddRule(ctx, roleID, input.Pattern, input.Effect, user.ID, role.Version)
+	if err != nil {
+		return h.roleChangeError(c, roleID, err, "failed to add permission rule")
+	}
+
+	h.setRoleETag(c, roleID)
+	return c.JSON(http.StatusCreated, rule)
+}
+
+// RemoveRoleRule removes a permission rule from a role. If-Match must carry
+// the role's current ETag.
+// DELETE /api/v1/auth/roles/:id/rules/:ruleId
+func (h *RBACHandler) RemoveRoleRule(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("ruleId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid rule ID")
+	}
+
+	current, err := h.checkRoleIfMatch(c, roleID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.roleStore.RemoveRule(ctx, roleID, ruleID, current.Version); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "permission rule not found")
+		}
+		return h.roleChangeError(c, roleID, err, "failed to remove permission rule")
+	}
+
+	h.setRoleETag(c, roleID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "permission rule removed"})
+}
+
+// AddParentRole makes a role inherit another role's permissions. If-Match
+// must carry the role's current ETag.
+// POST /api/v1/auth/roles/:id/parents
+func (h *RBACHandler) AddParentRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	var input struct {
+		ParentRoleID int `json:"parent_role_id"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user, ok := c.Get("user").(*users.User)
+	if !ok || user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	current, err := h.checkRoleIfMatch(c, roleID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.roleStore.GetByID(ctx, input.ParentRoleID); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "parent role not found")
+	}
+
+	// Inheriting a parent gives the role all of the parent's permissions
+	if err := h.userRoleStore.CheckCanGrant(ctx, user.ID, input.ParentRoleID, nil); err != nil {
+		return safeguardError(err, "failed to check parent role")
+	}
+
+	if err := h.roleStore.AddParent(ctx, roleID, input.ParentRoleID, user.ID, current.Version); err != nil {
+		if err == rbac.ErrRoleCycle {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return h.roleChangeError(c, roleID, err, "failed to add parent role")
+	}
+
+	h.setRoleETag(c, roleID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "parent role added"})
+}
+
+// RemoveParentRole stops a role inheriting from a parent role. If-Match must
+// carry the role's current ETag.
+// DELETE /api/v1/auth/roles/:id/parents/:parentId
+func (h *RBACHandler) RemoveParentRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
+	}
+
+	parentID, err := strconv.Atoi(c.Param("parentId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid parent role ID")
+	}
+
+	current, err := h.checkRoleIfMatch(c, roleID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.roleStore.RemoveParent(ctx, roleID, parentID, current.Version); err != nil {
+		return h.roleChangeError(c, roleID, err, "failed to remove parent role")
+	}
+
+	h.setRoleETag(c, roleID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "parent role removed"})
+}
+
 // ============================================================================
 // User Role Endpoints
 // ============================================================================
@@ -302,7 +855,56 @@ func (h *RBACHandler) GetMyPermissions(c echo.Context) error {
 	return c.JSON(http.StatusOK, permissions)
 }
 
-// AssignRoleToUser assigns a role to a user
+// ExplainPermission explains why a user is or isn't granted a permission
+// (users may explain their own; others require users.view)
+// GET /api/v1/auth/users/:id/permissions/explain?permission=X&resource_type=&resource_id=
+func (h *RBACHandler) ExplainPermission(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+	}
+
+	user, ok := c.Get("user").(*users.User)
+	if !ok || user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+	if user.ID != userID {
+		allowed, err := auth.HasPermission(c, h.userRoleStore, permissions.UsersView)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+		}
+		if !allowed {
+			return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+		}
+	}
+
+	permission := c.QueryParam("permission")
+	if permission == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "permission is required")
+	}
+
+	resourceType, resourceID := c.QueryParam("resource_type"), c.QueryParam("resource_id")
+	if (resourceType == "") != (resourceID == "") {
+		return echo.NewHTTPError(http.StatusBadRequest, "resource_type and resource_id must be provided together")
+	}
+	var resource *rbac.Resource
+	if resourceType != "" {
+		resource = &rbac.Resource{Type: resourceType, ID: resourceID}
+	}
+
+	// Conditions are evaluated against this request
+	explanation, err := h.userRoleStore.Explain(ctx, userID, permission, resource, auth.GetRequestContext(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to explain permission")
+	}
+
+	return c.JSON(http.StatusOK, explanation)
+}
+
+// AssignRoleToUser assigns a role to a user, globally or scoped to matching
+// resources, optionally only for requests meeting conditions
 // POST /api/v1/auth/users/:id/roles
 func (h *RBACHandler) AssignRoleToUser(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -313,14 +915,26 @@ func (h *RBACHandler) AssignRoleToUser(c echo.Context) error {
 	}
 
 	var input struct {
-		RoleID    int        `json:"role_id"`
-		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+		RoleID       int              `json:"role_id"`
+		ExpiresAt    *time.Time       `json:"expires_at,omitempty"`
+		ResourceType string           `json:"resource_type,omitempty"`
+		ResourceID   string           `json:"resource_id,omitempty"`
+		Conditions   *rbac.Conditions `json:"conditions,omitempty"`
 	}
 
 	if err := c.Bind(&input); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	scope, err := parseScope(input.ResourceType, input.ResourceID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateConditions(input.Conditions); err != nil {
+		return err
+	}
+
 	// Get current user ID (who is assigning the role)
 	user, ok := c.Get("user").(*users.User)
 	if !ok || user == nil {
@@ -328,14 +942,23 @@ func (h *RBACHandler) AssignRoleToUser(c echo.Context) error {
 	}
 	assignedBy := user.ID
 
-	if err := h.userRoleStore.AssignRole(ctx, userID, input.RoleID, assignedBy, input.ExpiresAt); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to assign role")
+	// Nobody may hand out (least of all to themselves) permissions they don't hold
+	if err := h.userRoleStore.CheckCanGrant(ctx, user.ID, input.RoleID, scope); err != nil {
+		return safeguardError(err, "failed to assign role")
+	}
+
+	if err := h.userRoleStore.AssignScopedRole(ctx, userID, input.RoleID, assignedBy, scope, input.ExpiresAt, input.Conditions); err != nil {
+		if err == rbac.ErrEmailNotVerified {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return safeguardError(err, "failed to assign role")
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "role assigned"})
 }
 
 // RemoveRoleFromUser removes a role from a user
+// (the scoped assignment when ?resource_type=&resource_id= are given)
 // DELETE /api/v1/auth/users/:id/roles/:roleId
 func (h *RBACHandler) RemoveRoleFromUser(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -350,20 +973,36 @@ func (h *RBACHandler) RemoveRoleFromUser(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid role ID")
 	}
 
-	if err := h.userRoleStore.RemoveRole(ctx, userID, roleID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove role")
+	scope, err := parseScope(c.QueryParam("resource_type"), c.QueryParam("resource_id"))
+	if err != nil {
+		return err
+	}
+
+	user, ok := c.Get("user").(*users.User)
+	if !ok || user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	if scope != nil {
+		err = h.userRoleStore.RemoveScopedRole(ctx, userID, roleID, *scope, user.ID)
+	} else {
+		err = h.userRoleStore.RemoveRole(ctx, userID, roleID, user.ID)
+	}
+	if err != nil {
+		return safeguardError(err, "failed to remove role")
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "role removed"})
 }
 
-// CheckPermission checks if the current user has a specific permission
+// CheckPermission checks if the current user has a specific permission,
+// optionally on a specific resource
 // POST /api/v1/auth/check
 func (h *RBACHandler) CheckPermission(c echo.Context) error {
-	ctx := c.Request().Context()
-
 	var input struct {
-		Permission string `json:"permission"`
+		Permission   string `json:"permission"`
+		ResourceType string `json:"resource_type,omitempty"`
+		ResourceID   string `json:"resource_id,omitempty"`
 	}
 
 	if err := c.Bind(&input); err != nil {
@@ -375,25 +1014,100 @@ func (h *RBACHandler) CheckPermission(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
 	}
 
-	// Check if admin (bypass permission check)
-	isAdmin, _ := h.userRoleStore.IsAdmin(ctx, user.ID)
-	if isAdmin {
-		return c.JSON(http.StatusOK, map[string]interface{}{
-			"permission": input.Permission,
-			"granted":    true,
-			"reason":     "admin",
-		})
+	return h.checkAccess(c, user.ID, input.Permission, input.ResourceType, input.ResourceID, auth.GetRequestContext(c))
+}
+
+// CheckAccess answers whether a user may perform an action on a resource.
+// Called by internal services with the service token rather than a session;
+// conditional grants only apply when the caller passes the user's request context.
+// POST /api/v1/auth/check/access
+func (h *RBACHandler) CheckAccess(c echo.Context) error {
+	var input struct {
+		UserID       int                  `json:"user_id"`
+		Permission   string               `json:"permission"`
+		ResourceType string               `json:"resource_type,omitempty"`
+		ResourceID   string               `json:"resource_id,omitempty"`
+		Context      *rbac.RequestContext `json:"context,omitempty"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.UserID == 0 || input.Permission == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "user_id and permission are required")
+	}
+
+	if input.Context != nil && input.Context.Time.IsZero() {
+		input.Context.Time = time.Now()
 	}
 
-	hasPermission, err := h.userRoleStore.CheckPermission(ctx, user.ID, input.Permission)
+	return h.checkAccess(c, input.UserID, input.Permission, input.ResourceType, input.ResourceID, input.Context)
+}
+
+// checkAccess evaluates a (possibly resource-scoped) permission for a user
+// making a request described by rc
+func (h *RBACHandler) checkAccess(c echo.Context, userID int, permission, resourceType, resourceID string, rc *rbac.RequestContext) error {
+	ctx := c.Request().Context()
+
+	if (resourceType == "") != (resourceID == "") {
+		return echo.NewHTTPError(http.StatusBadRequest, "resource_type and resource_id must be provided together")
+	}
+
+	response := map[string]interface{}{
+		"user_id":    userID,
+		"permission": permission,
+	}
+	if resourceType != "" {
+		response["resource_type"] = resourceType
+		response["resource_id"] = resourceID
+	}
+
+	policy, err := h.userRoleStore.LoadPolicy(ctx, userID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check permission")
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"permission": input.Permission,
-		"granted":    hasPermission,
-	})
+	var resource *rbac.Resource
+	if resourceType != "" {
+		resource = &rbac.Resource{Type: resourceType, ID: resourceID}
+	}
+
+	decision := policy.DecideWith(permission, resource, rc)
+	response["granted"] = decision.Allowed
+	response["reason"] = decision.Reason
+	if decision.Rule != nil {
+		response["rule"] = decision.Rule
+	}
+	if len(decision.Conditions) > 0 {
+		response["conditions"] = decision.Conditions
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// validateConditions rejects malformed access conditions
+func validateConditions(conditions *rbac.Conditions) error {
+	if conditions == nil {
+		return nil
+	}
+	if err := conditions.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+// parseScope validates an optional resource scope; both parts or neither must be set
+func parseScope(resourceType, resourceID string) (*rbac.Scope, error) {
+	if resourceType == "" && resourceID == "" {
+		return nil, nil
+	}
+	if resourceType == "" || resourceID == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "resource_type and resource_id must be provided together")
+	}
+	if _, err := path.Match(resourceID, ""); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid resource_id pattern")
+	}
+	return &rbac.Scope{ResourceType: resourceType, ResourceID: resourceID}, nil
 }
 
 // ============================================================================
@@ -402,25 +1116,41 @@ func (h *RBACHandler) CheckPermission(c echo.Context) error {
 
 func (h *RBACHandler) RegisterRoutes(e *echo.Group, authMiddleware echo.MiddlewareFunc) {
 	// All RBAC endpoints require authentication
+	canEditRoles := auth.RequirePermission(h.userRoleStore, permissions.RolesEdit)
+	canViewUsers := auth.RequirePermission(h.userRoleStore, permissions.UsersView)
+	canManageRoles := auth.RequirePermission(h.userRoleStore, permissions.UsersManageRoles)
+
 	// Permission endpoints
 	e.GET("/permissions", h.ListPermissions, authMiddleware)
 
 	// Role endpoints
 	e.GET("/roles", h.ListRoles, authMiddleware)
 	e.GET("/roles/:id", h.GetRole, authMiddleware)
-	e.POST("/roles", h.CreateRole, authMiddleware)           // Requires 'roles.create'
-	e.PUT("/roles/:id", h.UpdateRole, authMiddleware)        // Requires 'roles.edit'
-	e.DELETE("/roles/:id", h.DeleteRole, authMiddleware)     // Requires 'roles.delete'
+	e.POST("/roles", h.CreateRole, authMiddleware, auth.RequirePermission(h.userRoleStore, permissions.RolesCreate))
+	e.PUT("/roles/:id", h.UpdateRole, authMiddleware, canEditRoles)
+	e.DELETE("/roles/:id", h.DeleteRole, authMiddleware, auth.RequirePermission(h.userRoleStore, permissions.RolesDelete))
+	e.POST("/roles/:id/clone", h.CloneRole, authMiddleware, auth.RequirePermission(h.userRoleStore, permissions.RolesCreate))
 
 	// Role permission management
-	e.POST("/roles/:id/permissions", h.GrantPermissionToRole, authMiddleware)            // Requires 'roles.edit'
-	e.DELETE("/roles/:id/permissions/:permissionId", h.RevokePermissionFromRole, authMiddleware) // Requires 'roles.edit'
+	e.POST("/roles/:id/permissions", h.GrantPermissionToRole, authMiddleware, canEditRoles)
+	e.PUT("/roles/:id/permissions", h.SetRolePermissions, authMiddleware, canEditRoles)
+	e.POST("/roles/:id/permissions/revoke", h.RevokeRolePermissions, authMiddleware, canEditRoles)
+	e.DELETE("/roles/:id/permissions/:permissionId", h.RevokePermissionFromRole, authMiddleware, canEditRoles)
+
+	// Wildcard / deny permission rules
+	e.POST("/roles/:id/rules", h.AddRoleRule, authMiddleware, canEditRoles)
+	e.DELETE("/roles/:id/rules/:ruleId", h.RemoveRoleRule, authMiddleware, canEditRoles)
+
+	// Role hierarchy (a role inherits its parents' permissions)
+	e.POST("/roles/:id/parents", h.AddParentRole, authMiddleware, canEditRoles)
+	e.DELETE("/roles/:id/parents/:parentId", h.RemoveParentRole, authMiddleware, canEditRoles)
 
 	// User role management
-	e.GET("/users/:id/roles", h.GetUserRoles, authMiddleware)              // Requires 'users.view'
-	e.GET("/users/:id/permissions", h.GetUserPermissions, authMiddleware)  // Requires 'users.view'
-	e.POST("/users/:id/roles", h.AssignRoleToUser, authMiddleware)         // Requires 'users.manage_roles'
-	e.DELETE("/users/:id/roles/:roleId", h.RemoveRoleFromUser, authMiddleware) // Requires 'users.manage_roles'
+	e.GET("/users/:id/roles", h.GetUserRoles, authMiddleware, canViewUsers)
+	e.GET("/users/:id/permissions", h.GetUserPermissions, authMiddleware, canViewUsers)
+	e.GET("/users/:id/permissions/explain", h.ExplainPermission, authMiddleware) // Self, or 'users.view' (checked in the handler)
+	e.POST("/users/:id/roles", h.AssignRoleToUser, authMiddleware, canManageRoles)
+	e.DELETE("/users/:id/roles/:roleId", h.RemoveRoleFromUser, authMiddleware, canManageRoles)
 
 	// Current user permissions
 	e.GET("/me/permissions", h.GetMyPermissions, authMiddleware) // Always allowed for authenticated users
@@ -0,0 +1,1071 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bwburch/inflight-ui-service/internal/scim"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/bwburch/inflight-ui-service/internal/storage/sessions"
+	"github.com/bwburch/inflight-ui-service/internal/storage/users"
+	"github.com/labstack/echo/v4"
+)
+
+// SCIMHandler implements SCIM 2.0 provisioning: SCIM Users map to users rows
+// and SCIM Groups map to RBAC roles (group membership = role assignment)
+type SCIMHandler struct {
+	userStore     *users.Store
+	roleStore     *rbac.RoleStore
+	userRoleStore *rbac.UserRoleStore
+	sessionStore  *sessions.Store
+}
+
+func NewSCIMHandler(userStore *users.Store, roleStore *rbac.RoleStore, userRoleStore *rbac.UserRoleStore, sessionStore *sessions.Store) *SCIMHandler {
+	return &SCIMHandler{
+		userStore:     userStore,
+		roleStore:     roleStore,
+		userRoleStore: userRoleStore,
+		sessionStore:  sessionStore,
+	}
+}
+
+// ============================================================================
+// Service Provider Configuration
+// ============================================================================
+
+// ServiceProviderConfig describes the supported SCIM features
+// GET /scim/v2/ServiceProviderConfig
+func (h *SCIMHandler) ServiceProviderConfig(c echo.Context) error {
+	supported := func(b bool) map[string]bool { return map[string]bool{"supported": b} }
+
+	return writeSCIM(c, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{scim.SchemaSPConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": scim.MaxPageSize},
+		"changePassword": supported(true),
+		"sort":           supported(false),
+		"etag":           supported(true),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication using a shared bearer token",
+			"primary":     true,
+		}},
+	})
+}
+
+// ============================================================================
+// User Endpoints
+// ============================================================================
+
+// ListUsers returns users matching an optional filter
+// GET /scim/v2/Users?filter=userName eq "alice"&startIndex=1&count=100
+func (h *SCIMHandler) ListUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	filter, startIndex, count, err := parseListParams(c)
+	if err != nil {
+		return scimError(http.StatusBadRequest, "invalidFilter", err.Error())
+	}
+
+	// The filter and paging run in the database
+	userList, total, err := h.userStore.ListSCIM(ctx, filter, count, startIndex-1)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "failed to list users")
+	}
+
+	ids := make([]int, len(userList))
+	for i := range userList {
+		ids[i] = userList[i].ID
+	}
+	roles, err := h.userRoleStore.GetUsersRoles(ctx, ids)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "failed to fetch user roles")
+	}
+
+	resources := make([]*scim.User, 0, len(userList))
+	for i := range userList {
+		resources = append(resources, h.toSCIMUser(c, &userList[i], roles[userList[i].ID]))
+	}
+
+	return writeSCIM(c, http.StatusOK, scim.NewListResponse(resources, total, startIndex, len(resources)))
+}
+
+// GetUser returns a single user
+// GET /scim/v2/Users/:id
+func (h *SCIMHandler) GetUser(c echo.Context) error {
+	resource, err := h.loadUser(c)
+	if err != nil {
+		return err
+	}
+
+	if etagMatches(c.Request().Header.Get("If-None-Match"), resource.Meta.Version) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return writeSCIMResource(c, http.StatusOK, resource, resource.Meta.Version)
+}
+
+// CreateUser provisions a new user
+// POST /scim/v2/Users
+func (h *SCIMHandler) CreateUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var input scim.User
+	if err := json.NewDecoder(c.Request().Body).Decode(&input); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "invalid request body")
+	}
+
+	if input.UserName == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	email := input.PrimaryEmail()
+	if email == "" && strings.Contains(input.UserName, "@") {
+		email = input.UserName
+	}
+	if email == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "an email address is required")
+	}
+
+	existing, err := h.userStore.GetByUsername(ctx, input.UserName)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "failed to check existing users")
+	}
+	if existing != nil {
+		return scimError(http.StatusConflict, "uniqueness", "userName is already in use")
+	}
+
+	var externalID *string
+	if input.ExternalID != "" {
+		externalID = &input.ExternalID
+	}
+
+	user, err := h.userStore.Create(ctx, users.CreateUserInput{
+		Username:   input.UserName,
+		Email:      email,
+		FullName:   input.FormattedName(),
+		Password:   input.Password,
+		ExternalID: externalID,
+		IsActive:   input.Active,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return scimError(http.StatusConflict, "uniqueness", "userName, email or externalId is already in use")
+		}
+		return scimError(http.StatusInternalServerError, "", "failed to create user")
+	}
+
+	resource := h.toSCIMUser(c, user, nil)
+	c.Response().Header().Set(echo.HeaderLocation, resource.Meta.Location)
+	return writeSCIMResource(c, http.StatusCreated, resource, resource.Meta.Version)
+}
+
+// ReplaceUser replaces a user's attributes
+// PUT /scim/v2/Users/:id
+func (h *SCIMHandler) ReplaceUser(c echo.Context) error {
+	current, err := h.loadUser(c)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatchSatisfied(c, current.Meta.Version) {
+		return scimError(http.StatusPreconditionFailed, "", "resource has been modified")
+	}
+
+	var desired scim.User
+	if err := json.NewDecoder(c.Request().Body).Decode(&desired); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "invalid request body")
+	}
+
+	// PUT replaces everything; an omitted active flag means active
+	if desired.Active == nil {
+		active := true
+		desired.Active = &active
+	}
+
+	return h.saveUser(c, current, &desired)
+}
+
+// PatchUser applies PATCH operations to a user
+// PATCH /scim/v2/Users/:id
+func (h *SCIMHandler) PatchUser(c echo.Context) error {
+	current, err := h.loadUser(c)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatchSatisfied(c, current.Meta.Version) {
+		return scimError(http.StatusPreconditionFailed, "", "resource has been modified")
+	}
+
+	var patch scim.PatchRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&patch); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "invalid request body")
+	}
+
+	desired := *current
+	desired.Emails = append([]scim.MultiValue(nil), current.Emails...)
+	if current.Name != nil {
+		name := *current.Name
+		desired.Name = &name
+	}
+	if current.Active != nil {
+		active := *current.Active
+		desired.Active = &active
+	}
+
+	for _, op := range patch.Operations {
+		if err := applyUserPatch(&desired, op); err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+		}
+	}
+
+	return h.saveUser(c, current, &desired)
+}
+
+// DeleteUser deprovisions a user: the account is deactivated (preserving
+// audit history and ownership references) and all sessions are revoked
+// DELETE /scim/v2/Users/:id
+func (h *SCIMHandler) DeleteUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	current, err := h.loadUser(c)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatchSatisfied(c, current.Meta.Version) {
+		return scimError(http.StatusPreconditionFailed, "", "resource has been modified")
+	}
+
+	id, _ := strconv.Atoi(current.ID)
+	inactive := false
+	if _, err := h.userStore.Update(ctx, id, users.UpdateUserInput{IsActive: &inactive}); err != nil {
+		if err == rbac.ErrLastAdmin {
+			return scimError(http.StatusConflict, "", err.Error())
+		}
+		return scimError(http.StatusInternalServerError, "", "failed to deactivate user")
+	}
+
+	if err := h.sessionStore.DeleteAllUserSessions(ctx, id); err != nil {
+		return scimError(http.StatusInternalServerError, "", "failed to revoke user sessions")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// loadUser fetches the user named by the :id path parameter as a SCIM resource
+func (h *SCIMHandler) loadUser(c echo.Context) (*scim.User, error) {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, scimError(http.StatusNotFound, "", "user not found")
+	}
+
+	user, err := h.userStore.Get(ctx, id)
+	if err != nil {
+		return nil, scimError(http.StatusInternalServerError, "", "failed to fetch user")
+	}
+	if user == nil {
+		return nil, scimError(http.StatusNotFound, "", "user not found")
+	}
+
+	roles, err := h.userRoleStore.GetUserRoles(ctx, id)
+	if err != nil {
+		return nil, scimError(http.StatusInternalServerError, "", "failed to fetch user roles")
+	}
+
+	return h.toSCIMUser(c, user, roles), nil
+}
+
+// saveUser persists the differences between the current and desired user.
+// Deactivating a user revokes all of their sessions.
+func (h *SCIMHandler) saveUser(c echo.Context, current, desired *scim.User) error {
+	ctx := c.Request().Context()
+	id, _ := strconv.Atoi(current.ID)
+
+	if desired.UserName == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	var input users.UpdateUserInput
+	if desired.UserName != current.UserName {
+		input.Username = &desired.UserName
+	}
+	if email := desired.PrimaryEmail(); email != "" && email != current.PrimaryEmail() {
+		input.Email = &email
+	}
+	if name := desired.FormattedName(); name != current.FormattedName() {
+		input.FullName = &name
+	}
+	if desired.ExternalID != current.ExternalID {
+		input.ExternalID = &desired.ExternalID
+	}
+
+	wasActive := current.Active == nil || *current.Active
+	isActive := desired.Active == nil || *desired.Active
+	if isActive != wasActive {
+		input.IsActive = &isActive
+	}
+
+	user, err := h.userStore.Update(ctx, id, input)
+	if err != nil {
+		if err == rbac.ErrLastAdmin {
+			return scimError(http.StatusConflict, "", err.Error())
+		}
+		if isUniqueViolation(err) {
+			return scimError(http.StatusConflict, "uniqueness", "userName, email or externalId is already in use")
+		}
+		return scimError(http.StatusInternalServerError, "", "failed to update user")
+	}
+	if user == nil {
+		return scimError(http.StatusNotFound, "", "user not found")
+	}
+
+	if desired.Password != "" {
+		if err := h.userStore.UpdatePassword(ctx, id, desired.Password); err != nil {
+			return scimError(http.StatusInternalServerError, "", "failed to update password")
+		}
+	}
+
+	if wasActive && !isActive {
+		if err := h.sessionStore.DeleteAllUserSessions(ctx, id); err != nil {
+			return scimError(http.StatusInternalServerError, "", "failed to revoke user sessions")
+		}
+	}
+
+	roles, err := h.userRoleStore.GetUserRoles(ctx, id)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "failed to fetch user roles")
+	}
+
+	resource := h.toSCIMUser(c, user, roles)
+	return writeSCIMResource(c, http.StatusOK, resource, resource.Meta.Version)
+}
+
+func (h *SCIMHandler) toSCIMUser(c echo.Context, u *users.User, roles []rbac.UserRole) *scim.User {
+	active := u.IsActive
+	resource := &scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          strconv.Itoa(u.ID),
+		UserName:    u.Username,
+		DisplayName: u.FullName,
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: scim.ResourceTypeUser,
+			Created:      &u.CreatedAt,
+			LastModified: &u.CreatedAt,
+			Location:     scimBaseURL(c) + "/Users/" + strconv.Itoa(u.ID),
+		},
+	}
+	if u.UpdatedAt != nil {
+		resource.Meta.LastModified = u.UpdatedAt
+	}
+	if u.ExternalID != nil {
+		resource.ExternalID = *u.ExternalID
+	}
+	if u.FullName != "" {
+		resource.Name = &scim.Name{Formatted: u.FullName}
+	}
+	if u.Email != "" {
+		resource.Emails = []scim.MultiValue{{Value: u.Email, Type: "work", Primary: true}}
+	}
+	for _, role := range roles {
+		if role.ResourceType != nil {
+			continue // Scoped grants have no SCIM group equivalent
+		}
+		resource.Groups = append(resource.Groups, scim.MultiValue{
+			Value:   strconv.Itoa(role.RoleID),
+			Display: role.RoleName,
+			Ref:     scimBaseURL(c) + "/Groups/" + strconv.Itoa(role.RoleID),
+		})
+	}
+
+	resource.Meta.Version = scim.ETag(resource)
+	return resource
+}
+
+// applyUserPatch applies a single PATCH operation to a SCIM user
+func applyUserPatch(u *scim.User, op scim.PatchOperation) error {
+	opName := strings.ToLower(op.Op)
+	if opName != "add" && opName != "replace" && opName != "remove" {
+		return fmt.Errorf("unsupported patch op %q", op.Op)
+	}
+
+	// Without a path the value is an object of attribute -> value
+	if op.Path == "" {
+		if opName == "remove" {
+			return fmt.Errorf("remove requires a path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return fmt.Errorf("patch value must be an object when no path is given")
+		}
+		for key, value := range values {
+			if err := applyUserPatch(u, scim.PatchOperation{Op: op.Op, Path: key, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	attr, _, subAttr, err := scim.ParseValuePath(op.Path)
+	if err != nil {
+		return err
+	}
+	if subAttr != "" {
+		attr += "." + subAttr
+	}
+
+	if opName == "remove" {
+		switch attr {
+		case "displayname":
+			u.DisplayName = ""
+		case "externalid":
+			u.ExternalID = ""
+		case "name", "name.formatted":
+			u.Name = nil
+		default:
+			return fmt.Errorf("attribute %q cannot be removed", op.Path)
+		}
+		return nil
+	}
+
+	switch attr {
+	case "username":
+		return json.Unmarshal(op.Value, &u.UserName)
+	case "displayname":
+		return json.Unmarshal(op.Value, &u.DisplayName)
+	case "externalid":
+		return json.Unmarshal(op.Value, &u.ExternalID)
+	case "password":
+		return json.Unmarshal(op.Value, &u.Password)
+	case "name":
+		return json.Unmarshal(op.Value, &u.Name)
+	case "name.formatted", "name.givenname", "name.familyname":
+		if u.Name == nil {
+			u.Name = &scim.Name{}
+		}
+		switch attr {
+		case "name.formatted":
+			return json.Unmarshal(op.Value, &u.Name.Formatted)
+		case "name.givenname":
+			u.Name.Formatted = ""
+			return json.Unmarshal(op.Value, &u.Name.GivenName)
+		default:
+			u.Name.Formatted = ""
+			return json.Unmarshal(op.Value, &u.Name.FamilyName)
+		}
+	case "active":
+		active, err := parseSCIMBool(op.Value)
+		if err != nil {
+			return err
+		}
+		u.Active = &active
+		return nil
+	case "emails":
+		var emails []scim.MultiValue
+		if err := json.Unmarshal(op.Value, &emails); err != nil {
+			return fmt.Errorf("emails must be an array")
+		}
+		if len(emails) > 0 {
+			u.Emails = emails
+		}
+		return nil
+	case "emails.value":
+		// Only a single email address is stored per user
+		var email string
+		if err := json.Unmarshal(op.Value, &email); err != nil {
+			return fmt.Errorf("email value must be a string")
+		}
+		u.Emails = []scim.MultiValue{{Value: email, Type: "work", Primary: true}}
+		return nil
+	case "groups":
+		return fmt.Errorf("groups is read-only; patch the Group resource instead")
+	}
+
+	return fmt.Errorf("unsupported attribute %q", op.Path)
+}
+
+// parseSCIMBool accepts JSON booleans and the "True"/"False" strings some
+// identity providers send
+func parseSCIMBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if parsed, err := strconv.ParseBool(s); err == nil {
+			return parsed, nil
+		}
+	}
+	return false, fmt.Errorf("active must be a boolean")
+}
+
+// ============================================================================
+// Group Endpoints (RBAC roles)
+// ============================================================================
+
+// ListGroups returns roles matching an optional filter
+// GET /scim/v2/Groups?filter=displayName eq "operator"&excludedAttributes=members
+func (h *SCIMHandler) ListGroups(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	filter, startIndex, count, err := parseListParams(c)
+	if err != nil {
+		return scimError(http.StatusBadRequest, "invalidFilter", err.Error())
+	}
+
+	// The filter and paging run in the database
+	roles, total, err := h.roleStore.ListSCIM(ctx, filter, count, startIndex-1)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "failed to list groups")
+	}
+
+	excludeMembers := strings.Contains(strings.ToLower(c.QueryParam("excludedAttributes")), "members")
+
+	// Members are loaded even when excluded: the ETag covers them
+	ids := make([]int, len(roles))
+	for i := range roles {
+		ids[i] = roles[i].ID
+	}
+	members, err := h.userRoleStore.GetRolesMembers(ctx, ids)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "failed to fetch group members")
+	}
+
+	resources := make([]*scim.Group, 0, len(roles))
+	for i := range roles {
+		resource := h.toSCIMGroup(c, &roles[i], members[roles[i].ID])
+		if excludeMembers {
+			resource.Members = nil
+		}
+		resources = append(resources, resource)
+	}
+
+	return writeSCIM(c, http.StatusOK, scim.NewListResponse(resources, total, startIndex, len(resources)))
+}
+
+// GetGroup returns a single role as a group
+// GET /scim/v2/Groups/:id
+func (h *SCIMHandler) GetGroup(c echo.Context) error {
+	resource, err := h.loadGroup(c)
+	if err != nil {
+		return err
+	}
+
+	if etagMatches(c.Request().Header.Get("If-None-Match"), resource.Meta.Version) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return writeSCIMResource(c, http.StatusOK, resource, resource.Meta.Version)
+}
+
+// CreateGroup creates a custom role and assigns its members
+// POST /scim/v2/Groups
+func (h *SCIMHandler) CreateGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var input scim.Group
+	if err := json.NewDecoder(c.Request().Body).Decode(&input); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "invalid request body")
+	}
+
+	if input.DisplayName == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	if existing, err := h.roleStore.GetByName(ctx, input.DisplayName); err == nil && existing != nil {
+		return scimError(http.StatusConflict, "uniqueness", "a group with this displayName already exists")
+	}
+
+	role, err := h.roleStore.Create(ctx, input.DisplayName, "Provisioned via SCIM")
+	if err != nil {
+		if isUniqueViolation(err) {
+			return scimError(http.StatusConflict, "uniqueness", "a group with this displayName already exists")
+		}
+		return scimError(http.StatusInternalServerError, "", "failed to create group")
+	}
+
+	if err := h.setGroupMembers(ctx, role.ID, nil, input.Members); err != nil {
+		// Drop the half-created group (its memberships cascade) so the IdP
+		// can retry the same displayName
+		if delErr := h.roleStore.Delete(ctx, role.ID, 0); delErr != nil {
+			return scimError(http.StatusInternalServerError, "", "failed to create group")
+		}
+		return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	resource, err := h.buildGroup(c, role)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, resource.Meta.Location)
+	return writeSCIMResource(c, http.StatusCreated, resource, resource.Meta.Version)
+}
+
+// ReplaceGroup replaces a group's name and membership
+// PUT /scim/v2/Groups/:id
+func (h *SCIMHandler) ReplaceGroup(c echo.Context) error {
+	current, err := h.loadGroup(c)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatchSatisfied(c, current.Meta.Version) {
+		return scimError(http.StatusPreconditionFailed, "", "resource has been modified")
+	}
+
+	var desired scim.Group
+	if err := json.NewDecoder(c.Request().Body).Decode(&desired); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "invalid request body")
+	}
+
+	return h.saveGroup(c, current, &desired)
+}
+
+// PatchGroup applies PATCH operations to a group (typically membership changes)
+// PATCH /scim/v2/Groups/:id
+func (h *SCIMHandler) PatchGroup(c echo.Context) error {
+	current, err := h.loadGroup(c)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatchSatisfied(c, current.Meta.Version) {
+		return scimError(http.StatusPreconditionFailed, "", "resource has been modified")
+	}
+
+	var patch scim.PatchRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&patch); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "invalid request body")
+	}
+
+	desired := *current
+	desired.Members = append([]scim.MultiValue(nil), current.Members...)
+
+	for _, op := range patch.Operations {
+		if err := applyGroupPatch(&desired, op); err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+		}
+	}
+
+	return h.saveGroup(c, current, &desired)
+}
+
+// DeleteGroup deletes a custom role (system roles cannot be deleted)
+// DELETE /scim/v2/Groups/:id
+func (h *SCIMHandler) DeleteGroup(c echo.Context) error {
+	current, err := h.loadGroup(c)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatchSatisfied(c, current.Meta.Version) {
+		return scimError(http.StatusPreconditionFailed, "", "resource has been modified")
+	}
+
+	id, _ := strconv.Atoi(current.ID)
+	if err := h.roleStore.Delete(c.Request().Context(), id, 0); err != nil {
+		if err == sql.ErrNoRows {
+			return scimError(http.StatusBadRequest, "mutability", "system roles cannot be deleted")
+		}
+		if err == rbac.ErrLastAdmin || err == rbac.ErrLockout {
+			return scimError(http.StatusConflict, "", err.Error())
+		}
+		return scimError(http.StatusInternalServerError, "", "failed to delete group")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// loadGroup fetches the role named by the :id path parameter as a SCIM resource
+func (h *SCIMHandler) loadGroup(c echo.Context) (*scim.Group, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, scimError(http.StatusNotFound, "", "group not found")
+	}
+
+	role, err := h.roleStore.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, scimError(http.StatusNotFound, "", "group not found")
+	}
+
+	return h.buildGroup(c, role)
+}
+
+func (h *SCIMHandler) buildGroup(c echo.Context, role *rbac.Role) (*scim.Group, error) {
+	members, err := h.userRoleStore.GetRoleMembers(c.Request().Context(), role.ID)
+	if err != nil {
+		return nil, scimError(http.StatusInternalServerError, "", "failed to fetch group members")
+	}
+	return h.toSCIMGroup(c, role, members), nil
+}
+
+// saveGroup persists name and membership differences for a group
+func (h *SCIMHandler) saveGroup(c echo.Context, current, desired *scim.Group) error {
+	ctx := c.Request().Context()
+	id, _ := strconv.Atoi(current.ID)
+
+	if desired.DisplayName == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	role, err := h.roleStore.GetByID(ctx, id)
+	if err != nil {
+		return scimError(http.StatusNotFound, "", "group not found")
+	}
+
+	if desired.DisplayName != role.Name {
+		if err := h.roleStore.Update(ctx, id, desired.DisplayName, role.Description, 0); err != nil {
+			if err == sql.ErrNoRows {
+				return scimError(http.StatusBadRequest, "mutability", "system roles cannot be renamed")
+			}
+			if isUniqueViolation(err) {
+				return scimError(http.StatusConflict, "uniqueness", "a group with this displayName already exists")
+			}
+			return scimError(http.StatusInternalServerError, "", "failed to update group")
+		}
+	}
+
+	if err := h.setGroupMembers(ctx, id, current.Members, desired.Members); err != nil {
+		if errors.Is(err, rbac.ErrLastAdmin) {
+			return scimError(http.StatusConflict, "", err.Error())
+		}
+		return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	role, err = h.roleStore.GetByID(ctx, id)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "failed to fetch group")
+	}
+
+	resource, err := h.buildGroup(c, role)
+	if err != nil {
+		return err
+	}
+	return writeSCIMResource(c, http.StatusOK, resource, resource.Meta.Version)
+}
+
+// setGroupMembers reconciles role assignments from the current to the desired member list
+func (h *SCIMHandler) setGroupMembers(ctx context.Context, roleID int, current, desired []scim.MultiValue) error {
+	currentIDs := make(map[int]bool, len(current))
+	for _, m := range current {
+		if id, err := strconv.Atoi(m.Value); err == nil {
+			currentIDs[id] = true
+		}
+	}
+
+	desiredIDs := make(map[int]bool, len(desired))
+	for _, m := range desired {
+		id, err := strconv.Atoi(m.Value)
+		if err != nil {
+			return fmt.Errorf("invalid member value %q", m.Value)
+		}
+		desiredIDs[id] = true
+	}
+
+	for id := range desiredIDs {
+		if currentIDs[id] {
+			continue
+		}
+		user, err := h.userStore.Get(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to look up member %d", id)
+		}
+		if user == nil {
+			return fmt.Errorf("member %d does not exist", id)
+		}
+		if err := h.userRoleStore.AssignRole(ctx, id, roleID, 0, nil); err != nil {
+			var sod *rbac.SoDViolationError
+			if err == rbac.ErrEmailNotVerified || errors.As(err, &sod) {
+				return fmt.Errorf("member %d: %w", id, err)
+			}
+			return fmt.Errorf("failed to add member %d", id)
+		}
+	}
+
+	for id := range currentIDs {
+		if desiredIDs[id] {
+			continue
+		}
+		if err := h.userRoleStore.RemoveRole(ctx, id, roleID, 0); err != nil {
+			if err == rbac.ErrLastAdmin {
+				return fmt.Errorf("member %d: %w", id, err)
+			}
+			return fmt.Errorf("failed to remove member %d", id)
+		}
+	}
+
+	return nil
+}
+
+func (h *SCIMHandler) toSCIMGroup(c echo.Context, role *rbac.Role, members []rbac.RoleMember) *scim.Group {
+	resource := &scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          strconv.Itoa(role.ID),
+		DisplayName: role.Name,
+		Meta: &scim.Meta{
+			ResourceType: scim.ResourceTypeGroup,
+			Created:      &role.CreatedAt,
+			LastModified: &role.UpdatedAt,
+			Location:     scimBaseURL(c) + "/Groups/" + strconv.Itoa(role.ID),
+		},
+	}
+	for _, m := range members {
+		resource.Members = append(resource.Members, scim.MultiValue{
+			Value:   strconv.Itoa(m.UserID),
+			Display: m.Username,
+			Ref:     scimBaseURL(c) + "/Users/" + strconv.Itoa(m.UserID),
+		})
+	}
+
+	resource.Meta.Version = scim.ETag(resource)
+	return resource
+}
+
+// applyGroupPatch applies a single PATCH operation to a SCIM group
+func applyGroupPatch(g *scim.Group, op scim.PatchOperation) error {
+	opName := strings.ToLower(op.Op)
+	if opName != "add" && opName != "replace" && opName != "remove" {
+		return fmt.Errorf("unsupported patch op %q", op.Op)
+	}
+
+	if op.Path == "" {
+		if opName == "remove" {
+			return fmt.Errorf("remove requires a path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return fmt.Errorf("patch value must be an object when no path is given")
+		}
+		for key, value := range values {
+			if err := applyGroupPatch(g, scim.PatchOperation{Op: op.Op, Path: key, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	attr, filter, _, err := scim.ParseValuePath(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch attr {
+	case "displayname":
+		if opName == "remove" {
+			return fmt.Errorf("displayName cannot be removed")
+		}
+		return json.Unmarshal(op.Value, &g.DisplayName)
+	case "externalid":
+		// Groups are matched by displayName; externalId is accepted but not stored
+		return nil
+	case "members":
+	default:
+		return fmt.Errorf("unsupported attribute %q", op.Path)
+	}
+
+	var values []scim.MultiValue
+	if len(op.Value) > 0 && string(op.Value) != "null" {
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			var single scim.MultiValue
+			if err := json.Unmarshal(op.Value, &single); err != nil {
+				return fmt.Errorf("members must be an array")
+			}
+			values = []scim.MultiValue{single}
+		}
+	}
+
+	switch opName {
+	case "replace":
+		g.Members = values
+	case "add":
+		for _, v := range values {
+			if !containsMember(g.Members, v.Value) {
+				g.Members = append(g.Members, v)
+			}
+		}
+	case "remove":
+		kept := g.Members[:0]
+		for _, m := range g.Members {
+			remove := false
+			switch {
+			case filter != nil:
+				remove = filter.Match(map[string][]string{"value": {m.Value}, "display": {m.Display}})
+			case len(values) > 0:
+				remove = containsMember(values, m.Value)
+			default:
+				remove = true // Remove all members
+			}
+			if !remove {
+				kept = append(kept, m)
+			}
+		}
+		g.Members = kept
+	}
+
+	return nil
+}
+
+func containsMember(members []scim.MultiValue, value string) bool {
+	for _, m := range members {
+		if m.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+func parseListParams(c echo.Context) (scim.Filter, int, int, error) {
+	var filter scim.Filter
+	if expr := c.QueryParam("filter"); expr != "" {
+		f, err := scim.ParseFilter(expr)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		filter = f
+	}
+
+	startIndex := 1
+	if v, err := strconv.Atoi(c.QueryParam("startIndex")); err == nil && v > 0 {
+		startIndex = v
+	}
+
+	count := scim.DefaultPageSize
+	if v, err := strconv.Atoi(c.QueryParam("count")); err == nil && v >= 0 {
+		count = v
+	}
+	if count > scim.MaxPageSize {
+		count = scim.MaxPageSize
+	}
+
+	return filter, startIndex, count, nil
+}
+
+func scimBaseURL(c echo.Context) string {
+	return c.Scheme() + "://" + c.Request().Host + "/scim/v2"
+}
+
+// etagMatches reports whether an If-Match/If-None-Match header matches an ETag
+// using weak comparison
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchSatisfied reports whether the request's If-Match precondition (if any) holds
+func ifMatchSatisfied(c echo.Context, etag string) bool {
+	header := c.Request().Header.Get("If-Match")
+	return header == "" || etagMatches(header, etag)
+}
+
+func writeSCIM(c echo.Context, status int, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.Blob(status, scim.ContentType, data)
+}
+
+func writeSCIMResource(c echo.Context, status int, body interface{}, etag string) error {
+	c.Response().Header().Set("ETag", etag)
+	return writeSCIM(c, status, body)
+}
+
+// scimErr is an error rendered as a SCIM error response by scimErrorMiddleware
+type scimErr struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *scimErr) Error() string {
+	return e.detail
+}
+
+func scimError(status int, scimType, detail string) error {
+	return &scimErr{status: status, scimType: scimType, detail: detail}
+}
+
+// scimErrorMiddleware renders handler and middleware errors (including
+// authentication failures) in the SCIM error format
+func scimErrorMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+		if err == nil || c.Response().Committed {
+			return err
+		}
+
+		var se *scimErr
+		if errors.As(err, &se) {
+			return writeSCIM(c, se.status, &scim.Error{
+				Schemas:  []string{scim.SchemaError},
+				Status:   strconv.Itoa(se.status),
+				ScimType: se.scimType,
+				Detail:   se.detail,
+			})
+		}
+
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			return writeSCIM(c, he.Code, &scim.Error{
+				Schemas: []string{scim.SchemaError},
+				Status:  strconv.Itoa(he.Code),
+				Detail:  fmt.Sprint(he.Message),
+			})
+		}
+
+		return err
+	}
+}
+
+// ============================================================================
+// Route Registration
+// ============================================================================
+
+func (h *SCIMHandler) RegisterRoutes(e *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	e.Use(scimErrorMiddleware)
+
+	// All SCIM endpoints require the identity provider's bearer token
+	e.GET("/ServiceProviderConfig", h.ServiceProviderConfig, authMiddleware)
+
+	e.GET("/Users", h.ListUsers, authMiddleware)
+	e.POST("/Users", h.CreateUser, authMiddleware)
+	e.GET("/Users/:id", h.GetUser, authMiddleware)
+	e.PUT("/Users/:id", h.ReplaceUser, authMiddleware)
+	e.PATCH("/Users/:id", h.PatchUser, authMiddleware)
+	e.DELETE("/Users/:id", h.DeleteUser, authMiddleware)
+
+	e.GET("/Groups", h.ListGroups, authMiddleware)
+	e.POST("/Groups", h.CreateGroup, authMiddleware)
+	e.GET("/Groups/:id", h.GetGroup, authMiddleware)
+	e.PUT("/Groups/:id", h.ReplaceGroup, authMiddleware)
+	e.PATCH("/Groups/:id", h.PatchGroup, authMiddleware)
+	e.DELETE("/Groups/:id", h.DeleteGroup, authMiddleware)
+}
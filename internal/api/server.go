@@ -1,14 +1,23 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"net"
 	"net/http"
 
 	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/cache"
+	"github.com/bwburch/inflight-ui-service/internal/config"
+	"github.com/bwburch/inflight-ui-service/internal/jobs"
+	"github.com/bwburch/inflight-ui-service/internal/notify"
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/bwburch/inflight-ui-service/internal/storage/privacy"
 	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
 	"github.com/bwburch/inflight-ui-service/internal/storage/sessions"
 	"github.com/bwburch/inflight-ui-service/internal/storage/templates"
 	"github.com/bwburch/inflight-ui-service/internal/storage/users"
+	"github.com/bwburch/inflight-ui-service/internal/storage/verifications"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/redis/go-redis/v9"
@@ -17,23 +26,37 @@ import (
 
 type Server struct {
 	echo             *echo.Echo
+	cfg              *config.Config
 	db               *sql.DB
 	redis            *redis.Client
 	templatesHandler *TemplatesHandler
 	usersHandler     *UsersHandler
 	authHandler      *AuthHandler
 	rbacHandler      *RBACHandler
+	groupsHandler    *GroupsHandler
+	accessHandler    *AccessRequestsHandler
+	policyHandler    *PolicyHandler
+	scimHandler      *SCIMHandler
+	privacyHandler   *PrivacyHandler
+	sodHandler       *SoDHandler
+	reviewsHandler   *AccessReviewsHandler
+	userRoleStore    *rbac.UserRoleStore
 	authMiddleware   *auth.Middleware
+	permissionCache  *cache.PermissionCache
+	stopBackground   context.CancelFunc
 	logger           *logrus.Logger
 }
 
-func NewServer(db *sql.DB, redisClient *redis.Client, logger *logrus.Logger) *Server {
+func NewServer(cfg *config.Config, db *sql.DB, redisClient *redis.Client, logger *logrus.Logger) *Server {
 	e := echo.New()
 	e.HideBanner = true
 
 	// Disable validator - we'll do manual validation
 	e.Validator = nil
 
+	// Client address for source CIDR access conditions
+	e.IPExtractor = ipExtractor(cfg.Server.TrustedProxies, logger)
+
 	// Middleware
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
@@ -46,25 +69,87 @@ func NewServer(db *sql.DB, redisClient *redis.Client, logger *logrus.Logger) *Se
 	roleStore := rbac.NewRoleStore(db)
 	permissionStore := rbac.NewPermissionStore(db)
 	userRoleStore := rbac.NewUserRoleStore(db)
+	userRoleStore.SetRequireVerifiedEmail(cfg.Auth.RequireEmailVerification)
+	groupStore := rbac.NewGroupStore(db)
+	groupStore.SetRequireVerifiedEmail(cfg.Auth.RequireEmailVerification)
+	auditStore := rbac.NewAuditStore(db)
+	accessStore := rbac.NewAccessRequestStore(db)
+	accessStore.SetRequireVerifiedEmail(cfg.Auth.RequireEmailVerification)
+	policyStore := rbac.NewPolicyStore(db)
+	policyStore.SetRequireVerifiedEmail(cfg.Auth.RequireEmailVerification)
+	privacyStore := privacy.NewStore(db)
+	sodStore := rbac.NewSoDStore(db)
+	reviewStore := rbac.NewAccessReviewStore(db)
+	verificationStore := verifications.NewStore(redisClient)
+	notifier := notify.NewLogNotifier(logger)
+
+	// Permission cache (invalidated by the stores on any authorization change)
+	var permissionCache *cache.PermissionCache
+	if cfg.Cache.Enabled {
+		permissionCache = cache.NewPermissionCache(redisClient, logger, cache.Options{
+			Size:     cfg.Cache.Size,
+			LocalTTL: cfg.Cache.LocalTTL,
+			RedisTTL: cfg.Cache.RedisTTL,
+		})
+		userRoleStore.SetCache(permissionCache)
+		roleStore.SetInvalidator(permissionCache)
+		groupStore.SetInvalidator(permissionCache)
+		accessStore.SetInvalidator(permissionCache)
+		policyStore.SetInvalidator(permissionCache)
+		usersStore.SetInvalidator(permissionCache)
+		privacyStore.SetInvalidator(permissionCache)
+		reviewStore.SetInvalidator(permissionCache)
+	}
 
 	// Initialize handlers
 	templatesHandler := NewTemplatesHandler(templatesStore)
-	usersHandler := NewUsersHandler(usersStore)
-	authHandler := NewAuthHandler(usersStore, sessionStore)
+	usersHandler := NewUsersHandler(usersStore, userRoleStore)
+	authHandler := NewAuthHandler(usersStore, sessionStore, verificationStore, notifier, cfg.Auth.VerificationURL)
 	rbacHandler := NewRBACHandler(roleStore, permissionStore, userRoleStore)
+	groupsHandler := NewGroupsHandler(groupStore, userRoleStore)
+	accessHandler := NewAccessRequestsHandler(accessStore, roleStore, userRoleStore, notifier)
+	policyHandler := NewPolicyHandler(policyStore, userRoleStore)
+	scimHandler := NewSCIMHandler(usersStore, roleStore, userRoleStore, sessionStore)
+	privacyHandler := NewPrivacyHandler(privacyStore, usersStore, userRoleStore, auditStore, sessionStore)
+	sodHandler := NewSoDHandler(sodStore, userRoleStore)
+	reviewsHandler := NewAccessReviewsHandler(reviewStore, roleStore, groupStore, userRoleStore, notifier)
 
 	// Initialize auth middleware
 	authMiddleware := auth.NewMiddleware(sessionStore, usersStore)
+	if permissionCache != nil {
+		authMiddleware.SetUserCache(permissionCache)
+	}
+
+	background, stopBackground := context.WithCancel(context.Background())
+	if permissionCache != nil {
+		go permissionCache.Run(background)
+	}
+	if cfg.Jobs.Enabled {
+		scheduler := jobs.NewScheduler(db, logger, cfg.Jobs.Interval)
+		scheduler.Register(jobs.RoleExpiryJob(userRoleStore, groupStore, notifier, logger, cfg.Jobs.ExpiryWarningDays))
+		go scheduler.Run(background)
+	}
 
 	s := &Server{
 		echo:             e,
+		cfg:              cfg,
 		db:               db,
 		redis:            redisClient,
 		templatesHandler: templatesHandler,
 		usersHandler:     usersHandler,
 		authHandler:      authHandler,
 		rbacHandler:      rbacHandler,
+		groupsHandler:    groupsHandler,
+		accessHandler:    accessHandler,
+		policyHandler:    policyHandler,
+		scimHandler:      scimHandler,
+		privacyHandler:   privacyHandler,
+		sodHandler:       sodHandler,
+		reviewsHandler:   reviewsHandler,
+		userRoleStore:    userRoleStore,
 		authMiddleware:   authMiddleware,
+		permissionCache:  permissionCache,
+		stopBackground:   stopBackground,
 		logger:           logger,
 	}
 
@@ -76,6 +161,7 @@ func (s *Server) registerRoutes() {
 	// Health endpoints
 	s.echo.GET("/health", s.handleHealth)
 	s.echo.GET("/ready", s.handleReady)
+	s.echo.GET("/metrics", s.handleMetrics)
 
 	// API v1
 	v1 := s.echo.Group("/api/v1")
@@ -85,27 +171,81 @@ func (s *Server) registerRoutes() {
 	authGroup.POST("/login", s.authHandler.Login)
 	authGroup.POST("/logout", s.authHandler.Logout)
 	authGroup.GET("/me", s.authHandler.Me, s.authMiddleware.RequireAuth)
+	authGroup.POST("/verify-email", s.authHandler.VerifyEmail)
+	authGroup.POST("/verify-email/send", s.authHandler.SendVerification, s.authMiddleware.RequireAuth)
 
 	// RBAC endpoints (auth required)
 	s.rbacHandler.RegisterRoutes(authGroup, s.authMiddleware.RequireAuth)
+	s.groupsHandler.RegisterRoutes(authGroup, s.authMiddleware.RequireAuth)
+	s.accessHandler.RegisterRoutes(authGroup, s.authMiddleware.RequireAuth)
+	s.policyHandler.RegisterRoutes(authGroup, s.authMiddleware.RequireAuth)
+	s.sodHandler.RegisterRoutes(authGroup, s.authMiddleware.RequireAuth)
+	s.reviewsHandler.RegisterRoutes(authGroup, s.authMiddleware.RequireAuth)
+
+	// Service-to-service access checks (advisor, simulator)
+	if s.cfg.Auth.ServiceToken != "" {
+		authGroup.POST("/check/access", s.rbacHandler.CheckAccess, auth.RequireBearerToken(s.cfg.Auth.ServiceToken))
+	}
 
 	// Protected endpoints (auth required)
 	// Templates
 	templates := v1.Group("/templates", s.authMiddleware.RequireAuth)
 	templates.GET("", s.templatesHandler.ListTemplates)
 	templates.POST("", s.templatesHandler.CreateTemplate)
+	templates.GET("/schema", s.templatesHandler.GetSchema)
+	templates.GET("/tags", s.templatesHandler.ListTags)
+	templates.GET("/export", s.templatesHandler.ExportTemplates)
+	templates.POST("/import", s.templatesHandler.ImportTemplates)
 	templates.GET("/:id", s.templatesHandler.GetTemplate)
 	templates.PUT("/:id", s.templatesHandler.UpdateTemplate)
 	templates.DELETE("/:id", s.templatesHandler.DeleteTemplate)
+	templates.GET("/:id/revisions", s.templatesHandler.ListRevisions)
+	templates.GET("/:id/revisions/:revision", s.templatesHandler.GetRevision)
+	templates.POST("/:id/revisions/:revision/restore", s.templatesHandler.RestoreRevision)
+	templates.GET("/:id/diff", s.templatesHandler.DiffRevisions)
+	templates.POST("/:id/render", s.templatesHandler.RenderTemplate)
+	templates.GET("/:id/shares", s.templatesHandler.ListShares)
+	templates.POST("/:id/shares", s.templatesHandler.ShareTemplate)
+	templates.DELETE("/:id/shares/:shareId", s.templatesHandler.UnshareTemplate)
 
-	// Users (admin only - for now just require auth)
+	// Users
 	usersGroup := v1.Group("/users", s.authMiddleware.RequireAuth)
 	usersGroup.GET("", s.usersHandler.ListUsers)
-	usersGroup.POST("", s.usersHandler.CreateUser)
+	usersGroup.POST("", s.usersHandler.CreateUser, auth.RequirePermission(s.userRoleStore, permissions.UsersCreate))
 	usersGroup.GET("/:id", s.usersHandler.GetUser)
-	usersGroup.PUT("/:id", s.usersHandler.UpdateUser)
-	usersGroup.DELETE("/:id", s.usersHandler.DeleteUser)
+	usersGroup.PUT("/:id", s.usersHandler.UpdateUser, auth.RequirePermission(s.userRoleStore, permissions.UsersEdit))
+	usersGroup.DELETE("/:id", s.usersHandler.DeleteUser, auth.RequirePermission(s.userRoleStore, permissions.UsersDelete))
 	usersGroup.PUT("/:id/password", s.usersHandler.UpdatePassword)
+	usersGroup.POST("/:id/verify-email", s.authHandler.SendUserVerification, auth.RequirePermission(s.userRoleStore, permissions.UsersEdit))
+	usersGroup.POST("/:id/erase", s.privacyHandler.EraseUser, auth.RequirePermission(s.userRoleStore, permissions.UsersDelete))
+
+	// Current user self-service
+	me := v1.Group("/me", s.authMiddleware.RequireAuth)
+	me.GET("/export", s.privacyHandler.ExportMyData)
+
+	// SCIM 2.0 provisioning (identity provider bearer token)
+	if s.cfg.SCIM.Enabled {
+		scimGroup := s.echo.Group("/scim/v2")
+		s.scimHandler.RegisterRoutes(scimGroup, auth.RequireBearerToken(s.cfg.SCIM.BearerToken))
+	}
+}
+
+// ipExtractor believes X-Forwarded-For only from the trusted proxy networks
+func ipExtractor(trustedProxies []string, logger *logrus.Logger) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range trustedProxies {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logger.Warnf("Ignoring invalid trusted proxy %q: %v", cidr, err)
+			continue
+		}
+		options = append(options, echo.TrustIPRange(network))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
 }
 
 func (s *Server) handleHealth(c echo.Context) error {
@@ -128,11 +268,21 @@ func (s *Server) handleReady(c echo.Context) error {
 	})
 }
 
+func (s *Server) handleMetrics(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4")
+	c.Response().WriteHeader(http.StatusOK)
+	if s.permissionCache != nil {
+		s.permissionCache.WriteMetrics(c.Response())
+	}
+	return nil
+}
+
 func (s *Server) Start(address string) error {
 	s.logger.Infof("Starting UI service on %s", address)
 	return s.echo.Start(address)
 }
 
 func (s *Server) Shutdown() error {
+	s.stopBackground()
 	return s.echo.Shutdown(nil)
 }
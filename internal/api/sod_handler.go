@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/labstack/echo/v4"
+)
+
+type SoDHandler struct {
+	sodStore      *rbac.SoDStore
+	userRoleStore *rbac.UserRoleStore
+}
+
+func NewSoDHandler(sodStore *rbac.SoDStore, userRoleStore *rbac.UserRoleStore) *SoDHandler {
+	return &SoDHandler{
+		sodStore:      sodStore,
+		userRoleStore: userRoleStore,
+	}
+}
+
+// ============================================================================
+// Separation of Duties Endpoints
+// ============================================================================
+
+// ListConstraints retrieves all separation-of-duties constraints
+// GET /api/v1/auth/sod-constraints
+func (h *SoDHandler) ListConstraints(c echo.Context) error {
+	constraints, err := h.sodStore.List(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch constraints")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"constraints": constraints,
+		"total":       len(constraints),
+	})
+}
+
+// CreateConstraint defines a set of mutually exclusive roles. Existing
+// assignments are left alone and reported by ListViolations.
+// POST /api/v1/auth/sod-constraints
+func (h *SoDHandler) CreateConstraint(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		RoleIDs     []int  `json:"role_ids"`
+	}
+
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "constraint name is required")
+	}
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	constraint, err := h.sodStore.Create(ctx, input.Name, input.Description, input.RoleIDs, user.ID)
+	if err != nil {
+		if errors.Is(err, rbac.ErrInvalidConstraint) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if isUniqueViolation(err) {
+			return echo.NewHTTPError(http.StatusConflict, "a constraint with this name already exists")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create constraint")
+	}
+
+	return c.JSON(http.StatusCreated, constraint)
+}
+
+// DeleteConstraint removes a separation-of-duties constraint
+// DELETE /api/v1/auth/sod-constraints/:id
+func (h *SoDHandler) DeleteConstraint(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid constraint ID")
+	}
+
+	if err := h.sodStore.Delete(c.Request().Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "constraint not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete constraint")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "constraint deleted"})
+}
+
+// ListViolations reports users currently holding more than one role of a
+// constraint, including assignments made before the constraint existed
+// GET /api/v1/auth/sod-constraints/violations
+func (h *SoDHandler) ListViolations(c echo.Context) error {
+	violations, err := h.sodStore.Violations(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch violations")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"violations": violations,
+		"total":      len(violations),
+	})
+}
+
+// ============================================================================
+// Route Registration
+// ============================================================================
+
+func (h *SoDHandler) RegisterRoutes(e *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	canView := auth.RequirePermission(h.userRoleStore, permissions.RolesView)
+	canEdit := auth.RequirePermission(h.userRoleStore, permissions.RolesEdit)
+	canAudit := auth.RequirePermission(h.userRoleStore, permissions.UsersView)
+
+	e.GET("/sod-constraints", h.ListConstraints, authMiddleware, canView)
+	e.POST("/sod-constraints", h.CreateConstraint, authMiddleware, canEdit)
+	e.DELETE("/sod-constraints/:id", h.DeleteConstraint, authMiddleware, canEdit)
+	e.GET("/sod-constraints/violations", h.ListViolations, authMiddleware, canAudit)
+}
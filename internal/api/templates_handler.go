@@ -2,14 +2,24 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/bwburch/inflight-ui-service/internal/auth"
 	"github.com/bwburch/inflight-ui-service/internal/storage/templates"
+	"github.com/bwburch/inflight-ui-service/internal/templateparams"
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
 	"github.com/labstack/echo/v4"
 )
 
+// maxBundleSize bounds the template bundle accepted for import
+const maxBundleSize = 4 << 20
+
 type TemplatesHandler struct {
 	store *templates.Store
 }
@@ -18,26 +28,156 @@ func NewTemplatesHandler(store *templates.Store) *TemplatesHandler {
 	return &TemplatesHandler{store: store}
 }
 
-// ListTemplates returns all templates for the current user
+// ListTemplates returns a page of the templates the current user can view,
+// searched (?q=), filtered (?owner_id=, mine=, shared=, tag=, created_after=,
+// created_before=, updated_after=, updated_before=) and sorted (?sort=, order=)
 func (h *TemplatesHandler) ListTemplates(c echo.Context) error {
-	// TODO: Get user ID from auth context
-	userID := 1 // Placeholder - will come from JWT/session
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+	userID := user.ID
+
+	opts := templates.ListOptions{
+		Query:      strings.TrimSpace(c.QueryParam("q")),
+		Mine:       c.QueryParam("mine") == "true",
+		SharedOnly: c.QueryParam("shared") == "true",
+		Sort:       c.QueryParam("sort"),
+		Limit:      50,
+	}
+
+	for _, tag := range c.QueryParams()["tag"] {
+		opts.Tags = append(opts.Tags, strings.Split(tag, ",")...)
+	}
+
+	if ownerStr := c.QueryParam("owner_id"); ownerStr != "" {
+		ownerID, err := strconv.Atoi(ownerStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "owner_id must be a user ID",
+			})
+		}
+		opts.OwnerID = &ownerID
+	}
+
+	for param, dest := range map[string]**time.Time{
+		"created_after":  &opts.CreatedAfter,
+		"created_before": &opts.CreatedBefore,
+		"updated_after":  &opts.UpdatedAfter,
+		"updated_before": &opts.UpdatedBefore,
+	} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseDateParam(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: param + " must be a date (2006-01-02) or RFC 3339 timestamp",
+			})
+		}
+		*dest = &t
+	}
 
-	templates, err := h.store.List(c.Request().Context(), userID)
+	switch c.QueryParam("order") {
+	case "":
+		opts.Ascending = opts.Sort == templates.SortName
+	case "asc":
+		opts.Ascending = true
+	case "desc":
+	default:
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "order must be asc or desc",
+		})
+	}
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			opts.Limit = l
+		}
+	}
+	if opts.Limit > 200 {
+		opts.Limit = 200
+	}
+
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			opts.Offset = o
+		}
+	}
+
+	list, total, err := h.store.List(c.Request().Context(), userID, opts)
 	if err != nil {
+		if errors.Is(err, templates.ErrInvalidListOptions) || errors.Is(err, templates.ErrInvalidTags) {
+			return templateError(c, err)
+		}
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error: "Failed to list templates",
 		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"templates": templates,
+		"templates": list,
+		"total":     total,
+		"limit":     opts.Limit,
+		"offset":    opts.Offset,
+	})
+}
+
+// ListTags returns the tags on templates the current user can view, with counts
+func (h *TemplatesHandler) ListTags(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	tags, err := h.store.ListTags(c.Request().Context(), user.ID)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"tags": tags,
+	})
+}
+
+// parseDateParam accepts a date (midnight UTC) or an RFC 3339 timestamp
+func parseDateParam(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// GetSchema returns the JSON Schema configuration_data must match
+func (h *TemplatesHandler) GetSchema(c echo.Context) error {
+	schema, err := templateschema.Schema(templateschema.CurrentVersion)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"schema_version": templateschema.CurrentVersion,
+		"schema":         schema,
 	})
 }
 
-// GetTemplate returns a specific template
+// GetTemplate returns a specific template with its version as the ETag,
+// or 304 when If-None-Match already holds that version
 func (h *TemplatesHandler) GetTemplate(c echo.Context) error {
-	userID := 1 // Placeholder
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+	userID := user.ID
 
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -48,9 +188,11 @@ func (h *TemplatesHandler) GetTemplate(c echo.Context) error {
 
 	template, err := h.store.Get(c.Request().Context(), id, userID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, ErrorResponse{
-			Error: err.Error(),
-		})
+		return templateError(c, err)
+	}
+
+	if notModified(c, template.Version) {
+		return c.NoContent(http.StatusNotModified)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -60,13 +202,20 @@ func (h *TemplatesHandler) GetTemplate(c echo.Context) error {
 
 // CreateTemplate creates a new template
 func (h *TemplatesHandler) CreateTemplate(c echo.Context) error {
-	userID := 1 // Placeholder
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+	userID := user.ID
 
 	var req struct {
 		Name         string          `json:"name"`
 		Description  string          `json:"description"`
 		ConfigurationData json.RawMessage `json:"configuration_data"`
 		IsShared     bool            `json:"is_shared"`
+		Tags         []string        `json:"tags"`
 	}
 
 	if err := c.Bind(&req); err != nil {
@@ -81,12 +230,21 @@ func (h *TemplatesHandler) CreateTemplate(c echo.Context) error {
 		})
 	}
 
+	if err := templateschema.Validate(req.ConfigurationData); err != nil {
+		return templateError(c, err)
+	}
+	if err := templateparams.Check(req.ConfigurationData); err != nil {
+		return templateError(c, err)
+	}
+
 	template, err := h.store.Create(c.Request().Context(), templates.CreateTemplateInput{
 		UserID:       userID,
 		Name:         req.Name,
 		Description:  req.Description,
 		ConfigurationData: req.ConfigurationData,
+		SchemaVersion: templateschema.CurrentVersion,
 		IsShared:     req.IsShared,
+		Tags:         req.Tags,
 	})
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -94,14 +252,23 @@ func (h *TemplatesHandler) CreateTemplate(c echo.Context) error {
 		})
 	}
 
+	setETag(c, template.Version)
 	return c.JSON(http.StatusCreated, map[string]interface{}{
 		"template": template,
 	})
 }
 
-// UpdateTemplate updates a template
+// UpdateTemplate updates a template (owner or editor; is_shared is only
+// applied for the owner). If-Match must carry the template's current ETag; a
+// stale one gets 412 with the current template.
 func (h *TemplatesHandler) UpdateTemplate(c echo.Context) error {
-	userID := 1 // Placeholder
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+	userID := user.ID
 
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -115,6 +282,7 @@ func (h *TemplatesHandler) UpdateTemplate(c echo.Context) error {
 		Description  string          `json:"description"`
 		ConfigurationData json.RawMessage `json:"configuration_data"`
 		IsShared     bool            `json:"is_shared"`
+		Tags         *[]string       `json:"tags"` // Omitted keeps the current tags
 	}
 
 	if err := c.Bind(&req); err != nil {
@@ -123,26 +291,282 @@ func (h *TemplatesHandler) UpdateTemplate(c echo.Context) error {
 		})
 	}
 
-	template, err := h.store.Update(c.Request().Context(), id, userID, templates.UpdateTemplateInput{
+	if err := templateschema.Validate(req.ConfigurationData); err != nil {
+		return templateError(c, err)
+	}
+	if err := templateparams.Check(req.ConfigurationData); err != nil {
+		return templateError(c, err)
+	}
+
+	current, err := h.store.Get(c.Request().Context(), id, userID)
+	if err != nil {
+		return templateError(c, err)
+	}
+	if current.Access == templates.AccessViewer {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error: "Template is shared with you as a viewer",
+		})
+	}
+	ifMatch := c.Request().Header.Get(headerIfMatch)
+	if ifMatch == "" {
+		return c.JSON(http.StatusPreconditionRequired, ErrorResponse{
+			Error: "If-Match header with the template's ETag is required",
+		})
+	}
+	if !matchesETag(ifMatch, current.Version) {
+		return h.templatePreconditionFailed(c, id, userID)
+	}
+
+	input := templates.UpdateTemplateInput{
 		Name:         req.Name,
 		Description:  req.Description,
 		ConfigurationData: req.ConfigurationData,
+		SchemaVersion: templateschema.CurrentVersion,
 		IsShared:     req.IsShared,
+		Tags:         current.Tags,
+		Version:      current.Version,
+	}
+	if req.Tags != nil {
+		input.Tags = *req.Tags
+	}
+
+	template, err := h.store.Update(c.Request().Context(), id, userID, input)
+	if errors.Is(err, templates.ErrVersionMismatch) {
+		return h.templatePreconditionFailed(c, id, userID)
+	}
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	setETag(c, template.Version)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"template": template,
 	})
+}
+
+// DeleteTemplate deletes a template (owner only). If-Match must carry the
+// template's current ETag; a stale one gets 412 with the current template.
+func (h *TemplatesHandler) DeleteTemplate(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+	userID := user.ID
+
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error: err.Error(),
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid template ID",
+		})
+	}
+
+	current, err := h.store.Get(c.Request().Context(), id, userID)
+	if err != nil {
+		return templateError(c, err)
+	}
+	if current.Access != templates.AccessOwner {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error: "Only the owner can delete a template",
+		})
+	}
+	ifMatch := c.Request().Header.Get(headerIfMatch)
+	if ifMatch == "" {
+		return c.JSON(http.StatusPreconditionRequired, ErrorResponse{
+			Error: "If-Match header with the template's ETag is required",
+		})
+	}
+	if !matchesETag(ifMatch, current.Version) {
+		return h.templatePreconditionFailed(c, id, userID)
+	}
+
+	err = h.store.Delete(c.Request().Context(), id, userID, current.Version)
+	if errors.Is(err, templates.ErrVersionMismatch) {
+		return h.templatePreconditionFailed(c, id, userID)
+	}
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListRevisions returns a template's revision history, newest first
+func (h *TemplatesHandler) ListRevisions(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid template ID",
+		})
+	}
+
+	revisions, err := h.store.ListRevisions(c.Request().Context(), id, user.ID)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"revisions": revisions,
+	})
+}
+
+// GetRevision returns one revision of a template with its full configuration
+func (h *TemplatesHandler) GetRevision(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid template ID",
+		})
+	}
+
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid revision",
+		})
+	}
+
+	rev, err := h.store.GetRevision(c.Request().Context(), id, revision, user.ID)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"revision": rev,
+	})
+}
+
+// DiffRevisions compares the proposed_changes of two revisions (?from=&to=,
+// to defaulting to the current revision)
+func (h *TemplatesHandler) DiffRevisions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid template ID",
+		})
+	}
+
+	from, err := strconv.Atoi(c.QueryParam("from"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "from must be a revision number",
+		})
+	}
+
+	var to int
+	if v := c.QueryParam("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "to must be a revision number",
+			})
+		}
+	} else {
+		template, err := h.store.Get(ctx, id, user.ID)
+		if err != nil {
+			return templateError(c, err)
+		}
+		to = template.Revision
+	}
+
+	diff, err := h.store.DiffRevisions(ctx, id, from, to, user.ID)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"diff": diff,
+	})
+}
+
+// RestoreRevision makes an old revision the template's content again, as a new revision
+func (h *TemplatesHandler) RestoreRevision(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid template ID",
+		})
+	}
+
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid revision",
+		})
+	}
+
+	current, err := h.store.Get(c.Request().Context(), id, user.ID)
+	if err != nil {
+		return templateError(c, err)
+	}
+	if current.Access == templates.AccessViewer {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error: "Template is shared with you as a viewer",
+		})
+	}
+	ifMatch := c.Request().Header.Get(headerIfMatch)
+	if ifMatch == "" {
+		return c.JSON(http.StatusPreconditionRequired, ErrorResponse{
+			Error: "If-Match header with the template's ETag is required",
 		})
 	}
+	if !matchesETag(ifMatch, current.Version) {
+		return h.templatePreconditionFailed(c, id, user.ID)
+	}
 
+	template, err := h.store.Restore(c.Request().Context(), id, revision, user.ID, current.Version)
+	if errors.Is(err, templates.ErrVersionMismatch) {
+		return h.templatePreconditionFailed(c, id, user.ID)
+	}
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	setETag(c, template.Version)
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"template": template,
 	})
 }
 
-// DeleteTemplate deletes a template
-func (h *TemplatesHandler) DeleteTemplate(c echo.Context) error {
-	userID := 1 // Placeholder
+// RenderTemplate substitutes arguments for a template's parameters and
+// returns the concrete configuration for the workbench
+func (h *TemplatesHandler) RenderTemplate(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
 
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -151,15 +575,303 @@ func (h *TemplatesHandler) DeleteTemplate(c echo.Context) error {
 		})
 	}
 
-	if err := h.store.Delete(c.Request().Context(), id, userID); err != nil {
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error: err.Error(),
+	var req struct {
+		Arguments map[string]json.RawMessage `json:"arguments"`
+	}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+
+	template, err := h.store.Get(c.Request().Context(), id, user.ID)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	rendered, err := templateparams.Render(template.ConfigurationData, req.Arguments)
+	if err != nil {
+		return templateError(c, err)
+	}
+	if err := templateschema.Validate(rendered.Configuration); err != nil {
+		return templateError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"template_id":        template.ID,
+		"revision":           template.Revision,
+		"configuration_data": rendered.Configuration,
+		"arguments":          rendered.Arguments,
+	})
+}
+
+// ExportTemplates downloads templates as a portable bundle, as JSON (default)
+// or YAML (?format=yaml). ?id= (repeated or comma-separated) picks templates the
+// user can view; without it every template the user owns is exported.
+func (h *TemplatesHandler) ExportTemplates(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "yaml" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Format must be json or yaml",
+		})
+	}
+
+	var ids []int
+	for _, param := range c.QueryParams()["id"] {
+		for _, part := range strings.Split(param, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, ErrorResponse{
+					Error: "Invalid template ID",
+				})
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	bundle, err := h.store.Export(c.Request().Context(), user.ID, ids)
+	if err != nil {
+		return templateError(c, err)
+	}
+	bundle.ExportedBy = user.Username
+
+	filename := "quick-templates-" + bundle.ExportedAt.Format("20060102")
+	if format != "yaml" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		return c.JSON(http.StatusOK, bundle)
+	}
+
+	data, err := bundle.EncodeYAML()
+	if err != nil {
+		return templateError(c, err)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.yaml"`, filename))
+	return c.Blob(http.StatusOK, "application/yaml", data)
+}
+
+// ImportTemplates creates the templates in a bundle (YAML or JSON body), owned
+// by the current user. ?conflict= handles a template named like one the user
+// owns: skip (default), overwrite or rename. ?dry_run=true previews the import
+// without saving. An invalid template fails the whole import with 422.
+func (h *TemplatesHandler) ImportTemplates(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	conflict := c.QueryParam("conflict")
+	switch conflict {
+	case "":
+		conflict = templates.ConflictSkip
+	case templates.ConflictSkip, templates.ConflictOverwrite, templates.ConflictRename:
+	default:
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Conflict must be skip, overwrite or rename",
+		})
+	}
+	dryRun := c.QueryParam("dry_run") == "true"
+
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxBundleSize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Failed to read bundle",
+		})
+	}
+	if len(data) > maxBundleSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error: "Bundle is too large",
+		})
+	}
+
+	// YAML is a superset of JSON, so either content type parses
+	bundle, err := templates.ParseBundle(data)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	result, err := h.store.Import(c.Request().Context(), bundle, user.ID, conflict, dryRun)
+	if err != nil {
+		return templateError(c, err)
+	}
+	if result.Invalid > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":  fmt.Sprintf("%d of %d templates are invalid; nothing was imported", result.Invalid, len(result.Templates)),
+			"import": result,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"import": result,
+	})
+}
+
+// ListShares returns who a template is shared with
+func (h *TemplatesHandler) ListShares(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid template ID",
+		})
+	}
+
+	template, err := h.store.Get(ctx, id, user.ID)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	shares, err := h.store.ListShares(ctx, id, user.ID)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"owner_id": template.UserID,
+		"public":   template.IsShared,
+		"shares":   shares,
+	})
+}
+
+// ShareTemplate shares a template with a user or group as viewer or editor,
+// or changes the level of an existing share (owner only)
+func (h *TemplatesHandler) ShareTemplate(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid template ID",
+		})
+	}
+
+	var req struct {
+		UserID  *int   `json:"user_id"`
+		GroupID *int   `json:"group_id"`
+		Level   string `json:"level"`
+	}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
 		})
 	}
 
+	share, err := h.store.Share(c.Request().Context(), id, user.ID, templates.ShareInput{
+		UserID:  req.UserID,
+		GroupID: req.GroupID,
+		Level:   req.Level,
+	})
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"share": share,
+	})
+}
+
+// UnshareTemplate removes a share (owner only)
+func (h *TemplatesHandler) UnshareTemplate(c echo.Context) error {
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "User not authenticated",
+		})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid template ID",
+		})
+	}
+
+	shareID, err := strconv.Atoi(c.Param("shareId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid share ID",
+		})
+	}
+
+	if err := h.store.Unshare(c.Request().Context(), id, shareID, user.ID); err != nil {
+		return templateError(c, err)
+	}
+
 	return c.NoContent(http.StatusNoContent)
 }
 
+// templatePreconditionFailed answers a write made against a stale version
+// with 412 and the template as it is now
+func (h *TemplatesHandler) templatePreconditionFailed(c echo.Context, id, userID int) error {
+	template, err := h.store.Get(c.Request().Context(), id, userID)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	setETag(c, template.Version)
+	return c.JSON(http.StatusPreconditionFailed, map[string]interface{}{
+		"error":    "Template was modified since it was read",
+		"template": template,
+	})
+}
+
+// templateError maps template store errors to responses
+func templateError(c echo.Context, err error) error {
+	if errors.Is(err, templates.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	if errors.Is(err, templates.ErrInvalidShare) || errors.Is(err, templates.ErrGranteeNotFound) ||
+		errors.Is(err, templates.ErrInvalidTags) || errors.Is(err, templates.ErrInvalidListOptions) ||
+		errors.Is(err, templates.ErrInvalidBundle) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	var badArgs *templateparams.ArgumentError
+	if errors.As(err, &badArgs) {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":  "Invalid arguments",
+			"errors": badArgs.Errors,
+		})
+	}
+	var invalid *templateschema.ValidationError
+	if errors.As(err, &invalid) {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":          fmt.Sprintf("Configuration does not match schema v%d", invalid.Version),
+			"schema_version": invalid.Version,
+			"errors":         invalid.Errors,
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error: err.Error(),
+	})
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
@@ -2,21 +2,31 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/bwburch/inflight-ui-service/internal/auth"
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
 	"github.com/bwburch/inflight-ui-service/internal/storage/users"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 )
 
 type UsersHandler struct {
-	store *users.Store
+	store         *users.Store
+	userRoleStore *rbac.UserRoleStore
 }
 
-func NewUsersHandler(store *users.Store) *UsersHandler {
-	return &UsersHandler{store: store}
+func NewUsersHandler(store *users.Store, userRoleStore *rbac.UserRoleStore) *UsersHandler {
+	return &UsersHandler{store: store, userRoleStore: userRoleStore}
 }
 
+// errRoleField rejects the legacy role field: roles are only assigned through
+// the RBAC endpoints, which check escalation, SoD and email verification
+var errRoleField = echo.NewHTTPError(http.StatusBadRequest, "role is not accepted here; assign roles with POST /api/v1/auth/users/:id/roles")
+
 // ListUsers returns all users with pagination
 // GET /api/v1/users?role=admin&is_active=true&limit=20&offset=0
 func (h *UsersHandler) ListUsers(c echo.Context) error {
@@ -78,7 +88,7 @@ func (h *UsersHandler) GetUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, user)
 }
 
-// CreateUser creates a new user
+// CreateUser creates a new user, without roles
 // POST /api/v1/users
 func (h *UsersHandler) CreateUser(c echo.Context) error {
 	var input struct {
@@ -86,7 +96,7 @@ func (h *UsersHandler) CreateUser(c echo.Context) error {
 		Email    string `json:"email" validate:"required,email"`
 		FullName string `json:"full_name"`
 		Password string `json:"password" validate:"required,min=8"`
-		Role     string `json:"role"`
+		Role     string `json:"role"` // Rejected
 	}
 
 	if err := c.Bind(&input); err != nil {
@@ -104,14 +114,8 @@ func (h *UsersHandler) CreateUser(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "password must be at least 8 characters")
 	}
 
-	// Default role to 'user' if not specified
-	if input.Role == "" {
-		input.Role = "user"
-	}
-
-	// Validate role
-	if input.Role != "admin" && input.Role != "user" && input.Role != "viewer" {
-		return echo.NewHTTPError(http.StatusBadRequest, "role must be admin, user, or viewer")
+	if input.Role != "" {
+		return errRoleField
 	}
 
 	user, err := h.store.Create(c.Request().Context(), users.CreateUserInput{
@@ -119,10 +123,12 @@ func (h *UsersHandler) CreateUser(c echo.Context) error {
 		Email:    input.Email,
 		FullName: input.FullName,
 		Password: input.Password,
-		Role:     input.Role,
 	})
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return echo.NewHTTPError(http.StatusConflict, "username or email is already in use")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
@@ -140,7 +146,7 @@ func (h *UsersHandler) UpdateUser(c echo.Context) error {
 	var input struct {
 		Email    *string `json:"email,omitempty"`
 		FullName *string `json:"full_name,omitempty"`
-		Role     *string `json:"role,omitempty"`
+		Role     *string `json:"role,omitempty"` // Rejected
 		IsActive *bool   `json:"is_active,omitempty"`
 	}
 
@@ -148,22 +154,23 @@ func (h *UsersHandler) UpdateUser(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	// Validate role if provided
 	if input.Role != nil {
-		role := *input.Role
-		if role != "admin" && role != "user" && role != "viewer" {
-			return echo.NewHTTPError(http.StatusBadRequest, "role must be admin, user, or viewer")
-		}
+		return errRoleField
 	}
 
 	user, err := h.store.Update(c.Request().Context(), id, users.UpdateUserInput{
 		Email:    input.Email,
 		FullName: input.FullName,
-		Role:     input.Role,
 		IsActive: input.IsActive,
 	})
 
 	if err != nil {
+		if err == rbac.ErrLastAdmin {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		if isUniqueViolation(err) {
+			return echo.NewHTTPError(http.StatusConflict, "email is already in use")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
@@ -191,13 +198,17 @@ func (h *UsersHandler) DeleteUser(c echo.Context) error {
 		if err == sql.ErrNoRows {
 			return echo.NewHTTPError(http.StatusNotFound, "user not found")
 		}
+		if err == rbac.ErrLastAdmin {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
 	return c.JSON(http.StatusOK, map[string]bool{"success": true})
 }
 
-// UpdatePassword changes a user's password
+// UpdatePassword changes a user's password. Users may change their own;
+// anyone else's requires users.edit.
 // PUT /api/v1/users/:id/password
 func (h *UsersHandler) UpdatePassword(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -205,6 +216,20 @@ func (h *UsersHandler) UpdatePassword(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
 	}
 
+	user := auth.GetUserFromContext(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "not authenticated")
+	}
+	if user.ID != id {
+		allowed, err := auth.HasPermission(c, h.userRoleStore, permissions.UsersEdit)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
+		}
+		if !allowed {
+			return echo.NewHTTPError(http.StatusForbidden, "changing another user's password requires users.edit")
+		}
+	}
+
 	var input struct {
 		Password string `json:"password" validate:"required,min=8"`
 	}
@@ -227,3 +252,9 @@ func (h *UsersHandler) UpdatePassword(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, map[string]bool{"success": true})
 }
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
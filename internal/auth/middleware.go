@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"context"
 	"net/http"
+	"time"
 
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
 	"github.com/bwburch/inflight-ui-service/internal/storage/sessions"
 	"github.com/bwburch/inflight-ui-service/internal/storage/users"
 	"github.com/labstack/echo/v4"
@@ -11,12 +14,19 @@ import (
 const (
 	SessionCookieName = "session_id"
 	UserContextKey    = "user"
+	SessionContextKey = "session"
 )
 
+// UserCache caches users looked up by the middleware, calling load on a miss
+type UserCache interface {
+	GetUser(ctx context.Context, userID int, load func(context.Context) (*users.User, error)) (*users.User, error)
+}
+
 // Middleware handles session authentication
 type Middleware struct {
 	sessionStore *sessions.Store
 	userStore    *users.Store
+	userCache    UserCache
 }
 
 // NewMiddleware creates authentication middleware
@@ -27,6 +37,21 @@ func NewMiddleware(sessionStore *sessions.Store, userStore *users.Store) *Middle
 	}
 }
 
+// SetUserCache avoids a database lookup per request for recently seen users
+func (m *Middleware) SetUserCache(cache UserCache) {
+	m.userCache = cache
+}
+
+// getUser loads the session's user, through the cache when configured
+func (m *Middleware) getUser(ctx context.Context, userID int) (*users.User, error) {
+	if m.userCache == nil {
+		return m.userStore.Get(ctx, userID)
+	}
+	return m.userCache.GetUser(ctx, userID, func(ctx context.Context) (*users.User, error) {
+		return m.userStore.Get(ctx, userID)
+	})
+}
+
 // RequireAuth validates session and injects user into context
 func (m *Middleware) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -51,8 +76,8 @@ func (m *Middleware) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired session")
 		}
 
-		// Get user from database
-		user, err := m.userStore.Get(c.Request().Context(), session.UserID)
+		// Get user (cached, falling back to the database)
+		user, err := m.getUser(c.Request().Context(), session.UserID)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, "user lookup failed")
 		}
@@ -67,8 +92,9 @@ func (m *Middleware) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
 			c.Logger().Warn("failed to update session activity:", err)
 		}
 
-		// Inject user into context
+		// Inject user and session into context
 		c.Set(UserContextKey, user)
+		c.Set(SessionContextKey, session)
 
 		return next(c)
 	}
@@ -81,9 +107,10 @@ func (m *Middleware) OptionalAuth(next echo.HandlerFunc) echo.HandlerFunc {
 		if err == nil && cookie.Value != "" {
 			session, err := m.sessionStore.Get(c.Request().Context(), cookie.Value)
 			if err == nil && session != nil {
-				user, err := m.userStore.Get(c.Request().Context(), session.UserID)
+				user, err := m.getUser(c.Request().Context(), session.UserID)
 				if err == nil && user != nil && user.IsActive {
 					c.Set(UserContextKey, user)
+					c.Set(SessionContextKey, session)
 					m.sessionStore.UpdateActivity(c.Request().Context(), cookie.Value)
 				}
 			}
@@ -92,6 +119,16 @@ func (m *Middleware) OptionalAuth(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+// GetRequestContext describes the current request (time, client address and
+// session) for evaluating access conditions
+func GetRequestContext(c echo.Context) *rbac.RequestContext {
+	rc := &rbac.RequestContext{Time: time.Now(), SourceIP: c.RealIP()}
+	if session, ok := c.Get(SessionContextKey).(*sessions.Session); ok && session != nil {
+		rc.SessionStartedAt = &session.CreatedAt
+	}
+	return rc
+}
+
 // GetUserFromContext extracts the authenticated user from context
 func GetUserFromContext(c echo.Context) *users.User {
 	user, ok := c.Get(UserContextKey).(*users.User)
@@ -3,13 +3,17 @@ package auth
 import (
 	"net/http"
 
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
 	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
 	"github.com/bwburch/inflight-ui-service/internal/storage/users"
 	"github.com/labstack/echo/v4"
 )
 
-// PermissionMiddleware creates middleware that checks if the user has required permission(s)
-func PermissionMiddleware(userRoleStore *rbac.UserRoleStore, permission string) echo.MiddlewareFunc {
+// PermissionMiddleware creates middleware that checks if the user has required permission(s).
+// It panics if the permission was never declared in the permissions registry.
+func PermissionMiddleware(userRoleStore *rbac.UserRoleStore, permission permissions.Permission) echo.MiddlewareFunc {
+	permissions.MustBeDeclared(permission)
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// Get user from context (set by AuthMiddleware)
@@ -18,26 +22,41 @@ func PermissionMiddleware(userRoleStore *rbac.UserRoleStore, permission string)
 				return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
 			}
 
-			ctx := c.Request().Context()
-
-			// Check if user is admin (bypass permission check)
-			isAdmin, err := userRoleStore.IsAdmin(ctx, user.ID)
+			// Resolve the user's policy (admins are allowed everything, deny overrides allow,
+			// conditional grants only apply when this request meets their conditions)
+			policy, err := userRoleStore.LoadPolicy(c.Request().Context(), user.ID)
 			if err != nil {
 				return echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
 			}
 
-			if isAdmin {
-				// Admins have all permissions
-				return next(c)
+			if !policy.AllowsWith(string(permission), GetRequestContext(c)) {
+				return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// AnyPermissionMiddleware checks if user has ANY of the specified permissions
+func AnyPermissionMiddleware(userRoleStore *rbac.UserRoleStore, required []permissions.Permission) echo.MiddlewareFunc {
+	permissions.MustBeDeclared(required...)
+	names := permissions.Strings(required)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, ok := c.Get("user").(*users.User)
+			if !ok || user == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
 			}
 
-			// Check if user has the required permission
-			hasPermission, err := userRoleStore.CheckPermission(ctx, user.ID, permission)
+			policy, err := userRoleStore.LoadPolicy(c.Request().Context(), user.ID)
 			if err != nil {
 				return echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
 			}
 
-			if !hasPermission {
+			// Check if user has any of the required permissions
+			if !policy.AllowsAnyWith(names, GetRequestContext(c)) {
 				return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
 			}
 
@@ -46,8 +65,11 @@ func PermissionMiddleware(userRoleStore *rbac.UserRoleStore, permission string)
 	}
 }
 
-// AnyPermissionMiddleware checks if user has ANY of the specified permissions
-func AnyPermissionMiddleware(userRoleStore *rbac.UserRoleStore, permissions []string) echo.MiddlewareFunc {
+// ScopedPermissionMiddleware checks if the user has a permission on the resource
+// identified by the route parameter param (globally or via a matching scoped grant)
+func ScopedPermissionMiddleware(userRoleStore *rbac.UserRoleStore, permission permissions.Permission, resourceType, param string) echo.MiddlewareFunc {
+	permissions.MustBeDeclared(permission)
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			user, ok := c.Get("user").(*users.User)
@@ -55,25 +77,18 @@ func AnyPermissionMiddleware(userRoleStore *rbac.UserRoleStore, permissions []st
 				return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
 			}
 
-			ctx := c.Request().Context()
-
-			// Check if admin
-			isAdmin, err := userRoleStore.IsAdmin(ctx, user.ID)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
-			}
-
-			if isAdmin {
-				return next(c)
+			resourceID := c.Param(param)
+			if resourceID == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "missing resource identifier")
 			}
 
-			// Check if user has any of the required permissions
-			hasAny, err := userRoleStore.CheckAnyPermission(ctx, user.ID, permissions)
+			policy, err := userRoleStore.LoadPolicy(c.Request().Context(), user.ID)
 			if err != nil {
 				return echo.NewHTTPError(http.StatusInternalServerError, "permission check failed")
 			}
 
-			if !hasAny {
+			resource := &rbac.Resource{Type: resourceType, ID: resourceID}
+			if !policy.DecideWith(string(permission), resource, GetRequestContext(c)).Allowed {
 				return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
 			}
 
@@ -82,12 +97,32 @@ func AnyPermissionMiddleware(userRoleStore *rbac.UserRoleStore, permissions []st
 	}
 }
 
+// HasPermission reports whether the authenticated user holds permission
+// globally for the current request, for checks made inside a handler
+func HasPermission(c echo.Context, userRoleStore *rbac.UserRoleStore, permission permissions.Permission) (bool, error) {
+	user := GetUserFromContext(c)
+	if user == nil {
+		return false, nil
+	}
+
+	policy, err := userRoleStore.LoadPolicy(c.Request().Context(), user.ID)
+	if err != nil {
+		return false, err
+	}
+	return policy.AllowsWith(string(permission), GetRequestContext(c)), nil
+}
+
 // RequirePermission is a helper function to create permission middleware
-func RequirePermission(userRoleStore *rbac.UserRoleStore, permission string) echo.MiddlewareFunc {
+func RequirePermission(userRoleStore *rbac.UserRoleStore, permission permissions.Permission) echo.MiddlewareFunc {
 	return PermissionMiddleware(userRoleStore, permission)
 }
 
 // RequireAnyPermission is a helper function to create "any permission" middleware
-func RequireAnyPermission(userRoleStore *rbac.UserRoleStore, permissions ...string) echo.MiddlewareFunc {
-	return AnyPermissionMiddleware(userRoleStore, permissions)
+func RequireAnyPermission(userRoleStore *rbac.UserRoleStore, required ...permissions.Permission) echo.MiddlewareFunc {
+	return AnyPermissionMiddleware(userRoleStore, required)
+}
+
+// RequireScopedPermission is a helper function to create resource-scoped permission middleware
+func RequireScopedPermission(userRoleStore *rbac.UserRoleStore, permission permissions.Permission, resourceType, param string) echo.MiddlewareFunc {
+	return ScopedPermissionMiddleware(userRoleStore, permission, resourceType, param)
 }
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequireBearerToken authenticates machine clients (e.g. an identity provider)
+// using a static shared token in the Authorization header
+func RequireBearerToken(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			provided, found := strings.CutPrefix(header, "Bearer ")
+			if !found || provided == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "bearer token required")
+			}
+
+			// Constant-time comparison to avoid leaking the token via timing
+			if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid bearer token")
+			}
+
+			return next(c)
+		}
+	}
+}
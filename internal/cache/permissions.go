@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/bwburch/inflight-ui-service/internal/storage/users"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// InvalidationChannel is the Redis pub/sub channel replicas use to drop
+	// each other's in-process entries
+	InvalidationChannel = "rbac:invalidate"
+
+	policyPrefix      = "rbac:policy:"
+	versionPrefix     = "rbac:policy_version:"
+	globalVersionKey  = versionPrefix + "global"
+	invalidateAllBody = "all"
+)
+
+// Options configures a PermissionCache
+type Options struct {
+	Size     int           // Max in-process entries per kind (policies, users)
+	LocalTTL time.Duration // Upper bound on staleness if an invalidation message is missed
+	RedisTTL time.Duration
+}
+
+// Entries of both tiers are also capped at the policy's ExpiresAt, so a role
+// assignment stops applying when it expires even though nothing invalidates it.
+
+// PermissionCache caches resolved RBAC policies in an in-process LRU backed by
+// Redis, and authenticated users in-process only (they carry password hashes).
+//
+// Redis entries are keyed by a global and a per-user version; invalidating
+// bumps the version, so stale Redis entries are never read again and simply
+// expire. In-process entries are dropped directly and on every other replica
+// via pub/sub.
+type PermissionCache struct {
+	redis    *redis.Client
+	logger   *logrus.Logger
+	policies *LRU[int, *rbac.Policy]
+	users    *LRU[int, *users.User]
+	redisTTL time.Duration
+	instance string // Identifies this replica's own pub/sub messages
+
+	// generation is bumped by every invalidation; a value loaded before an
+	// invalidation is not stored in-process afterwards
+	generation atomic.Uint64
+
+	policyLocalHits     atomic.Uint64
+	policyRedisHits     atomic.Uint64
+	policyMisses        atomic.Uint64
+	userHits            atomic.Uint64
+	userMisses          atomic.Uint64
+	localInvalidations  atomic.Uint64
+	remoteInvalidations atomic.Uint64
+	redisErrors         atomic.Uint64
+}
+
+// NewPermissionCache creates a permission cache. Call Run to receive
+// invalidations from other replicas.
+func NewPermissionCache(redisClient *redis.Client, logger *logrus.Logger, opts Options) *PermissionCache {
+	if opts.Size <= 0 {
+		opts.Size = 10000
+	}
+	if opts.LocalTTL <= 0 {
+		opts.LocalTTL = 30 * time.Second
+	}
+	if opts.RedisTTL <= 0 {
+		opts.RedisTTL = 5 * time.Minute
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return &PermissionCache{
+		redis:    redisClient,
+		logger:   logger,
+		policies: NewLRU[int, *rbac.Policy](opts.Size, opts.LocalTTL),
+		users:    NewLRU[int, *users.User](opts.Size, opts.LocalTTL),
+		redisTTL: opts.RedisTTL,
+		instance: hex.EncodeToString(id),
+	}
+}
+
+// GetPolicy returns the user's cached policy, loading and caching it on a miss.
+// Redis errors are logged and fall through to load. A policy is never cached
+// or served past its ExpiresAt.
+func (c *PermissionCache) GetPolicy(ctx context.Context, userID int, load func(context.Context) (*rbac.Policy, error)) (*rbac.Policy, error) {
+	if policy, ok := c.policies.Get(userID); ok {
+		c.policyLocalHits.Add(1)
+		return policy, nil
+	}
+
+	generation := c.generation.Load()
+
+	key, err := c.policyKey(ctx, userID)
+	if err != nil {
+		c.redisError("read policy version", err)
+	} else {
+		data, err := c.redis.Get(ctx, key).Bytes()
+		if err == nil {
+			var policy rbac.Policy
+			if err := json.Unmarshal(data, &policy); err == nil && !expired(&policy) {
+				c.policyRedisHits.Add(1)
+				c.storePolicy(generation, userID, &policy)
+				return &policy, nil
+			}
+		} else if err != redis.Nil {
+			c.redisError("read policy", err)
+		}
+	}
+
+	c.policyMisses.Add(1)
+	policy, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := c.redisTTL
+	if policy.ExpiresAt != nil {
+		ttl = min(ttl, time.Until(*policy.ExpiresAt))
+	}
+	if key != "" && ttl > 0 {
+		if data, err := json.Marshal(policy); err == nil {
+			if err := c.redis.Set(ctx, key, data, ttl).Err(); err != nil {
+				c.redisError("write policy", err)
+			}
+		}
+	}
+	c.storePolicy(generation, userID, policy)
+
+	return policy, nil
+}
+
+// GetUser returns a copy of the cached user, loading and caching it on a miss.
+// A nil user from load (not found) is not cached.
+func (c *PermissionCache) GetUser(ctx context.Context, userID int, load func(context.Context) (*users.User, error)) (*users.User, error) {
+	if user, ok := c.users.Get(userID); ok {
+		c.userHits.Add(1)
+		copied := *user
+		return &copied, nil
+	}
+
+	c.userMisses.Add(1)
+	generation := c.generation.Load()
+
+	user, err := load(ctx)
+	if err != nil || user == nil {
+		return user, err
+	}
+
+	if c.generation.Load() == generation {
+		copied := *user
+		c.users.Set(userID, &copied)
+	}
+	return user, nil
+}
+
+// InvalidateUser drops a user's cached policy and user record on every replica
+func (c *PermissionCache) InvalidateUser(ctx context.Context, userID int) {
+	c.localInvalidations.Add(1)
+	c.dropUser(userID)
+
+	if err := c.redis.Incr(ctx, versionPrefix+strconv.Itoa(userID)).Err(); err != nil {
+		c.redisError("bump user version", err)
+	}
+	c.publish(ctx, strconv.Itoa(userID))
+}
+
+// InvalidateAll drops every cached policy and user on every replica
+func (c *PermissionCache) InvalidateAll(ctx context.Context) {
+	c.localInvalidations.Add(1)
+	c.dropAll()
+
+	if err := c.redis.Incr(ctx, globalVersionKey).Err(); err != nil {
+		c.redisError("bump global version", err)
+	}
+	c.publish(ctx, invalidateAllBody)
+}
+
+// Run applies invalidations published by other replicas until ctx is done
+func (c *PermissionCache) Run(ctx context.Context) {
+	pubsub := c.redis.Subscribe(ctx, InvalidationChannel)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			c.handleMessage(msg.Payload)
+		}
+	}
+}
+
+// WriteMetrics writes cache counters in the Prometheus text exposition format
+func (c *PermissionCache) WriteMetrics(w io.Writer) {
+	fmt.Fprintln(w, "# HELP ui_service_permission_cache_requests_total Permission cache lookups by cache and result.")
+	fmt.Fprintln(w, "# TYPE ui_service_permission_cache_requests_total counter")
+	fmt.Fprintf(w, "ui_service_permission_cache_requests_total{cache=\"policy\",result=\"local_hit\"} %d\n", c.policyLocalHits.Load())
+	fmt.Fprintf(w, "ui_service_permission_cache_requests_total{cache=\"policy\",result=\"redis_hit\"} %d\n", c.policyRedisHits.Load())
+	fmt.Fprintf(w, "ui_service_permission_cache_requests_total{cache=\"policy\",result=\"miss\"} %d\n", c.policyMisses.Load())
+	fmt.Fprintf(w, "ui_service_permission_cache_requests_total{cache=\"user\",result=\"local_hit\"} %d\n", c.userHits.Load())
+	fmt.Fprintf(w, "ui_service_permission_cache_requests_total{cache=\"user\",result=\"miss\"} %d\n", c.userMisses.Load())
+
+	fmt.Fprintln(w, "# HELP ui_service_permission_cache_invalidations_total Invalidations by origin (this replica or another via pub/sub).")
+	fmt.Fprintln(w, "# TYPE ui_service_permission_cache_invalidations_total counter")
+	fmt.Fprintf(w, "ui_service_permission_cache_invalidations_total{origin=\"local\"} %d\n", c.localInvalidations.Load())
+	fmt.Fprintf(w, "ui_service_permission_cache_invalidations_total{origin=\"remote\"} %d\n", c.remoteInvalidations.Load())
+
+	fmt.Fprintln(w, "# HELP ui_service_permission_cache_redis_errors_total Redis errors (lookups fall back to the database).")
+	fmt.Fprintln(w, "# TYPE ui_service_permission_cache_redis_errors_total counter")
+	fmt.Fprintf(w, "ui_service_permission_cache_redis_errors_total %d\n", c.redisErrors.Load())
+
+	fmt.Fprintln(w, "# HELP ui_service_permission_cache_entries In-process cache entries by cache.")
+	fmt.Fprintln(w, "# TYPE ui_service_permission_cache_entries gauge")
+	fmt.Fprintf(w, "ui_service_permission_cache_entries{cache=\"policy\"} %d\n", c.policies.Len())
+	fmt.Fprintf(w, "ui_service_permission_cache_entries{cache=\"user\"} %d\n", c.users.Len())
+}
+
+// policyKey builds the versioned Redis key for a user's policy
+func (c *PermissionCache) policyKey(ctx context.Context, userID int) (string, error) {
+	versions, err := c.redis.MGet(ctx, globalVersionKey, versionPrefix+strconv.Itoa(userID)).Result()
+	if err != nil {
+		return "", err
+	}
+
+	version := func(v interface{}) string {
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return "0"
+	}
+	return fmt.Sprintf("%s%d:%s:%s", policyPrefix, userID, version(versions[0]), version(versions[1])), nil
+}
+
+func (c *PermissionCache) storePolicy(generation uint64, userID int, policy *rbac.Policy) {
+	if c.generation.Load() != generation || expired(policy) {
+		return
+	}
+	var deadline time.Time
+	if policy.ExpiresAt != nil {
+		deadline = *policy.ExpiresAt
+	}
+	c.policies.SetUntil(userID, policy, deadline)
+}
+
+// expired reports whether one of the policy's assignments has lapsed since it was loaded
+func expired(policy *rbac.Policy) bool {
+	return policy.ExpiresAt != nil && !time.Now().Before(*policy.ExpiresAt)
+}
+
+func (c *PermissionCache) dropUser(userID int) {
+	c.generation.Add(1)
+	c.policies.Delete(userID)
+	c.users.Delete(userID)
+}
+
+func (c *PermissionCache) dropAll() {
+	c.generation.Add(1)
+	c.policies.Purge()
+	c.users.Purge()
+}
+
+// publish broadcasts "<instance> <target>" where target is a user ID or "all"
+func (c *PermissionCache) publish(ctx context.Context, target string) {
+	if err := c.redis.Publish(ctx, InvalidationChannel, c.instance+" "+target).Err(); err != nil {
+		c.redisError("publish invalidation", err)
+	}
+}
+
+func (c *PermissionCache) handleMessage(payload string) {
+	instance, target, ok := strings.Cut(payload, " ")
+	if !ok || instance == c.instance {
+		return
+	}
+
+	c.remoteInvalidations.Add(1)
+	if target == invalidateAllBody {
+		c.dropAll()
+		return
+	}
+
+	userID, err := strconv.Atoi(target)
+	if err != nil {
+		c.logger.Warnf("ignoring malformed permission cache invalidation %q", payload)
+		return
+	}
+	c.dropUser(userID)
+}
+
+func (c *PermissionCache) redisError(op string, err error) {
+	c.redisErrors.Add(1)
+	c.logger.WithError(err).Warnf("permission cache: %s", op)
+}
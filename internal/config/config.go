@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,11 +14,19 @@ type Config struct {
 	Redis      RedisConfig      `yaml:"redis"`
 	Logging    LoggingConfig    `yaml:"logging"`
 	Migrations MigrationsConfig `yaml:"migrations"`
+	SCIM       SCIMConfig       `yaml:"scim"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Cache      CacheConfig      `yaml:"cache"`
+	Jobs       JobsConfig       `yaml:"jobs"`
 }
 
 type ServerConfig struct {
 	Port string `yaml:"port"`
 	Host string `yaml:"host"`
+	// TrustedProxies are the CIDRs whose X-Forwarded-For header is believed
+	// when resolving the client address for source CIDR access conditions;
+	// when empty the connection's remote address is used
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
 type DatabaseConfig struct {
@@ -48,6 +57,40 @@ type MigrationsConfig struct {
 	Path    string `yaml:"path"`
 }
 
+type SCIMConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	BearerToken string `yaml:"bearer_token"`
+}
+
+type AuthConfig struct {
+	// RequireEmailVerification blocks assigning any role beyond viewer until
+	// the user's email address is verified
+	RequireEmailVerification bool `yaml:"require_email_verification"`
+	// VerificationURL is the UI page that completes verification (?token= is appended)
+	VerificationURL string `yaml:"verification_url"`
+	// ServiceToken authenticates internal services (advisor, simulator) calling
+	// POST /api/v1/auth/check/access; the endpoint is disabled when empty
+	ServiceToken string `yaml:"service_token"`
+}
+
+type CacheConfig struct {
+	// Enabled caches resolved permissions (in-process LRU backed by Redis) and
+	// authenticated users (in-process only)
+	Enabled  bool          `yaml:"enabled"`
+	Size     int           `yaml:"size"`      // Max in-process entries per kind
+	LocalTTL time.Duration `yaml:"local_ttl"` // Staleness bound if an invalidation is missed; role expiry is honoured exactly
+	RedisTTL time.Duration `yaml:"redis_ttl"`
+}
+
+type JobsConfig struct {
+	// Enabled runs background jobs; only the replica holding the Postgres
+	// advisory lock executes them
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// ExpiryWarningDays sends "role expires in N days" notices at each threshold
+	ExpiryWarningDays []int `yaml:"expiry_warning_days"`
+}
+
 // Load reads configuration from a YAML file
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -83,6 +126,13 @@ func Load(path string) (*Config, error) {
 		cfg.Redis.Password = val
 	}
 
+	if val := os.Getenv("SCIM_BEARER_TOKEN"); val != "" {
+		cfg.SCIM.BearerToken = val
+	}
+	if val := os.Getenv("AUTH_SERVICE_TOKEN"); val != "" {
+		cfg.Auth.ServiceToken = val
+	}
+
 	return &cfg, nil
 }
 
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/notify"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+	"github.com/sirupsen/logrus"
+)
+
+// RoleExpiryJob archives lapsed user and group role assignments and warns
+// users before their time-limited roles, or their groups', expire
+func RoleExpiryJob(userRoleStore *rbac.UserRoleStore, groupStore *rbac.GroupStore, notifier notify.Notifier, logger *logrus.Logger, warningDays []int) Job {
+	// Largest threshold first, so a role first seen inside several windows
+	// only gets the most urgent warning
+	thresholds := append([]int(nil), warningDays...)
+	sort.Sort(sort.Reverse(sort.IntSlice(thresholds)))
+
+	return Job{
+		Name: "role_expiry",
+		Run: func(ctx context.Context) error {
+			expired, err := userRoleStore.ArchiveExpired(ctx)
+			if err != nil {
+				return fmt.Errorf("archive expired roles: %w", err)
+			}
+			if len(expired) > 0 {
+				logger.WithField("count", len(expired)).Info("jobs: archived expired role assignments")
+			}
+
+			expiredGroupRoles, err := groupStore.ArchiveExpiredRoles(ctx)
+			if err != nil {
+				return fmt.Errorf("archive expired group roles: %w", err)
+			}
+			if len(expiredGroupRoles) > 0 {
+				logger.WithField("count", len(expiredGroupRoles)).Info("jobs: archived expired group role assignments")
+			}
+
+			if err := warnExpiring(ctx, thresholds, userRoleStore.ListExpiring, userRoleStore.MarkExpiryWarned, notifier, logger); err != nil {
+				return err
+			}
+			return warnExpiring(ctx, thresholds, groupStore.ListExpiringRoles, groupStore.MarkRoleExpiryWarned, notifier, logger)
+		},
+	}
+}
+
+// warnExpiring sends each threshold's warnings and marks an assignment
+// warned once every user it was listed for was notified; a group role is
+// listed once per member, so a failed delivery retries the whole group
+func warnExpiring(
+	ctx context.Context,
+	thresholds []int,
+	list func(context.Context, int) ([]rbac.ExpiringAssignment, error),
+	mark func(context.Context, int, int) error,
+	notifier notify.Notifier,
+	logger *logrus.Logger,
+) error {
+	warned := make(map[int]bool)
+	for i, days := range thresholds {
+		expiring, err := list(ctx, days)
+		if err != nil {
+			return fmt.Errorf("list expiring roles: %w", err)
+		}
+
+		var sent []int
+		failed := make(map[int]bool)
+		for _, a := range expiring {
+			// Inside a smaller window too? Leave it to that threshold.
+			if i+1 < len(thresholds) && a.TimeLeft() <= time.Duration(thresholds[i+1])*24*time.Hour {
+				continue
+			}
+			if warned[a.ID] {
+				continue
+			}
+			if len(sent) == 0 || sent[len(sent)-1] != a.ID {
+				sent = append(sent, a.ID)
+			}
+
+			if err := notifier.Send(ctx, expiryWarning(a, days)); err != nil {
+				logger.WithError(err).WithField("user_id", a.UserID).Warn("jobs: failed to send expiry warning")
+				failed[a.ID] = true
+			}
+		}
+
+		for _, id := range sent {
+			warned[id] = true
+			if failed[id] {
+				continue
+			}
+			if err := mark(ctx, id, days); err != nil {
+				return fmt.Errorf("mark expiry warned: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func expiryWarning(a rbac.ExpiringAssignment, days int) notify.Message {
+	role := a.RoleName
+	if a.ResourceType != nil {
+		role = fmt.Sprintf("%s (%s %s)", a.RoleName, *a.ResourceType, *a.ResourceID)
+	}
+	if a.GroupName != nil {
+		role = fmt.Sprintf("%s (through the %s group)", a.RoleName, *a.GroupName)
+	}
+
+	unit := "days"
+	if days == 1 {
+		unit = "day"
+	}
+
+	return notify.Message{
+		To:      a.Email,
+		Subject: fmt.Sprintf("Your %s role expires in %d %s", a.RoleName, days, unit),
+		Body: fmt.Sprintf("Hi %s,\n\nYour %s role expires at %s. Request an extension if you still need it.",
+			a.Username, role, a.ExpiresAt.Format(time.RFC1123)),
+	}
+}
@@ -0,0 +1,122 @@
+// Package jobs runs periodic background work on exactly one replica, elected
+// with a Postgres advisory lock
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// leaderLockKey is the pg advisory lock key identifying the job leader
+const leaderLockKey = 0x75692d6a6f6273 // "ui-jobs"
+
+// Job is a unit of periodic work
+type Job struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs every interval while this replica holds the
+// leader lock. The lock lives on a dedicated connection, so it is released
+// automatically if the replica dies or the connection drops.
+type Scheduler struct {
+	db       *sql.DB
+	logger   *logrus.Logger
+	interval time.Duration
+	jobs     []Job
+
+	conn *sql.Conn // Non-nil while leader
+}
+
+// NewScheduler creates a scheduler that ticks every interval
+func NewScheduler(db *sql.DB, logger *logrus.Logger, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Scheduler{db: db, logger: logger, interval: interval}
+}
+
+// Register adds a job; call before Run
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Run ticks until ctx is done, running every job on each tick while leader
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.resign()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if s.ensureLeader(ctx) {
+			s.runJobs(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ensureLeader keeps or tries to take the leader lock
+func (s *Scheduler) ensureLeader(ctx context.Context) bool {
+	if s.conn != nil {
+		if err := s.conn.PingContext(ctx); err == nil {
+			return true
+		}
+		s.logger.Warn("jobs: lost leader connection")
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		s.logger.WithError(err).Warn("jobs: failed to get connection for leader election")
+		return false
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, leaderLockKey).Scan(&acquired); err != nil || !acquired {
+		if err != nil {
+			s.logger.WithError(err).Warn("jobs: leader election failed")
+		}
+		conn.Close()
+		return false
+	}
+
+	s.logger.Info("jobs: acquired leader lock")
+	s.conn = conn
+	return true
+}
+
+// resign releases the leader lock
+func (s *Scheduler) resign() {
+	if s.conn == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, leaderLockKey)
+	s.conn.Close()
+	s.conn = nil
+}
+
+func (s *Scheduler) runJobs(ctx context.Context) {
+	for _, job := range s.jobs {
+		start := time.Now()
+		if err := job.Run(ctx); err != nil {
+			s.logger.WithError(err).WithField("job", job.Name).Error("jobs: job failed")
+			continue
+		}
+		s.logger.WithFields(logrus.Fields{
+			"job":      job.Name,
+			"duration": time.Since(start).String(),
+		}).Debug("jobs: job completed")
+	}
+}
@@ -0,0 +1,41 @@
+// Package notify delivers user-facing notifications (verification emails,
+// expiry warnings, approval requests)
+package notify
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Message is a notification addressed to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier sends notifications
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes notifications to the service log. It is the default
+// until an outbound mail transport is configured.
+type LogNotifier struct {
+	logger *logrus.Logger
+}
+
+// NewLogNotifier creates a notifier that logs messages
+func NewLogNotifier(logger *logrus.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+// Send logs the message
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	n.logger.WithFields(logrus.Fields{
+		"to":      msg.To,
+		"subject": msg.Subject,
+	}).Info(msg.Body)
+	return nil
+}
@@ -0,0 +1,114 @@
+package permissions
+
+// Built-in permissions, matching the rows seeded by migration 000009
+const (
+	NavOverview  Permission = "nav.overview"
+	NavServices  Permission = "nav.services"
+	NavModels    Permission = "nav.models"
+	NavAlerts    Permission = "nav.alerts"
+	NavMetrics   Permission = "nav.metrics"
+	NavQuery     Permission = "nav.query"
+	NavWorkbench Permission = "nav.workbench"
+	NavReports   Permission = "nav.reports"
+	NavSettings  Permission = "nav.settings"
+
+	ServicesView   Permission = "services.view"
+	ServicesCreate Permission = "services.create"
+	ServicesEdit   Permission = "services.edit"
+	ServicesDelete Permission = "services.delete"
+
+	ModelsView      Permission = "models.view"
+	ModelsCalibrate Permission = "models.calibrate"
+	ModelsEdit      Permission = "models.edit"
+	ModelsDelete    Permission = "models.delete"
+
+	MetricsView Permission = "metrics.view"
+
+	CanonicalMetricsView   Permission = "canonical_metrics.view"
+	CanonicalMetricsCreate Permission = "canonical_metrics.create"
+	CanonicalMetricsEdit   Permission = "canonical_metrics.edit"
+	CanonicalMetricsDelete Permission = "canonical_metrics.delete"
+
+	ApmMappingsView   Permission = "apm_mappings.view"
+	ApmMappingsCreate Permission = "apm_mappings.create"
+	ApmMappingsEdit   Permission = "apm_mappings.edit"
+	ApmMappingsDelete Permission = "apm_mappings.delete"
+
+	ApmProvidersView      Permission = "apm_providers.view"
+	ApmProvidersConfigure Permission = "apm_providers.configure"
+	ApmProvidersCreate    Permission = "apm_providers.create"
+	ApmProvidersToggle    Permission = "apm_providers.toggle"
+
+	CollectionJobsView    Permission = "collection_jobs.view"
+	CollectionJobsCreate  Permission = "collection_jobs.create"
+	CollectionJobsEdit    Permission = "collection_jobs.edit"
+	CollectionJobsDelete  Permission = "collection_jobs.delete"
+	CollectionJobsTrigger Permission = "collection_jobs.trigger"
+
+	QueriesExecute Permission = "queries.execute"
+	QueriesSave    Permission = "queries.save"
+	QueriesDelete  Permission = "queries.delete"
+
+	UsersView        Permission = "users.view"
+	UsersCreate      Permission = "users.create"
+	UsersEdit        Permission = "users.edit"
+	UsersDelete      Permission = "users.delete"
+	UsersManageRoles Permission = "users.manage_roles"
+
+	RolesView   Permission = "roles.view"
+	RolesCreate Permission = "roles.create"
+	RolesEdit   Permission = "roles.edit"
+	RolesDelete Permission = "roles.delete"
+)
+
+func init() {
+	Declare(
+		Definition{Name: NavOverview, Resource: "navigation", Action: "view", Category: "navigation", Description: "Access Overview dashboard"},
+		Definition{Name: NavServices, Resource: "navigation", Action: "view", Category: "navigation", Description: "Access Services page"},
+		Definition{Name: NavModels, Resource: "navigation", Action: "view", Category: "navigation", Description: "Access Calibrated Models page"},
+		Definition{Name: NavAlerts, Resource: "navigation", Action: "view", Category: "navigation", Description: "Access Alerts page"},
+		Definition{Name: NavMetrics, Resource: "navigation", Action: "view", Category: "navigation", Description: "Access Metrics Configuration page"},
+		Definition{Name: NavQuery, Resource: "navigation", Action: "view", Category: "navigation", Description: "Access Query Explorer page"},
+		Definition{Name: NavWorkbench, Resource: "navigation", Action: "view", Category: "navigation", Description: "Access Scientific Workbench page"},
+		Definition{Name: NavReports, Resource: "navigation", Action: "view", Category: "navigation", Description: "Access Reports page"},
+		Definition{Name: NavSettings, Resource: "navigation", Action: "view", Category: "navigation", Description: "Access Settings page"},
+		Definition{Name: ServicesView, Resource: "services", Action: "view", Category: "data", Description: "View service list and details"},
+		Definition{Name: ServicesCreate, Resource: "services", Action: "create", Category: "data", Description: "Register new services"},
+		Definition{Name: ServicesEdit, Resource: "services", Action: "edit", Category: "data", Description: "Update service configuration"},
+		Definition{Name: ServicesDelete, Resource: "services", Action: "delete", Category: "data", Description: "Delete services"},
+		Definition{Name: ModelsView, Resource: "models", Action: "view", Category: "data", Description: "View calibrated models"},
+		Definition{Name: ModelsCalibrate, Resource: "models", Action: "calibrate", Category: "data", Description: "Trigger model calibration"},
+		Definition{Name: ModelsEdit, Resource: "models", Action: "edit", Category: "data", Description: "Update model metadata"},
+		Definition{Name: ModelsDelete, Resource: "models", Action: "delete", Category: "data", Description: "Delete model versions"},
+		Definition{Name: MetricsView, Resource: "metrics", Action: "view", Category: "data", Description: "View metrics data"},
+		Definition{Name: CanonicalMetricsView, Resource: "canonical_metrics", Action: "view", Category: "configuration", Description: "View canonical metrics registry"},
+		Definition{Name: CanonicalMetricsCreate, Resource: "canonical_metrics", Action: "create", Category: "configuration", Description: "Create new canonical metrics"},
+		Definition{Name: CanonicalMetricsEdit, Resource: "canonical_metrics", Action: "edit", Category: "configuration", Description: "Edit canonical metric definitions"},
+		Definition{Name: CanonicalMetricsDelete, Resource: "canonical_metrics", Action: "delete", Category: "configuration", Description: "Delete canonical metrics"},
+		Definition{Name: ApmMappingsView, Resource: "apm_mappings", Action: "view", Category: "configuration", Description: "View APM provider mappings"},
+		Definition{Name: ApmMappingsCreate, Resource: "apm_mappings", Action: "create", Category: "configuration", Description: "Create APM metric mappings"},
+		Definition{Name: ApmMappingsEdit, Resource: "apm_mappings", Action: "edit", Category: "configuration", Description: "Edit APM metric mappings"},
+		Definition{Name: ApmMappingsDelete, Resource: "apm_mappings", Action: "delete", Category: "configuration", Description: "Delete APM metric mappings"},
+		Definition{Name: ApmProvidersView, Resource: "apm_providers", Action: "view", Category: "configuration", Description: "View APM provider configurations"},
+		Definition{Name: ApmProvidersConfigure, Resource: "apm_providers", Action: "configure", Category: "configuration", Description: "Configure APM provider settings"},
+		Definition{Name: ApmProvidersCreate, Resource: "apm_providers", Action: "create", Category: "configuration", Description: "Create new APM providers"},
+		Definition{Name: ApmProvidersToggle, Resource: "apm_providers", Action: "toggle", Category: "configuration", Description: "Enable/disable APM providers"},
+		Definition{Name: CollectionJobsView, Resource: "collection_jobs", Action: "view", Category: "configuration", Description: "View collection schedules"},
+		Definition{Name: CollectionJobsCreate, Resource: "collection_jobs", Action: "create", Category: "configuration", Description: "Create collection schedules"},
+		Definition{Name: CollectionJobsEdit, Resource: "collection_jobs", Action: "edit", Category: "configuration", Description: "Edit collection schedules"},
+		Definition{Name: CollectionJobsDelete, Resource: "collection_jobs", Action: "delete", Category: "configuration", Description: "Delete collection schedules"},
+		Definition{Name: CollectionJobsTrigger, Resource: "collection_jobs", Action: "trigger", Category: "configuration", Description: "Manually trigger collection jobs"},
+		Definition{Name: QueriesExecute, Resource: "queries", Action: "execute", Category: "data", Description: "Execute queries against metrics"},
+		Definition{Name: QueriesSave, Resource: "queries", Action: "save", Category: "data", Description: "Save queries for reuse"},
+		Definition{Name: QueriesDelete, Resource: "queries", Action: "delete", Category: "data", Description: "Delete saved queries"},
+		Definition{Name: UsersView, Resource: "users", Action: "view", Category: "admin", Description: "View user accounts"},
+		Definition{Name: UsersCreate, Resource: "users", Action: "create", Category: "admin", Description: "Create new user accounts"},
+		Definition{Name: UsersEdit, Resource: "users", Action: "edit", Category: "admin", Description: "Edit user account details"},
+		Definition{Name: UsersDelete, Resource: "users", Action: "delete", Category: "admin", Description: "Delete user accounts"},
+		Definition{Name: UsersManageRoles, Resource: "users", Action: "manage_roles", Category: "admin", Description: "Assign and revoke user roles"},
+		Definition{Name: RolesView, Resource: "roles", Action: "view", Category: "admin", Description: "View roles and permissions"},
+		Definition{Name: RolesCreate, Resource: "roles", Action: "create", Category: "admin", Description: "Create custom roles"},
+		Definition{Name: RolesEdit, Resource: "roles", Action: "edit", Category: "admin", Description: "Edit role permissions"},
+		Definition{Name: RolesDelete, Resource: "roles", Action: "delete", Category: "admin", Description: "Delete custom roles"},
+	)
+}
@@ -0,0 +1,111 @@
+// Package permissions is the registry of permission names the services check.
+// Each permission is declared once in Go; the registry is upserted into the
+// permissions table at startup, and the permission middleware refuses to
+// guard a route with a name that was never declared.
+package permissions
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9_]+\.[a-z0-9_]+$`)
+
+// Permission is a declared permission name, e.g. "roles.edit"
+type Permission string
+
+// Definition describes a permission as stored in the permissions table
+type Definition struct {
+	Name        Permission
+	Resource    string
+	Action      string
+	Category    string
+	Description string
+}
+
+// Registry holds declared permissions
+type Registry struct {
+	mu   sync.RWMutex
+	defs map[Permission]Definition
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{defs: make(map[Permission]Definition)}
+}
+
+// Declare adds permissions to the registry. It panics on an invalid or
+// duplicate name, since either is a programming error caught at startup.
+func (r *Registry) Declare(defs ...Definition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, def := range defs {
+		if !validName.MatchString(string(def.Name)) {
+			panic(fmt.Sprintf("permissions: invalid permission name %q", def.Name))
+		}
+		if def.Resource == "" || def.Action == "" {
+			panic(fmt.Sprintf("permissions: %q needs a resource and an action", def.Name))
+		}
+		if _, exists := r.defs[def.Name]; exists {
+			panic(fmt.Sprintf("permissions: %q declared twice", def.Name))
+		}
+		r.defs[def.Name] = def
+	}
+}
+
+// Lookup returns the definition of a declared permission
+func (r *Registry) Lookup(name Permission) (Definition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def, ok := r.defs[name]
+	return def, ok
+}
+
+// All returns every declared permission sorted by name
+func (r *Registry) All() []Definition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]Definition, 0, len(r.defs))
+	for _, def := range r.defs {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// Default is the registry the built-in catalog is declared into
+var Default = NewRegistry()
+
+// Declare adds permissions to the default registry
+func Declare(defs ...Definition) {
+	Default.Declare(defs...)
+}
+
+// All returns every permission in the default registry
+func All() []Definition {
+	return Default.All()
+}
+
+// MustBeDeclared panics unless every name is in the default registry. Route
+// registration calls it so a typo fails at startup instead of denying everyone.
+func MustBeDeclared(names ...Permission) {
+	for _, name := range names {
+		if _, ok := Default.Lookup(name); !ok {
+			panic(fmt.Sprintf("permissions: %q is not a declared permission", name))
+		}
+	}
+}
+
+// Strings converts permission names for the policy evaluator
+func Strings(names []Permission) []string {
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = string(name)
+	}
+	return out
+}
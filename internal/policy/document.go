@@ -0,0 +1,146 @@
+// Package policy defines the declarative RBAC policy file and diffs it
+// against the roles and permissions stored in the database
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var permissionName = regexp.MustCompile(`^[a-z0-9_]+\.[a-z0-9_]+$`)
+
+// Document is a policy file: the permission catalog, the roles and their
+// permission grants, and optional default user assignments
+type Document struct {
+	Permissions []PermissionSpec `yaml:"permissions" json:"permissions"`
+	Roles       []RoleSpec       `yaml:"roles" json:"roles"`
+	Assignments []AssignmentSpec `yaml:"assignments" json:"assignments"`
+}
+
+// PermissionSpec declares a permission; resource and action are derived
+// from the "resource.action" name
+type PermissionSpec struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	Category    string `yaml:"category" json:"category"`
+}
+
+// RoleSpec declares a role and the exact set of permissions granted to it
+type RoleSpec struct {
+	Name        string   `yaml:"name" json:"name"`
+	Description string   `yaml:"description" json:"description"`
+	Permissions []string `yaml:"permissions" json:"permissions"`
+}
+
+// AssignmentSpec gives a user global roles. Assignments are only ever added;
+// roles a user holds beyond those listed are left alone.
+type AssignmentSpec struct {
+	User  string   `yaml:"user" json:"user"` // Username
+	Roles []string `yaml:"roles" json:"roles"`
+}
+
+// Resource returns the resource half of the permission name
+func (p PermissionSpec) Resource() string {
+	resource, _, _ := strings.Cut(p.Name, ".")
+	return resource
+}
+
+// Action returns the action half of the permission name
+func (p PermissionSpec) Action() string {
+	_, action, _ := strings.Cut(p.Name, ".")
+	return action
+}
+
+// LoadFile reads and validates a policy file
+func LoadFile(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a policy document. Unknown fields are rejected
+// so that typos don't silently drop part of the policy.
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Validate checks the document is internally consistent. References to
+// permissions and roles not declared in the file are checked against the
+// database when planning.
+func (d *Document) Validate() error {
+	permissions := make(map[string]bool, len(d.Permissions))
+	for _, p := range d.Permissions {
+		if !permissionName.MatchString(p.Name) {
+			return fmt.Errorf("permission %q: name must be resource.action (lowercase letters, digits, underscores)", p.Name)
+		}
+		if len(p.Name) > 100 {
+			return fmt.Errorf("permission %q: name is longer than 100 characters", p.Name)
+		}
+		if permissions[p.Name] {
+			return fmt.Errorf("permission %q is declared more than once", p.Name)
+		}
+		permissions[p.Name] = true
+	}
+
+	roles := make(map[string]bool, len(d.Roles))
+	for _, r := range d.Roles {
+		if strings.TrimSpace(r.Name) == "" {
+			return fmt.Errorf("role name is required")
+		}
+		if len(r.Name) > 50 {
+			return fmt.Errorf("role %q: name is longer than 50 characters", r.Name)
+		}
+		if roles[r.Name] {
+			return fmt.Errorf("role %q is declared more than once", r.Name)
+		}
+		roles[r.Name] = true
+
+		granted := make(map[string]bool, len(r.Permissions))
+		for _, name := range r.Permissions {
+			if granted[name] {
+				return fmt.Errorf("role %q: permission %q is listed more than once", r.Name, name)
+			}
+			granted[name] = true
+
+			// With a declared catalog, roles may only use permissions from it
+			if len(d.Permissions) > 0 && !permissions[name] {
+				return fmt.Errorf("role %q: permission %q is not declared in the policy", r.Name, name)
+			}
+		}
+	}
+
+	users := make(map[string]bool, len(d.Assignments))
+	for _, a := range d.Assignments {
+		if strings.TrimSpace(a.User) == "" {
+			return fmt.Errorf("assignment user is required")
+		}
+		if users[a.User] {
+			return fmt.Errorf("user %q has more than one assignment entry", a.User)
+		}
+		users[a.User] = true
+		if len(a.Roles) == 0 {
+			return fmt.Errorf("user %q: at least one role is required", a.User)
+		}
+	}
+
+	return nil
+}
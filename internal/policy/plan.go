@@ -0,0 +1,264 @@
+package policy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Kinds of object a change applies to
+const (
+	KindPermission = "permission"
+	KindRole       = "role"
+	KindGrant      = "grant"      // role → permission
+	KindAssignment = "assignment" // user → global role
+)
+
+// Change actions
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// State is the current database state a document is compared against
+type State struct {
+	Permissions map[string]PermissionSpec
+	Roles       map[string]RoleState
+	Users       map[string]UserState // Keyed by username
+	// Registered are permissions declared in code; they are never pruned
+	// because the startup sync would recreate them
+	Registered map[string]bool
+	// RequireVerifiedEmail mirrors auth.require_email_verification: roles
+	// beyond viewer are not assigned to users with unverified email
+	RequireVerifiedEmail bool
+}
+
+// RoleState is a stored role with its direct permission grants
+type RoleState struct {
+	Description string
+	IsSystem    bool
+	Permissions map[string]bool
+}
+
+// UserState is a stored user with their global roles
+type UserState struct {
+	ID            int
+	Active        bool
+	EmailVerified bool
+	Roles         map[string]bool
+}
+
+// Options control how a document is reconciled
+type Options struct {
+	// Prune deletes permissions and custom roles missing from the document.
+	// Permissions are only pruned when the document declares a catalog.
+	Prune bool
+}
+
+// Change is one step needed to bring the database in line with the document
+type Change struct {
+	Kind        string `json:"kind"`
+	Action      string `json:"action"`
+	Permission  string `json:"permission,omitempty"`
+	Role        string `json:"role,omitempty"`
+	User        string `json:"user,omitempty"`
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category,omitempty"`
+}
+
+// Plan is the ordered list of changes an apply would make
+type Plan struct {
+	Changes  []Change `json:"changes"`
+	Warnings []string `json:"warnings"`
+}
+
+// Empty reports whether the database already matches the document
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Diff computes the changes that reconcile state with doc. Changes are
+// ordered so they can be applied in sequence: creations and updates first,
+// then grants and assignments, then deletions.
+func Diff(doc *Document, state *State, opts Options) (*Plan, error) {
+	plan := &Plan{Changes: []Change{}, Warnings: []string{}}
+
+	// Permissions
+	declared := make(map[string]bool, len(doc.Permissions))
+	for _, p := range doc.Permissions {
+		declared[p.Name] = true
+		current, ok := state.Permissions[p.Name]
+		switch {
+		case !ok:
+			plan.add(Change{Kind: KindPermission, Action: ActionCreate, Permission: p.Name, Description: p.Description, Category: p.Category})
+		case current.Description == p.Description && current.Category == p.Category:
+			// Up to date
+		case state.Registered[p.Name]:
+			plan.warn("permission %q is declared in code; change its description and category there", p.Name)
+		default:
+			plan.add(Change{Kind: KindPermission, Action: ActionUpdate, Permission: p.Name, Description: p.Description, Category: p.Category})
+		}
+	}
+	permissionExists := func(name string) bool {
+		if len(doc.Permissions) > 0 {
+			return declared[name]
+		}
+		_, ok := state.Permissions[name]
+		return ok
+	}
+
+	// Roles and their grants
+	roles := make(map[string]bool, len(doc.Roles))
+	var grants, revokes []Change
+	for _, r := range doc.Roles {
+		roles[r.Name] = true
+		current, ok := state.Roles[r.Name]
+		switch {
+		case !ok:
+			plan.add(Change{Kind: KindRole, Action: ActionCreate, Role: r.Name, Description: r.Description})
+		case current.Description != r.Description:
+			plan.add(Change{Kind: KindRole, Action: ActionUpdate, Role: r.Name, Description: r.Description})
+		}
+
+		wanted := make(map[string]bool, len(r.Permissions))
+		for _, name := range r.Permissions {
+			if !permissionExists(name) {
+				return nil, fmt.Errorf("role %q: permission %q does not exist", r.Name, name)
+			}
+			wanted[name] = true
+			if !current.Permissions[name] {
+				grants = append(grants, Change{Kind: KindGrant, Action: ActionCreate, Role: r.Name, Permission: name})
+			}
+		}
+		for _, name := range sortedKeys(current.Permissions) {
+			if !wanted[name] {
+				revokes = append(revokes, Change{Kind: KindGrant, Action: ActionDelete, Role: r.Name, Permission: name})
+			}
+		}
+	}
+	plan.add(grants...)
+	plan.add(revokes...)
+
+	// Default assignments
+	for _, a := range doc.Assignments {
+		user, ok := state.Users[a.User]
+		if !ok {
+			plan.warn("user %q does not exist; skipping their assignments", a.User)
+			continue
+		}
+		if !user.Active {
+			plan.warn("user %q is inactive; skipping their assignments", a.User)
+			continue
+		}
+		for _, role := range a.Roles {
+			if _, exists := state.Roles[role]; !exists && !roles[role] {
+				return nil, fmt.Errorf("user %q: role %q does not exist", a.User, role)
+			}
+			if user.Roles[role] {
+				continue
+			}
+			if state.RequireVerifiedEmail && role != "viewer" && !user.EmailVerified {
+				plan.warn("user %q has not verified their email; skipping role %q", a.User, role)
+				continue
+			}
+			plan.add(Change{Kind: KindAssignment, Action: ActionCreate, User: a.User, Role: role})
+		}
+	}
+
+	// Objects the document doesn't manage
+	for _, name := range sortedKeys(state.Roles) {
+		if roles[name] {
+			continue
+		}
+		switch {
+		case state.Roles[name].IsSystem:
+			plan.warn("system role %q is not in the policy and cannot be deleted", name)
+		case opts.Prune:
+			plan.add(Change{Kind: KindRole, Action: ActionDelete, Role: name})
+		default:
+			plan.warn("role %q is not in the policy (prune to delete)", name)
+		}
+	}
+	if len(doc.Permissions) > 0 {
+		for _, name := range sortedKeys(state.Permissions) {
+			if declared[name] {
+				continue
+			}
+			switch {
+			case state.Registered[name]:
+				plan.warn("permission %q is declared in code and cannot be removed by the policy", name)
+			case opts.Prune:
+				plan.add(Change{Kind: KindPermission, Action: ActionDelete, Permission: name})
+			default:
+				plan.warn("permission %q is not in the policy (prune to delete)", name)
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// Write renders the plan for a terminal, one change per line
+func (p *Plan) Write(w io.Writer) {
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+	if p.Empty() {
+		fmt.Fprintln(w, "No changes. The database matches the policy.")
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, c := range p.Changes {
+		counts[c.Action]++
+		fmt.Fprintf(w, "%s %s\n", symbol(c.Action), c)
+	}
+	fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete.\n",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionDelete])
+}
+
+// String describes the change, e.g. "grant services.view to role operator"
+func (c Change) String() string {
+	switch c.Kind {
+	case KindGrant:
+		if c.Action == ActionDelete {
+			return fmt.Sprintf("revoke %s from role %s", c.Permission, c.Role)
+		}
+		return fmt.Sprintf("grant %s to role %s", c.Permission, c.Role)
+	case KindAssignment:
+		return fmt.Sprintf("assign role %s to user %s", c.Role, c.User)
+	case KindRole:
+		return fmt.Sprintf("%s role %s", c.Action, c.Role)
+	default:
+		return fmt.Sprintf("%s permission %s", c.Action, c.Permission)
+	}
+}
+
+func symbol(action string) string {
+	switch action {
+	case ActionCreate:
+		return "+"
+	case ActionDelete:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+func (p *Plan) add(changes ...Change) {
+	p.Changes = append(p.Changes, changes...)
+}
+
+func (p *Plan) warn(format string, args ...interface{}) {
+	p.Warnings = append(p.Warnings, fmt.Sprintf(format, args...))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,262 @@
+package policy
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// baseState has one code-registered permission, one custom one, the system
+// viewer role and a custom operator role
+func baseState() *State {
+	return &State{
+		Permissions: map[string]PermissionSpec{
+			"services.view":  {Name: "services.view", Description: "View services", Category: "services"},
+			"reports.export": {Name: "reports.export", Description: "Export reports", Category: "reports"},
+		},
+		Roles: map[string]RoleState{
+			"viewer":   {Description: "Read only", IsSystem: true, Permissions: map[string]bool{"services.view": true}},
+			"operator": {Description: "Operates", Permissions: map[string]bool{"services.view": true, "reports.export": true}},
+		},
+		Users: map[string]UserState{
+			"alice": {ID: 1, Active: true, EmailVerified: true, Roles: map[string]bool{"viewer": true}},
+			"bob":   {ID: 2, Active: true, Roles: map[string]bool{}},
+			"carol": {ID: 3, Active: false, Roles: map[string]bool{}},
+		},
+		Registered: map[string]bool{"services.view": true},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	viewer := RoleSpec{Name: "viewer", Description: "Read only", Permissions: []string{"services.view"}}
+	operator := RoleSpec{Name: "operator", Description: "Operates", Permissions: []string{"services.view", "reports.export"}}
+
+	tests := []struct {
+		name     string
+		doc      Document
+		state    func(*State)
+		opts     Options
+		changes  []string
+		warnings []string // Substrings, in order
+		wantErr  string
+	}{
+		{
+			name: "matching document plans nothing",
+			doc:  Document{Roles: []RoleSpec{viewer, operator}},
+		},
+		{
+			name: "new permission, role and grant in apply order",
+			doc: Document{
+				Permissions: []PermissionSpec{
+					{Name: "services.view", Description: "View services", Category: "services"},
+					{Name: "reports.export", Description: "Export reports", Category: "reports"},
+					{Name: "audits.view", Description: "View audits"},
+				},
+				Roles: []RoleSpec{viewer, operator, {Name: "auditor", Permissions: []string{"audits.view"}}},
+			},
+			changes: []string{
+				"create permission audits.view",
+				"create role auditor",
+				"grant audits.view to role auditor",
+			},
+		},
+		{
+			name: "grants come before revokes",
+			doc: Document{Roles: []RoleSpec{
+				{Name: "viewer", Description: "Read only", Permissions: []string{"services.view", "reports.export"}},
+				{Name: "operator", Description: "Operates", Permissions: []string{"services.view"}},
+			}},
+			changes: []string{
+				"grant reports.export to role viewer",
+				"revoke reports.export from role operator",
+			},
+		},
+		{
+			name: "changed descriptions update",
+			doc: Document{
+				Permissions: []PermissionSpec{
+					{Name: "services.view", Description: "View services", Category: "services"},
+					{Name: "reports.export", Description: "Download reports", Category: "reports"},
+				},
+				Roles: []RoleSpec{viewer, {Name: "operator", Description: "Runs things", Permissions: operator.Permissions}},
+			},
+			changes: []string{"update permission reports.export", "update role operator"},
+		},
+		{
+			name: "registered permissions are not updated",
+			doc: Document{
+				Permissions: []PermissionSpec{
+					{Name: "services.view", Description: "See services", Category: "services"},
+					{Name: "reports.export", Description: "Export reports", Category: "reports"},
+				},
+				Roles: []RoleSpec{viewer, operator},
+			},
+			warnings: []string{`permission "services.view" is declared in code`},
+		},
+		{
+			name:     "unmanaged role is kept without prune",
+			doc:      Document{Roles: []RoleSpec{viewer}},
+			warnings: []string{`role "operator" is not in the policy (prune to delete)`},
+		},
+		{
+			name:     "prune deletes custom roles but never system ones",
+			doc:      Document{},
+			opts:     Options{Prune: true},
+			changes:  []string{"delete role operator"},
+			warnings: []string{`system role "viewer" is not in the policy`},
+		},
+		{
+			name: "prune deletes undeclared permissions except registered ones",
+			doc: Document{
+				Permissions: []PermissionSpec{{Name: "audits.view"}},
+				Roles:       []RoleSpec{{Name: "viewer", Description: "Read only"}, {Name: "operator", Description: "Operates"}},
+			},
+			opts: Options{Prune: true},
+			changes: []string{
+				"create permission audits.view",
+				"revoke services.view from role viewer",
+				"revoke reports.export from role operator",
+				"revoke services.view from role operator",
+				"delete permission reports.export",
+			},
+			warnings: []string{`permission "services.view" is declared in code and cannot be removed`},
+		},
+		{
+			name: "permissions are not pruned without a catalog",
+			doc:  Document{Roles: []RoleSpec{viewer, operator}},
+			opts: Options{Prune: true},
+		},
+		{
+			name: "assignments skip held roles and missing or inactive users",
+			doc: Document{
+				Roles: []RoleSpec{viewer, operator},
+				Assignments: []AssignmentSpec{
+					{User: "alice", Roles: []string{"viewer", "operator"}},
+					{User: "carol", Roles: []string{"viewer"}},
+					{User: "dave", Roles: []string{"viewer"}},
+				},
+			},
+			changes: []string{"assign role operator to user alice"},
+			warnings: []string{
+				`user "carol" is inactive`,
+				`user "dave" does not exist`,
+			},
+		},
+		{
+			name: "assignment of a role created by the same plan",
+			doc: Document{
+				Roles:       []RoleSpec{viewer, operator, {Name: "auditor"}},
+				Assignments: []AssignmentSpec{{User: "bob", Roles: []string{"auditor"}}},
+			},
+			changes: []string{"create role auditor", "assign role auditor to user bob"},
+		},
+		{
+			name: "unverified email only gets viewer when verification is required",
+			doc: Document{
+				Roles:       []RoleSpec{viewer, operator},
+				Assignments: []AssignmentSpec{{User: "bob", Roles: []string{"viewer", "operator"}}},
+			},
+			state:    func(s *State) { s.RequireVerifiedEmail = true },
+			changes:  []string{"assign role viewer to user bob"},
+			warnings: []string{`user "bob" has not verified their email; skipping role "operator"`},
+		},
+		{
+			name:    "grant of an unknown permission",
+			doc:     Document{Roles: []RoleSpec{{Name: "viewer", Permissions: []string{"nope.view"}}}},
+			wantErr: `role "viewer": permission "nope.view" does not exist`,
+		},
+		{
+			name: "grant of a permission missing from the catalog",
+			doc: Document{
+				Permissions: []PermissionSpec{{Name: "services.view"}},
+				Roles:       []RoleSpec{{Name: "viewer", Permissions: []string{"reports.export"}}},
+			},
+			wantErr: `role "viewer": permission "reports.export" does not exist`,
+		},
+		{
+			name: "assignment of an unknown role",
+			doc: Document{
+				Roles:       []RoleSpec{viewer, operator},
+				Assignments: []AssignmentSpec{{User: "bob", Roles: []string{"ghost"}}},
+			},
+			wantErr: `user "bob": role "ghost" does not exist`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := baseState()
+			if tt.state != nil {
+				tt.state(state)
+			}
+
+			plan, err := Diff(&tt.doc, state, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Diff error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Diff: %v", err)
+			}
+
+			changes := []string{}
+			for _, c := range plan.Changes {
+				changes = append(changes, c.String())
+			}
+			if tt.changes == nil {
+				tt.changes = []string{}
+			}
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changes =\n  %s\nwant\n  %s", strings.Join(changes, "\n  "), strings.Join(tt.changes, "\n  "))
+			}
+
+			if len(plan.Warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %q, want %d matching %q", plan.Warnings, len(tt.warnings), tt.warnings)
+			}
+			for i, want := range tt.warnings {
+				if !strings.Contains(plan.Warnings[i], want) {
+					t.Errorf("warning %d = %q, want it to contain %q", i, plan.Warnings[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		plan Plan
+		want string
+	}{
+		{
+			name: "empty",
+			plan: Plan{Warnings: []string{"role \"x\" is not in the policy"}},
+			want: "warning: role \"x\" is not in the policy\nNo changes. The database matches the policy.\n",
+		},
+		{
+			name: "changes with totals",
+			plan: Plan{Changes: []Change{
+				{Kind: KindPermission, Action: ActionCreate, Permission: "audits.view"},
+				{Kind: KindRole, Action: ActionUpdate, Role: "operator"},
+				{Kind: KindGrant, Action: ActionDelete, Role: "operator", Permission: "reports.export"},
+			}},
+			want: "+ create permission audits.view\n" +
+				"~ update role operator\n" +
+				"- revoke reports.export from role operator\n" +
+				"\nPlan: 1 to create, 1 to update, 1 to delete.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.plan.Write(&buf)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Write =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
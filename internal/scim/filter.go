@@ -0,0 +1,402 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2)
+type Filter interface {
+	// Match reports whether a resource with the given attributes matches
+	Match(attrs map[string][]string) bool
+
+	// sql renders the filter as a boolean SQL expression, appending its
+	// comparison values to args
+	sql(columns Columns, args []interface{}) (string, []interface{})
+}
+
+// Column maps a filterable attribute onto SQL. Expr is a text expression,
+// NULL where the resource has no such attribute. A multi-valued attribute
+// sets From to the rest of a correlated subquery ("FROM ... WHERE ...")
+// whose rows hold its values, one Expr each.
+type Column struct {
+	Expr string
+	From string
+}
+
+// Columns maps lowercase attribute paths (as in Attributes) to their SQL
+type Columns map[string]Column
+
+// SQL renders a filter as a boolean SQL expression over columns that selects
+// the same resources as Match. Comparison values are appended to args and
+// referenced as $n placeholders numbered after the ones already there.
+// Attributes missing from columns are treated as absent.
+func SQL(f Filter, columns Columns, args []interface{}) (string, []interface{}) {
+	return f.sql(columns, args)
+}
+
+type logicalFilter struct {
+	op          string // "and" | "or"
+	left, right Filter
+}
+
+func (f *logicalFilter) Match(attrs map[string][]string) bool {
+	if f.op == "and" {
+		return f.left.Match(attrs) && f.right.Match(attrs)
+	}
+	return f.left.Match(attrs) || f.right.Match(attrs)
+}
+
+func (f *logicalFilter) sql(columns Columns, args []interface{}) (string, []interface{}) {
+	left, args := f.left.sql(columns, args)
+	right, args := f.right.sql(columns, args)
+	return "(" + left + " " + strings.ToUpper(f.op) + " " + right + ")", args
+}
+
+type notFilter struct {
+	inner Filter
+}
+
+func (f *notFilter) Match(attrs map[string][]string) bool {
+	return !f.inner.Match(attrs)
+}
+
+func (f *notFilter) sql(columns Columns, args []interface{}) (string, []interface{}) {
+	inner, args := f.inner.sql(columns, args)
+	return "NOT " + inner, args
+}
+
+type compareFilter struct {
+	attr  string
+	op    string
+	value string
+}
+
+func (f *compareFilter) Match(attrs map[string][]string) bool {
+	values := attrs[f.attr]
+
+	if f.op == "pr" {
+		for _, v := range values {
+			if v != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Multi-valued attributes match if any value matches; comparisons are
+	// case-insensitive as none of our attributes are caseExact
+	want := strings.ToLower(f.value)
+	for _, v := range values {
+		got := strings.ToLower(v)
+		var ok bool
+		switch f.op {
+		case "eq":
+			ok = got == want
+		case "ne":
+			ok = got != want
+		case "co":
+			ok = strings.Contains(got, want)
+		case "sw":
+			ok = strings.HasPrefix(got, want)
+		case "ew":
+			ok = strings.HasSuffix(got, want)
+		case "gt":
+			ok = got > want
+		case "ge":
+			ok = got >= want
+		case "lt":
+			ok = got < want
+		case "le":
+			ok = got <= want
+		}
+		if ok {
+			return true
+		}
+	}
+
+	// "ne" against a missing attribute is true
+	return f.op == "ne" && len(values) == 0
+}
+
+// sqlOperators maps comparison operators to SQL; co, sw and ew use LIKE
+var sqlOperators = map[string]string{
+	"eq": "=", "ne": "<>", "co": "LIKE", "sw": "LIKE", "ew": "LIKE",
+	"gt": ">", "ge": ">=", "lt": "<", "le": "<=",
+}
+
+// Every predicate is kept two-valued (never NULL) so NOT matches Match
+func (f *compareFilter) sql(columns Columns, args []interface{}) (string, []interface{}) {
+	column, ok := columns[f.attr]
+	if !ok {
+		if f.op == "ne" {
+			return "TRUE", args
+		}
+		return "FALSE", args
+	}
+
+	var predicate string
+	if f.op == "pr" {
+		predicate = fmt.Sprintf("COALESCE(%s, '') <> ''", column.Expr)
+	} else {
+		value := strings.ToLower(f.value)
+		switch f.op {
+		case "co":
+			value = "%" + escapeLike(value) + "%"
+		case "sw":
+			value = escapeLike(value) + "%"
+		case "ew":
+			value = "%" + escapeLike(value)
+		}
+		args = append(args, value)
+		// Byte order, as Match compares Go strings
+		predicate = fmt.Sprintf(`LOWER(%s) COLLATE "C" %s $%d`, column.Expr, sqlOperators[f.op], len(args))
+	}
+
+	if column.From == "" {
+		if f.op == "ne" {
+			return "COALESCE(" + predicate + ", TRUE)", args
+		}
+		return "COALESCE(" + predicate + ", FALSE)", args
+	}
+
+	exists := "EXISTS (SELECT 1 " + column.From + " AND " + predicate + ")"
+	if f.op == "ne" {
+		return "(" + exists + " OR NOT EXISTS (SELECT 1 " + column.From + "))", args
+	}
+	return exists, args
+}
+
+// escapeLike escapes LIKE's wildcards and its default escape character
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// ParseFilter parses a filter expression such as
+// `userName eq "alice" and (active eq true or emails co "@example.com")`
+func ParseFilter(input string) (Filter, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		ch := input[i]
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, token{text: string(ch)})
+			i++
+		case ch == '"':
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(input) {
+				if input[i] == '\\' && i+1 < len(input) {
+					sb.WriteByte(input[i+1])
+					i += 2
+					continue
+				}
+				if input[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(input[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, token{text: sb.String(), quoted: true})
+		default:
+			start := i
+			for i < len(input) && input[i] != ' ' && input[i] != '(' && input[i] != ')' {
+				i++
+			}
+			tokens = append(tokens, token{text: input[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	t := p.tokens[p.pos]
+	return !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+func (p *filterParser) next() (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, fmt.Errorf("unexpected end of filter")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseTerm() (Filter, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		inner, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &notFilter{inner: inner}, nil
+	}
+	if p.peekKeyword("(") {
+		return p.parseGroup()
+	}
+
+	attr, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if attr.quoted {
+		return nil, fmt.Errorf("expected attribute name, got %q", attr.text)
+	}
+
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	opName := strings.ToLower(op.text)
+
+	// Strip the core schema URN prefix if fully qualified
+	attrPath := strings.ToLower(attr.text)
+	for _, urn := range []string{SchemaUser, SchemaGroup} {
+		attrPath = strings.TrimPrefix(attrPath, strings.ToLower(urn)+":")
+	}
+
+	if opName == "pr" {
+		return &compareFilter{attr: attrPath, op: opName}, nil
+	}
+
+	switch opName {
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("unsupported filter operator %q", op.text)
+	}
+
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if !value.quoted && strings.EqualFold(value.text, "null") {
+		value.text = ""
+	}
+
+	return &compareFilter{attr: attrPath, op: opName, value: value.text}, nil
+}
+
+func (p *filterParser) parseGroup() (Filter, error) {
+	open, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if open.quoted || open.text != "(" {
+		return nil, fmt.Errorf("expected '(', got %q", open.text)
+	}
+	inner, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	closing, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if closing.quoted || closing.text != ")" {
+		return nil, fmt.Errorf("expected ')', got %q", closing.text)
+	}
+	return inner, nil
+}
+
+// ParseValuePath splits a PATCH path such as `members[value eq "42"]` or
+// `emails[type eq "work"].value` into the attribute, an optional value
+// filter and an optional sub-attribute (all attribute names lowercased)
+func ParseValuePath(path string) (attr string, filter Filter, subAttr string, err error) {
+	path = strings.TrimSpace(path)
+	for _, urn := range []string{SchemaUser, SchemaGroup} {
+		if len(path) > len(urn) && strings.EqualFold(path[:len(urn)+1], urn+":") {
+			path = path[len(urn)+1:]
+		}
+	}
+
+	open := strings.Index(path, "[")
+	if open < 0 {
+		return strings.ToLower(path), nil, "", nil
+	}
+
+	closeIdx := strings.LastIndex(path, "]")
+	if closeIdx < open {
+		return "", nil, "", fmt.Errorf("invalid path %q", path)
+	}
+
+	filter, err = ParseFilter(path[open+1 : closeIdx])
+	if err != nil {
+		return "", nil, "", fmt.Errorf("invalid path filter: %w", err)
+	}
+
+	attr = strings.ToLower(path[:open])
+	subAttr = strings.ToLower(strings.TrimPrefix(path[closeIdx+1:], "."))
+	return attr, filter, subAttr, nil
+}
@@ -0,0 +1,196 @@
+package scim
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilterMatch(t *testing.T) {
+	alice := map[string][]string{"username": {"Alice"}, "active": {"true"}, "emails": {"alice@example.com", "a@corp.io"}}
+	bob := map[string][]string{"username": {"bob"}, "active": {"false"}}
+
+	tests := []struct {
+		filter string
+		attrs  map[string][]string
+		want   bool
+	}{
+		{`userName eq "alice"`, alice, true},
+		{`userName eq "alice"`, bob, false},
+		{`USERNAME EQ "ALICE"`, alice, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "alice"`, alice, true},
+		{`emails co "@corp"`, alice, true},
+		{`emails sw "bob"`, alice, false},
+		{`emails ew ".io"`, alice, true},
+		{`emails pr`, alice, true},
+		{`emails pr`, bob, false},
+		{`emails ne "x@example.com"`, bob, true}, // ne against a missing attribute
+		{`userName gt "b"`, bob, true},
+		{`userName eq null`, map[string][]string{"username": {""}}, true},
+		// and binds tighter than or
+		{`userName eq "alice" or userName eq "bob" and active eq "true"`, alice, true},
+		{`userName eq "alice" or userName eq "bob" and active eq "true"`, bob, false},
+		{`(userName eq "alice" or userName eq "bob") and active eq "true"`, bob, false},
+		{`(userName eq "alice" or userName eq "bob") and active eq "false"`, bob, true},
+		{`not (userName eq "alice") and active eq "false"`, bob, true},
+		{`not (userName eq "alice" or active eq "false")`, bob, false},
+		{`userName eq "a b (c)"`, map[string][]string{"username": {"a b (c)"}}, true},
+		{`userName eq "say \"hi\""`, map[string][]string{"username": {`say "hi"`}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) = %v", tt.filter, err)
+			}
+			if got := f.Match(tt.attrs); got != tt.want {
+				t.Errorf("ParseFilter(%q).Match(%v) = %v, want %v", tt.filter, tt.attrs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	invalid := []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName regex "a.*"`,
+		`"userName" eq "alice"`,
+		`userName eq "alice`,
+		`(userName eq "alice"`,
+		`userName eq "alice")`,
+		`not userName eq "alice"`,
+		`userName eq "alice" and`,
+		`userName eq "alice" bob`,
+	}
+	for _, filter := range invalid {
+		if _, err := ParseFilter(filter); err == nil {
+			t.Errorf("ParseFilter(%q) = nil error, want an error", filter)
+		}
+	}
+}
+
+func TestFilterSQL(t *testing.T) {
+	const emailsFrom = "FROM user_emails e WHERE e.user_id = u.id"
+	columns := Columns{
+		"username": {Expr: "u.username"},
+		"active":   {Expr: "u.is_active::TEXT"},
+		"emails":   {Expr: "e.email", From: emailsFrom},
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		args   []interface{}
+		want   string
+		values []interface{}
+	}{
+		{
+			name:   "eq lowercases the value",
+			filter: `userName eq "Alice"`,
+			want:   `COALESCE(LOWER(u.username) COLLATE "C" = $1, FALSE)`,
+			values: []interface{}{"alice"},
+		},
+		{
+			name:   "ne holds for a missing value",
+			filter: `userName ne "alice"`,
+			want:   `COALESCE(LOWER(u.username) COLLATE "C" <> $1, TRUE)`,
+			values: []interface{}{"alice"},
+		},
+		{
+			name:   "co escapes LIKE wildcards",
+			filter: `userName co "50%_off\\"`,
+			want:   `COALESCE(LOWER(u.username) COLLATE "C" LIKE $1, FALSE)`,
+			values: []interface{}{`%50\%\_off\\%`},
+		},
+		{
+			name:   "sw",
+			filter: `userName sw "al"`,
+			want:   `COALESCE(LOWER(u.username) COLLATE "C" LIKE $1, FALSE)`,
+			values: []interface{}{"al%"},
+		},
+		{
+			name:   "ew",
+			filter: `userName ew "ce"`,
+			want:   `COALESCE(LOWER(u.username) COLLATE "C" LIKE $1, FALSE)`,
+			values: []interface{}{"%ce"},
+		},
+		{
+			name:   "pr takes no value",
+			filter: `userName pr`,
+			want:   `COALESCE(COALESCE(u.username, '') <> '', FALSE)`,
+		},
+		{
+			name:   "values never reach the SQL",
+			filter: `userName eq "x' OR '1'='1"`,
+			want:   `COALESCE(LOWER(u.username) COLLATE "C" = $1, FALSE)`,
+			values: []interface{}{"x' or '1'='1"},
+		},
+		{
+			name:   "placeholders continue after existing args",
+			filter: `userName eq "alice"`,
+			args:   []interface{}{10, 20},
+			want:   `COALESCE(LOWER(u.username) COLLATE "C" = $3, FALSE)`,
+			values: []interface{}{10, 20, "alice"},
+		},
+		{
+			name:   "unknown attribute matches nothing",
+			filter: `nickName eq "al"`,
+			want:   `FALSE`,
+		},
+		{
+			name:   "ne on an unknown attribute matches everything",
+			filter: `nickName ne "al"`,
+			want:   `TRUE`,
+		},
+		{
+			name:   "multi-valued attribute",
+			filter: `emails eq "a@corp.io"`,
+			want:   `EXISTS (SELECT 1 ` + emailsFrom + ` AND LOWER(e.email) COLLATE "C" = $1)`,
+			values: []interface{}{"a@corp.io"},
+		},
+		{
+			name:   "ne on a multi-valued attribute holds with no values",
+			filter: `emails ne "a@corp.io"`,
+			want:   `(EXISTS (SELECT 1 ` + emailsFrom + ` AND LOWER(e.email) COLLATE "C" <> $1) OR NOT EXISTS (SELECT 1 ` + emailsFrom + `))`,
+			values: []interface{}{"a@corp.io"},
+		},
+		{
+			name:   "and binds tighter than or",
+			filter: `userName eq "a" or userName eq "b" and active eq "true"`,
+			want: `(COALESCE(LOWER(u.username) COLLATE "C" = $1, FALSE) OR ` +
+				`(COALESCE(LOWER(u.username) COLLATE "C" = $2, FALSE) AND COALESCE(LOWER(u.is_active::TEXT) COLLATE "C" = $3, FALSE)))`,
+			values: []interface{}{"a", "b", "true"},
+		},
+		{
+			name:   "parentheses group",
+			filter: `(userName eq "a" or userName eq "b") and active eq "true"`,
+			want: `((COALESCE(LOWER(u.username) COLLATE "C" = $1, FALSE) OR COALESCE(LOWER(u.username) COLLATE "C" = $2, FALSE)) AND ` +
+				`COALESCE(LOWER(u.is_active::TEXT) COLLATE "C" = $3, FALSE))`,
+			values: []interface{}{"a", "b", "true"},
+		},
+		{
+			name:   "not",
+			filter: `not (userName eq "a")`,
+			want:   `NOT COALESCE(LOWER(u.username) COLLATE "C" = $1, FALSE)`,
+			values: []interface{}{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) = %v", tt.filter, err)
+			}
+			got, values := SQL(f, columns, tt.args)
+			if got != tt.want {
+				t.Errorf("SQL(%q) = %s, want %s", tt.filter, got, tt.want)
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("SQL(%q) args = %#v, want %#v", tt.filter, values, tt.values)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Package scim implements the SCIM 2.0 (RFC 7643/7644) protocol types, filter
+// expressions and PATCH path handling used by the provisioning endpoints
+package scim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	SchemaUser        = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup       = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResp    = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp     = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError       = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaSPConfig    = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ContentType       = "application/scim+json"
+	DefaultPageSize   = 100
+	MaxPageSize       = 1000
+	ResourceTypeUser  = "User"
+	ResourceTypeGroup = "Group"
+)
+
+// Meta carries resource metadata, including the version used for ETags
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+	Version      string     `json:"version,omitempty"`
+}
+
+// Name is the SCIM user name complex attribute
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// MultiValue is a SCIM multi-valued attribute entry (emails, groups, members)
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// User is the SCIM representation of a users row
+type User struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *Name        `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []MultiValue `json:"emails,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
+	Password    string       `json:"password,omitempty"` // Write-only
+	Groups      []MultiValue `json:"groups,omitempty"`   // Read-only
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// PrimaryEmail returns the primary (or first) email address
+func (u *User) PrimaryEmail() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// FormattedName returns the best available full name
+func (u *User) FormattedName() string {
+	if u.Name != nil {
+		if u.Name.Formatted != "" {
+			return u.Name.Formatted
+		}
+		if u.Name.GivenName != "" || u.Name.FamilyName != "" {
+			if u.Name.GivenName == "" || u.Name.FamilyName == "" {
+				return u.Name.GivenName + u.Name.FamilyName
+			}
+			return u.Name.GivenName + " " + u.Name.FamilyName
+		}
+	}
+	return u.DisplayName
+}
+
+// Attributes returns the filterable attribute values keyed by lowercase path
+func (u *User) Attributes() map[string][]string {
+	attrs := map[string][]string{
+		"id":          {u.ID},
+		"externalid":  {u.ExternalID},
+		"username":    {u.UserName},
+		"displayname": {u.DisplayName},
+	}
+	if u.Name != nil {
+		attrs["name.formatted"] = []string{u.Name.Formatted}
+		attrs["name.givenname"] = []string{u.Name.GivenName}
+		attrs["name.familyname"] = []string{u.Name.FamilyName}
+	}
+	for _, e := range u.Emails {
+		attrs["emails"] = append(attrs["emails"], e.Value)
+		attrs["emails.value"] = append(attrs["emails.value"], e.Value)
+	}
+	if u.Active != nil {
+		attrs["active"] = []string{boolString(*u.Active)}
+	}
+	for _, g := range u.Groups {
+		attrs["groups"] = append(attrs["groups"], g.Value)
+		attrs["groups.value"] = append(attrs["groups.value"], g.Value)
+		attrs["groups.display"] = append(attrs["groups.display"], g.Display)
+	}
+	return attrs
+}
+
+// Group is the SCIM representation of an RBAC role
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// Attributes returns the filterable attribute values keyed by lowercase path
+func (g *Group) Attributes() map[string][]string {
+	attrs := map[string][]string{
+		"id":          {g.ID},
+		"externalid":  {g.ExternalID},
+		"displayname": {g.DisplayName},
+	}
+	for _, m := range g.Members {
+		attrs["members"] = append(attrs["members"], m.Value)
+		attrs["members.value"] = append(attrs["members.value"], m.Value)
+		attrs["members.display"] = append(attrs["members.display"], m.Display)
+	}
+	return attrs
+}
+
+// ListResponse wraps a page of query results
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// NewListResponse builds a list response for a page of resources
+func NewListResponse(resources interface{}, total, startIndex, count int) *ListResponse {
+	return &ListResponse{
+		Schemas:      []string{SchemaListResp},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: count,
+		Resources:    resources,
+	}
+}
+
+// PatchRequest is the body of a PATCH operation
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is a single add/replace/remove operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Error is the SCIM error response body
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// ETag computes a weak entity tag from a resource's representation.
+// The meta.version field must be empty when the ETag is computed.
+func ETag(resource interface{}) string {
+	data, _ := json.Marshal(resource)
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,339 @@
+package privacy
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
+)
+
+// Content policies for owned templates and saved queries on erasure
+const (
+	ContentDelete         = "delete"          // Delete all owned content
+	ContentReassign       = "reassign"        // Transfer all owned content to another user
+	ContentReassignShared = "reassign_shared" // Transfer shared content, delete private content
+)
+
+var (
+	// ErrAlreadyErased is returned when erasing a user whose data was already erased
+	ErrAlreadyErased = errors.New("user data has already been erased")
+	// ErrUserNotFound is returned when the user to erase does not exist
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// OwnedTemplate is a quick template owned by the user
+type OwnedTemplate struct {
+	ID                int             `json:"id"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	ConfigurationData json.RawMessage `json:"configuration_data"`
+	IsShared          bool            `json:"is_shared"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         *time.Time      `json:"updated_at,omitempty"`
+}
+
+// SavedQuery is a saved metric query owned by the user
+type SavedQuery struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	QueryData   json.RawMessage `json:"query_data"`
+	IsShared    bool            `json:"is_shared"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   *time.Time      `json:"updated_at,omitempty"`
+}
+
+// Preferences are the user's UI preferences
+type Preferences struct {
+	Theme            string          `json:"theme"`
+	DefaultServiceID *string         `json:"default_service_id,omitempty"`
+	PreferencesData  json.RawMessage `json:"preferences_data,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        *time.Time      `json:"updated_at,omitempty"`
+}
+
+// UserContent is the user-owned content included in a data export
+type UserContent struct {
+	Templates    []OwnedTemplate `json:"templates"`
+	SavedQueries []SavedQuery    `json:"saved_queries"`
+	Preferences  *Preferences    `json:"preferences"`
+}
+
+// ErasurePolicy controls how an erasure treats owned content
+type ErasurePolicy struct {
+	Content    string // ContentDelete | ContentReassign | ContentReassignShared
+	ReassignTo int    // Required when content is reassigned
+	ErasedBy   int
+}
+
+// ErasureResult summarises what an erasure changed
+type ErasureResult struct {
+	UserID                 int       `json:"user_id"`
+	ContentPolicy          string    `json:"content_policy"`
+	ReassignedTo           *int      `json:"reassigned_to,omitempty"`
+	TemplatesDeleted       int64     `json:"templates_deleted"`
+	TemplatesReassigned    int64     `json:"templates_reassigned"`
+	SavedQueriesDeleted    int64     `json:"saved_queries_deleted"`
+	SavedQueriesReassigned int64     `json:"saved_queries_reassigned"`
+	RolesRevoked           int64     `json:"roles_revoked"`
+	ErasedAt               time.Time `json:"erased_at"`
+}
+
+// Store handles data subject requests (export and erasure)
+type Store struct {
+	db          *sql.DB
+	invalidator rbac.Invalidator
+}
+
+// NewStore creates a new privacy store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// SetInvalidator registers the cache to notify when a user is erased
+func (s *Store) SetInvalidator(invalidator rbac.Invalidator) {
+	s.invalidator = invalidator
+}
+
+// GetUserContent retrieves all content owned by a user
+func (s *Store) GetUserContent(ctx context.Context, userID int) (*UserContent, error) {
+	content := &UserContent{
+		Templates:    []OwnedTemplate{},
+		SavedQueries: []SavedQuery{},
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, COALESCE(description, ''), configuration_data, COALESCE(is_shared, false), created_at, updated_at
+		FROM quick_templates
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list templates: %w", err)
+	}
+	for rows.Next() {
+		var t OwnedTemplate
+		if err := rows.Scan(&t.ID, &t.Name, &t.Description, &t.ConfigurationData, &t.IsShared, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan template: %w", err)
+		}
+		content.Templates = append(content.Templates, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list templates: %w", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT id, name, COALESCE(description, ''), query_data, COALESCE(is_shared, false), created_at, updated_at
+		FROM saved_queries
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list saved queries: %w", err)
+	}
+	for rows.Next() {
+		var q SavedQuery
+		if err := rows.Scan(&q.ID, &q.Name, &q.Description, &q.QueryData, &q.IsShared, &q.CreatedAt, &q.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan saved query: %w", err)
+		}
+		content.SavedQueries = append(content.SavedQueries, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list saved queries: %w", err)
+	}
+
+	var prefs Preferences
+	var prefsData []byte
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(theme, ''), default_service_id, preferences_data, created_at, updated_at
+		FROM user_preferences
+		WHERE user_id = $1
+	`, userID).Scan(&prefs.Theme, &prefs.DefaultServiceID, &prefsData, &prefs.CreatedAt, &prefs.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("get preferences: %w", err)
+	}
+	if err == nil {
+		if prefsData != nil {
+			prefs.PreferencesData = prefsData
+		}
+		content.Preferences = &prefs
+	}
+
+	return content, nil
+}
+
+// Erase anonymises a user's personal data in a single transaction.
+//
+// The users row is kept (anonymised and deactivated) rather than deleted so
+// that permission_audit, role_permissions.granted_by and user_roles.assigned_by
+// references remain valid. Owned content is deleted or reassigned according
+// to the policy; role assignments, group memberships, approver designations
+// and preferences are removed, pending access requests are cancelled, and the
+// erasure itself is audited. Returns rbac.ErrLastAdmin, erasing nothing, if
+// the user is the last active admin.
+func (s *Store) Erase(ctx context.Context, userID int, policy ErasurePolicy) (*ErasureResult, error) {
+	switch policy.Content {
+	case ContentDelete:
+	case ContentReassign, ContentReassignShared:
+		if policy.ReassignTo == 0 || policy.ReassignTo == userID {
+			return nil, fmt.Errorf("a different user to reassign content to is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown content policy %q", policy.Content)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Erasure deactivates the user and drops their roles and memberships, so
+	// it must not take away the last admin
+	if err := rbac.LockSafeguards(ctx, tx); err != nil {
+		return nil, fmt.Errorf("lock safeguards: %w", err)
+	}
+	adminsBefore, err := rbac.CountAdmins(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("count admins: %w", err)
+	}
+
+	var erasedAt *time.Time
+	err = tx.QueryRowContext(ctx, `SELECT erased_at FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&erasedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock user: %w", err)
+	}
+	if erasedAt != nil {
+		return nil, ErrAlreadyErased
+	}
+
+	result := &ErasureResult{UserID: userID, ContentPolicy: policy.Content}
+
+	if policy.Content != ContentDelete {
+		var active bool
+		err := tx.QueryRowContext(ctx, `SELECT is_active AND erased_at IS NULL FROM users WHERE id = $1`, policy.ReassignTo).Scan(&active)
+		if err == sql.ErrNoRows || (err == nil && !active) {
+			return nil, fmt.Errorf("reassignment target must be an active user")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("check reassignment target: %w", err)
+		}
+		result.ReassignedTo = &policy.ReassignTo
+	}
+
+	for _, table := range []struct {
+		name                string
+		shared              string // Condition for content that is shared with others
+		deleted, reassigned *int64
+	}{
+		{"quick_templates", "is_shared = TRUE OR EXISTS (SELECT 1 FROM template_shares s WHERE s.template_id = quick_templates.id)", &result.TemplatesDeleted, &result.TemplatesReassigned},
+		{"saved_queries", "is_shared = TRUE", &result.SavedQueriesDeleted, &result.SavedQueriesReassigned},
+	} {
+		if policy.Content != ContentDelete {
+			reassignQuery := fmt.Sprintf(`UPDATE %s SET user_id = $2, updated_at = NOW() WHERE user_id = $1`, table.name)
+			if policy.Content == ContentReassignShared {
+				reassignQuery += ` AND (` + table.shared + `)`
+			}
+			if *table.reassigned, err = execCount(ctx, tx, reassignQuery, userID, policy.ReassignTo); err != nil {
+				return nil, fmt.Errorf("reassign %s: %w", table.name, err)
+			}
+		}
+
+		deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, table.name)
+		if *table.deleted, err = execCount(ctx, tx, deleteQuery, userID); err != nil {
+			return nil, fmt.Errorf("delete %s: %w", table.name, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_preferences WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("delete preferences: %w", err)
+	}
+
+	if result.RolesRevoked, err = execCount(ctx, tx, `DELETE FROM user_roles WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("revoke roles: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM template_shares WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("remove template shares: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM group_members WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("remove group memberships: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_approvers WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("remove approver designations: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE access_requests SET status = 'cancelled' WHERE user_id = $1 AND status = 'pending'`, userID); err != nil {
+		return nil, fmt.Errorf("cancel access requests: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users
+		SET username = 'erased-user-' || id,
+		    email = 'erased-' || id || '@erased.invalid',
+		    full_name = NULL,
+		    password_hash = NULL,
+		    external_id = NULL,
+		    email_verified_at = NULL,
+		    last_login_at = NULL,
+		    is_active = false,
+		    erased_at = NOW(),
+		    updated_at = NOW()
+		WHERE id = $1
+		RETURNING erased_at
+	`, userID).Scan(&result.ErasedAt)
+	if err != nil {
+		return nil, fmt.Errorf("anonymise user: %w", err)
+	}
+
+	if adminsBefore > 0 {
+		admins, err := rbac.CountAdmins(ctx, tx)
+		if err != nil {
+			return nil, fmt.Errorf("count admins: %w", err)
+		}
+		if admins == 0 {
+			return nil, rbac.ErrLastAdmin
+		}
+	}
+
+	metadata, _ := json.Marshal(result)
+	erasedBy := policy.ErasedBy
+	if err := rbac.RecordAudit(ctx, tx, rbac.AuditEntry{
+		UserID:    &userID,
+		Action:    rbac.AuditUserErased,
+		ChangedBy: &erasedBy,
+		Metadata:  metadata,
+	}); err != nil {
+		return nil, fmt.Errorf("audit erasure: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit erasure: %w", err)
+	}
+
+	if s.invalidator != nil {
+		s.invalidator.InvalidateUser(ctx, userID)
+	}
+	return result, nil
+}
+
+func execCount(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,470 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Access request statuses
+const (
+	AccessPending   = "pending"
+	AccessApproved  = "approved"
+	AccessDenied    = "denied"
+	AccessCancelled = "cancelled"
+)
+
+// MaxAccessDuration bounds how long a just-in-time grant may last
+const MaxAccessDuration = 7 * 24 * time.Hour
+
+var (
+	// ErrRequestNotPending is returned when deciding or cancelling a request that was already decided
+	ErrRequestNotPending = errors.New("access request is not pending")
+	// ErrSelfApproval is returned when a user tries to decide their own request
+	ErrSelfApproval = errors.New("cannot approve or deny your own access request")
+)
+
+// AccessRequest is a user's request to hold a role for a limited time
+type AccessRequest struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"user_id"`
+	Username        string     `json:"username"`
+	RoleID          int        `json:"role_id"`
+	RoleName        string     `json:"role_name"`
+	DurationMinutes int        `json:"duration_minutes"`
+	Justification   string     `json:"justification"`
+	Status          string     `json:"status"`
+	DecidedBy       *int       `json:"decided_by,omitempty"`
+	DecidedAt       *time.Time `json:"decided_at,omitempty"`
+	DecisionNote    *string    `json:"decision_note,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// AccessRequestFilter narrows List results; zero values match everything
+type AccessRequestFilter struct {
+	Status string
+	UserID int
+	RoleID int
+	// VisibleTo restricts results to requests made by, or approvable by, this user
+	VisibleTo int
+}
+
+// Approver is a user designated to approve requests for a role
+type Approver struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	AddedAt  time.Time `json:"added_at"`
+	AddedBy  *int      `json:"added_by,omitempty"`
+}
+
+// AccessRequestStore handles database operations for access requests and approvers
+type AccessRequestStore struct {
+	db                   *sql.DB
+	requireVerifiedEmail bool
+	invalidator          Invalidator
+}
+
+// NewAccessRequestStore creates a new access request store
+func NewAccessRequestStore(db *sql.DB) *AccessRequestStore {
+	return &AccessRequestStore{db: db, invalidator: nopInvalidator{}}
+}
+
+// SetInvalidator registers the cache to notify when an approval grants a role
+func (s *AccessRequestStore) SetInvalidator(invalidator Invalidator) {
+	s.invalidator = invalidator
+}
+
+// SetRequireVerifiedEmail controls whether approving a role beyond viewer
+// requires the requester's email to be verified
+func (s *AccessRequestStore) SetRequireVerifiedEmail(required bool) {
+	s.requireVerifiedEmail = required
+}
+
+const accessRequestColumns = `
+	ar.id, ar.user_id, u.username, ar.role_id, r.name, ar.duration_minutes, ar.justification,
+	ar.status, ar.decided_by, ar.decided_at, ar.decision_note, ar.expires_at, ar.created_at, ar.updated_at`
+
+const accessRequestJoins = `
+	FROM access_requests ar
+	JOIN users u ON u.id = ar.user_id
+	JOIN roles r ON r.id = ar.role_id`
+
+// List retrieves access requests matching the filter, newest first
+func (s *AccessRequestStore) List(ctx context.Context, filter AccessRequestFilter) ([]AccessRequest, error) {
+	query := `SELECT ` + accessRequestColumns + accessRequestJoins + ` WHERE 1=1`
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.Status != "" {
+		query += fmt.Sprintf(" AND ar.status = $%d", argCount)
+		args = append(args, filter.Status)
+		argCount++
+	}
+	if filter.UserID != 0 {
+		query += fmt.Sprintf(" AND ar.user_id = $%d", argCount)
+		args = append(args, filter.UserID)
+		argCount++
+	}
+	if filter.RoleID != 0 {
+		query += fmt.Sprintf(" AND ar.role_id = $%d", argCount)
+		args = append(args, filter.RoleID)
+		argCount++
+	}
+	if filter.VisibleTo != 0 {
+		query += fmt.Sprintf(` AND (ar.user_id = $%d OR ar.role_id IN (
+			SELECT role_id FROM role_approvers WHERE user_id = $%d))`, argCount, argCount)
+		args = append(args, filter.VisibleTo)
+		argCount++
+	}
+
+	query += " ORDER BY ar.created_at DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAccessRequests(rows)
+}
+
+// ListExpiredGrants retrieves approved requests whose granted role has lapsed
+func (s *AccessRequestStore) ListExpiredGrants(ctx context.Context) ([]AccessRequest, error) {
+	query := `SELECT ` + accessRequestColumns + accessRequestJoins + `
+		WHERE ar.status = 'approved' AND ar.expires_at <= NOW()
+		ORDER BY ar.expires_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAccessRequests(rows)
+}
+
+// Get retrieves an access request by ID
+func (s *AccessRequestStore) Get(ctx context.Context, id int) (*AccessRequest, error) {
+	query := `SELECT ` + accessRequestColumns + accessRequestJoins + ` WHERE ar.id = $1`
+
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests, err := scanAccessRequests(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &requests[0], nil
+}
+
+// Create records a pending request and audits it
+func (s *AccessRequestStore) Create(ctx context.Context, userID, roleID int, duration time.Duration, justification string) (*AccessRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO access_requests (user_id, role_id, duration_minutes, justification)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, userID, roleID, int(duration/time.Minute), justification).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RecordAudit(ctx, tx, AuditEntry{
+		UserID:    &userID,
+		RoleID:    &roleID,
+		Action:    AuditAccessRequested,
+		ChangedBy: &userID,
+		Metadata:  accessMetadata(id, map[string]interface{}{"duration_minutes": int(duration / time.Minute)}),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, id)
+}
+
+// Approve grants the requested role until now + the requested duration.
+// An existing permanent assignment is left permanent and a longer existing
+// expiry is not shortened. The approver must be able to grant the role
+// themselves (an *EscalationError otherwise), and the requester's email must
+// be verified when that is required (ErrEmailNotVerified).
+func (s *AccessRequestStore) Approve(ctx context.Context, id, approverID int, note string) (*AccessRequest, error) {
+	// The approver's permissions as they stand before the approval
+	approver, err := NewUserRoleStore(s.db).LoadPolicy(ctx, approverID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	req, err := lockPendingRequest(ctx, tx, id, approverID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCanGrant(ctx, tx, approver, req.roleID, nil); err != nil {
+		return nil, err
+	}
+
+	if s.requireVerifiedEmail {
+		var roleName string
+		var verified bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT r.name, u.email_verified_at IS NOT NULL
+			FROM roles r, users u
+			WHERE r.id = $1 AND u.id = $2
+		`, req.roleID, req.userID).Scan(&roleName, &verified)
+		if err != nil {
+			return nil, err
+		}
+		if roleName != "viewer" && !verified {
+			return nil, ErrEmailNotVerified
+		}
+	}
+
+	var expiresAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		UPDATE access_requests
+		SET status = 'approved', decided_by = $2, decided_at = NOW(), decision_note = NULLIF($3, ''),
+		    expires_at = NOW() + make_interval(mins => duration_minutes)
+		WHERE id = $1
+		RETURNING expires_at
+	`, id, approverID, note).Scan(&expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = enforceSoD(ctx, tx, []int{req.userID}, func() error {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role_id, assigned_by, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, role_id, (COALESCE(resource_type, '')), (COALESCE(resource_id, ''))) DO UPDATE
+		SET expires_at = CASE
+			WHEN user_roles.expires_at IS NULL THEN NULL
+			ELSE GREATEST(user_roles.expires_at, EXCLUDED.expires_at)
+		END,
+		expiry_warned_days = NULL
+	`, req.userID, req.roleID, approverID, expiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := accessMetadata(id, map[string]interface{}{"expires_at": expiresAt, "note": note})
+	for _, action := range []string{AuditAccessApproved, AuditRoleGranted} {
+		if err := RecordAudit(ctx, tx, AuditEntry{
+			UserID:    &req.userID,
+			RoleID:    &req.roleID,
+			Action:    action,
+			ChangedBy: &approverID,
+			Metadata:  metadata,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.invalidator.InvalidateUser(ctx, req.userID)
+	return s.Get(ctx, id)
+}
+
+// Deny rejects a pending request
+func (s *AccessRequestStore) Deny(ctx context.Context, id, approverID int, note string) (*AccessRequest, error) {
+	return s.finish(ctx, id, approverID, AccessDenied, AuditAccessDenied, note)
+}
+
+// Cancel withdraws a pending request; only the requester may cancel
+func (s *AccessRequestStore) Cancel(ctx context.Context, id, userID int) (*AccessRequest, error) {
+	return s.finish(ctx, id, userID, AccessCancelled, AuditAccessCancelled, "")
+}
+
+// finish moves a pending request to a final status without granting anything
+func (s *AccessRequestStore) finish(ctx context.Context, id, actorID int, status, action, note string) (*AccessRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var req *pendingRequest
+	if status == AccessCancelled {
+		req, err = lockPendingRequest(ctx, tx, id, 0)
+		if err == nil && req.userID != actorID {
+			err = sql.ErrNoRows // Only the requester can see this request as cancellable
+		}
+	} else {
+		req, err = lockPendingRequest(ctx, tx, id, actorID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE access_requests
+		SET status = $2, decided_by = $3, decided_at = NOW(), decision_note = NULLIF($4, '')
+		WHERE id = $1
+	`, id, status, actorID, note)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RecordAudit(ctx, tx, AuditEntry{
+		UserID:    &req.userID,
+		RoleID:    &req.roleID,
+		Action:    action,
+		ChangedBy: &actorID,
+		Metadata:  accessMetadata(id, map[string]interface{}{"note": note}),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, id)
+}
+
+// IsApprover reports whether a user is a designated approver for a role
+func (s *AccessRequestStore) IsApprover(ctx context.Context, roleID, userID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM role_approvers WHERE role_id = $1 AND user_id = $2)
+	`, roleID, userID).Scan(&exists)
+	return exists, err
+}
+
+// ListApprovers retrieves the designated approvers for a role
+func (s *AccessRequestStore) ListApprovers(ctx context.Context, roleID int) ([]Approver, error) {
+	query := `
+		SELECT u.id, u.username, u.email, ra.added_at, ra.added_by
+		FROM role_approvers ra
+		JOIN users u ON u.id = ra.user_id
+		WHERE ra.role_id = $1 AND u.is_active = true
+		ORDER BY u.username
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvers []Approver
+	for rows.Next() {
+		var a Approver
+		if err := rows.Scan(&a.UserID, &a.Username, &a.Email, &a.AddedAt, &a.AddedBy); err != nil {
+			return nil, err
+		}
+		approvers = append(approvers, a)
+	}
+
+	return approvers, rows.Err()
+}
+
+// AddApprover designates a user as an approver for a role.
+// Returns sql.ErrNoRows if the role or user does not exist.
+func (s *AccessRequestStore) AddApprover(ctx context.Context, roleID, userID, addedBy int) error {
+	query := `
+		INSERT INTO role_approvers (role_id, user_id, added_by)
+		VALUES ($1, $2, NULLIF($3, 0))
+		ON CONFLICT (role_id, user_id) DO NOTHING
+	`
+
+	_, err := s.db.ExecContext(ctx, query, roleID, userID, addedBy)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// RemoveApprover removes a user from a role's approvers
+func (s *AccessRequestStore) RemoveApprover(ctx context.Context, roleID, userID int) error {
+	query := `DELETE FROM role_approvers WHERE role_id = $1 AND user_id = $2`
+
+	_, err := s.db.ExecContext(ctx, query, roleID, userID)
+	return err
+}
+
+type pendingRequest struct {
+	userID int
+	roleID int
+}
+
+// lockPendingRequest locks a request row for a decision. A non-zero deciderID
+// must not be the requester.
+func lockPendingRequest(ctx context.Context, tx *sql.Tx, id, deciderID int) (*pendingRequest, error) {
+	var req pendingRequest
+	var status string
+	err := tx.QueryRowContext(ctx, `
+		SELECT user_id, role_id, status FROM access_requests WHERE id = $1 FOR UPDATE
+	`, id).Scan(&req.userID, &req.roleID, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != AccessPending {
+		return nil, ErrRequestNotPending
+	}
+	if deciderID != 0 && deciderID == req.userID {
+		return nil, ErrSelfApproval
+	}
+
+	return &req, nil
+}
+
+func accessMetadata(requestID int, fields map[string]interface{}) json.RawMessage {
+	fields["access_request_id"] = requestID
+	data, _ := json.Marshal(fields)
+	return data
+}
+
+func scanAccessRequests(rows *sql.Rows) ([]AccessRequest, error) {
+	var requests []AccessRequest
+	for rows.Next() {
+		var r AccessRequest
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Username, &r.RoleID, &r.RoleName, &r.DurationMinutes, &r.Justification,
+			&r.Status, &r.DecidedBy, &r.DecidedAt, &r.DecisionNote, &r.ExpiresAt, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+
+	return requests, rows.Err()
+}
@@ -0,0 +1,680 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Access review campaign statuses
+const (
+	ReviewOpen   = "open"
+	ReviewClosed = "closed"
+)
+
+// Access review item decisions
+const (
+	ReviewPending   = "pending"
+	ReviewCertified = "certified"
+	ReviewRevoked   = "revoked"
+	ReviewExpired   = "expired" // Still pending when a campaign expiring unreviewed entries closed
+)
+
+var (
+	// ErrCampaignClosed is returned when deciding on, or closing, a closed campaign
+	ErrCampaignClosed = errors.New("access review campaign is closed")
+	// ErrAlreadyReviewed is returned when deciding on an item that was already decided
+	ErrAlreadyReviewed = errors.New("assignment has already been reviewed")
+	// ErrSelfReview is returned when a reviewer decides on their own assignment,
+	// or on a role of a group they belong to
+	ErrSelfReview = errors.New("cannot review your own role assignment")
+	// ErrNoReviewers is returned when a campaign would open with nobody to review it
+	ErrNoReviewers = errors.New("access review has no reviewers: give reviewer_ids or designate approvers for the role")
+)
+
+// ReviewCampaign is a round of certifying the role assignments for a role or
+// for a group's members
+type ReviewCampaign struct {
+	ID               int        `json:"id"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	RoleID           *int       `json:"role_id,omitempty"`
+	RoleName         *string    `json:"role_name,omitempty"`
+	GroupID          *int       `json:"group_id,omitempty"`
+	GroupName        *string    `json:"group_name,omitempty"`
+	Status           string     `json:"status"`
+	DueAt            *time.Time `json:"due_at,omitempty"`
+	ExpireUnreviewed bool       `json:"expire_unreviewed"`
+	CreatedBy        *int       `json:"created_by,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ClosedBy         *int       `json:"closed_by,omitempty"`
+	ClosedAt         *time.Time `json:"closed_at,omitempty"`
+	// Item counts by decision
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Certified int `json:"certified"`
+	Revoked   int `json:"revoked"`
+	Expired   int `json:"expired"`
+	// Reviewers is only filled by GetCampaign
+	Reviewers []Reviewer `json:"reviewers,omitempty"`
+}
+
+// Reviewer is a user designated to decide on a campaign's items
+type Reviewer struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// ReviewItem is one user_roles or group_roles entry under review, copied when
+// the campaign opened. A user item has the User fields set, a group item the
+// Group ones.
+type ReviewItem struct {
+	ID            int        `json:"id"`
+	CampaignID    int        `json:"campaign_id"`
+	UserRoleID    *int       `json:"user_role_id,omitempty"`
+	UserID        *int       `json:"user_id,omitempty"`
+	Username      *string    `json:"username,omitempty"`
+	Email         *string    `json:"email,omitempty"`
+	GroupRoleID   *int       `json:"group_role_id,omitempty"`
+	GroupID       *int       `json:"group_id,omitempty"`
+	GroupName     *string    `json:"group_name,omitempty"`
+	RoleID        int        `json:"role_id"`
+	RoleName      string     `json:"role_name"`
+	ResourceType  *string    `json:"resource_type,omitempty"`
+	ResourceID    *string    `json:"resource_id,omitempty"`
+	AssignedAt    *time.Time `json:"assigned_at,omitempty"`
+	AssignedBy    *int       `json:"assigned_by,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Decision      string     `json:"decision"`
+	DecidedBy     *int       `json:"decided_by,omitempty"`
+	DecidedByName *string    `json:"decided_by_name,omitempty"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+	Note          *string    `json:"note,omitempty"`
+}
+
+// NewReviewCampaign describes a campaign to open. Exactly one of RoleID and
+// GroupID is set. Without ReviewerIDs a role campaign is reviewed by the
+// role's designated approvers; a campaign left with no reviewers is rejected
+// with ErrNoReviewers.
+type NewReviewCampaign struct {
+	Name             string
+	Description      string
+	RoleID           int
+	GroupID          int
+	DueAt            *time.Time
+	ExpireUnreviewed bool
+	ReviewerIDs      []int
+}
+
+// ReviewCampaignFilter narrows ListCampaigns results; zero values match everything
+type ReviewCampaignFilter struct {
+	Status string
+	// ReviewerID restricts results to campaigns this user reviews
+	ReviewerID int
+}
+
+// AccessReviewStore handles database operations for access review campaigns
+type AccessReviewStore struct {
+	db          *sql.DB
+	invalidator Invalidator
+}
+
+// NewAccessReviewStore creates a new access review store
+func NewAccessReviewStore(db *sql.DB) *AccessReviewStore {
+	return &AccessReviewStore{db: db, invalidator: nopInvalidator{}}
+}
+
+// SetInvalidator registers the cache to notify when a review removes a role
+func (s *AccessReviewStore) SetInvalidator(invalidator Invalidator) {
+	s.invalidator = invalidator
+}
+
+const reviewCampaignColumns = `
+	c.id, c.name, COALESCE(c.description, ''), c.role_id, r.name, c.group_id, g.name, c.status, c.due_at,
+	c.expire_unreviewed, c.created_by, c.created_at, c.closed_by, c.closed_at,
+	COUNT(i.id),
+	COUNT(i.id) FILTER (WHERE i.decision = 'pending'),
+	COUNT(i.id) FILTER (WHERE i.decision = 'certified'),
+	COUNT(i.id) FILTER (WHERE i.decision = 'revoked'),
+	COUNT(i.id) FILTER (WHERE i.decision = 'expired')`
+
+const reviewCampaignJoins = `
+	FROM access_review_campaigns c
+	LEFT JOIN roles r ON r.id = c.role_id
+	LEFT JOIN groups g ON g.id = c.group_id
+	LEFT JOIN access_review_items i ON i.campaign_id = c.id`
+
+// ListCampaigns retrieves campaigns matching the filter, newest first
+func (s *AccessReviewStore) ListCampaigns(ctx context.Context, filter ReviewCampaignFilter) ([]ReviewCampaign, error) {
+	query := `SELECT ` + reviewCampaignColumns + reviewCampaignJoins + ` WHERE 1=1`
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.Status != "" {
+		query += fmt.Sprintf(" AND c.status = $%d", argCount)
+		args = append(args, filter.Status)
+		argCount++
+	}
+	if filter.ReviewerID != 0 {
+		query += fmt.Sprintf(` AND c.id IN (
+			SELECT campaign_id FROM access_review_reviewers WHERE user_id = $%d)`, argCount)
+		args = append(args, filter.ReviewerID)
+		argCount++
+	}
+
+	query += " GROUP BY c.id, r.name, g.name ORDER BY c.created_at DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanReviewCampaigns(rows)
+}
+
+// GetCampaign retrieves a campaign with its reviewers
+func (s *AccessReviewStore) GetCampaign(ctx context.Context, id int) (*ReviewCampaign, error) {
+	query := `SELECT ` + reviewCampaignColumns + reviewCampaignJoins + `
+		WHERE c.id = $1
+		GROUP BY c.id, r.name, g.name`
+
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campaigns, err := scanReviewCampaigns(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(campaigns) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	campaign := &campaigns[0]
+
+	campaign.Reviewers, err = s.listReviewers(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// CreateCampaign opens a campaign, snapshotting every active assignment in
+// its scope as a pending item: a role campaign takes the role's user and
+// group assignments, a group campaign its members' assignments and the
+// group's own roles
+func (s *AccessReviewStore) CreateCampaign(ctx context.Context, input NewReviewCampaign, createdBy int) (*ReviewCampaign, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO access_review_campaigns (name, description, role_id, group_id, due_at, expire_unreviewed, created_by)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, 0), NULLIF($4, 0), $5, $6, NULLIF($7, 0))
+		RETURNING id
+	`, input.Name, input.Description, input.RoleID, input.GroupID, input.DueAt, input.ExpireUnreviewed, createdBy).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input.ReviewerIDs) > 0 {
+		ids := make([]int64, len(input.ReviewerIDs))
+		for i, reviewerID := range input.ReviewerIDs {
+			ids[i] = int64(reviewerID)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO access_review_reviewers (campaign_id, user_id)
+			SELECT $1, u.id FROM users u WHERE u.id = ANY($2)
+			ON CONFLICT DO NOTHING
+		`, id, pq.Array(ids))
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO access_review_reviewers (campaign_id, user_id)
+			SELECT $1, user_id FROM role_approvers WHERE role_id = $2
+		`, id, input.RoleID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reviewers int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM access_review_reviewers WHERE campaign_id = $1`, id).Scan(&reviewers); err != nil {
+		return nil, err
+	}
+	if reviewers == 0 {
+		return nil, ErrNoReviewers
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO access_review_items
+			(campaign_id, user_role_id, user_id, role_id, resource_type, resource_id, assigned_at, assigned_by, expires_at)
+		SELECT $1, ur.id, ur.user_id, ur.role_id, ur.resource_type, ur.resource_id, ur.assigned_at, ur.assigned_by, ur.expires_at
+		FROM user_roles ur
+		WHERE (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		  AND ($2 = 0 OR ur.role_id = $2)
+		  AND ($3 = 0 OR ur.user_id IN (SELECT user_id FROM group_members WHERE group_id = $3))
+	`, id, input.RoleID, input.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO access_review_items
+			(campaign_id, group_role_id, group_id, role_id, assigned_at, assigned_by, expires_at)
+		SELECT $1, gr.id, gr.group_id, gr.role_id, gr.assigned_at, gr.assigned_by, gr.expires_at
+		FROM group_roles gr
+		WHERE (gr.expires_at IS NULL OR gr.expires_at > NOW())
+		  AND ($2 = 0 OR gr.role_id = $2)
+		  AND ($3 = 0 OR gr.group_id = $3)
+	`, id, input.RoleID, input.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetCampaign(ctx, id)
+}
+
+// ListItems retrieves a campaign's items, optionally only those with a decision
+func (s *AccessReviewStore) ListItems(ctx context.Context, campaignID int, decision string) ([]ReviewItem, error) {
+	query := `
+		SELECT i.id, i.campaign_id, i.user_role_id, i.user_id, u.username, u.email,
+		       i.group_role_id, i.group_id, g.name, i.role_id, r.name,
+		       i.resource_type, i.resource_id, i.assigned_at, i.assigned_by, i.expires_at,
+		       i.decision, i.decided_by, d.username, i.decided_at, i.note
+		FROM access_review_items i
+		LEFT JOIN users u ON u.id = i.user_id
+		LEFT JOIN groups g ON g.id = i.group_id
+		JOIN roles r ON r.id = i.role_id
+		LEFT JOIN users d ON d.id = i.decided_by
+		WHERE i.campaign_id = $1 AND ($2 = '' OR i.decision = $2)
+		ORDER BY i.group_id IS NOT NULL, u.username, g.name, r.name, i.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, campaignID, decision)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ReviewItem
+	for rows.Next() {
+		var it ReviewItem
+		if err := rows.Scan(&it.ID, &it.CampaignID, &it.UserRoleID, &it.UserID, &it.Username, &it.Email,
+			&it.GroupRoleID, &it.GroupID, &it.GroupName, &it.RoleID, &it.RoleName,
+			&it.ResourceType, &it.ResourceID, &it.AssignedAt, &it.AssignedBy, &it.ExpiresAt,
+			&it.Decision, &it.DecidedBy, &it.DecidedByName, &it.DecidedAt, &it.Note); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+
+	return items, rows.Err()
+}
+
+// IsReviewer reports whether a user is a designated reviewer for a campaign
+func (s *AccessReviewStore) IsReviewer(ctx context.Context, campaignID, userID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM access_review_reviewers WHERE campaign_id = $1 AND user_id = $2)
+	`, campaignID, userID).Scan(&exists)
+	return exists, err
+}
+
+// Certify records that an assignment is still needed
+func (s *AccessReviewStore) Certify(ctx context.Context, campaignID, itemID, reviewerID int, note string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	item, err := lockPendingItem(ctx, tx, campaignID, itemID, reviewerID)
+	if err != nil {
+		return err
+	}
+
+	if err := decideItem(ctx, tx, itemID, ReviewCertified, reviewerID, note); err != nil {
+		return err
+	}
+
+	if err := RecordAudit(ctx, tx, AuditEntry{
+		UserID:    item.userID,
+		RoleID:    &item.roleID,
+		Action:    AuditRoleCertified,
+		ChangedBy: &reviewerID,
+		Metadata:  reviewMetadata(campaignID, item, note),
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Revoke removes an assignment on a reviewer's decision. Returns ErrLastAdmin
+// if that would leave no active admin.
+func (s *AccessReviewStore) Revoke(ctx context.Context, campaignID, itemID, reviewerID int, note string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return err
+	}
+
+	item, err := lockPendingItem(ctx, tx, campaignID, itemID, reviewerID)
+	if err != nil {
+		return err
+	}
+
+	adminsBefore, err := CountAdmins(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	// The entry may already be gone (removed or expired since the campaign opened)
+	if item.groupRoleID != nil {
+		_, err = tx.ExecContext(ctx, `DELETE FROM group_roles WHERE id = $1`, *item.groupRoleID)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM user_roles WHERE id = $1`, *item.userRoleID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if adminsBefore > 0 {
+		admins, err := CountAdmins(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if admins == 0 {
+			return ErrLastAdmin
+		}
+	}
+
+	if err := decideItem(ctx, tx, itemID, ReviewRevoked, reviewerID, note); err != nil {
+		return err
+	}
+
+	if err := RecordAudit(ctx, tx, AuditEntry{
+		UserID:    item.userID,
+		RoleID:    &item.roleID,
+		Action:    AuditRoleRevoked,
+		ChangedBy: &reviewerID,
+		Metadata:  reviewMetadata(campaignID, item, note),
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.invalidate(ctx, []pendingItem{*item})
+	return nil
+}
+
+// CloseCampaign ends a campaign. If it expires unreviewed entries, every
+// still-pending assignment is set to expire now (the role expiry job archives
+// user and group assignments), audited as revoked by closedBy, and its item marked
+// expired. Returns ErrLastAdmin if that would leave no active admin.
+func (s *AccessReviewStore) CloseCampaign(ctx context.Context, campaignID, closedBy int) (*ReviewCampaign, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	var status string
+	var expireUnreviewed bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, expire_unreviewed FROM access_review_campaigns WHERE id = $1 FOR UPDATE
+	`, campaignID).Scan(&status, &expireUnreviewed)
+	if err != nil {
+		return nil, err
+	}
+	if status != ReviewOpen {
+		return nil, ErrCampaignClosed
+	}
+
+	var expired []pendingItem
+	if expireUnreviewed {
+		adminsBefore, err := CountAdmins(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, query := range []string{`
+			UPDATE user_roles ur
+			SET expires_at = NOW()
+			FROM access_review_items i
+			WHERE i.campaign_id = $1 AND i.decision = 'pending' AND ur.id = i.user_role_id
+			  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+			RETURNING i.user_role_id, i.user_id, i.group_role_id, i.group_id, i.role_id
+		`, `
+			UPDATE group_roles gr
+			SET expires_at = NOW()
+			FROM access_review_items i
+			WHERE i.campaign_id = $1 AND i.decision = 'pending' AND gr.id = i.group_role_id
+			  AND (gr.expires_at IS NULL OR gr.expires_at > NOW())
+			RETURNING i.user_role_id, i.user_id, i.group_role_id, i.group_id, i.role_id
+		`} {
+			items, err := queryPendingItems(ctx, tx, query, campaignID)
+			if err != nil {
+				return nil, err
+			}
+			expired = append(expired, items...)
+		}
+
+		if adminsBefore > 0 {
+			admins, err := CountAdmins(ctx, tx)
+			if err != nil {
+				return nil, err
+			}
+			if admins == 0 {
+				return nil, ErrLastAdmin
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE access_review_items
+			SET decision = 'expired', decided_at = NOW(), note = $2
+			WHERE campaign_id = $1 AND decision = 'pending'
+		`, campaignID, expiredNote)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range expired {
+			item := &expired[i]
+			if err := RecordAudit(ctx, tx, AuditEntry{
+				UserID:    item.userID,
+				RoleID:    &item.roleID,
+				Action:    AuditRoleRevoked,
+				ChangedBy: changedByRef(closedBy),
+				Metadata:  reviewMetadata(campaignID, item, expiredNote),
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE access_review_campaigns
+		SET status = 'closed', closed_by = NULLIF($2, 0), closed_at = NOW()
+		WHERE id = $1
+	`, campaignID, closedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.invalidate(ctx, expired)
+	return s.GetCampaign(ctx, campaignID)
+}
+
+// expiredNote is recorded on items still pending when a campaign expiring
+// unreviewed entries closes
+const expiredNote = "not reviewed before the campaign closed"
+
+// invalidate drops cached permissions affected by removing items'
+// assignments: their users', or everyone's for a group assignment
+func (s *AccessReviewStore) invalidate(ctx context.Context, items []pendingItem) {
+	invalidated := make(map[int]bool)
+	for _, item := range items {
+		if item.groupRoleID != nil {
+			s.invalidator.InvalidateAll(ctx)
+			return
+		}
+		if !invalidated[*item.userID] {
+			invalidated[*item.userID] = true
+			s.invalidator.InvalidateUser(ctx, *item.userID)
+		}
+	}
+}
+
+func (s *AccessReviewStore) listReviewers(ctx context.Context, campaignID int) ([]Reviewer, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT u.id, u.username, u.email
+		FROM access_review_reviewers rr
+		JOIN users u ON u.id = rr.user_id
+		WHERE rr.campaign_id = $1
+		ORDER BY u.username
+	`, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviewers := []Reviewer{}
+	for rows.Next() {
+		var r Reviewer
+		if err := rows.Scan(&r.UserID, &r.Username, &r.Email); err != nil {
+			return nil, err
+		}
+		reviewers = append(reviewers, r)
+	}
+
+	return reviewers, rows.Err()
+}
+
+// pendingItem is the assignment an item reviews: a user's (userRoleID and
+// userID set) or a group's (groupRoleID and groupID set)
+type pendingItem struct {
+	userRoleID  *int
+	userID      *int
+	groupRoleID *int
+	groupID     *int
+	roleID      int
+}
+
+// lockPendingItem locks an undecided item of an open campaign for a decision
+// by reviewerID, who must not hold the assignment or belong to its group
+func lockPendingItem(ctx context.Context, tx *sql.Tx, campaignID, itemID, reviewerID int) (*pendingItem, error) {
+	var item pendingItem
+	var decision, status string
+	var member bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT i.user_role_id, i.user_id, i.group_role_id, i.group_id, i.role_id, i.decision, c.status,
+		       EXISTS(SELECT 1 FROM group_members gm WHERE gm.group_id = i.group_id AND gm.user_id = $3)
+		FROM access_review_items i
+		JOIN access_review_campaigns c ON c.id = i.campaign_id
+		WHERE i.id = $1 AND i.campaign_id = $2
+		FOR UPDATE OF i FOR SHARE OF c
+	`, itemID, campaignID, reviewerID).Scan(&item.userRoleID, &item.userID, &item.groupRoleID, &item.groupID, &item.roleID, &decision, &status, &member)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != ReviewOpen {
+		return nil, ErrCampaignClosed
+	}
+	if decision != ReviewPending {
+		return nil, ErrAlreadyReviewed
+	}
+	if member || (item.userID != nil && reviewerID == *item.userID) {
+		return nil, ErrSelfReview
+	}
+
+	return &item, nil
+}
+
+// queryPendingItems runs a statement returning (user_role_id, user_id,
+// group_role_id, group_id, role_id) rows
+func queryPendingItems(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]pendingItem, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []pendingItem
+	for rows.Next() {
+		var item pendingItem
+		if err := rows.Scan(&item.userRoleID, &item.userID, &item.groupRoleID, &item.groupID, &item.roleID); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func decideItem(ctx context.Context, tx *sql.Tx, itemID int, decision string, reviewerID int, note string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE access_review_items
+		SET decision = $2, decided_by = $3, decided_at = NOW(), note = NULLIF($4, '')
+		WHERE id = $1
+	`, itemID, decision, reviewerID, note)
+	return err
+}
+
+func reviewMetadata(campaignID int, item *pendingItem, note string) json.RawMessage {
+	fields := map[string]interface{}{
+		"access_review_id": campaignID,
+		"note":             note,
+	}
+	if item.groupRoleID != nil {
+		fields["group_role_id"], fields["group_id"] = *item.groupRoleID, *item.groupID
+	} else {
+		fields["user_role_id"] = *item.userRoleID
+	}
+	data, _ := json.Marshal(fields)
+	return data
+}
+
+func scanReviewCampaigns(rows *sql.Rows) ([]ReviewCampaign, error) {
+	var campaigns []ReviewCampaign
+	for rows.Next() {
+		var c ReviewCampaign
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.RoleID, &c.RoleName, &c.GroupID, &c.GroupName, &c.Status, &c.DueAt,
+			&c.ExpireUnreviewed, &c.CreatedBy, &c.CreatedAt, &c.ClosedBy, &c.ClosedAt,
+			&c.Total, &c.Pending, &c.Certified, &c.Revoked, &c.Expired); err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, c)
+	}
+	return campaigns, rows.Err()
+}
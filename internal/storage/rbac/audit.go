@@ -0,0 +1,112 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Audit actions recorded in permission_audit
+const (
+	AuditRoleGranted       = "role_granted"
+	AuditRoleRevoked       = "role_revoked"
+	AuditRoleExpired       = "role_expired"
+	AuditRoleCertified     = "role_certified"
+	AuditPermissionGranted = "permission_granted"
+	AuditPermissionRevoked = "permission_revoked"
+	AuditUserErased        = "user_erased"
+	AuditAccessRequested   = "access_requested"
+	AuditAccessApproved    = "access_approved"
+	AuditAccessDenied      = "access_denied"
+	AuditAccessCancelled   = "access_cancelled"
+	AuditPolicyApplied     = "policy_applied"
+)
+
+// AuditEntry is a row in the permission audit log
+type AuditEntry struct {
+	ID           int             `json:"id"`
+	UserID       *int            `json:"user_id,omitempty"`
+	RoleID       *int            `json:"role_id,omitempty"`
+	PermissionID *int            `json:"permission_id,omitempty"`
+	Action       string          `json:"action"`
+	ChangedBy    *int            `json:"changed_by,omitempty"`
+	Timestamp    time.Time       `json:"timestamp"`
+	Metadata     json.RawMessage `json:"metadata,omitempty"`
+}
+
+// AuditStore handles database operations for the permission audit log
+type AuditStore struct {
+	db *sql.DB
+}
+
+// NewAuditStore creates a new audit store
+func NewAuditStore(db *sql.DB) *AuditStore {
+	return &AuditStore{db: db}
+}
+
+// Record appends an entry to the audit log
+func (s *AuditStore) Record(ctx context.Context, entry AuditEntry) error {
+	return RecordAudit(ctx, s.db, entry)
+}
+
+// ListForUser retrieves audit entries about a user or made by them
+func (s *AuditStore) ListForUser(ctx context.Context, userID int) ([]AuditEntry, error) {
+	query := `
+		SELECT id, user_id, role_id, permission_id, COALESCE(action, ''), changed_by, timestamp, metadata
+		FROM permission_audit
+		WHERE user_id = $1 OR changed_by = $1
+		ORDER BY timestamp DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var metadata []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.RoleID, &e.PermissionID, &e.Action, &e.ChangedBy, &e.Timestamp, &metadata); err != nil {
+			return nil, err
+		}
+		if metadata != nil {
+			e.Metadata = metadata
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Execer is satisfied by *sql.DB and *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// RecordAudit inserts an audit entry using a DB or transaction, so callers
+// can audit a change atomically with the change itself
+func RecordAudit(ctx context.Context, db Execer, entry AuditEntry) error {
+	var metadata interface{}
+	if len(entry.Metadata) > 0 {
+		metadata = []byte(entry.Metadata)
+	}
+
+	query := `
+		INSERT INTO permission_audit (user_id, role_id, permission_id, action, changed_by, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := db.ExecContext(ctx, query, entry.UserID, entry.RoleID, entry.PermissionID, entry.Action, entry.ChangedBy, metadata)
+	return err
+}
+
+// changedByRef returns the audit actor for a user ID, or nil for the system (0)
+func changedByRef(userID int) *int {
+	if userID == 0 {
+		return nil
+	}
+	return &userID
+}
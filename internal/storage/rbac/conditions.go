@@ -0,0 +1,260 @@
+package rbac
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConditions is returned for malformed access conditions
+var ErrInvalidConditions = errors.New("invalid conditions")
+
+// Condition names reported in outcomes
+const (
+	ConditionTimeWindow = "time_window"
+	ConditionSourceCIDR = "source_cidr"
+	ConditionSessionAge = "session_age"
+)
+
+// Conditions restrict a role assignment or role permission grant to requests
+// matching every condition set. A zero Conditions always holds.
+type Conditions struct {
+	// TimeWindows: the request time must fall inside at least one
+	TimeWindows []TimeWindow `json:"time_windows,omitempty"`
+	// SourceCIDRs: the client address must be inside at least one
+	SourceCIDRs []string `json:"source_cidrs,omitempty"`
+	// MaxSessionAgeMinutes: the session must have been established at most this long ago
+	MaxSessionAgeMinutes int `json:"max_session_age_minutes,omitempty"`
+}
+
+// TimeWindow is a daily span of local time on some weekdays. A window whose
+// end is before its start wraps past midnight and belongs to the day it starts.
+type TimeWindow struct {
+	Days     []string `json:"days,omitempty"`     // "mon" … "sun"; empty = every day
+	Start    string   `json:"start"`              // "HH:MM", inclusive
+	End      string   `json:"end"`                // "HH:MM", exclusive
+	Timezone string   `json:"timezone,omitempty"` // IANA name; empty = UTC
+}
+
+// RequestContext is the request state conditions are evaluated against
+type RequestContext struct {
+	Time             time.Time  `json:"time"`
+	SourceIP         string     `json:"source_ip,omitempty"`
+	SessionStartedAt *time.Time `json:"session_started_at,omitempty"`
+}
+
+// ConditionOutcome is the result of evaluating one condition
+type ConditionOutcome struct {
+	Condition string `json:"condition"`
+	Satisfied bool   `json:"satisfied"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// UnmarshalJSON rejects unknown fields, so a condition this version can't
+// evaluate (or a misspelt one) is an error rather than silently dropped
+func (c *Conditions) UnmarshalJSON(data []byte) error {
+	type plain Conditions
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var decoded plain
+	if err := dec.Decode(&decoded); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConditions, err)
+	}
+	*c = Conditions(decoded)
+	return nil
+}
+
+// IsZero reports whether the conditions impose nothing
+func (c *Conditions) IsZero() bool {
+	return c == nil || (len(c.TimeWindows) == 0 && len(c.SourceCIDRs) == 0 && c.MaxSessionAgeMinutes == 0)
+}
+
+// Validate checks every window, timezone and CIDR parses, and that only
+// conditions a session can meet are set
+func (c *Conditions) Validate() error {
+	for _, w := range c.TimeWindows {
+		for _, day := range w.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("%w: unknown day %q", ErrInvalidConditions, day)
+			}
+		}
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return fmt.Errorf("%w: time window %s-%s is empty", ErrInvalidConditions, w.Start, w.End)
+		}
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidConditions, w.Timezone)
+		}
+	}
+	for _, cidr := range c.SourceCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("%w: invalid CIDR %q", ErrInvalidConditions, cidr)
+		}
+	}
+	if c.MaxSessionAgeMinutes < 0 {
+		return fmt.Errorf("%w: max_session_age_minutes must not be negative", ErrInvalidConditions)
+	}
+	return nil
+}
+
+// Evaluate checks each condition set against the request. Without a request
+// context every condition is reported unsatisfied.
+func (c *Conditions) Evaluate(rc *RequestContext) ([]ConditionOutcome, bool) {
+	var outcomes []ConditionOutcome
+	check := func(condition string, evaluate func() ConditionOutcome) {
+		if rc == nil {
+			outcomes = append(outcomes, ConditionOutcome{Condition: condition, Detail: "no request context"})
+			return
+		}
+		outcome := evaluate()
+		outcome.Condition = condition
+		outcomes = append(outcomes, outcome)
+	}
+
+	if len(c.TimeWindows) > 0 {
+		check(ConditionTimeWindow, func() ConditionOutcome { return c.evaluateTime(rc) })
+	}
+	if len(c.SourceCIDRs) > 0 {
+		check(ConditionSourceCIDR, func() ConditionOutcome { return c.evaluateSource(rc) })
+	}
+	if c.MaxSessionAgeMinutes > 0 {
+		check(ConditionSessionAge, func() ConditionOutcome { return c.evaluateSessionAge(rc) })
+	}
+
+	met := true
+	for _, outcome := range outcomes {
+		met = met && outcome.Satisfied
+	}
+	return outcomes, met
+}
+
+func (c *Conditions) evaluateTime(rc *RequestContext) ConditionOutcome {
+	for _, w := range c.TimeWindows {
+		if w.contains(rc.Time) {
+			return ConditionOutcome{Satisfied: true, Detail: "within " + w.String()}
+		}
+	}
+	return ConditionOutcome{Detail: "outside every time window"}
+}
+
+func (c *Conditions) evaluateSource(rc *RequestContext) ConditionOutcome {
+	ip := net.ParseIP(rc.SourceIP)
+	if ip == nil {
+		return ConditionOutcome{Detail: "source address unknown"}
+	}
+	for _, cidr := range c.SourceCIDRs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return ConditionOutcome{Satisfied: true, Detail: rc.SourceIP + " in " + cidr}
+		}
+	}
+	return ConditionOutcome{Detail: rc.SourceIP + " outside every allowed network"}
+}
+
+func (c *Conditions) evaluateSessionAge(rc *RequestContext) ConditionOutcome {
+	if rc.SessionStartedAt == nil {
+		return ConditionOutcome{Detail: "no session"}
+	}
+	age := rc.Time.Sub(*rc.SessionStartedAt)
+	limit := time.Duration(c.MaxSessionAgeMinutes) * time.Minute
+	return ConditionOutcome{
+		Satisfied: age <= limit,
+		Detail:    fmt.Sprintf("session age %s, limit %s", age.Truncate(time.Second), limit),
+	}
+}
+
+// contains reports whether t falls inside the window
+func (w TimeWindow) contains(t time.Time) bool {
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return w.onDay(local.Weekday()) && minute >= start && minute < end
+	}
+	// Wraps past midnight: the late part is on the window's day, the early part on the next
+	return (w.onDay(local.Weekday()) && minute >= start) ||
+		(w.onDay((local.Weekday()+6)%7) && minute < end)
+}
+
+func (w TimeWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (w TimeWindow) String() string {
+	s := w.Start + "-" + w.End
+	if len(w.Days) > 0 {
+		s = strings.Join(w.Days, ",") + " " + s
+	}
+	if w.Timezone != "" {
+		s += " " + w.Timezone
+	}
+	return s
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: time %q must be HH:MM", ErrInvalidConditions, s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// conditionsValue encodes conditions for a JSONB column (NULL when there are none)
+func conditionsValue(c *Conditions) (interface{}, error) {
+	if c.IsZero() {
+		return nil, nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// scanConditions decodes a nullable JSONB conditions column
+func scanConditions(data []byte) (*Conditions, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var c Conditions
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,194 @@
+package rbac
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimeWindowContains(t *testing.T) {
+	weekdays := TimeWindow{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"}
+	everyDay := TimeWindow{Start: "09:00", End: "17:00"}
+	overnight := TimeWindow{Days: []string{"fri"}, Start: "22:00", End: "06:00", Timezone: "America/New_York"}
+	berlin := TimeWindow{Days: []string{"Mon"}, Start: "09:00", End: "10:00", Timezone: "Europe/Berlin"}
+
+	tests := []struct {
+		name   string
+		window TimeWindow
+		at     time.Time
+		want   bool
+	}{
+		{"inside on a listed day", weekdays, time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), true},
+		{"start is inclusive", weekdays, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), true},
+		{"end is exclusive", weekdays, time.Date(2026, 10, 19, 17, 0, 0, 0, time.UTC), false},
+		{"before start", weekdays, time.Date(2026, 10, 19, 8, 59, 0, 0, time.UTC), false},
+		{"unlisted day", weekdays, time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), false},
+		{"no days means every day", everyDay, time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), true},
+		{"timezone defaults to UTC", everyDay, time.Date(2026, 10, 19, 12, 0, 0, 0, time.FixedZone("UTC+5", 5*3600)), false},
+		{"overnight late part on its day", overnight, time.Date(2026, 10, 24, 2, 30, 0, 0, time.UTC), true},         // Fri 22:30 in New York
+		{"overnight early part on the next day", overnight, time.Date(2026, 10, 24, 9, 0, 0, 0, time.UTC), true},    // Sat 05:00 in New York
+		{"overnight after end", overnight, time.Date(2026, 10, 24, 11, 0, 0, 0, time.UTC), false},                   // Sat 07:00 in New York
+		{"overnight late part on another day", overnight, time.Date(2026, 10, 25, 2, 30, 0, 0, time.UTC), false},    // Sat 22:30 in New York
+		{"overnight early part after another day", overnight, time.Date(2026, 10, 23, 9, 0, 0, 0, time.UTC), false}, // Thu 05:00 in New York
+		{"day names are case-insensitive", berlin, time.Date(2026, 10, 19, 7, 30, 0, 0, time.UTC), true},            // Mon 09:30 in Berlin
+		{"local day decides", berlin, time.Date(2026, 10, 18, 7, 30, 0, 0, time.UTC), false},                        // Sun 09:30 in Berlin
+		{"unknown timezone never matches", TimeWindow{Start: "00:00", End: "23:59", Timezone: "Mars/Olympus"}, time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.contains(tt.at); got != tt.want {
+				t.Errorf("%s contains(%s) = %v, want %v", tt.window, tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionsEvaluateSource(t *testing.T) {
+	conditions := Conditions{SourceCIDRs: []string{"10.0.0.0/8", "192.0.2.0/24", "2001:db8::/32"}}
+
+	tests := []struct {
+		name   string
+		ip     string
+		want   bool
+		detail string
+	}{
+		{"inside the first network", "10.1.2.3", true, "10.1.2.3 in 10.0.0.0/8"},
+		{"inside a later network", "192.0.2.200", true, "192.0.2.200 in 192.0.2.0/24"},
+		{"network address", "192.0.2.0", true, "192.0.2.0 in 192.0.2.0/24"},
+		{"just outside", "192.0.3.1", false, "192.0.3.1 outside every allowed network"},
+		{"IPv6 inside", "2001:db8::1", true, "2001:db8::1 in 2001:db8::/32"},
+		{"IPv6 outside", "2001:db9::1", false, "2001:db9::1 outside every allowed network"},
+		{"IPv4-mapped IPv6 matches the IPv4 network", "::ffff:10.0.0.1", true, "::ffff:10.0.0.1 in 10.0.0.0/8"},
+		{"unparseable address", "not-an-ip", false, "source address unknown"},
+		{"no address", "", false, "source address unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := conditions.evaluateSource(&RequestContext{SourceIP: tt.ip})
+			if got.Satisfied != tt.want || got.Detail != tt.detail {
+				t.Errorf("evaluateSource(%q) = {satisfied: %v, detail: %q}, want {satisfied: %v, detail: %q}",
+					tt.ip, got.Satisfied, got.Detail, tt.want, tt.detail)
+			}
+		})
+	}
+}
+
+func TestConditionsEvaluate(t *testing.T) {
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	evening := monday.Add(8 * time.Hour)
+	sessionStart := monday.Add(-30 * time.Minute)
+	eveningStart := evening.Add(-30 * time.Minute)
+	conditions := Conditions{
+		TimeWindows:          []TimeWindow{{Days: []string{"mon"}, Start: "09:00", End: "17:00"}, {Days: []string{"sat"}, Start: "10:00", End: "12:00"}},
+		SourceCIDRs:          []string{"10.0.0.0/8"},
+		MaxSessionAgeMinutes: 60,
+	}
+
+	tests := []struct {
+		name      string
+		rc        *RequestContext
+		satisfied []bool // time_window, source_cidr, session_age
+		met       bool
+	}{
+		{
+			name:      "all met",
+			rc:        &RequestContext{Time: monday, SourceIP: "10.0.0.5", SessionStartedAt: &sessionStart},
+			satisfied: []bool{true, true, true},
+			met:       true,
+		},
+		{
+			name:      "any window will do",
+			rc:        &RequestContext{Time: time.Date(2026, 10, 24, 11, 0, 0, 0, time.UTC), SourceIP: "10.0.0.5", SessionStartedAt: &sessionStart},
+			satisfied: []bool{true, true, false},
+		},
+		{
+			name:      "outside every window",
+			rc:        &RequestContext{Time: evening, SourceIP: "10.0.0.5", SessionStartedAt: &eveningStart},
+			satisfied: []bool{false, true, true},
+		},
+		{
+			name:      "wrong network",
+			rc:        &RequestContext{Time: monday, SourceIP: "192.0.2.1", SessionStartedAt: &sessionStart},
+			satisfied: []bool{true, false, true},
+		},
+		{
+			name:      "no session",
+			rc:        &RequestContext{Time: monday, SourceIP: "10.0.0.5"},
+			satisfied: []bool{true, true, false},
+		},
+		{
+			name:      "no request context",
+			satisfied: []bool{false, false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcomes, met := conditions.Evaluate(tt.rc)
+
+			var names []string
+			var satisfied []bool
+			for _, o := range outcomes {
+				names = append(names, o.Condition)
+				satisfied = append(satisfied, o.Satisfied)
+			}
+			if want := []string{ConditionTimeWindow, ConditionSourceCIDR, ConditionSessionAge}; !reflect.DeepEqual(names, want) {
+				t.Fatalf("conditions = %v, want %v", names, want)
+			}
+			if !reflect.DeepEqual(satisfied, tt.satisfied) || met != tt.met {
+				t.Errorf("Evaluate = %v (met %v), want %v (met %v); outcomes %+v", satisfied, met, tt.satisfied, tt.met, outcomes)
+			}
+		})
+	}
+}
+
+func TestConditionsValidate(t *testing.T) {
+	valid := []Conditions{
+		{},
+		{TimeWindows: []TimeWindow{{Days: []string{"Mon", "fri"}, Start: "09:00", End: "17:00", Timezone: "Europe/Berlin"}}},
+		{TimeWindows: []TimeWindow{{Start: "22:00", End: "06:00"}}},
+		{SourceCIDRs: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		{MaxSessionAgeMinutes: 30},
+	}
+	for _, c := range valid {
+		if err := c.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", c, err)
+		}
+	}
+
+	invalid := []Conditions{
+		{TimeWindows: []TimeWindow{{Start: "9am", End: "17:00"}}},
+		{TimeWindows: []TimeWindow{{Start: "09:00", End: "24:00"}}},
+		{TimeWindows: []TimeWindow{{Days: []string{"funday"}, Start: "09:00", End: "17:00"}}},
+		{TimeWindows: []TimeWindow{{Start: "09:00", End: "09:00"}}},
+		{TimeWindows: []TimeWindow{{Start: "09:00", End: "17:00", Timezone: "Mars/Olympus"}}},
+		{SourceCIDRs: []string{"10.0.0.1"}},
+		{SourceCIDRs: []string{"10.0.0.0/33"}},
+		{MaxSessionAgeMinutes: -1},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); !errors.Is(err, ErrInvalidConditions) {
+			t.Errorf("Validate(%+v) = %v, want ErrInvalidConditions", c, err)
+		}
+	}
+}
+
+func TestConditionsUnmarshal(t *testing.T) {
+	var c Conditions
+	if err := json.Unmarshal([]byte(`{"source_cidrs": ["10.0.0.0/8"], "max_session_age_minutes": 30}`), &c); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if want := (Conditions{SourceCIDRs: []string{"10.0.0.0/8"}, MaxSessionAgeMinutes: 30}); !reflect.DeepEqual(c, want) {
+		t.Errorf("Unmarshal = %+v, want %+v", c, want)
+	}
+
+	for _, data := range []string{`{"require_mfa": true}`, `{"source_cidr": ["10.0.0.0/8"]}`} {
+		if err := json.Unmarshal([]byte(data), &c); !errors.Is(err, ErrInvalidConditions) {
+			t.Errorf("Unmarshal(%s) = %v, want ErrInvalidConditions", data, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package rbac
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Effect is the outcome a permission rule applies
+type Effect string
+
+// Rule effects
+const (
+	EffectAllow Effect = "allow"
+	EffectDeny  Effect = "deny"
+)
+
+// ErrInvalidPattern is returned for malformed permission patterns
+var ErrInvalidPattern = errors.New("invalid permission pattern")
+
+// Decision reasons
+const (
+	ReasonAdmin   = "admin"
+	ReasonAllow   = "allow"
+	ReasonDeny    = "deny"
+	ReasonNoGrant = "no matching grant"
+	// ReasonConditions: only grants whose conditions the request doesn't meet match
+	ReasonConditions = "conditions not met"
+)
+
+// PolicyRule is one allow or deny pattern a user holds through a role.
+// Exact role permissions are allow rules whose pattern is the permission name.
+type PolicyRule struct {
+	Pattern string `json:"pattern"`
+	Effect  Effect `json:"effect"`
+	Role    string `json:"role"`
+	Scope   *Scope `json:"scope,omitempty"` // nil = applies to every resource
+	// Conditions from the role assignment and the permission grant; all must hold
+	Conditions []Conditions `json:"conditions,omitempty"`
+}
+
+// Resource identifies the object a permission is requested on
+type Resource struct {
+	Type string `json:"resource_type"`
+	ID   string `json:"resource_id"`
+}
+
+// Decision is the result of evaluating a permission
+type Decision struct {
+	Allowed bool        `json:"allowed"`
+	Reason  string      `json:"reason"`
+	Rule    *PolicyRule `json:"rule,omitempty"` // The deciding rule, if any
+	// Conditions is the deciding rule's condition outcomes, if it has any
+	Conditions []ConditionOutcome `json:"conditions,omitempty"`
+}
+
+// Policy is a user's resolved authorization state. It is the single place
+// permission decisions are made.
+type Policy struct {
+	UserID int          `json:"user_id"`
+	Admin  bool         `json:"admin"`
+	Rules  []PolicyRule `json:"rules"`
+	// ExpiresAt is the earliest expiry among the assignments the policy was
+	// resolved from; it must not be cached past it
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Decide evaluates permission, optionally on a resource, outside any request:
+// conditional allow rules never apply and conditional deny rules always do
+func (p *Policy) Decide(permission string, resource *Resource) Decision {
+	return p.DecideWith(permission, resource, nil)
+}
+
+// DecideWith evaluates permission, optionally on a resource, for a request.
+// Precedence:
+//  1. global admins are allowed everything
+//  2. any applicable deny rule matching the permission denies
+//  3. any applicable allow rule matching the permission allows
+//  4. otherwise the permission is denied
+//
+// Scoped rules only apply when resource is given and matches their scope, and
+// conditional rules only when rc meets their conditions (with a nil rc,
+// conditional denies are assumed to hold).
+func (p *Policy) DecideWith(permission string, resource *Resource, rc *RequestContext) Decision {
+	if p.Admin {
+		return Decision{Allowed: true, Reason: ReasonAdmin}
+	}
+
+	var allow, unmet *PolicyRule
+	var allowOutcomes, unmetOutcomes []ConditionOutcome
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.appliesTo(resource) || !MatchPermission(rule.Pattern, permission) {
+			continue
+		}
+		outcomes, met := rule.evaluate(rc)
+		if rule.Effect == EffectDeny {
+			if met || rc == nil {
+				return Decision{Allowed: false, Reason: ReasonDeny, Rule: rule, Conditions: outcomes}
+			}
+			continue
+		}
+		if met && allow == nil {
+			allow, allowOutcomes = rule, outcomes
+		} else if !met && unmet == nil {
+			unmet, unmetOutcomes = rule, outcomes
+		}
+	}
+
+	if allow != nil {
+		return Decision{Allowed: true, Reason: ReasonAllow, Rule: allow, Conditions: allowOutcomes}
+	}
+	if unmet != nil {
+		return Decision{Allowed: false, Reason: ReasonConditions, Rule: unmet, Conditions: unmetOutcomes}
+	}
+	return Decision{Allowed: false, Reason: ReasonNoGrant}
+}
+
+// Allows reports whether permission is granted globally outside any request
+func (p *Policy) Allows(permission string) bool {
+	return p.AllowsWith(permission, nil)
+}
+
+// AllowsWith reports whether permission is granted globally for a request
+func (p *Policy) AllowsWith(permission string, rc *RequestContext) bool {
+	return p.DecideWith(permission, nil, rc).Allowed
+}
+
+// AllowsAny reports whether any of the permissions is granted globally
+func (p *Policy) AllowsAny(permissions []string) bool {
+	return p.AllowsAnyWith(permissions, nil)
+}
+
+// AllowsAnyWith reports whether any of the permissions is granted globally for a request
+func (p *Policy) AllowsAnyWith(permissions []string, rc *RequestContext) bool {
+	for _, permission := range permissions {
+		if p.AllowsWith(permission, rc) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PolicyRule) appliesTo(resource *Resource) bool {
+	if r.Scope == nil {
+		return true
+	}
+	return resource != nil && r.Scope.Matches(resource.Type, resource.ID)
+}
+
+// evaluate checks every condition set on the rule against the request
+func (r *PolicyRule) evaluate(rc *RequestContext) ([]ConditionOutcome, bool) {
+	var outcomes []ConditionOutcome
+	met := true
+	for i := range r.Conditions {
+		o, ok := r.Conditions[i].Evaluate(rc)
+		outcomes = append(outcomes, o...)
+		met = met && ok
+	}
+	return outcomes, met
+}
+
+// MatchPermission reports whether a permission name matches a pattern.
+// Patterns are dot-separated segments where "*" matches exactly one segment
+// ("apm_mappings.*", "*.view"); a bare "*" matches every permission.
+func MatchPermission(pattern, permission string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	patternSegs := strings.Split(pattern, ".")
+	permSegs := strings.Split(permission, ".")
+	if len(patternSegs) != len(permSegs) {
+		return false
+	}
+
+	for i, seg := range patternSegs {
+		if seg != "*" && seg != permSegs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidatePattern checks that a pattern is "*" or non-empty dot-separated
+// segments, each either "*" or free of wildcards
+func ValidatePattern(pattern string) error {
+	if pattern == "*" {
+		return nil
+	}
+	for _, seg := range strings.Split(pattern, ".") {
+		if seg == "" || (seg != "*" && strings.Contains(seg, "*")) {
+			return ErrInvalidPattern
+		}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package rbac
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchPermission(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		permission string
+		want       bool
+	}{
+		{"services.view", "services.view", true},
+		{"services.view", "services.edit", false},
+		{"apm_mappings.*", "apm_mappings.delete", true},
+		{"apm_mappings.*", "apm_providers.view", false},
+		{"*.view", "models.view", true},
+		{"*.view", "models.calibrate", false},
+		{"*", "users.manage_roles", true},
+		{"nav.*", "nav", false},
+		{"*.*", "queries.save", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPermission(tt.pattern, tt.permission); got != tt.want {
+			t.Errorf("MatchPermission(%q, %q) = %v, want %v", tt.pattern, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	valid := []string{"*", "services.view", "apm_mappings.*", "*.view", "*.*"}
+	for _, p := range valid {
+		if err := ValidatePattern(p); err != nil {
+			t.Errorf("ValidatePattern(%q) = %v, want nil", p, err)
+		}
+	}
+
+	invalid := []string{"", ".", "services.", "serv*.view", "services..view"}
+	for _, p := range invalid {
+		if err := ValidatePattern(p); err != ErrInvalidPattern {
+			t.Errorf("ValidatePattern(%q) = %v, want ErrInvalidPattern", p, err)
+		}
+	}
+}
+
+func TestPolicyDecidePrecedence(t *testing.T) {
+	payments := &Scope{ResourceType: "service", ResourceID: "payments-*"}
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		permission string
+		resource   *Resource
+		allowed    bool
+		reason     string
+	}{
+		{
+			name:       "no rules denies",
+			policy:     Policy{},
+			permission: "services.view",
+			reason:     ReasonNoGrant,
+		},
+		{
+			name:       "exact allow",
+			policy:     Policy{Rules: []PolicyRule{{Pattern: "services.view", Effect: EffectAllow}}},
+			permission: "services.view",
+			allowed:    true,
+			reason:     ReasonAllow,
+		},
+		{
+			name:       "wildcard allow covers unlisted permission",
+			policy:     Policy{Rules: []PolicyRule{{Pattern: "apm_mappings.*", Effect: EffectAllow}}},
+			permission: "apm_mappings.export",
+			allowed:    true,
+			reason:     ReasonAllow,
+		},
+		{
+			name: "exact deny overrides wildcard allow",
+			policy: Policy{Rules: []PolicyRule{
+				{Pattern: "apm_mappings.*", Effect: EffectAllow},
+				{Pattern: "apm_mappings.delete", Effect: EffectDeny},
+			}},
+			permission: "apm_mappings.delete",
+			reason:     ReasonDeny,
+		},
+		{
+			name: "wildcard deny overrides exact allow regardless of order",
+			policy: Policy{Rules: []PolicyRule{
+				{Pattern: "*.delete", Effect: EffectDeny},
+				{Pattern: "services.delete", Effect: EffectAllow},
+			}},
+			permission: "services.delete",
+			reason:     ReasonDeny,
+		},
+		{
+			name: "deny from another role still wins",
+			policy: Policy{Rules: []PolicyRule{
+				{Pattern: "*", Effect: EffectAllow, Role: "operator"},
+				{Pattern: "users.*", Effect: EffectDeny, Role: "contractor"},
+			}},
+			permission: "users.view",
+			reason:     ReasonDeny,
+		},
+		{
+			name: "non-matching deny does not block",
+			policy: Policy{Rules: []PolicyRule{
+				{Pattern: "*.view", Effect: EffectAllow},
+				{Pattern: "users.*", Effect: EffectDeny},
+			}},
+			permission: "services.view",
+			allowed:    true,
+			reason:     ReasonAllow,
+		},
+		{
+			name: "admin is allowed despite deny",
+			policy: Policy{Admin: true, Rules: []PolicyRule{
+				{Pattern: "*", Effect: EffectDeny},
+			}},
+			permission: "roles.delete",
+			allowed:    true,
+			reason:     ReasonAdmin,
+		},
+		{
+			name:       "scoped allow ignored for global check",
+			policy:     Policy{Rules: []PolicyRule{{Pattern: "services.edit", Effect: EffectAllow, Scope: payments}}},
+			permission: "services.edit",
+			reason:     ReasonNoGrant,
+		},
+		{
+			name:       "scoped allow applies to matching resource",
+			policy:     Policy{Rules: []PolicyRule{{Pattern: "services.edit", Effect: EffectAllow, Scope: payments}}},
+			permission: "services.edit",
+			resource:   &Resource{Type: "service", ID: "payments-api"},
+			allowed:    true,
+			reason:     ReasonAllow,
+		},
+		{
+			name:       "scoped allow does not apply to other resource",
+			policy:     Policy{Rules: []PolicyRule{{Pattern: "services.edit", Effect: EffectAllow, Scope: payments}}},
+			permission: "services.edit",
+			resource:   &Resource{Type: "service", ID: "billing-api"},
+			reason:     ReasonNoGrant,
+		},
+		{
+			name: "scoped deny overrides global allow on matching resource",
+			policy: Policy{Rules: []PolicyRule{
+				{Pattern: "services.*", Effect: EffectAllow},
+				{Pattern: "services.edit", Effect: EffectDeny, Scope: payments},
+			}},
+			permission: "services.edit",
+			resource:   &Resource{Type: "service", ID: "payments-api"},
+			reason:     ReasonDeny,
+		},
+		{
+			name: "scoped deny does not affect global check",
+			policy: Policy{Rules: []PolicyRule{
+				{Pattern: "services.*", Effect: EffectAllow},
+				{Pattern: "services.edit", Effect: EffectDeny, Scope: payments},
+			}},
+			permission: "services.edit",
+			allowed:    true,
+			reason:     ReasonAllow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Decide(tt.permission, tt.resource)
+			if got.Allowed != tt.allowed || got.Reason != tt.reason {
+				t.Errorf("Decide(%q) = {allowed: %v, reason: %q}, want {allowed: %v, reason: %q}",
+					tt.permission, got.Allowed, got.Reason, tt.allowed, tt.reason)
+			}
+		})
+	}
+}
+
+func TestPolicyAllowsAny(t *testing.T) {
+	policy := Policy{Rules: []PolicyRule{
+		{Pattern: "queries.*", Effect: EffectAllow},
+		{Pattern: "queries.delete", Effect: EffectDeny},
+	}}
+
+	if !policy.AllowsAny([]string{"queries.delete", "queries.save"}) {
+		t.Error("AllowsAny should allow when one permission is granted")
+	}
+	if policy.AllowsAny([]string{"queries.delete", "users.view"}) {
+		t.Error("AllowsAny should deny when every permission is denied or ungranted")
+	}
+}
+
+func TestPolicyDecideConditions(t *testing.T) {
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	sessionStart := monday.Add(-2 * time.Hour)
+	businessHours := Conditions{TimeWindows: []TimeWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"}}}
+	office := Conditions{SourceCIDRs: []string{"10.0.0.0/8"}}
+	freshSession := Conditions{MaxSessionAgeMinutes: 60}
+
+	inOffice := &RequestContext{Time: monday, SourceIP: "10.1.2.3", SessionStartedAt: &sessionStart}
+
+	tests := []struct {
+		name    string
+		rules   []PolicyRule
+		rc      *RequestContext
+		allowed bool
+		reason  string
+	}{
+		{
+			name:    "met conditions allow",
+			rules:   []PolicyRule{{Pattern: "services.edit", Effect: EffectAllow, Conditions: []Conditions{businessHours, office}}},
+			rc:      inOffice,
+			allowed: true,
+			reason:  ReasonAllow,
+		},
+		{
+			name:   "unmet conditions deny",
+			rules:  []PolicyRule{{Pattern: "services.edit", Effect: EffectAllow, Conditions: []Conditions{office}}},
+			rc:     &RequestContext{Time: monday, SourceIP: "192.0.2.1"},
+			reason: ReasonConditions,
+		},
+		{
+			name:   "every condition set must hold",
+			rules:  []PolicyRule{{Pattern: "services.edit", Effect: EffectAllow, Conditions: []Conditions{office, freshSession}}},
+			rc:     inOffice,
+			reason: ReasonConditions,
+		},
+		{
+			name:   "outside time window",
+			rules:  []PolicyRule{{Pattern: "services.edit", Effect: EffectAllow, Conditions: []Conditions{businessHours}}},
+			rc:     &RequestContext{Time: monday.Add(-24 * time.Hour)},
+			reason: ReasonConditions,
+		},
+		{
+			name:   "stale session",
+			rules:  []PolicyRule{{Pattern: "services.edit", Effect: EffectAllow, Conditions: []Conditions{freshSession}}},
+			rc:     inOffice,
+			reason: ReasonConditions,
+		},
+		{
+			name: "unconditional grant still allows",
+			rules: []PolicyRule{
+				{Pattern: "services.edit", Effect: EffectAllow, Conditions: []Conditions{freshSession}},
+				{Pattern: "services.*", Effect: EffectAllow},
+			},
+			rc:      inOffice,
+			allowed: true,
+			reason:  ReasonAllow,
+		},
+		{
+			name:   "conditional allow needs a request context",
+			rules:  []PolicyRule{{Pattern: "services.edit", Effect: EffectAllow, Conditions: []Conditions{office}}},
+			reason: ReasonConditions,
+		},
+		{
+			name: "unmet conditional deny does not apply",
+			rules: []PolicyRule{
+				{Pattern: "services.*", Effect: EffectAllow},
+				{Pattern: "services.edit", Effect: EffectDeny, Conditions: []Conditions{{SourceCIDRs: []string{"192.0.2.0/24"}}}},
+			},
+			rc:      inOffice,
+			allowed: true,
+			reason:  ReasonAllow,
+		},
+		{
+			name: "conditional deny applies without a request context",
+			rules: []PolicyRule{
+				{Pattern: "services.*", Effect: EffectAllow},
+				{Pattern: "services.edit", Effect: EffectDeny, Conditions: []Conditions{office}},
+			},
+			reason: ReasonDeny,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := Policy{Rules: tt.rules}
+			got := policy.DecideWith("services.edit", nil, tt.rc)
+			if got.Allowed != tt.allowed || got.Reason != tt.reason {
+				t.Errorf("DecideWith = {allowed: %v, reason: %q}, want {allowed: %v, reason: %q}",
+					got.Allowed, got.Reason, tt.allowed, tt.reason)
+			}
+		})
+	}
+}
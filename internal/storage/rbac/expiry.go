@@ -0,0 +1,259 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// ExpiringAssignment is an active role assignment that lapses soon. For a
+// group role there is one per member: ID is the group_roles ID and GroupID
+// and GroupName are set.
+type ExpiringAssignment struct {
+	UserRole
+	Username  string  `json:"username"`
+	Email     string  `json:"email"`
+	GroupID   *int    `json:"group_id,omitempty"`
+	GroupName *string `json:"group_name,omitempty"`
+}
+
+// ArchiveExpired moves every lapsed user_roles row to user_roles_archive,
+// audits each removal and invalidates the affected users' cached permissions
+func (s *UserRoleStore) ArchiveExpired(ctx context.Context) ([]UserRole, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH expired AS (
+			DELETE FROM user_roles
+			WHERE expires_at <= NOW()
+			RETURNING id, user_id, role_id, resource_type, resource_id, assigned_at, assigned_by, expires_at, conditions
+		), archived AS (
+			INSERT INTO user_roles_archive (user_role_id, user_id, role_id, resource_type, resource_id, assigned_at, assigned_by, expires_at, conditions)
+			SELECT id, user_id, role_id, resource_type, resource_id, assigned_at, assigned_by, expires_at, conditions
+			FROM expired
+		)
+		SELECT e.id, e.user_id, e.role_id, r.name, e.assigned_at, e.assigned_by, e.expires_at, e.resource_type, e.resource_id
+		FROM expired e
+		JOIN roles r ON r.id = e.role_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []UserRole
+	for rows.Next() {
+		var ur UserRole
+		if err := rows.Scan(&ur.ID, &ur.UserID, &ur.RoleID, &ur.RoleName, &ur.AssignedAt, &ur.AssignedBy, &ur.ExpiresAt, &ur.ResourceType, &ur.ResourceID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, ur)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range expired {
+		ur := &expired[i]
+		metadata, _ := json.Marshal(map[string]interface{}{
+			"reason":        "expired",
+			"expires_at":    ur.ExpiresAt,
+			"resource_type": ur.ResourceType,
+			"resource_id":   ur.ResourceID,
+		})
+		if err := RecordAudit(ctx, tx, AuditEntry{
+			UserID:   &ur.UserID,
+			RoleID:   &ur.RoleID,
+			Action:   AuditRoleExpired,
+			Metadata: metadata,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidated := make(map[int]bool)
+	for _, ur := range expired {
+		if !invalidated[ur.UserID] {
+			invalidated[ur.UserID] = true
+			s.invalidator.InvalidateUser(ctx, ur.UserID)
+		}
+	}
+
+	return expired, nil
+}
+
+// ListExpiring retrieves active users' assignments lapsing within the given
+// number of days that have not yet been warned at this threshold or a smaller one
+func (s *UserRoleStore) ListExpiring(ctx context.Context, withinDays int) ([]ExpiringAssignment, error) {
+	query := `
+		SELECT ur.id, ur.user_id, ur.role_id, r.name, ur.assigned_at, ur.assigned_by, ur.expires_at,
+		       ur.resource_type, ur.resource_id, u.username, u.email
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		JOIN users u ON u.id = ur.user_id
+		WHERE ur.expires_at > NOW()
+		  AND ur.expires_at <= NOW() + make_interval(days => $1)
+		  AND (ur.expiry_warned_days IS NULL OR ur.expiry_warned_days > $1)
+		  AND u.is_active = true
+		ORDER BY ur.expires_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, withinDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expiring []ExpiringAssignment
+	for rows.Next() {
+		var a ExpiringAssignment
+		if err := rows.Scan(&a.ID, &a.UserID, &a.RoleID, &a.RoleName, &a.AssignedAt, &a.AssignedBy, &a.ExpiresAt,
+			&a.ResourceType, &a.ResourceID, &a.Username, &a.Email); err != nil {
+			return nil, err
+		}
+		expiring = append(expiring, a)
+	}
+
+	return expiring, rows.Err()
+}
+
+// MarkExpiryWarned records that the "expiring in N days" warning was sent
+func (s *UserRoleStore) MarkExpiryWarned(ctx context.Context, userRoleID, days int) error {
+	query := `UPDATE user_roles SET expiry_warned_days = $2 WHERE id = $1`
+
+	_, err := s.db.ExecContext(ctx, query, userRoleID, days)
+	return err
+}
+
+// ArchiveExpiredRoles moves every lapsed group_roles row to
+// group_roles_archive, audits each removal as a revocation and invalidates
+// every cached permission if anything was archived
+func (s *GroupStore) ArchiveExpiredRoles(ctx context.Context) ([]GroupRole, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH expired AS (
+			DELETE FROM group_roles
+			WHERE expires_at <= NOW()
+			RETURNING id, group_id, role_id, assigned_at, assigned_by, expires_at
+		), archived AS (
+			INSERT INTO group_roles_archive (group_role_id, group_id, role_id, assigned_at, assigned_by, expires_at)
+			SELECT id, group_id, role_id, assigned_at, assigned_by, expires_at
+			FROM expired
+		)
+		SELECT e.id, e.group_id, e.role_id, r.name, e.assigned_at, e.assigned_by, e.expires_at
+		FROM expired e
+		JOIN roles r ON r.id = e.role_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []GroupRole
+	for rows.Next() {
+		var gr GroupRole
+		if err := rows.Scan(&gr.ID, &gr.GroupID, &gr.RoleID, &gr.RoleName, &gr.AssignedAt, &gr.AssignedBy, &gr.ExpiresAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, gr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range expired {
+		gr := &expired[i]
+		metadata, _ := json.Marshal(map[string]interface{}{
+			"reason":        "expired",
+			"expires_at":    gr.ExpiresAt,
+			"group_id":      gr.GroupID,
+			"group_role_id": gr.ID,
+		})
+		if err := RecordAudit(ctx, tx, AuditEntry{
+			RoleID:   &gr.RoleID,
+			Action:   AuditRoleRevoked,
+			Metadata: metadata,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if len(expired) > 0 {
+		s.invalidator.InvalidateAll(ctx)
+	}
+	return expired, nil
+}
+
+// ListExpiringRoles retrieves, for each active member, the group role
+// assignments lapsing within the given number of days that have not yet been
+// warned at this threshold or a smaller one
+func (s *GroupStore) ListExpiringRoles(ctx context.Context, withinDays int) ([]ExpiringAssignment, error) {
+	query := `
+		SELECT gr.id, u.id, gr.role_id, r.name, gr.assigned_at, gr.assigned_by, gr.expires_at,
+		       u.username, u.email, g.id, g.name
+		FROM group_roles gr
+		JOIN roles r ON r.id = gr.role_id
+		JOIN groups g ON g.id = gr.group_id
+		JOIN group_members gm ON gm.group_id = gr.group_id
+		JOIN users u ON u.id = gm.user_id
+		WHERE gr.expires_at > NOW()
+		  AND gr.expires_at <= NOW() + make_interval(days => $1)
+		  AND (gr.expiry_warned_days IS NULL OR gr.expiry_warned_days > $1)
+		  AND u.is_active = true
+		ORDER BY gr.expires_at, gr.id, u.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, withinDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expiring []ExpiringAssignment
+	for rows.Next() {
+		var a ExpiringAssignment
+		if err := rows.Scan(&a.ID, &a.UserID, &a.RoleID, &a.RoleName, &a.AssignedAt, &a.AssignedBy, &a.ExpiresAt,
+			&a.Username, &a.Email, &a.GroupID, &a.GroupName); err != nil {
+			return nil, err
+		}
+		expiring = append(expiring, a)
+	}
+
+	return expiring, rows.Err()
+}
+
+// MarkRoleExpiryWarned records that a group role's members were sent the
+// "expiring in N days" warning
+func (s *GroupStore) MarkRoleExpiryWarned(ctx context.Context, groupRoleID, days int) error {
+	query := `UPDATE group_roles SET expiry_warned_days = $2 WHERE id = $1`
+
+	_, err := s.db.ExecContext(ctx, query, groupRoleID, days)
+	return err
+}
+
+// TimeLeft returns how long until the assignment lapses
+func (a *ExpiringAssignment) TimeLeft() time.Duration {
+	if a.ExpiresAt == nil {
+		return 0
+	}
+	return time.Until(*a.ExpiresAt)
+}
@@ -0,0 +1,189 @@
+package rbac
+
+import (
+	"context"
+	"time"
+)
+
+// RolePath is one way a user holds a role: the assignment (direct or through
+// a group) it comes from and, for inherited roles, the assigned role that
+// inherits it
+type RolePath struct {
+	Role               string     `json:"role"`
+	Source             string     `json:"source"` // direct | group
+	GroupID            *int       `json:"group_id,omitempty"`
+	Group              *string    `json:"group,omitempty"`
+	InheritedVia       *string    `json:"inherited_via,omitempty"`
+	ResourceType       *string    `json:"resource_type,omitempty"` // Set for scoped assignments
+	ResourceID         *string    `json:"resource_id,omitempty"`
+	AssignedAt         *time.Time `json:"assigned_at,omitempty"`
+	AssignedBy         *int       `json:"assigned_by,omitempty"`
+	AssignedByUsername *string    `json:"assigned_by_username,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	// Conditions restrict the requests the assignment applies to
+	Conditions *Conditions `json:"conditions,omitempty"`
+}
+
+// Derivation is a role permission or rule that matches the explained permission
+type Derivation struct {
+	RolePath
+	Pattern           string     `json:"pattern"` // Permission name, or the rule's wildcard pattern
+	Effect            Effect     `json:"effect"`
+	GrantedAt         *time.Time `json:"granted_at,omitempty"`
+	GrantedBy         *int       `json:"granted_by,omitempty"`
+	GrantedByUsername *string    `json:"granted_by_username,omitempty"`
+	// GrantConditions restrict the requests the role's permission grant applies to
+	GrantConditions *Conditions `json:"grant_conditions,omitempty"`
+	// ConditionOutcomes evaluates the assignment and grant conditions against the request
+	ConditionOutcomes []ConditionOutcome `json:"condition_outcomes,omitempty"`
+	// Applies is false for scoped grants that don't cover the requested resource
+	// and for grants whose conditions the request doesn't meet
+	Applies bool `json:"applies"`
+}
+
+// Explanation is a permission decision with every path that contributed to it
+type Explanation struct {
+	Decision
+	UserID     int          `json:"user_id"`
+	Permission string       `json:"permission"`
+	Resource   *Resource    `json:"resource,omitempty"`
+	AdminVia   []RolePath   `json:"admin_via,omitempty"` // How the user holds admin, when it decided
+	Grants     []Derivation `json:"grants"`
+	Denials    []Derivation `json:"denials"`
+}
+
+// Explain decides permission for a user exactly as the middleware does for a
+// request with context rc and lists the derivation: the admin bypass, and each
+// role permission or rule matching it with its grant and role assignment
+// details and condition outcomes
+func (s *UserRoleStore) Explain(ctx context.Context, userID int, permission string, resource *Resource, rc *RequestContext) (*Explanation, error) {
+	policy, err := s.LoadPolicy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &Explanation{
+		Decision:   policy.DecideWith(permission, resource, rc),
+		UserID:     userID,
+		Permission: permission,
+		Resource:   resource,
+		Grants:     []Derivation{},
+		Denials:    []Derivation{},
+	}
+
+	query := `
+		WITH RECURSIVE ` + assignedRolesCTE + `,
+		matches AS (
+			SELECT a.*, p.name AS pattern, 'allow' AS effect, rp.granted_at, rp.granted_by, rp.conditions AS grant_conditions
+			FROM assigned a
+			JOIN role_permissions rp ON rp.role_id = a.role_id
+			JOIN permissions p ON p.id = rp.permission_id
+			WHERE p.name = $2
+			UNION ALL
+			SELECT a.*, rr.pattern, rr.effect, rr.created_at, rr.created_by, NULL::JSONB
+			FROM assigned a
+			JOIN role_permission_rules rr ON rr.role_id = a.role_id
+			WHERE rr.pattern LIKE '%*%' OR rr.pattern = $2
+		)
+		SELECT r.name, m.source, m.group_id, m.group_name,
+		       CASE WHEN m.via_role_id <> m.role_id THEN via.name END,
+		       m.resource_type, m.resource_id, m.assigned_at, m.assigned_by, ab.username, m.expires_at, m.conditions,
+		       m.pattern, m.effect, m.granted_at, m.granted_by, gb.username, m.grant_conditions
+		FROM matches m
+		JOIN roles r ON r.id = m.role_id
+		JOIN roles via ON via.id = m.via_role_id
+		LEFT JOIN users ab ON ab.id = m.assigned_by
+		LEFT JOIN users gb ON gb.id = m.granted_by
+		ORDER BY m.effect DESC, r.name, m.source, m.group_name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, permission)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d Derivation
+		var conditions, grantConditions []byte
+		if err := rows.Scan(&d.Role, &d.Source, &d.GroupID, &d.Group, &d.InheritedVia,
+			&d.ResourceType, &d.ResourceID, &d.AssignedAt, &d.AssignedBy, &d.AssignedByUsername, &d.ExpiresAt, &conditions,
+			&d.Pattern, &d.Effect, &d.GrantedAt, &d.GrantedBy, &d.GrantedByUsername, &grantConditions); err != nil {
+			return nil, err
+		}
+		if !MatchPermission(d.Pattern, permission) {
+			continue
+		}
+		if d.Conditions, err = scanConditions(conditions); err != nil {
+			return nil, err
+		}
+		if d.GrantConditions, err = scanConditions(grantConditions); err != nil {
+			return nil, err
+		}
+
+		rule := PolicyRule{Pattern: d.Pattern, Effect: d.Effect, Role: d.Role}
+		if d.ResourceType != nil {
+			rule.Scope = &Scope{ResourceType: *d.ResourceType, ResourceID: *d.ResourceID}
+		}
+		for _, c := range []*Conditions{d.Conditions, d.GrantConditions} {
+			if c != nil {
+				rule.Conditions = append(rule.Conditions, *c)
+			}
+		}
+		outcomes, met := rule.evaluate(rc)
+		d.ConditionOutcomes = outcomes
+		// Conditional denies are assumed to hold without a request context, as in DecideWith
+		d.Applies = rule.appliesTo(resource) && (met || (rc == nil && d.Effect == EffectDeny))
+
+		if d.Effect == EffectDeny {
+			explanation.Denials = append(explanation.Denials, d)
+		} else {
+			explanation.Grants = append(explanation.Grants, d)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if explanation.Reason == ReasonAdmin {
+		if explanation.AdminVia, err = s.adminPaths(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return explanation, nil
+}
+
+// adminPaths lists how a user holds the admin role globally and unconditionally
+func (s *UserRoleStore) adminPaths(ctx context.Context, userID int) ([]RolePath, error) {
+	query := `
+		WITH RECURSIVE ` + assignedRolesCTE + `
+		SELECT r.name, a.source, a.group_id, a.group_name,
+		       CASE WHEN a.via_role_id <> a.role_id THEN via.name END,
+		       a.assigned_at, a.assigned_by, ab.username, a.expires_at
+		FROM assigned a
+		JOIN roles r ON r.id = a.role_id
+		JOIN roles via ON via.id = a.via_role_id
+		LEFT JOIN users ab ON ab.id = a.assigned_by
+		WHERE r.name = 'admin' AND a.resource_type IS NULL AND a.conditions IS NULL
+		ORDER BY a.source, a.group_name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []RolePath
+	for rows.Next() {
+		var p RolePath
+		if err := rows.Scan(&p.Role, &p.Source, &p.GroupID, &p.Group, &p.InheritedVia,
+			&p.AssignedAt, &p.AssignedBy, &p.AssignedByUsername, &p.ExpiresAt); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+
+	return paths, rows.Err()
+}
@@ -0,0 +1,393 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Group represents a team of users that can be assigned roles
+type Group struct {
+	ID          int       `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	CreatedBy   *int      `db:"created_by" json:"created_by,omitempty"`
+	MemberCount int       `json:"member_count"`
+	RoleCount   int       `json:"role_count"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// GroupMember represents a user's membership in a group
+type GroupMember struct {
+	GroupID  int       `db:"group_id" json:"group_id"`
+	UserID   int       `db:"user_id" json:"user_id"`
+	Username string    `db:"username" json:"username"`
+	AddedAt  time.Time `db:"added_at" json:"added_at"`
+	AddedBy  *int      `db:"added_by" json:"added_by,omitempty"`
+}
+
+// GroupRole represents a role assigned to a group
+type GroupRole struct {
+	ID         int        `db:"id" json:"id"`
+	GroupID    int        `db:"group_id" json:"group_id"`
+	RoleID     int        `db:"role_id" json:"role_id"`
+	RoleName   string     `db:"role_name" json:"role_name"`
+	AssignedAt time.Time  `db:"assigned_at" json:"assigned_at"`
+	AssignedBy *int       `db:"assigned_by" json:"assigned_by,omitempty"`
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+}
+
+// GroupStore handles database operations for groups, membership and group roles
+type GroupStore struct {
+	db                   *sql.DB
+	requireVerifiedEmail bool
+	invalidator          Invalidator
+}
+
+// NewGroupStore creates a new group store
+func NewGroupStore(db *sql.DB) *GroupStore {
+	return &GroupStore{db: db, invalidator: nopInvalidator{}}
+}
+
+// SetInvalidator registers the cache to notify when memberships or group roles change
+func (s *GroupStore) SetInvalidator(invalidator Invalidator) {
+	s.invalidator = invalidator
+}
+
+// SetRequireVerifiedEmail controls whether members without a verified email
+// may receive roles beyond viewer through a group
+func (s *GroupStore) SetRequireVerifiedEmail(required bool) {
+	s.requireVerifiedEmail = required
+}
+
+const groupColumns = `
+	g.id, g.name, COALESCE(g.description, ''), g.created_by,
+	(SELECT COUNT(*) FROM group_members gm WHERE gm.group_id = g.id),
+	(SELECT COUNT(*) FROM group_roles gr WHERE gr.group_id = g.id AND (gr.expires_at IS NULL OR gr.expires_at > NOW())),
+	g.created_at, g.updated_at`
+
+// List retrieves all groups
+func (s *GroupStore) List(ctx context.Context) ([]Group, error) {
+	query := `SELECT ` + groupColumns + ` FROM groups g ORDER BY g.name`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanGroups(rows)
+}
+
+// GetByID retrieves a group by ID
+func (s *GroupStore) GetByID(ctx context.Context, id int) (*Group, error) {
+	query := `SELECT ` + groupColumns + ` FROM groups g WHERE g.id = $1`
+
+	var g Group
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&g.ID, &g.Name, &g.Description, &g.CreatedBy, &g.MemberCount, &g.RoleCount, &g.CreatedAt, &g.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &g, nil
+}
+
+// GetUserGroups retrieves the groups a user belongs to
+func (s *GroupStore) GetUserGroups(ctx context.Context, userID int) ([]Group, error) {
+	query := `
+		SELECT ` + groupColumns + `
+		FROM groups g
+		JOIN group_members m ON m.group_id = g.id
+		WHERE m.user_id = $1
+		ORDER BY g.name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanGroups(rows)
+}
+
+// Create creates a new group
+func (s *GroupStore) Create(ctx context.Context, name, description string, createdBy int) (*Group, error) {
+	query := `
+		INSERT INTO groups (name, description, created_by)
+		VALUES ($1, $2, NULLIF($3, 0))
+		RETURNING id
+	`
+
+	var id int
+	if err := s.db.QueryRowContext(ctx, query, name, description, createdBy).Scan(&id); err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(ctx, id)
+}
+
+// Update updates a group's name and description
+func (s *GroupStore) Update(ctx context.Context, id int, name, description string) error {
+	query := `UPDATE groups SET name = $2, description = $3 WHERE id = $1`
+
+	result, err := s.db.ExecContext(ctx, query, id, name, description)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Delete deletes a group (members lose the group's roles).
+// Returns ErrLastAdmin if that would leave no active admin.
+func (s *GroupStore) Delete(ctx context.Context, id int) error {
+	rows, err := ExecPreservingAdmin(ctx, s.db, `DELETE FROM groups WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return nil
+}
+
+// GetMembers retrieves all members of a group
+func (s *GroupStore) GetMembers(ctx context.Context, groupID int) ([]GroupMember, error) {
+	query := `
+		SELECT gm.group_id, gm.user_id, u.username, gm.added_at, gm.added_by
+		FROM group_members gm
+		JOIN users u ON u.id = gm.user_id
+		WHERE gm.group_id = $1
+		ORDER BY u.username
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []GroupMember
+	for rows.Next() {
+		var m GroupMember
+		if err := rows.Scan(&m.GroupID, &m.UserID, &m.Username, &m.AddedAt, &m.AddedBy); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMember adds a user to a group.
+// Returns a *SoDViolationError if the user would hold mutually exclusive roles.
+func (s *GroupStore) AddMember(ctx context.Context, groupID, userID, addedBy int) error {
+	if s.requireVerifiedEmail {
+		var blocked bool
+		err := s.db.QueryRowContext(ctx, `
+			SELECT EXISTS(
+				SELECT 1
+				FROM group_roles gr
+				JOIN roles r ON r.id = gr.role_id
+				JOIN users u ON u.id = $2
+				WHERE gr.group_id = $1 AND r.name <> 'viewer' AND u.email_verified_at IS NULL
+			)
+		`, groupID, userID).Scan(&blocked)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrEmailNotVerified
+		}
+	}
+
+	query := `
+		INSERT INTO group_members (group_id, user_id, added_by)
+		VALUES ($1, $2, NULLIF($3, 0))
+		ON CONFLICT (group_id, user_id) DO NOTHING
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return err
+	}
+
+	err = enforceSoD(ctx, tx, []int{userID}, func() error {
+		_, err := tx.ExecContext(ctx, query, groupID, userID, addedBy)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateUser(ctx, userID)
+	return nil
+}
+
+// RemoveMember removes a user from a group.
+// Returns ErrLastAdmin if that would leave no active admin.
+func (s *GroupStore) RemoveMember(ctx context.Context, groupID, userID int) error {
+	query := `DELETE FROM group_members WHERE group_id = $1 AND user_id = $2`
+
+	if _, err := ExecPreservingAdmin(ctx, s.db, query, groupID, userID); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateUser(ctx, userID)
+	return nil
+}
+
+// GetRoles retrieves the active roles assigned to a group
+func (s *GroupStore) GetRoles(ctx context.Context, groupID int) ([]GroupRole, error) {
+	query := `
+		SELECT gr.id, gr.group_id, gr.role_id, r.name, gr.assigned_at, gr.assigned_by, gr.expires_at
+		FROM group_roles gr
+		JOIN roles r ON r.id = gr.role_id
+		WHERE gr.group_id = $1
+		  AND (gr.expires_at IS NULL OR gr.expires_at > NOW())
+		ORDER BY r.name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []GroupRole
+	for rows.Next() {
+		var gr GroupRole
+		if err := rows.Scan(&gr.ID, &gr.GroupID, &gr.RoleID, &gr.RoleName, &gr.AssignedAt, &gr.AssignedBy, &gr.ExpiresAt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, gr)
+	}
+
+	return roles, rows.Err()
+}
+
+// AssignRole assigns a role to a group, optionally expiring.
+// Returns a *SoDViolationError if a member would hold mutually exclusive roles.
+func (s *GroupStore) AssignRole(ctx context.Context, groupID, roleID, assignedBy int, expiresAt *time.Time) error {
+	if s.requireVerifiedEmail {
+		var blocked bool
+		err := s.db.QueryRowContext(ctx, `
+			SELECT r.name <> 'viewer' AND EXISTS(
+				SELECT 1
+				FROM group_members gm
+				JOIN users u ON u.id = gm.user_id
+				WHERE gm.group_id = $1 AND u.email_verified_at IS NULL
+			)
+			FROM roles r
+			WHERE r.id = $2
+		`, groupID, roleID).Scan(&blocked)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrEmailNotVerified
+		}
+	}
+
+	query := `
+		INSERT INTO group_roles (group_id, role_id, assigned_by, expires_at)
+		VALUES ($1, $2, NULLIF($3, 0), $4)
+		ON CONFLICT (group_id, role_id) DO UPDATE
+		SET expires_at = EXCLUDED.expires_at, expiry_warned_days = NULL
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return err
+	}
+
+	members, err := groupMemberIDs(ctx, tx, groupID)
+	if err != nil {
+		return err
+	}
+
+	err = enforceSoD(ctx, tx, members, func() error {
+		_, err := tx.ExecContext(ctx, query, groupID, roleID, assignedBy, expiresAt)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return nil
+}
+
+// RemoveRole removes a role from a group.
+// Returns ErrLastAdmin if that would leave no active admin.
+func (s *GroupStore) RemoveRole(ctx context.Context, groupID, roleID int) error {
+	query := `DELETE FROM group_roles WHERE group_id = $1 AND role_id = $2`
+
+	if _, err := ExecPreservingAdmin(ctx, s.db, query, groupID, roleID); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return nil
+}
+
+// groupMemberIDs lists the users in a group
+func groupMemberIDs(ctx context.Context, q Querier, groupID int) ([]int, error) {
+	rows, err := q.QueryContext(ctx, `SELECT user_id FROM group_members WHERE group_id = $1`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func scanGroups(rows *sql.Rows) ([]Group, error) {
+	var groups []Group
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.CreatedBy, &g.MemberCount, &g.RoleCount, &g.CreatedAt, &g.UpdatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
@@ -0,0 +1,27 @@
+package rbac
+
+import "context"
+
+// Invalidator is notified when authorization data changes so that cached
+// policies (and cached users, whose is_admin flag is derived from roles) can
+// be dropped
+type Invalidator interface {
+	// InvalidateUser drops cached state for one user
+	InvalidateUser(ctx context.Context, userID int)
+	// InvalidateAll drops cached state for every user, for changes to roles
+	// or groups that may affect many users
+	InvalidateAll(ctx context.Context)
+}
+
+// PolicyCache caches resolved policies, calling load on a miss.
+// Returned policies are shared and must not be modified.
+type PolicyCache interface {
+	Invalidator
+	GetPolicy(ctx context.Context, userID int, load func(context.Context) (*Policy, error)) (*Policy, error)
+}
+
+// nopInvalidator is used until a cache is configured
+type nopInvalidator struct{}
+
+func (nopInvalidator) InvalidateUser(context.Context, int) {}
+func (nopInvalidator) InvalidateAll(context.Context)       {}
@@ -3,7 +3,11 @@ package rbac
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/lib/pq"
 )
 
 // Permission represents a system permission
@@ -15,6 +19,10 @@ type Permission struct {
 	Description string    `db:"description" json:"description"`
 	Category    string    `db:"category" json:"category"`
 	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	// UndeclaredSince is set when the permission registry no longer declares it
+	UndeclaredSince *time.Time `db:"undeclared_since" json:"undeclared_since,omitempty"`
+	// Conditions is set on a role's conditional grant of the permission
+	Conditions *Conditions `db:"conditions" json:"conditions,omitempty"`
 }
 
 // PermissionStore handles database operations for permissions
@@ -30,7 +38,7 @@ func NewPermissionStore(db *sql.DB) *PermissionStore {
 // List retrieves all permissions
 func (s *PermissionStore) List(ctx context.Context) ([]Permission, error) {
 	query := `
-		SELECT id, name, resource, action, description, category, created_at
+		SELECT id, name, resource, action, description, category, created_at, undeclared_since
 		FROM permissions
 		ORDER BY category, resource, action
 	`
@@ -44,7 +52,7 @@ func (s *PermissionStore) List(ctx context.Context) ([]Permission, error) {
 	var permissions []Permission
 	for rows.Next() {
 		var perm Permission
-		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt); err != nil {
+		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt, &perm.UndeclaredSince); err != nil {
 			return nil, err
 		}
 		permissions = append(permissions, perm)
@@ -56,14 +64,14 @@ func (s *PermissionStore) List(ctx context.Context) ([]Permission, error) {
 // GetByID retrieves a permission by ID
 func (s *PermissionStore) GetByID(ctx context.Context, id int) (*Permission, error) {
 	query := `
-		SELECT id, name, resource, action, description, category, created_at
+		SELECT id, name, resource, action, description, category, created_at, undeclared_since
 		FROM permissions
 		WHERE id = $1
 	`
 
 	var perm Permission
 	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt,
+		&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt, &perm.UndeclaredSince,
 	)
 	if err != nil {
 		return nil, err
@@ -75,14 +83,14 @@ func (s *PermissionStore) GetByID(ctx context.Context, id int) (*Permission, err
 // GetByName retrieves a permission by name
 func (s *PermissionStore) GetByName(ctx context.Context, name string) (*Permission, error) {
 	query := `
-		SELECT id, name, resource, action, description, category, created_at
+		SELECT id, name, resource, action, description, category, created_at, undeclared_since
 		FROM permissions
 		WHERE name = $1
 	`
 
 	var perm Permission
 	err := s.db.QueryRowContext(ctx, query, name).Scan(
-		&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt,
+		&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt, &perm.UndeclaredSince,
 	)
 	if err != nil {
 		return nil, err
@@ -94,7 +102,7 @@ func (s *PermissionStore) GetByName(ctx context.Context, name string) (*Permissi
 // ListByCategory retrieves permissions filtered by category
 func (s *PermissionStore) ListByCategory(ctx context.Context, category string) ([]Permission, error) {
 	query := `
-		SELECT id, name, resource, action, description, category, created_at
+		SELECT id, name, resource, action, description, category, created_at, undeclared_since
 		FROM permissions
 		WHERE category = $1
 		ORDER BY resource, action
@@ -109,7 +117,7 @@ func (s *PermissionStore) ListByCategory(ctx context.Context, category string) (
 	var permissions []Permission
 	for rows.Next() {
 		var perm Permission
-		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt); err != nil {
+		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt, &perm.UndeclaredSince); err != nil {
 			return nil, err
 		}
 		permissions = append(permissions, perm)
@@ -117,3 +125,81 @@ func (s *PermissionStore) ListByCategory(ctx context.Context, category string) (
 
 	return permissions, nil
 }
+
+// SyncResult summarises a registry sync
+type SyncResult struct {
+	Created    []string // Declared permissions added to the table
+	Updated    []string // Declared permissions whose details changed
+	Undeclared []string // Stored permissions the registry no longer declares
+}
+
+// Sync upserts the declared permissions into the permissions table in one
+// transaction and flags stored permissions that are no longer declared.
+// Undeclared permissions are kept (roles may still grant them) until removed
+// explicitly, e.g. by a pruning policy apply.
+func (s *PermissionStore) Sync(ctx context.Context, declared []permissions.Definition) (*SyncResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &SyncResult{}
+	names := make([]string, 0, len(declared))
+	for _, def := range declared {
+		names = append(names, string(def.Name))
+
+		// xmax = 0 distinguishes an insert from an update of an existing row
+		var inserted bool
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO permissions (name, resource, action, category, description)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (name) DO UPDATE
+			SET resource = EXCLUDED.resource,
+			    action = EXCLUDED.action,
+			    category = EXCLUDED.category,
+			    description = EXCLUDED.description,
+			    undeclared_since = NULL
+			WHERE (permissions.resource, permissions.action, permissions.category, permissions.description, permissions.undeclared_since)
+			      IS DISTINCT FROM (EXCLUDED.resource, EXCLUDED.action, EXCLUDED.category, EXCLUDED.description, NULL::TIMESTAMP)
+			RETURNING xmax = 0
+		`, def.Name, def.Resource, def.Action, def.Category, def.Description).Scan(&inserted)
+		switch {
+		case err == sql.ErrNoRows:
+			// Already up to date
+		case err != nil:
+			return nil, fmt.Errorf("upsert permission %s: %w", def.Name, err)
+		case inserted:
+			result.Created = append(result.Created, string(def.Name))
+		default:
+			result.Updated = append(result.Updated, string(def.Name))
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE permissions
+		SET undeclared_since = COALESCE(undeclared_since, NOW())
+		WHERE NOT (name = ANY($1))
+		RETURNING name
+	`, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("flag undeclared permissions: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.Undeclared = append(result.Undeclared, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
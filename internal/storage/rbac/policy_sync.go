@@ -0,0 +1,338 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/bwburch/inflight-ui-service/internal/policy"
+)
+
+// PolicyStore reconciles roles and permissions with a declarative policy file
+type PolicyStore struct {
+	db                   *sql.DB
+	invalidator          Invalidator
+	requireVerifiedEmail bool
+}
+
+// NewPolicyStore creates a new policy store
+func NewPolicyStore(db *sql.DB) *PolicyStore {
+	return &PolicyStore{db: db, invalidator: nopInvalidator{}}
+}
+
+// SetInvalidator registers the cache to notify after a policy is applied
+func (s *PolicyStore) SetInvalidator(invalidator Invalidator) {
+	s.invalidator = invalidator
+}
+
+// SetRequireVerifiedEmail skips default assignments beyond viewer for users
+// whose email is unverified
+func (s *PolicyStore) SetRequireVerifiedEmail(required bool) {
+	s.requireVerifiedEmail = required
+}
+
+// Plan diffs the document against the database without changing anything
+func (s *PolicyStore) Plan(ctx context.Context, doc *policy.Document, opts policy.Options) (*policy.Plan, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	state, err := s.loadState(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Diff(doc, state, opts)
+}
+
+// Apply reconciles the database with the document in a single transaction
+// and returns the changes made. appliedBy may be 0 for the CLI; otherwise
+// every assignment must pass the same escalation check as the assignment
+// endpoint, returning an *EscalationError.
+func (s *PolicyStore) Apply(ctx context.Context, doc *policy.Document, opts policy.Options, appliedBy int) (*policy.Plan, error) {
+	// The actor's permissions as they stand before the apply
+	var actor *Policy
+	if appliedBy != 0 {
+		var err error
+		if actor, err = NewUserRoleStore(s.db).LoadPolicy(ctx, appliedBy); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Keep concurrent role and permission edits out until the apply commits
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE permissions, roles, role_permissions IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return nil, fmt.Errorf("lock rbac tables: %w", err)
+	}
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return nil, err
+	}
+	adminsBefore, err := CountAdmins(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.loadState(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := policy.Diff(doc, state, opts)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Empty() {
+		return plan, nil
+	}
+
+	var changedBy *int
+	if appliedBy != 0 {
+		changedBy = &appliedBy
+	}
+
+	err = enforceSoD(ctx, tx, nil, func() error {
+		for _, change := range plan.Changes {
+			if err := applyChange(ctx, tx, change, state, actor, changedBy); err != nil {
+				return fmt.Errorf("%s: %w", change, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The same safeguards as the role and assignment endpoints
+	if err := checkLockout(ctx, tx); err != nil {
+		return nil, err
+	}
+	if adminsBefore > 0 {
+		admins, err := CountAdmins(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		if admins == 0 {
+			return nil, ErrLastAdmin
+		}
+	}
+
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"changes": len(plan.Changes),
+		"prune":   opts.Prune,
+	})
+	if err := RecordAudit(ctx, tx, AuditEntry{
+		Action:    AuditPolicyApplied,
+		ChangedBy: changedBy,
+		Metadata:  metadata,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return plan, nil
+}
+
+// applyChange makes one planned change. A non-nil actor must be allowed to
+// grant each role assigned.
+func applyChange(ctx context.Context, tx *sql.Tx, c policy.Change, state *policy.State, actor *Policy, changedBy *int) error {
+	metadata, _ := json.Marshal(map[string]string{"source": "policy"})
+
+	switch c.Kind + "/" + c.Action {
+	case policy.KindPermission + "/" + policy.ActionCreate:
+		spec := policy.PermissionSpec{Name: c.Permission}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO permissions (name, resource, action, description, category)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		`, c.Permission, spec.Resource(), spec.Action(), c.Description, c.Category)
+		return err
+
+	case policy.KindPermission + "/" + policy.ActionUpdate:
+		_, err := tx.ExecContext(ctx, `
+			UPDATE permissions SET description = $2, category = NULLIF($3, '') WHERE name = $1
+		`, c.Permission, c.Description, c.Category)
+		return err
+
+	case policy.KindPermission + "/" + policy.ActionDelete:
+		_, err := tx.ExecContext(ctx, `DELETE FROM permissions WHERE name = $1`, c.Permission)
+		return err
+
+	case policy.KindRole + "/" + policy.ActionCreate:
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO roles (name, description, is_system) VALUES ($1, $2, false)
+		`, c.Role, c.Description)
+		return err
+
+	case policy.KindRole + "/" + policy.ActionUpdate:
+		_, err := tx.ExecContext(ctx, `UPDATE roles SET description = $2, updated_at = NOW() WHERE name = $1`, c.Role, c.Description)
+		return err
+
+	case policy.KindRole + "/" + policy.ActionDelete:
+		_, err := tx.ExecContext(ctx, `DELETE FROM roles WHERE name = $1 AND is_system = false`, c.Role)
+		return err
+
+	case policy.KindGrant + "/" + policy.ActionCreate:
+		var roleID, permissionID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO role_permissions (role_id, permission_id, granted_by)
+			SELECT r.id, p.id, $3 FROM roles r, permissions p WHERE r.name = $1 AND p.name = $2
+			RETURNING role_id, permission_id
+		`, c.Role, c.Permission, changedBy).Scan(&roleID, &permissionID)
+		if err != nil {
+			return err
+		}
+		return RecordAudit(ctx, tx, AuditEntry{
+			RoleID: &roleID, PermissionID: &permissionID, Action: AuditPermissionGranted, ChangedBy: changedBy, Metadata: metadata,
+		})
+
+	case policy.KindGrant + "/" + policy.ActionDelete:
+		var roleID, permissionID int
+		err := tx.QueryRowContext(ctx, `
+			DELETE FROM role_permissions rp
+			USING roles r, permissions p
+			WHERE rp.role_id = r.id AND rp.permission_id = p.id AND r.name = $1 AND p.name = $2
+			RETURNING rp.role_id, rp.permission_id
+		`, c.Role, c.Permission).Scan(&roleID, &permissionID)
+		if err != nil {
+			return err
+		}
+		return RecordAudit(ctx, tx, AuditEntry{
+			RoleID: &roleID, PermissionID: &permissionID, Action: AuditPermissionRevoked, ChangedBy: changedBy, Metadata: metadata,
+		})
+
+	case policy.KindAssignment + "/" + policy.ActionCreate:
+		userID := state.Users[c.User].ID
+		var roleID int
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM roles WHERE name = $1`, c.Role).Scan(&roleID); err != nil {
+			return err
+		}
+		// Checked inside the transaction, so grants earlier in the plan count
+		if actor != nil {
+			if err := checkCanGrant(ctx, tx, actor, roleID, nil); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_roles (user_id, role_id, assigned_by)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, role_id, (COALESCE(resource_type, '')), (COALESCE(resource_id, ''))) DO UPDATE
+			SET expires_at = NULL, expiry_warned_days = NULL
+		`, userID, roleID, changedBy)
+		if err != nil {
+			return err
+		}
+		return RecordAudit(ctx, tx, AuditEntry{
+			UserID: &userID, RoleID: &roleID, Action: AuditRoleGranted, ChangedBy: changedBy, Metadata: metadata,
+		})
+	}
+
+	return fmt.Errorf("unsupported change %s/%s", c.Kind, c.Action)
+}
+
+// loadState reads the permission catalog, roles with their direct grants,
+// and users with their active global roles
+func (s *PolicyStore) loadState(ctx context.Context, tx *sql.Tx) (*policy.State, error) {
+	state := &policy.State{
+		Permissions:          make(map[string]policy.PermissionSpec),
+		Roles:                make(map[string]policy.RoleState),
+		Users:                make(map[string]policy.UserState),
+		Registered:           make(map[string]bool),
+		RequireVerifiedEmail: s.requireVerifiedEmail,
+	}
+
+	for _, def := range permissions.All() {
+		state.Registered[string(def.Name)] = true
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT name, COALESCE(description, ''), COALESCE(category, '') FROM permissions`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p policy.PermissionSpec
+		if err := rows.Scan(&p.Name, &p.Description, &p.Category); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		state.Permissions[p.Name] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+		SELECT r.name, COALESCE(r.description, ''), COALESCE(r.is_system, false), p.name
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, description string
+		var isSystem bool
+		var permission sql.NullString
+		if err := rows.Scan(&name, &description, &isSystem, &permission); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		role, ok := state.Roles[name]
+		if !ok {
+			role = policy.RoleState{Description: description, IsSystem: isSystem, Permissions: make(map[string]bool)}
+			state.Roles[name] = role
+		}
+		if permission.Valid {
+			role.Permissions[permission.String] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+		SELECT u.id, u.username, u.is_active, u.email_verified_at IS NOT NULL, r.name
+		FROM users u
+		LEFT JOIN user_roles ur ON ur.user_id = u.id
+		     AND ur.resource_type IS NULL
+		     AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		LEFT JOIN roles r ON r.id = ur.role_id
+		WHERE u.erased_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u policy.UserState
+		var username string
+		var role sql.NullString
+		if err := rows.Scan(&u.ID, &username, &u.Active, &u.EmailVerified, &role); err != nil {
+			return nil, err
+		}
+		user, ok := state.Users[username]
+		if !ok {
+			u.Roles = make(map[string]bool)
+			user = u
+			state.Users[username] = user
+		}
+		if role.Valid {
+			user.Roles[role.String] = true
+		}
+	}
+
+	return state, rows.Err()
+}
@@ -0,0 +1,319 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// PermissionSelector picks permissions by ID, name or category; a permission
+// is selected if it matches any of the lists
+type PermissionSelector struct {
+	IDs        []int    `json:"permission_ids"`
+	Names      []string `json:"permissions"`
+	Categories []string `json:"categories"`
+}
+
+// Empty reports whether nothing was selected
+func (s PermissionSelector) Empty() bool {
+	return len(s.IDs) == 0 && len(s.Names) == 0 && len(s.Categories) == 0
+}
+
+// UnknownPermissionsError lists selector entries that match no permission
+type UnknownPermissionsError struct {
+	Unknown []string
+}
+
+func (e *UnknownPermissionsError) Error() string {
+	return "unknown permissions or categories: " + strings.Join(e.Unknown, ", ")
+}
+
+// PermissionDiff lists the permission names a bulk change granted and revoked
+type PermissionDiff struct {
+	Granted []string `json:"granted"`
+	Revoked []string `json:"revoked"`
+}
+
+// SetPermissions atomically replaces a role's direct permissions with the
+// selected ones. Wildcard and deny rules are left alone.
+func (s *RoleStore) SetPermissions(ctx context.Context, roleID int, sel PermissionSelector, changedBy, version int) (*PermissionDiff, error) {
+	return s.bulkChange(ctx, roleID, sel, changedBy, version, func(selected, current map[int]string) (grant, revoke map[int]string) {
+		grant, revoke = map[int]string{}, map[int]string{}
+		for id, name := range selected {
+			if _, ok := current[id]; !ok {
+				grant[id] = name
+			}
+		}
+		for id, name := range current {
+			if _, ok := selected[id]; !ok {
+				revoke[id] = name
+			}
+		}
+		return grant, revoke
+	})
+}
+
+// GrantPermissions grants every selected permission the role doesn't have yet
+func (s *RoleStore) GrantPermissions(ctx context.Context, roleID int, sel PermissionSelector, changedBy, version int) (*PermissionDiff, error) {
+	return s.bulkChange(ctx, roleID, sel, changedBy, version, func(selected, current map[int]string) (grant, revoke map[int]string) {
+		grant = map[int]string{}
+		for id, name := range selected {
+			if _, ok := current[id]; !ok {
+				grant[id] = name
+			}
+		}
+		return grant, nil
+	})
+}
+
+// RevokePermissions revokes every selected permission the role has
+func (s *RoleStore) RevokePermissions(ctx context.Context, roleID int, sel PermissionSelector, changedBy, version int) (*PermissionDiff, error) {
+	return s.bulkChange(ctx, roleID, sel, changedBy, version, func(selected, current map[int]string) (grant, revoke map[int]string) {
+		revoke = map[int]string{}
+		for id, name := range selected {
+			if _, ok := current[id]; ok {
+				revoke[id] = name
+			}
+		}
+		return nil, revoke
+	})
+}
+
+// bulkChange resolves the selector, applies the grants and revocations plan
+// returns in one transaction, and audits each. A non-zero version must be the
+// role's current one, otherwise ErrVersionMismatch.
+func (s *RoleStore) bulkChange(ctx context.Context, roleID int, sel PermissionSelector, changedBy, version int,
+	plan func(selected, current map[int]string) (grant, revoke map[int]string)) (*PermissionDiff, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	// Also confirms the role exists
+	if err := lockRole(ctx, tx, roleID, version); err != nil {
+		return nil, err
+	}
+
+	selected, err := resolvePermissions(ctx, tx, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[int]string)
+	rows, err := tx.QueryContext(ctx, `
+		SELECT p.id, p.name
+		FROM role_permissions rp
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE rp.role_id = $1
+	`, roleID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		current[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	grant, revoke := plan(selected, current)
+	diff := &PermissionDiff{Granted: []string{}, Revoked: []string{}}
+
+	for id, name := range grant {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO role_permissions (role_id, permission_id, granted_by)
+			VALUES ($1, $2, NULLIF($3, 0))
+		`, roleID, id, changedBy); err != nil {
+			return nil, err
+		}
+		if err := auditRolePermission(ctx, tx, AuditPermissionGranted, roleID, id, changedBy); err != nil {
+			return nil, err
+		}
+		diff.Granted = append(diff.Granted, name)
+	}
+
+	for id, name := range revoke {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = $2`, roleID, id); err != nil {
+			return nil, err
+		}
+		if err := auditRolePermission(ctx, tx, AuditPermissionRevoked, roleID, id, changedBy); err != nil {
+			return nil, err
+		}
+		diff.Revoked = append(diff.Revoked, name)
+	}
+
+	if len(revoke) > 0 {
+		if err := checkLockout(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if len(grant) > 0 || len(revoke) > 0 {
+		s.invalidator.InvalidateAll(ctx)
+	}
+	sort.Strings(diff.Granted)
+	sort.Strings(diff.Revoked)
+	return diff, nil
+}
+
+// Clone creates a custom role with the source role's direct permissions,
+// wildcard/deny rules and parents, so it starts with the same effective
+// permissions. Memberships are not copied. Callers check the creator may
+// grant the source role (UserRoleStore.CheckCanGrant).
+func (s *RoleStore) Clone(ctx context.Context, sourceID int, name, description string, createdBy int) (*Role, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM roles WHERE id = $1)`, sourceID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	var role Role
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO roles (name, description, is_system)
+		VALUES ($1, $2, false)
+		RETURNING id, name, description, is_system, version, created_at, updated_at
+	`, name, description).Scan(&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.Version, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	copies := []struct {
+		what, query string
+	}{
+		{"permissions", `
+			INSERT INTO role_permissions (role_id, permission_id, granted_by, conditions)
+			SELECT $1, permission_id, NULLIF($3, 0), conditions FROM role_permissions WHERE role_id = $2`},
+		{"rules", `
+			INSERT INTO role_permission_rules (role_id, pattern, effect, created_by)
+			SELECT $1, pattern, effect, NULLIF($3, 0) FROM role_permission_rules WHERE role_id = $2`},
+		{"parents", `
+			INSERT INTO role_parents (role_id, parent_role_id, created_by)
+			SELECT $1, parent_role_id, NULLIF($3, 0) FROM role_parents WHERE role_id = $2`},
+	}
+	for _, c := range copies {
+		if _, err := tx.ExecContext(ctx, c.query, role.ID, sourceID, createdBy); err != nil {
+			return nil, fmt.Errorf("copy %s: %w", c.what, err)
+		}
+	}
+
+	metadata, _ := json.Marshal(map[string]int{"cloned_from": sourceID})
+	var changedBy *int
+	if createdBy != 0 {
+		changedBy = &createdBy
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO permission_audit (role_id, permission_id, action, changed_by, metadata)
+		SELECT $1, permission_id, $2, $3, $4 FROM role_permissions WHERE role_id = $1
+	`, role.ID, AuditPermissionGranted, changedBy, metadata); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// ResolvePermissions returns the names of the permissions a selector picks,
+// sorted, or an *UnknownPermissionsError
+func (s *RoleStore) ResolvePermissions(ctx context.Context, sel PermissionSelector) ([]string, error) {
+	selected, err := resolvePermissions(ctx, s.db, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(selected))
+	for _, name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// resolvePermissions maps a selector to permission IDs and names, failing if
+// any ID, name or category matches nothing
+func resolvePermissions(ctx context.Context, q Querier, sel PermissionSelector) (map[int]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, name, COALESCE(category, '')
+		FROM permissions
+		WHERE id = ANY($1) OR name = ANY($2) OR category = ANY($3)
+	`, pq.Array(sel.IDs), pq.Array(sel.Names), pq.Array(sel.Categories))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	selected := make(map[int]string)
+	seenIDs, seenNames, seenCategories := map[int]bool{}, map[string]bool{}, map[string]bool{}
+	for rows.Next() {
+		var id int
+		var name, category string
+		if err := rows.Scan(&id, &name, &category); err != nil {
+			return nil, err
+		}
+		selected[id] = name
+		seenIDs[id], seenNames[name], seenCategories[category] = true, true, true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	for _, id := range sel.IDs {
+		if !seenIDs[id] {
+			unknown = append(unknown, fmt.Sprintf("id %d", id))
+		}
+	}
+	for _, name := range sel.Names {
+		if !seenNames[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	for _, category := range sel.Categories {
+		if !seenCategories[category] {
+			unknown = append(unknown, "category "+category)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, &UnknownPermissionsError{Unknown: unknown}
+	}
+
+	return selected, nil
+}
+
+func auditRolePermission(ctx context.Context, tx *sql.Tx, action string, roleID, permissionID, changedBy int) error {
+	entry := AuditEntry{RoleID: &roleID, PermissionID: &permissionID, Action: action}
+	if changedBy != 0 {
+		entry.ChangedBy = &changedBy
+	}
+	return RecordAudit(ctx, tx, entry)
+}
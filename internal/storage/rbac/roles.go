@@ -3,7 +3,18 @@ package rbac
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/scim"
+)
+
+var (
+	// ErrRoleCycle is returned when adding a parent would make a role inherit from itself
+	ErrRoleCycle = errors.New("role hierarchy cannot contain cycles")
+	// ErrVersionMismatch is returned when a role changed since the version the caller last read
+	ErrVersionMismatch = errors.New("role was modified since it was read")
 )
 
 // Role represents a user role
@@ -12,6 +23,7 @@ type Role struct {
 	Name        string    `db:"name" json:"name"`
 	Description string    `db:"description" json:"description"`
 	IsSystem    bool      `db:"is_system" json:"is_system"`
+	Version     int       `db:"version" json:"version"` // Incremented by every change to the role or its ancestors
 	CreatedAt   time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
 }
@@ -24,19 +36,41 @@ type RoleWithPermissions struct {
 	UserCount        int          `json:"user_count"`
 }
 
+// InheritedPermission is a permission a role holds through one of its ancestors
+type InheritedPermission struct {
+	Permission
+	InheritedFrom string `json:"inherited_from"`
+}
+
+// RoleRule is a wildcard or deny permission pattern attached to a role
+type RoleRule struct {
+	ID        int       `json:"id"`
+	RoleID    int       `json:"role_id"`
+	Pattern   string    `json:"pattern"`
+	Effect    Effect    `json:"effect"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy *int      `json:"created_by,omitempty"`
+}
+
 // RoleStore handles database operations for roles
 type RoleStore struct {
-	db *sql.DB
+	db          *sql.DB
+	invalidator Invalidator
 }
 
 // NewRoleStore creates a new role store
 func NewRoleStore(db *sql.DB) *RoleStore {
-	return &RoleStore{db: db}
+	return &RoleStore{db: db, invalidator: nopInvalidator{}}
+}
+
+// SetInvalidator registers the cache to notify when role permissions change
+func (s *RoleStore) SetInvalidator(invalidator Invalidator) {
+	s.invalidator = invalidator
 }
 
 // List retrieves all roles
 func (s *RoleStore) List(ctx context.Context) ([]Role, error) {
-	query := `SELECT id, name, description, is_system, created_at, updated_at FROM roles ORDER BY name`
+	query := `SELECT id, name, description, is_system, version, created_at, updated_at FROM roles ORDER BY name`
 
 	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
@@ -47,7 +81,7 @@ func (s *RoleStore) List(ctx context.Context) ([]Role, error) {
 	var roles []Role
 	for rows.Next() {
 		var role Role
-		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.CreatedAt, &role.UpdatedAt); err != nil {
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.Version, &role.CreatedAt, &role.UpdatedAt); err != nil {
 			return nil, err
 		}
 		roles = append(roles, role)
@@ -56,12 +90,63 @@ func (s *RoleStore) List(ctx context.Context) ([]Role, error) {
 	return roles, nil
 }
 
+// activeRoleMembers selects a role's active global assignments, which SCIM
+// exposes as the group's members
+const activeRoleMembers = `FROM user_roles ur JOIN users u ON u.id = ur.user_id
+		WHERE ur.role_id = roles.id AND ur.resource_type IS NULL
+		  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())`
+
+// scimGroupColumns maps SCIM Group attributes onto the roles table
+var scimGroupColumns = scim.Columns{
+	"id":              {Expr: "roles.id::TEXT"},
+	"externalid":      {Expr: "''"},
+	"displayname":     {Expr: "roles.name"},
+	"members":         {Expr: "ur.user_id::TEXT", From: activeRoleMembers},
+	"members.value":   {Expr: "ur.user_id::TEXT", From: activeRoleMembers},
+	"members.display": {Expr: "u.username", From: activeRoleMembers},
+}
+
+// ListSCIM returns a page of the roles matching a SCIM Group filter (nil for
+// all), ordered by name, and the total number of matches
+func (s *RoleStore) ListSCIM(ctx context.Context, filter scim.Filter, limit, offset int) ([]Role, int, error) {
+	where, args := "TRUE", []interface{}{}
+	if filter != nil {
+		where, args = scim.SQL(filter, scimGroupColumns, args)
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM roles WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, name, description, is_system, version, created_at, updated_at
+		FROM roles WHERE %s ORDER BY name LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	rows, err := s.db.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.Version, &role.CreatedAt, &role.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, total, rows.Err()
+}
+
 // GetByID retrieves a role by ID
 func (s *RoleStore) GetByID(ctx context.Context, id int) (*Role, error) {
-	query := `SELECT id, name, description, is_system, created_at, updated_at FROM roles WHERE id = $1`
+	query := `SELECT id, name, description, is_system, version, created_at, updated_at FROM roles WHERE id = $1`
 
 	var role Role
-	err := s.db.QueryRowContext(ctx, query, id).Scan(&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.CreatedAt, &role.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.Version, &role.CreatedAt, &role.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -71,10 +156,10 @@ func (s *RoleStore) GetByID(ctx context.Context, id int) (*Role, error) {
 
 // GetByName retrieves a role by name
 func (s *RoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
-	query := `SELECT id, name, description, is_system, created_at, updated_at FROM roles WHERE name = $1`
+	query := `SELECT id, name, description, is_system, version, created_at, updated_at FROM roles WHERE name = $1`
 
 	var role Role
-	err := s.db.QueryRowContext(ctx, query, name).Scan(&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.CreatedAt, &role.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, name).Scan(&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.Version, &role.CreatedAt, &role.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -87,12 +172,12 @@ func (s *RoleStore) Create(ctx context.Context, name, description string) (*Role
 	query := `
 		INSERT INTO roles (name, description, is_system)
 		VALUES ($1, $2, false)
-		RETURNING id, name, description, is_system, created_at, updated_at
+		RETURNING id, name, description, is_system, version, created_at, updated_at
 	`
 
 	var role Role
 	err := s.db.QueryRowContext(ctx, query, name, description).Scan(
-		&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.CreatedAt, &role.UpdatedAt,
+		&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.Version, &role.CreatedAt, &role.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -101,48 +186,133 @@ func (s *RoleStore) Create(ctx context.Context, name, description string) (*Role
 	return &role, nil
 }
 
-// Update updates a role's name and description
-func (s *RoleStore) Update(ctx context.Context, id int, name, description string) error {
+// Update updates a role's name and description. A non-zero version must
+// match the role's current version, otherwise ErrVersionMismatch is returned.
+func (s *RoleStore) Update(ctx context.Context, id int, name, description string, version int) error {
 	query := `
 		UPDATE roles
 		SET name = $2, description = $3, updated_at = NOW()
-		WHERE id = $1 AND is_system = false
+		WHERE id = $1 AND is_system = false AND ($4 = 0 OR version = $4)
 	`
 
-	result, err := s.db.ExecContext(ctx, query, id, name, description)
+	result, err := s.db.ExecContext(ctx, query, id, name, description, version)
 	if err != nil {
 		return err
 	}
 
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return sql.ErrNoRows
+		return s.missingOrStale(ctx, id, version)
 	}
 
+	s.invalidator.InvalidateAll(ctx)
 	return nil
 }
 
-// Delete deletes a custom role (system roles cannot be deleted)
-func (s *RoleStore) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM roles WHERE id = $1 AND is_system = false`
+// Delete deletes a custom role (system roles cannot be deleted).
+// Returns ErrLastAdmin or ErrLockout if the role is the last way to hold
+// admin or to administer roles, and ErrVersionMismatch if a non-zero
+// version isn't the role's current one.
+func (s *RoleStore) Delete(ctx context.Context, id int, version int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	result, err := s.db.ExecContext(ctx, query, id)
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return err
+	}
+
+	isAdminRole, err := grantsAdmin(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM roles WHERE id = $1 AND is_system = false AND ($2 = 0 OR version = $2)`
+
+	result, err := tx.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
 
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
+		return s.missingOrStale(ctx, id, version)
+	}
+
+	if isAdminRole {
+		admins, err := CountAdmins(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if admins == 0 {
+			return ErrLastAdmin
+		}
+	}
+	if err := checkLockout(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return nil
+}
+
+// missingOrStale explains why a versioned update or delete matched no row:
+// ErrVersionMismatch if the custom role exists at another version, otherwise sql.ErrNoRows
+func (s *RoleStore) missingOrStale(ctx context.Context, id, version int) error {
+	if version == 0 {
 		return sql.ErrNoRows
 	}
+	var current int
+	err := s.db.QueryRowContext(ctx, `SELECT version FROM roles WHERE id = $1 AND is_system = false`, id).Scan(&current)
+	if err != nil {
+		return err
+	}
+	if current != version {
+		return ErrVersionMismatch
+	}
+	return sql.ErrNoRows
+}
 
+// lockRole locks a role's row until tx ends, so its version can't move
+// underneath the change. Returns sql.ErrNoRows if the role doesn't exist and
+// ErrVersionMismatch if a non-zero version isn't its current one.
+func lockRole(ctx context.Context, tx *sql.Tx, roleID, version int) error {
+	var current int
+	if err := tx.QueryRowContext(ctx, `SELECT version FROM roles WHERE id = $1 FOR UPDATE`, roleID).Scan(&current); err != nil {
+		return err
+	}
+	if version != 0 && current != version {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// checkVersion explains why a removal guarded by a non-zero version matched
+// nothing: ErrVersionMismatch if the role is at another version
+func (s *RoleStore) checkVersion(ctx context.Context, roleID, version int) error {
+	if version == 0 {
+		return nil
+	}
+	var current int
+	if err := s.db.QueryRowContext(ctx, `SELECT version FROM roles WHERE id = $1`, roleID).Scan(&current); err != nil {
+		return err
+	}
+	if current != version {
+		return ErrVersionMismatch
+	}
 	return nil
 }
 
 // GetPermissions retrieves all permissions for a role
 func (s *RoleStore) GetPermissions(ctx context.Context, roleID int) ([]Permission, error) {
 	query := `
-		SELECT p.id, p.name, p.resource, p.action, p.description, p.category, p.created_at
+		SELECT p.id, p.name, p.resource, p.action, p.description, p.category, p.created_at, rp.conditions
 		FROM permissions p
 		JOIN role_permissions rp ON p.id = rp.permission_id
 		WHERE rp.role_id = $1
@@ -158,7 +328,11 @@ func (s *RoleStore) GetPermissions(ctx context.Context, roleID int) ([]Permissio
 	var permissions []Permission
 	for rows.Next() {
 		var perm Permission
-		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt); err != nil {
+		var conditions []byte
+		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt, &conditions); err != nil {
+			return nil, err
+		}
+		if perm.Conditions, err = scanConditions(conditions); err != nil {
 			return nil, err
 		}
 		permissions = append(permissions, perm)
@@ -167,24 +341,87 @@ func (s *RoleStore) GetPermissions(ctx context.Context, roleID int) ([]Permissio
 	return permissions, nil
 }
 
-// GrantPermission grants a permission to a role
-func (s *RoleStore) GrantPermission(ctx context.Context, roleID, permissionID, grantedBy int) error {
+// GrantPermission grants a permission to a role, only for requests meeting
+// conditions when they are non-empty. Re-granting replaces the conditions.
+// Returns ErrLockout if making a grant conditional would leave no role able
+// to administer roles, and ErrVersionMismatch if a non-zero version isn't the
+// role's current one.
+func (s *RoleStore) GrantPermission(ctx context.Context, roleID, permissionID, grantedBy int, conditions *Conditions, version int) error {
+	conditionsJSON, err := conditionsValue(conditions)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := lockRole(ctx, tx, roleID, version); err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO role_permissions (role_id, permission_id, granted_by)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (role_id, permission_id) DO NOTHING
+		INSERT INTO role_permissions (role_id, permission_id, granted_by, conditions)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (role_id, permission_id) DO UPDATE SET conditions = EXCLUDED.conditions
 	`
 
-	_, err := s.db.ExecContext(ctx, query, roleID, permissionID, grantedBy)
-	return err
+	if _, err := tx.ExecContext(ctx, query, roleID, permissionID, grantedBy, conditionsJSON); err != nil {
+		return err
+	}
+
+	if err := checkLockout(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return nil
 }
 
-// RevokePermission revokes a permission from a role
-func (s *RoleStore) RevokePermission(ctx context.Context, roleID, permissionID int) error {
+// RevokePermission revokes a permission from a role.
+// Returns ErrLockout if no role would be left able to administer roles, and
+// ErrVersionMismatch if a non-zero version isn't the role's current one.
+func (s *RoleStore) RevokePermission(ctx context.Context, roleID, permissionID, version int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := lockRole(ctx, tx, roleID, version); err != nil {
+		return err
+	}
+
 	query := `DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = $2`
 
-	_, err := s.db.ExecContext(ctx, query, roleID, permissionID)
-	return err
+	if _, err := tx.ExecContext(ctx, query, roleID, permissionID); err != nil {
+		return err
+	}
+
+	if err := checkLockout(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return nil
 }
 
 // GetUserCount gets the number of users with this role
@@ -195,3 +432,270 @@ func (s *RoleStore) GetUserCount(ctx context.Context, roleID int) (int, error) {
 	err := s.db.QueryRowContext(ctx, query, roleID).Scan(&count)
 	return count, err
 }
+
+// GetParents retrieves the roles a role directly inherits from
+func (s *RoleStore) GetParents(ctx context.Context, roleID int) ([]Role, error) {
+	query := `
+		SELECT r.id, r.name, r.description, r.is_system, r.version, r.created_at, r.updated_at
+		FROM roles r
+		JOIN role_parents rp ON rp.parent_role_id = r.id
+		WHERE rp.role_id = $1
+		ORDER BY r.name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.IsSystem, &role.Version, &role.CreatedAt, &role.UpdatedAt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// AddParent makes a role inherit the permissions of parentID.
+// Returns ErrRoleCycle if parentID is the role itself or already inherits from it,
+// a *SoDViolationError if a holder of the role would hold mutually exclusive roles,
+// and ErrVersionMismatch if a non-zero version isn't the role's current one.
+func (s *RoleStore) AddParent(ctx context.Context, roleID, parentID, createdBy, version int) error {
+	if roleID == parentID {
+		return ErrRoleCycle
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := lockRole(ctx, tx, roleID, version); err != nil {
+		return err
+	}
+
+	// Serialise hierarchy changes so two concurrent inserts cannot close a cycle
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE role_parents IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+
+	var cycle bool
+	err = tx.QueryRowContext(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_role_id FROM role_parents WHERE role_id = $1
+			UNION
+			SELECT rp.parent_role_id
+			FROM ancestors a
+			JOIN role_parents rp ON rp.role_id = a.parent_role_id
+		)
+		SELECT EXISTS(SELECT 1 FROM ancestors WHERE parent_role_id = $2)
+	`, parentID, roleID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrRoleCycle
+	}
+
+	// Inheriting may give the role's holders a forbidden combination
+	err = enforceSoD(ctx, tx, nil, func() error {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO role_parents (role_id, parent_role_id, created_by)
+		VALUES ($1, $2, NULLIF($3, 0))
+		ON CONFLICT (role_id, parent_role_id) DO NOTHING
+	`, roleID, parentID, createdBy)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return nil
+}
+
+// RemoveParent stops a role inheriting from parentID.
+// Returns ErrLastAdmin if that takes admin away from the last active admin,
+// ErrLockout if nobody could administer roles afterwards, or
+// ErrVersionMismatch if a non-zero version isn't the role's current one.
+func (s *RoleStore) RemoveParent(ctx context.Context, roleID, parentID, version int) error {
+	query := `
+		DELETE FROM role_parents
+		WHERE role_id = $1 AND parent_role_id = $2
+		  AND ($3 = 0 OR EXISTS (SELECT 1 FROM roles WHERE id = $1 AND version = $3))
+	`
+
+	rows, err := execPreservingAccess(ctx, s.db, query, roleID, parentID, version)
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return s.checkVersion(ctx, roleID, version)
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return nil
+}
+
+// GetInheritedPermissions retrieves the permissions a role holds through its
+// ancestors and not directly, with the nearest ancestor granting each
+func (s *RoleStore) GetInheritedPermissions(ctx context.Context, roleID int) ([]InheritedPermission, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_role_id, 1 AS depth FROM role_parents WHERE role_id = $1
+			UNION
+			SELECT rp.parent_role_id, a.depth + 1
+			FROM ancestors a
+			JOIN role_parents rp ON rp.role_id = a.parent_role_id
+			WHERE a.depth < 32
+		)
+		SELECT DISTINCT ON (p.id)
+		       p.id, p.name, p.resource, p.action, p.description, p.category, p.created_at, r.name
+		FROM ancestors a
+		JOIN roles r ON r.id = a.parent_role_id
+		JOIN role_permissions rp ON rp.role_id = a.parent_role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE a.parent_role_id <> $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM role_permissions own
+			WHERE own.role_id = $1 AND own.permission_id = p.id
+		  )
+		ORDER BY p.id, a.depth, r.name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []InheritedPermission
+	for rows.Next() {
+		var perm InheritedPermission
+		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action, &perm.Description, &perm.Category, &perm.CreatedAt, &perm.InheritedFrom); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, perm)
+	}
+
+	return permissions, rows.Err()
+}
+
+// GetRules retrieves the wildcard and deny rules attached to a role
+func (s *RoleStore) GetRules(ctx context.Context, roleID int) ([]RoleRule, error) {
+	query := `
+		SELECT id, role_id, pattern, effect, created_at, created_by
+		FROM role_permission_rules
+		WHERE role_id = $1
+		ORDER BY effect DESC, pattern
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []RoleRule
+	for rows.Next() {
+		var rule RoleRule
+		if err := rows.Scan(&rule.ID, &rule.RoleID, &rule.Pattern, &rule.Effect, &rule.CreatedAt, &rule.CreatedBy); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// AddRule attaches an allow or deny permission pattern to a role.
+// Returns ErrLockout if a deny rule leaves nobody able to administer roles,
+// and ErrVersionMismatch if a non-zero version isn't the role's current one.
+func (s *RoleStore) AddRule(ctx context.Context, roleID int, pattern string, effect Effect, createdBy, version int) (*RoleRule, error) {
+	if err := ValidatePattern(pattern); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if err := lockRole(ctx, tx, roleID, version); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO role_permission_rules (role_id, pattern, effect, created_by)
+		VALUES ($1, $2, $3, NULLIF($4, 0))
+		ON CONFLICT (role_id, pattern, effect) DO UPDATE SET pattern = EXCLUDED.pattern
+		RETURNING id, role_id, pattern, effect, created_at, created_by
+	`
+
+	var rule RoleRule
+	err = tx.QueryRowContext(ctx, query, roleID, pattern, effect, createdBy).Scan(
+		&rule.ID, &rule.RoleID, &rule.Pattern, &rule.Effect, &rule.CreatedAt, &rule.CreatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if effect == EffectDeny {
+		if err := checkLockout(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return &rule, nil
+}
+
+// RemoveRule removes a permission rule from a role.
+// Returns ErrLockout if it was the last way to administer roles, and
+// ErrVersionMismatch if a non-zero version isn't the role's current one.
+func (s *RoleStore) RemoveRule(ctx context.Context, roleID, ruleID, version int) error {
+	query := `
+		DELETE FROM role_permission_rules
+		WHERE id = $1 AND role_id = $2
+		  AND ($3 = 0 OR EXISTS (SELECT 1 FROM roles WHERE id = $2 AND version = $3))
+	`
+
+	rows, err := execPreservingAccess(ctx, s.db, query, ruleID, roleID, version)
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		if err := s.checkVersion(ctx, roleID, version); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+
+	s.invalidator.InvalidateAll(ctx)
+	return nil
+}
@@ -0,0 +1,342 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/bwburch/inflight-ui-service/internal/permissions"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrLastAdmin is returned when a change would leave no active admin
+	ErrLastAdmin = errors.New("cannot remove the last admin")
+	// ErrLockout is returned when a change would leave no role granting a
+	// permission needed to administer roles
+	ErrLockout = errors.New("change would leave no role able to administer roles")
+)
+
+// lockoutPermissions must always be granted by at least one role, otherwise
+// nobody but an admin could ever repair the role configuration
+var lockoutPermissions = []string{string(permissions.RolesEdit), string(permissions.UsersManageRoles)}
+
+// safeguardLockKey serialises changes that are checked for lockout so two
+// concurrent removals can't each see the other's admin as remaining
+const safeguardLockKey = 0x72626163 // "rbac"
+
+// EscalationError is returned when an actor tries to grant a role carrying
+// permissions they don't hold themselves
+type EscalationError struct {
+	Role    string
+	Missing []string
+}
+
+func (e *EscalationError) Error() string {
+	return "cannot grant role " + e.Role + " with permissions you do not hold: " + strings.Join(e.Missing, ", ")
+}
+
+// Querier is satisfied by *sql.DB and *sql.Tx
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// adminRolesCTE selects admin and every role inheriting from it. It must
+// follow WITH RECURSIVE.
+const adminRolesCTE = `
+	admin_roles AS (
+		SELECT id FROM roles WHERE name = 'admin'
+		UNION
+		SELECT rp.role_id FROM role_parents rp JOIN admin_roles ar ON rp.parent_role_id = ar.id
+	)`
+
+// LockSafeguards serialises tx with every other change checked against the
+// last-admin and lockout safeguards until it ends
+func LockSafeguards(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, safeguardLockKey)
+	return err
+}
+
+// grantsAdmin reports whether holding roleID globally makes a user an admin
+func grantsAdmin(ctx context.Context, q Querier, roleID int) (bool, error) {
+	var admin bool
+	err := q.QueryRowContext(ctx, `
+		WITH RECURSIVE `+adminRolesCTE+`
+		SELECT EXISTS(SELECT 1 FROM admin_roles WHERE id = $1)
+	`, roleID).Scan(&admin)
+	return admin, err
+}
+
+// CountAdmins counts active users who are unconditional global admins, directly or through a group
+func CountAdmins(ctx context.Context, q Querier) (int, error) {
+	var count int
+	err := q.QueryRowContext(ctx, `
+		WITH RECURSIVE `+adminRolesCTE+`
+		SELECT COUNT(*)
+		FROM users u
+		WHERE u.is_active = true AND u.erased_at IS NULL
+		  AND (
+			EXISTS (
+				SELECT 1 FROM user_roles ur
+				WHERE ur.user_id = u.id AND ur.resource_type IS NULL AND ur.conditions IS NULL
+				  AND ur.role_id IN (SELECT id FROM admin_roles)
+				  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+			) OR EXISTS (
+				SELECT 1 FROM group_members gm
+				JOIN group_roles gr ON gr.group_id = gm.group_id
+				WHERE gm.user_id = u.id
+				  AND gr.role_id IN (SELECT id FROM admin_roles)
+				  AND (gr.expires_at IS NULL OR gr.expires_at > NOW())
+			)
+		  )
+	`).Scan(&count)
+	return count, err
+}
+
+// ExecPreservingAdmin runs a statement that removes role assignments,
+// memberships or users, returning ErrLastAdmin (and changing nothing) if it
+// took away the last active admin
+func ExecPreservingAdmin(ctx context.Context, db *sql.DB, query string, args ...interface{}) (int64, error) {
+	return execSafeguarded(ctx, db, false, nil, query, args...)
+}
+
+// execPreservingAccess is ExecPreservingAdmin for statements that change what
+// roles grant, also returning ErrLockout if nobody could administer roles
+// afterwards
+func execPreservingAccess(ctx context.Context, db *sql.DB, query string, args ...interface{}) (int64, error) {
+	return execSafeguarded(ctx, db, true, nil, query, args...)
+}
+
+// execSafeguarded runs a removal under the safeguard lock, checking the admin
+// count, lockout when asked, and recording audit if the statement did anything
+func execSafeguarded(ctx context.Context, db *sql.DB, lockout bool, audit *AuditEntry, query string, args ...interface{}) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	before, err := CountAdmins(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	affected, _ := result.RowsAffected()
+
+	if before > 0 && affected > 0 {
+		after, err := CountAdmins(ctx, tx)
+		if err != nil {
+			return 0, err
+		}
+		if after == 0 {
+			return 0, ErrLastAdmin
+		}
+	}
+
+	if lockout && affected > 0 {
+		if err := checkLockout(ctx, tx); err != nil {
+			return 0, err
+		}
+	}
+
+	if audit != nil && affected > 0 {
+		if err := RecordAudit(ctx, tx, *audit); err != nil {
+			return 0, err
+		}
+	}
+
+	return affected, tx.Commit()
+}
+
+// checkLockout returns ErrLockout unless every lockout permission is still
+// granted by some role (exactly and unconditionally, or through an allow rule
+// not cancelled by a deny rule on the same role)
+func checkLockout(ctx context.Context, q Querier) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT rp.role_id, p.name, 'allow'
+		FROM role_permissions rp
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE p.name = ANY($1) AND rp.conditions IS NULL
+		UNION ALL
+		SELECT role_id, pattern, effect FROM role_permission_rules
+	`, pq.Array(lockoutPermissions))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	roleRules := make(map[int][]PolicyRule)
+	for rows.Next() {
+		var roleID int
+		var rule PolicyRule
+		if err := rows.Scan(&roleID, &rule.Pattern, &rule.Effect); err != nil {
+			return err
+		}
+		roleRules[roleID] = append(roleRules[roleID], rule)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, permission := range lockoutPermissions {
+		granted := false
+		for _, rules := range roleRules {
+			if (&Policy{Rules: rules}).Allows(permission) {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return ErrLockout
+		}
+	}
+	return nil
+}
+
+// CheckCanGrant returns an *EscalationError unless the actor holds every
+// permission the role would give (including inherited ones and anything its
+// wildcard rules cover) on the given scope. Only admins may grant admin.
+func (s *UserRoleStore) CheckCanGrant(ctx context.Context, actorID, roleID int, scope *Scope) error {
+	actor, err := s.LoadPolicy(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	return checkCanGrant(ctx, s.db, actor, roleID, scope)
+}
+
+// checkCanGrant is CheckCanGrant for an already loaded actor policy, reading
+// the role through q so a transaction sees its own changes
+func checkCanGrant(ctx context.Context, q Querier, actor *Policy, roleID int, scope *Scope) error {
+	if actor.Admin {
+		return nil
+	}
+
+	var roleName string
+	if err := q.QueryRowContext(ctx, `SELECT name FROM roles WHERE id = $1`, roleID).Scan(&roleName); err != nil {
+		return err
+	}
+
+	isAdminRole, err := grantsAdmin(ctx, q, roleID)
+	if err != nil {
+		return err
+	}
+	if isAdminRole {
+		return &EscalationError{Role: roleName, Missing: []string{"admin"}}
+	}
+
+	// Every permission the role grants, inherited ones included: exact
+	// grants, plus every catalog permission its allow rules match
+	rows, err := q.QueryContext(ctx, `
+		WITH RECURSIVE lineage AS (
+			SELECT $1::INTEGER AS role_id
+			UNION
+			SELECT rp.parent_role_id FROM role_parents rp JOIN lineage l ON rp.role_id = l.role_id
+		),
+		patterns AS (
+			SELECT p.name AS pattern
+			FROM lineage l
+			JOIN role_permissions rp ON rp.role_id = l.role_id
+			JOIN permissions p ON p.id = rp.permission_id
+			UNION
+			SELECT rr.pattern
+			FROM lineage l
+			JOIN role_permission_rules rr ON rr.role_id = l.role_id AND rr.effect = 'allow'
+		)
+		SELECT p.name, pt.pattern
+		FROM patterns pt
+		JOIN permissions p ON p.name = pt.pattern OR pt.pattern LIKE '%*%'
+		ORDER BY p.name
+	`, roleID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var resource *Resource
+	if scope != nil {
+		// A scope's glob is checked as a resource ID: the actor's own scoped
+		// grant must cover it (e.g. "payments-*" covers "payments-*")
+		resource = &Resource{Type: scope.ResourceType, ID: scope.ResourceID}
+	}
+
+	var missing []string
+	checked := make(map[string]bool)
+	for rows.Next() {
+		var name, pattern string
+		if err := rows.Scan(&name, &pattern); err != nil {
+			return err
+		}
+		if checked[name] || !MatchPermission(pattern, name) {
+			continue
+		}
+		checked[name] = true
+		if !actor.Decide(name, resource).Allowed {
+			missing = append(missing, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return &EscalationError{Role: roleName, Missing: missing}
+	}
+	return nil
+}
+
+// CheckCanGrantPermissions returns an *EscalationError unless the actor holds
+// every catalog permission matched by the given names or wildcard patterns,
+// as they would be added to role. Admins may grant anything.
+func (s *UserRoleStore) CheckCanGrantPermissions(ctx context.Context, actorID int, role string, patterns []string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	actor, err := s.LoadPolicy(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	if actor.Admin {
+		return nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM permissions ORDER BY name`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		for _, pattern := range patterns {
+			if MatchPermission(pattern, name) {
+				if !actor.Decide(name, nil).Allowed {
+					missing = append(missing, name)
+				}
+				break
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return &EscalationError{Role: role, Missing: missing}
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidConstraint is returned for separation-of-duties constraints that
+// name fewer than two roles, unknown roles, or roles inheriting one another
+var ErrInvalidConstraint = errors.New("invalid separation-of-duties constraint")
+
+// SoDConstraint is a set of mutually exclusive roles: nobody may hold more than
+// one of them, directly, through a group or by inheritance
+type SoDConstraint struct {
+	ID          int           `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Roles       []RoleSummary `json:"roles"`
+	CreatedAt   time.Time     `json:"created_at"`
+	CreatedBy   *int          `json:"created_by,omitempty"`
+}
+
+// RoleSummary identifies a role
+type RoleSummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// SoDViolation is a user holding more than one role of a constraint
+type SoDViolation struct {
+	ConstraintID int      `json:"constraint_id"`
+	Constraint   string   `json:"constraint"`
+	UserID       int      `json:"user_id"`
+	Username     string   `json:"username"`
+	Roles        []string `json:"roles"` // The constraint's roles the user holds
+}
+
+// SoDViolationError is returned when an assignment would give a user a
+// combination of roles a constraint forbids
+type SoDViolationError struct {
+	SoDViolation
+}
+
+func (e *SoDViolationError) Error() string {
+	return fmt.Sprintf("separation of duties constraint %s forbids %s holding %s together",
+		e.Constraint, e.Username, strings.Join(e.Roles, " and "))
+}
+
+// heldRolesCTE selects every (user_id, role_id) pair currently held directly,
+// through a group or by inheritance, for the users in $1 (every user when $1
+// is NULL). It must follow WITH RECURSIVE.
+const heldRolesCTE = `
+	held_granted AS (
+		SELECT ur.user_id, ur.role_id
+		FROM user_roles ur
+		WHERE (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		  AND ($1::INTEGER[] IS NULL OR ur.user_id = ANY($1))
+		UNION
+		SELECT gm.user_id, gr.role_id
+		FROM group_roles gr
+		JOIN group_members gm ON gm.group_id = gr.group_id
+		WHERE (gr.expires_at IS NULL OR gr.expires_at > NOW())
+		  AND ($1::INTEGER[] IS NULL OR gm.user_id = ANY($1))
+	),
+	held AS (
+		SELECT user_id, role_id FROM held_granted
+		UNION
+		SELECT h.user_id, rp.parent_role_id FROM held h JOIN role_parents rp ON rp.role_id = h.role_id
+	)`
+
+// SoDStore handles database operations for separation-of-duties constraints
+type SoDStore struct {
+	db *sql.DB
+}
+
+// NewSoDStore creates a new separation-of-duties constraint store
+func NewSoDStore(db *sql.DB) *SoDStore {
+	return &SoDStore{db: db}
+}
+
+// List retrieves every constraint with its roles
+func (s *SoDStore) List(ctx context.Context) ([]SoDConstraint, error) {
+	return s.list(ctx, 0)
+}
+
+// Get retrieves a constraint by ID
+func (s *SoDStore) Get(ctx context.Context, id int) (*SoDConstraint, error) {
+	constraints, err := s.list(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(constraints) == 0 {
+		return nil, nil
+	}
+	return &constraints[0], nil
+}
+
+// list retrieves one constraint, or all when id is 0
+func (s *SoDStore) list(ctx context.Context, id int) ([]SoDConstraint, error) {
+	query := `
+		SELECT c.id, c.name, COALESCE(c.description, ''), c.created_at, c.created_by,
+		       array_agg(r.id ORDER BY r.name), array_agg(r.name ORDER BY r.name)
+		FROM sod_constraints c
+		JOIN sod_constraint_roles cr ON cr.constraint_id = c.id
+		JOIN roles r ON r.id = cr.role_id
+		WHERE $1 = 0 OR c.id = $1
+		GROUP BY c.id
+		ORDER BY c.name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	constraints := []SoDConstraint{}
+	for rows.Next() {
+		var c SoDConstraint
+		var roleIDs pq.Int64Array
+		var roleNames pq.StringArray
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.CreatedBy, &roleIDs, &roleNames); err != nil {
+			return nil, err
+		}
+		for i := range roleIDs {
+			c.Roles = append(c.Roles, RoleSummary{ID: int(roleIDs[i]), Name: roleNames[i]})
+		}
+		constraints = append(constraints, c)
+	}
+
+	return constraints, rows.Err()
+}
+
+// Create defines a constraint over roleIDs. Users already holding several of
+// the roles are not changed; they show up in Violations.
+func (s *SoDStore) Create(ctx context.Context, name, description string, roleIDs []int, createdBy int) (*SoDConstraint, error) {
+	ids := make([]int64, 0, len(roleIDs))
+	seen := make(map[int]bool)
+	for _, id := range roleIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, int64(id))
+		}
+	}
+	if len(ids) < 2 {
+		return nil, fmt.Errorf("%w: at least two distinct roles are required", ErrInvalidConstraint)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM roles WHERE id = ANY($1)`, pq.Array(ids)).Scan(&found); err != nil {
+		return nil, err
+	}
+	if found != len(ids) {
+		return nil, fmt.Errorf("%w: unknown role", ErrInvalidConstraint)
+	}
+
+	// A role inheriting another of the set would make every holder a violator
+	var role, ancestor string
+	err = tx.QueryRowContext(ctx, `
+		WITH RECURSIVE lineage AS (
+			SELECT id AS role_id, id AS ancestor_id FROM roles WHERE id = ANY($1)
+			UNION
+			SELECT l.role_id, rp.parent_role_id
+			FROM lineage l
+			JOIN role_parents rp ON rp.role_id = l.ancestor_id
+		)
+		SELECT r.name, a.name
+		FROM lineage l
+		JOIN roles r ON r.id = l.role_id
+		JOIN roles a ON a.id = l.ancestor_id
+		WHERE l.ancestor_id <> l.role_id AND l.ancestor_id = ANY($1)
+		LIMIT 1
+	`, pq.Array(ids)).Scan(&role, &ancestor)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %s inherits %s", ErrInvalidConstraint, role, ancestor)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO sod_constraints (name, description, created_by)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, 0))
+		RETURNING id
+	`, name, description, createdBy).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sod_constraint_roles (constraint_id, role_id)
+		SELECT $1, unnest($2::INTEGER[])
+	`, id, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, id)
+}
+
+// Delete removes a constraint
+func (s *SoDStore) Delete(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sod_constraints WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Violations lists users currently holding more than one role of a
+// constraint, such as assignments that predate it
+func (s *SoDStore) Violations(ctx context.Context) ([]SoDViolation, error) {
+	violations, err := sodViolations(ctx, s.db, nil)
+	if violations == nil {
+		violations = []SoDViolation{}
+	}
+	return violations, err
+}
+
+// sodViolations lists constraint violations, limited to userIDs when non-nil
+func sodViolations(ctx context.Context, q Querier, userIDs []int) ([]SoDViolation, error) {
+	var filter pq.Int64Array
+	if userIDs != nil {
+		filter = make(pq.Int64Array, len(userIDs))
+		for i, id := range userIDs {
+			filter[i] = int64(id)
+		}
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		WITH RECURSIVE `+heldRolesCTE+`
+		SELECT c.id, c.name, u.id, u.username, array_agg(r.name ORDER BY r.name)
+		FROM sod_constraints c
+		JOIN sod_constraint_roles cr ON cr.constraint_id = c.id
+		JOIN held h ON h.role_id = cr.role_id
+		JOIN users u ON u.id = h.user_id
+		JOIN roles r ON r.id = cr.role_id
+		WHERE u.erased_at IS NULL
+		GROUP BY c.id, c.name, u.id, u.username
+		HAVING COUNT(*) > 1
+		ORDER BY c.name, u.username
+	`, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var violations []SoDViolation
+	for rows.Next() {
+		var v SoDViolation
+		if err := rows.Scan(&v.ConstraintID, &v.Constraint, &v.UserID, &v.Username, pq.Array(&v.Roles)); err != nil {
+			return nil, err
+		}
+		violations = append(violations, v)
+	}
+
+	return violations, rows.Err()
+}
+
+// enforceSoD runs change and returns a *SoDViolationError if it gave any of
+// userIDs (every user when nil) a combination of roles a constraint forbids.
+// Violations a user already had, such as ones predating the constraint, are
+// tolerated unless the change adds to them. The caller must hold the
+// safeguard lock and roll back tx on error.
+func enforceSoD(ctx context.Context, tx *sql.Tx, userIDs []int, change func() error) error {
+	before, err := sodViolations(ctx, tx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	after, err := sodViolations(ctx, tx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	type key struct{ constraintID, userID int }
+	held := make(map[key]int, len(before))
+	for _, v := range before {
+		held[key{v.ConstraintID, v.UserID}] = len(v.Roles)
+	}
+	for _, v := range after {
+		if n, ok := held[key{v.ConstraintID, v.UserID}]; !ok || len(v.Roles) > n {
+			return &SoDViolationError{SoDViolation: v}
+		}
+	}
+	return nil
+}
@@ -3,42 +3,147 @@ package rbac
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrEmailNotVerified is returned when assigning a role beyond viewer to a user
+// whose email is unverified while verification is required
+var ErrEmailNotVerified = errors.New("email address must be verified before assigning this role")
+
+// Permission sources
+const (
+	SourceDirect = "direct" // Role assigned to the user
+	SourceGroup  = "group"  // Role assigned to a group the user belongs to
 )
 
+// assignedRolesCTE selects the roles user $1 currently holds, directly or
+// through group membership, expanded with every ancestor role they inherit.
+// resource_type/resource_id carry the assignment's scope (NULL = global) and
+// assigned_at/assigned_by/expires_at/conditions the assignment it came from.
+// via_role_id is the assigned role an inherited row was reached from. It must
+// follow WITH RECURSIVE; UNION (not UNION ALL) makes the expansion terminate
+// even if the hierarchy somehow contains a cycle.
+const assignedRolesCTE = `
+	granted AS (
+		SELECT ur.role_id, 'direct' AS source, NULL::INTEGER AS group_id, NULL::VARCHAR AS group_name,
+		       ur.resource_type, ur.resource_id, ur.assigned_at, ur.assigned_by, ur.expires_at, ur.conditions
+		FROM user_roles ur
+		WHERE ur.user_id = $1
+		  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		UNION ALL
+		SELECT gr.role_id, 'group', g.id, g.name, NULL, NULL, gr.assigned_at, gr.assigned_by, gr.expires_at, NULL
+		FROM group_roles gr
+		JOIN group_members gm ON gm.group_id = gr.group_id
+		JOIN groups g ON g.id = gr.group_id
+		WHERE gm.user_id = $1
+		  AND (gr.expires_at IS NULL OR gr.expires_at > NOW())
+	),
+	assigned AS (
+		SELECT role_id, source, group_id, group_name, resource_type, resource_id,
+		       assigned_at, assigned_by, expires_at, conditions, role_id AS via_role_id
+		FROM granted
+		UNION
+		SELECT rp.parent_role_id, a.source, a.group_id, a.group_name, a.resource_type, a.resource_id,
+		       a.assigned_at, a.assigned_by, a.expires_at, a.conditions, a.via_role_id
+		FROM assigned a
+		JOIN role_parents rp ON rp.role_id = a.role_id
+	)`
+
+// Scope limits a role assignment to resources of one type whose ID matches a
+// glob (path.Match syntax, e.g. "payments-*")
+type Scope struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+}
+
+// Matches reports whether the scope covers the given resource
+func (s Scope) Matches(resourceType, resourceID string) bool {
+	if s.ResourceType != resourceType {
+		return false
+	}
+	ok, err := path.Match(s.ResourceID, resourceID)
+	return err == nil && ok
+}
+
 // UserRole represents a user's role assignment
 type UserRole struct {
-	ID         int        `db:"id" json:"id"`
-	UserID     int        `db:"user_id" json:"user_id"`
-	RoleID     int        `db:"role_id" json:"role_id"`
-	RoleName   string     `db:"role_name" json:"role_name"`
-	AssignedAt time.Time  `db:"assigned_at" json:"assigned_at"`
-	AssignedBy *int       `db:"assigned_by" json:"assigned_by,omitempty"`
-	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	ID           int        `db:"id" json:"id"`
+	UserID       int        `db:"user_id" json:"user_id"`
+	RoleID       int        `db:"role_id" json:"role_id"`
+	RoleName     string     `db:"role_name" json:"role_name"`
+	AssignedAt   time.Time  `db:"assigned_at" json:"assigned_at"`
+	AssignedBy   *int       `db:"assigned_by" json:"assigned_by,omitempty"`
+	ExpiresAt    *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	ResourceType *string    `db:"resource_type" json:"resource_type,omitempty"` // NULL = global
+	ResourceID   *string    `db:"resource_id" json:"resource_id,omitempty"`
+	// Conditions restrict the requests the assignment applies to (nil = always)
+	Conditions *Conditions `db:"conditions" json:"conditions,omitempty"`
+}
+
+// PermissionSource records one way a user holds a permission
+type PermissionSource struct {
+	Permission   string  `json:"permission"`
+	Role         string  `json:"role"`
+	Source       string  `json:"source"` // direct | group
+	GroupID      *int    `json:"group_id,omitempty"`
+	Group        *string `json:"group,omitempty"`
+	InheritedVia *string `json:"inherited_via,omitempty"` // Assigned role that inherits Role
+	ResourceType *string `json:"resource_type,omitempty"` // Set for scoped grants
+	ResourceID   *string `json:"resource_id,omitempty"`
 }
 
-// UserPermissions aggregates a user's roles and permissions
+// UserPermissions aggregates a user's roles and permissions (direct and group-derived).
+// Roles and Permissions are global; scoped grants only appear in Sources.
 type UserPermissions struct {
-	UserID      int      `json:"user_id"`
-	Roles       []string `json:"roles"`
-	Permissions []string `json:"permissions"`
+	UserID      int                `json:"user_id"`
+	Roles       []string           `json:"roles"`
+	Permissions []string           `json:"permissions"`
+	Sources     []PermissionSource `json:"sources"`
+	Rules       []PolicyRule       `json:"rules,omitempty"` // Wildcard and deny rules
+}
+
+// RoleMember is a user holding a role
+type RoleMember struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
 }
 
 // UserRoleStore handles database operations for user-role mappings
 type UserRoleStore struct {
-	db *sql.DB
+	db                   *sql.DB
+	requireVerifiedEmail bool
+	cache                PolicyCache
+	invalidator          Invalidator
 }
 
 // NewUserRoleStore creates a new user role store
 func NewUserRoleStore(db *sql.DB) *UserRoleStore {
-	return &UserRoleStore{db: db}
+	return &UserRoleStore{db: db, invalidator: nopInvalidator{}}
+}
+
+// SetCache caches LoadPolicy results and invalidates them on role assignment changes
+func (s *UserRoleStore) SetCache(cache PolicyCache) {
+	s.cache = cache
+	s.invalidator = cache
+}
+
+// SetRequireVerifiedEmail controls whether AssignRole refuses roles beyond
+// viewer for users without a verified email address
+func (s *UserRoleStore) SetRequireVerifiedEmail(required bool) {
+	s.requireVerifiedEmail = required
 }
 
 // GetUserRoles retrieves all roles for a user
 func (s *UserRoleStore) GetUserRoles(ctx context.Context, userID int) ([]UserRole, error) {
 	query := `
 		SELECT ur.id, ur.user_id, ur.role_id, r.name as role_name,
-		       ur.assigned_at, ur.assigned_by, ur.expires_at
+		       ur.assigned_at, ur.assigned_by, ur.expires_at, ur.resource_type, ur.resource_id, ur.conditions
 		FROM user_roles ur
 		JOIN roles r ON ur.role_id = r.id
 		WHERE ur.user_id = $1
@@ -50,29 +155,67 @@ func (s *UserRoleStore) GetUserRoles(ctx context.Context, userID int) ([]UserRol
 	if err != nil {
 		return nil, err
 	}
+	return scanUserRoles(rows)
+}
+
+// GetUsersRoles retrieves the roles of several users in one query, keyed by user ID
+func (s *UserRoleStore) GetUsersRoles(ctx context.Context, userIDs []int) (map[int][]UserRole, error) {
+	query := `
+		SELECT ur.id, ur.user_id, ur.role_id, r.name as role_name,
+		       ur.assigned_at, ur.assigned_by, ur.expires_at, ur.resource_type, ur.resource_id, ur.conditions
+		FROM user_roles ur
+		JOIN roles r ON ur.role_id = r.id
+		WHERE ur.user_id = ANY($1)
+		  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		ORDER BY ur.assigned_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	userRoles, err := scanUserRoles(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[int][]UserRole, len(userIDs))
+	for _, ur := range userRoles {
+		byUser[ur.UserID] = append(byUser[ur.UserID], ur)
+	}
+	return byUser, nil
+}
+
+func scanUserRoles(rows *sql.Rows) ([]UserRole, error) {
 	defer rows.Close()
 
 	var userRoles []UserRole
 	for rows.Next() {
 		var ur UserRole
-		if err := rows.Scan(&ur.ID, &ur.UserID, &ur.RoleID, &ur.RoleName, &ur.AssignedAt, &ur.AssignedBy, &ur.ExpiresAt); err != nil {
+		var conditions []byte
+		var err error
+		if err = rows.Scan(&ur.ID, &ur.UserID, &ur.RoleID, &ur.RoleName, &ur.AssignedAt, &ur.AssignedBy, &ur.ExpiresAt, &ur.ResourceType, &ur.ResourceID, &conditions); err != nil {
+			return nil, err
+		}
+		if ur.Conditions, err = scanConditions(conditions); err != nil {
 			return nil, err
 		}
 		userRoles = append(userRoles, ur)
 	}
 
-	return userRoles, nil
+	return userRoles, rows.Err()
 }
 
-// GetUserPermissions retrieves all effective permissions for a user (aggregated from all roles)
+// GetUserPermissions retrieves all effective permissions for a user
+// (aggregated from direct, group-derived and inherited roles, with the source of each)
 func (s *UserRoleStore) GetUserPermissions(ctx context.Context, userID int) (*UserPermissions, error) {
 	// Get user's roles
 	rolesQuery := `
+		WITH RECURSIVE ` + assignedRolesCTE + `
 		SELECT DISTINCT r.name
-		FROM roles r
-		JOIN user_roles ur ON r.id = ur.role_id
-		WHERE ur.user_id = $1
-		  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		FROM assigned a
+		JOIN roles r ON r.id = a.role_id
+		WHERE a.resource_type IS NULL
 		ORDER BY r.name
 	`
 
@@ -91,15 +234,18 @@ func (s *UserRoleStore) GetUserPermissions(ctx context.Context, userID int) (*Us
 		roles = append(roles, roleName)
 	}
 
-	// Get user's permissions (aggregated from all roles)
+	// Get user's permissions with the role and source granting each
 	permsQuery := `
-		SELECT DISTINCT p.name
-		FROM permissions p
-		JOIN role_permissions rp ON p.id = rp.permission_id
-		JOIN user_roles ur ON rp.role_id = ur.role_id
-		WHERE ur.user_id = $1
-		  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
-		ORDER BY p.name
+		WITH RECURSIVE ` + assignedRolesCTE + `
+		SELECT DISTINCT p.name, r.name, a.source, a.group_id, a.group_name,
+		       CASE WHEN a.via_role_id <> a.role_id THEN via.name END,
+		       a.resource_type, a.resource_id
+		FROM assigned a
+		JOIN roles r ON r.id = a.role_id
+		JOIN roles via ON via.id = a.via_role_id
+		JOIN role_permissions rp ON rp.role_id = a.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		ORDER BY p.name, r.name, a.source, a.resource_type, a.resource_id
 	`
 
 	permRows, err := s.db.QueryContext(ctx, permsQuery, userID)
@@ -108,91 +254,350 @@ func (s *UserRoleStore) GetUserPermissions(ctx context.Context, userID int) (*Us
 	}
 	defer permRows.Close()
 
-	var permissions []string
+	var sources []PermissionSource
 	for permRows.Next() {
-		var permName string
-		if err := permRows.Scan(&permName); err != nil {
+		var src PermissionSource
+		if err := permRows.Scan(&src.Permission, &src.Role, &src.Source, &src.GroupID, &src.Group, &src.InheritedVia, &src.ResourceType, &src.ResourceID); err != nil {
 			return nil, err
 		}
-		permissions = append(permissions, permName)
+		sources = append(sources, src)
+	}
+
+	// Effective permissions come from the evaluator so wildcard and deny rules apply
+	policy, err := s.LoadPolicy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogRows, err := s.db.QueryContext(ctx, `SELECT name FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer catalogRows.Close()
+
+	var permissions []string
+	for catalogRows.Next() {
+		var name string
+		if err := catalogRows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if policy.Allows(name) {
+			permissions = append(permissions, name)
+		}
+	}
+
+	// Surface pattern, deny and conditional rules; exact unconditional allows
+	// are already listed in Sources
+	var rules []PolicyRule
+	for _, rule := range policy.Rules {
+		if rule.Effect == EffectDeny || strings.Contains(rule.Pattern, "*") || len(rule.Conditions) > 0 {
+			rules = append(rules, rule)
+		}
 	}
 
 	return &UserPermissions{
 		UserID:      userID,
 		Roles:       roles,
 		Permissions: permissions,
+		Sources:     sources,
+		Rules:       rules,
 	}, nil
 }
 
-// AssignRole assigns a role to a user
+// GetRoleMembers retrieves all users currently holding a role globally
+func (s *UserRoleStore) GetRoleMembers(ctx context.Context, roleID int) ([]RoleMember, error) {
+	query := `
+		SELECT u.id, u.username
+		FROM user_roles ur
+		JOIN users u ON ur.user_id = u.id
+		WHERE ur.role_id = $1 AND ur.resource_type IS NULL
+		  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		ORDER BY u.username
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []RoleMember
+	for rows.Next() {
+		var m RoleMember
+		if err := rows.Scan(&m.UserID, &m.Username); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// GetRolesMembers retrieves the global members of several roles in one
+// query, keyed by role ID
+func (s *UserRoleStore) GetRolesMembers(ctx context.Context, roleIDs []int) (map[int][]RoleMember, error) {
+	query := `
+		SELECT ur.role_id, u.id, u.username
+		FROM user_roles ur
+		JOIN users u ON ur.user_id = u.id
+		WHERE ur.role_id = ANY($1) AND ur.resource_type IS NULL
+		  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		ORDER BY u.username
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(roleIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byRole := make(map[int][]RoleMember, len(roleIDs))
+	for rows.Next() {
+		var roleID int
+		var m RoleMember
+		if err := rows.Scan(&roleID, &m.UserID, &m.Username); err != nil {
+			return nil, err
+		}
+		byRole[roleID] = append(byRole[roleID], m)
+	}
+
+	return byRole, rows.Err()
+}
+
+// AssignRole assigns a role to a user globally
+// An assignedBy of 0 records a system assignment (e.g. SCIM provisioning)
 func (s *UserRoleStore) AssignRole(ctx context.Context, userID, roleID, assignedBy int, expiresAt *time.Time) error {
+	return s.AssignScopedRole(ctx, userID, roleID, assignedBy, nil, expiresAt, nil)
+}
+
+// AssignScopedRole assigns a role to a user, limited to scope when non-nil
+// and to requests meeting conditions when they are non-empty, and audits it
+// Returns a *SoDViolationError if the user would hold mutually exclusive roles
+func (s *UserRoleStore) AssignScopedRole(ctx context.Context, userID, roleID, assignedBy int, scope *Scope, expiresAt *time.Time, conditions *Conditions) error {
+	conditionsJSON, err := conditionsValue(conditions)
+	if err != nil {
+		return err
+	}
+
+	if s.requireVerifiedEmail {
+		var roleName string
+		var verified bool
+		err := s.db.QueryRowContext(ctx, `
+			SELECT r.name, u.email_verified_at IS NOT NULL
+			FROM roles r, users u
+			WHERE r.id = $1 AND u.id = $2
+		`, roleID, userID).Scan(&roleName, &verified)
+		if err != nil {
+			return err
+		}
+		if roleName != "viewer" && !verified {
+			return ErrEmailNotVerified
+		}
+	}
+
+	var resourceType, resourceID *string
+	if scope != nil {
+		resourceType, resourceID = &scope.ResourceType, &scope.ResourceID
+	}
+
 	query := `
-		INSERT INTO user_roles (user_id, role_id, assigned_by, expires_at)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (user_id, role_id) DO UPDATE
-		SET expires_at = EXCLUDED.expires_at
+		INSERT INTO user_roles (user_id, role_id, assigned_by, expires_at, resource_type, resource_id, conditions)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7)
+		ON CONFLICT (user_id, role_id, (COALESCE(resource_type, '')), (COALESCE(resource_id, ''))) DO UPDATE
+		SET expires_at = EXCLUDED.expires_at, conditions = EXCLUDED.conditions, expiry_warned_days = NULL
 	`
 
-	_, err := s.db.ExecContext(ctx, query, userID, roleID, assignedBy, expiresAt)
-	return err
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := LockSafeguards(ctx, tx); err != nil {
+		return err
+	}
+
+	err = enforceSoD(ctx, tx, []int{userID}, func() error {
+		_, err := tx.ExecContext(ctx, query, userID, roleID, assignedBy, expiresAt, resourceType, resourceID, conditionsJSON)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := RecordAudit(ctx, tx, AuditEntry{
+		UserID:    &userID,
+		RoleID:    &roleID,
+		Action:    AuditRoleGranted,
+		ChangedBy: changedByRef(assignedBy),
+		Metadata:  assignmentMetadata(scope, expiresAt),
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateUser(ctx, userID)
+	return nil
 }
 
-// RemoveRole removes a role from a user
-func (s *UserRoleStore) RemoveRole(ctx context.Context, userID, roleID int) error {
-	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`
+// RemoveRole removes a user's global assignment of a role, and audits it.
+// A removedBy of 0 records a system removal.
+// Returns ErrLastAdmin if it would leave no active admin.
+func (s *UserRoleStore) RemoveRole(ctx context.Context, userID, roleID, removedBy int) error {
+	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2 AND resource_type IS NULL`
+
+	audit := &AuditEntry{UserID: &userID, RoleID: &roleID, Action: AuditRoleRevoked, ChangedBy: changedByRef(removedBy)}
+	if _, err := execSafeguarded(ctx, s.db, false, audit, query, userID, roleID); err != nil {
+		return err
+	}
 
-	_, err := s.db.ExecContext(ctx, query, userID, roleID)
-	return err
+	s.invalidator.InvalidateUser(ctx, userID)
+	return nil
 }
 
-// CheckPermission checks if a user has a specific permission
-func (s *UserRoleStore) CheckPermission(ctx context.Context, userID int, permission string) (bool, error) {
+// RemoveScopedRole removes a user's assignment of a role for exactly one
+// scope, and audits it
+func (s *UserRoleStore) RemoveScopedRole(ctx context.Context, userID, roleID int, scope Scope, removedBy int) error {
 	query := `
-		SELECT EXISTS(
-			SELECT 1
-			FROM permissions p
-			JOIN role_permissions rp ON p.id = rp.permission_id
-			JOIN user_roles ur ON rp.role_id = ur.role_id
-			WHERE ur.user_id = $1
-			  AND p.name = $2
-			  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
-		)
+		DELETE FROM user_roles
+		WHERE user_id = $1 AND role_id = $2 AND resource_type = $3 AND resource_id = $4
 	`
 
-	var exists bool
-	err := s.db.QueryRowContext(ctx, query, userID, permission).Scan(&exists)
-	return exists, err
+	audit := &AuditEntry{
+		UserID: &userID, RoleID: &roleID, Action: AuditRoleRevoked, ChangedBy: changedByRef(removedBy),
+		Metadata: assignmentMetadata(&scope, nil),
+	}
+	if _, err := execSafeguarded(ctx, s.db, false, audit, query, userID, roleID, scope.ResourceType, scope.ResourceID); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateUser(ctx, userID)
+	return nil
 }
 
-// CheckAnyPermission checks if a user has any of the specified permissions
-func (s *UserRoleStore) CheckAnyPermission(ctx context.Context, userID int, permissions []string) (bool, error) {
+// assignmentMetadata describes an assignment's scope and expiry for the audit
+// log, or nothing for a permanent global one
+func assignmentMetadata(scope *Scope, expiresAt *time.Time) json.RawMessage {
+	fields := map[string]interface{}{}
+	if scope != nil {
+		fields["resource_type"], fields["resource_id"] = scope.ResourceType, scope.ResourceID
+	}
+	if expiresAt != nil {
+		fields["expires_at"] = expiresAt
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(fields)
+	return data
+}
+
+// LoadPolicy resolves everything needed to decide a user's permissions: the
+// global admin flag plus every allow/deny rule from the user's direct, group
+// and inherited roles (exact role permissions become allow rules).
+// Served from the policy cache when one is configured.
+func (s *UserRoleStore) LoadPolicy(ctx context.Context, userID int) (*Policy, error) {
+	if s.cache != nil {
+		return s.cache.GetPolicy(ctx, userID, func(ctx context.Context) (*Policy, error) {
+			return s.loadPolicy(ctx, userID)
+		})
+	}
+	return s.loadPolicy(ctx, userID)
+}
+
+func (s *UserRoleStore) loadPolicy(ctx context.Context, userID int) (*Policy, error) {
+	isAdmin, err := s.IsAdmin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT EXISTS(
-			SELECT 1
-			FROM permissions p
-			JOIN role_permissions rp ON p.id = rp.permission_id
-			JOIN user_roles ur ON rp.role_id = ur.role_id
-			WHERE ur.user_id = $1
-			  AND p.name = ANY($2)
-			  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
-		)
+		WITH RECURSIVE ` + assignedRolesCTE + `
+		SELECT p.name, 'allow', r.name, a.resource_type, a.resource_id, a.conditions, rp.conditions
+		FROM assigned a
+		JOIN roles r ON r.id = a.role_id
+		JOIN role_permissions rp ON rp.role_id = a.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		UNION
+		SELECT rr.pattern, rr.effect, r.name, a.resource_type, a.resource_id, a.conditions, NULL::JSONB
+		FROM assigned a
+		JOIN roles r ON r.id = a.role_id
+		JOIN role_permission_rules rr ON rr.role_id = a.role_id
 	`
 
-	var exists bool
-	err := s.db.QueryRowContext(ctx, query, userID, permissions).Scan(&exists)
-	return exists, err
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policy := &Policy{UserID: userID, Admin: isAdmin}
+
+	// The policy changes as soon as any of its assignments lapses
+	err = s.db.QueryRowContext(ctx, `
+		WITH RECURSIVE `+assignedRolesCTE+`
+		SELECT MIN(expires_at) FROM granted
+	`, userID).Scan(&policy.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var rule PolicyRule
+		var scopeType, scopeID sql.NullString
+		var assignmentConditions, grantConditions []byte
+		if err := rows.Scan(&rule.Pattern, &rule.Effect, &rule.Role, &scopeType, &scopeID, &assignmentConditions, &grantConditions); err != nil {
+			return nil, err
+		}
+		if scopeType.Valid {
+			rule.Scope = &Scope{ResourceType: scopeType.String, ResourceID: scopeID.String}
+		}
+		for _, data := range [][]byte{assignmentConditions, grantConditions} {
+			conditions, err := scanConditions(data)
+			if err != nil {
+				return nil, err
+			}
+			if conditions != nil {
+				rule.Conditions = append(rule.Conditions, *conditions)
+			}
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+
+	return policy, rows.Err()
+}
+
+// CheckPermission checks if a user has a specific permission globally
+func (s *UserRoleStore) CheckPermission(ctx context.Context, userID int, permission string) (bool, error) {
+	policy, err := s.LoadPolicy(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return policy.Allows(permission), nil
 }
 
-// IsAdmin checks if a user has the admin role
+// CheckAnyPermission checks if a user has any of the specified permissions globally
+func (s *UserRoleStore) CheckAnyPermission(ctx context.Context, userID int, permissions []string) (bool, error) {
+	policy, err := s.LoadPolicy(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return policy.AllowsAny(permissions), nil
+}
+
+// IsAdmin checks if a user holds the admin role globally (direct or via a group)
+// and unconditionally; a conditional admin assignment only grants its "*" rule
 func (s *UserRoleStore) IsAdmin(ctx context.Context, userID int) (bool, error) {
 	query := `
+		WITH RECURSIVE ` + assignedRolesCTE + `
 		SELECT EXISTS(
 			SELECT 1
-			FROM roles r
-			JOIN user_roles ur ON r.id = ur.role_id
-			WHERE ur.user_id = $1
-			  AND r.name = 'admin'
-			  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+			FROM assigned a
+			JOIN roles r ON r.id = a.role_id
+			WHERE r.name = 'admin' AND a.resource_type IS NULL AND a.conditions IS NULL
 		)
 	`
 
@@ -200,3 +605,13 @@ func (s *UserRoleStore) IsAdmin(ctx context.Context, userID int) (bool, error) {
 	err := s.db.QueryRowContext(ctx, query, userID).Scan(&isAdmin)
 	return isAdmin, err
 }
+
+// CheckScopedPermission checks if a user has a permission on a specific
+// resource, either globally or through a scoped grant whose glob matches it
+func (s *UserRoleStore) CheckScopedPermission(ctx context.Context, userID int, permission, resourceType, resourceID string) (bool, error) {
+	policy, err := s.LoadPolicy(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return policy.Decide(permission, &Resource{Type: resourceType, ID: resourceID}).Allowed, nil
+}
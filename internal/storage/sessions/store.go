@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -13,6 +15,9 @@ import (
 const (
 	SessionDuration = 24 * time.Hour
 	SessionPrefix   = "session:"
+	// SessionMetaPrefix keys a hash of session attributes (created_at)
+	// kept alongside the session key with the same TTL
+	SessionMetaPrefix = "session_meta:"
 )
 
 // Session represents an authenticated session
@@ -53,7 +58,13 @@ func (s *Store) Create(ctx context.Context, userID int) (*Session, error) {
 
 	// Store in Redis with TTL
 	key := SessionPrefix + sessionID
-	err = s.redis.Set(ctx, key, userID, SessionDuration).Err()
+	metaKey := SessionMetaPrefix + sessionID
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, userID, SessionDuration)
+		pipe.HSet(ctx, metaKey, "created_at", now.Unix())
+		pipe.Expire(ctx, metaKey, SessionDuration)
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("store session: %w", err)
 	}
@@ -80,6 +91,11 @@ func (s *Store) Get(ctx context.Context, sessionID string) (*Session, error) {
 		return nil, fmt.Errorf("get session TTL: %w", err)
 	}
 
+	meta, err := s.redis.HGetAll(ctx, SessionMetaPrefix+sessionID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get session metadata: %w", err)
+	}
+
 	now := time.Now()
 	session := &Session{
 		SessionID:      sessionID,
@@ -88,6 +104,9 @@ func (s *Store) Get(ctx context.Context, sessionID string) (*Session, error) {
 		ExpiresAt:      now.Add(ttl),
 		LastActivityAt: now,
 	}
+	if createdAt, err := strconv.ParseInt(meta["created_at"], 10, 64); err == nil {
+		session.CreatedAt = time.Unix(createdAt, 0)
+	}
 
 	return session, nil
 }
@@ -97,7 +116,11 @@ func (s *Store) UpdateActivity(ctx context.Context, sessionID string) error {
 	key := SessionPrefix + sessionID
 
 	// Refresh TTL
-	err := s.redis.Expire(ctx, key, SessionDuration).Err()
+	_, err := s.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Expire(ctx, key, SessionDuration)
+		pipe.Expire(ctx, SessionMetaPrefix+sessionID, SessionDuration)
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("update session activity: %w", err)
 	}
@@ -109,7 +132,7 @@ func (s *Store) UpdateActivity(ctx context.Context, sessionID string) error {
 func (s *Store) Delete(ctx context.Context, sessionID string) error {
 	key := SessionPrefix + sessionID
 
-	err := s.redis.Del(ctx, key).Err()
+	err := s.redis.Del(ctx, key, SessionMetaPrefix+sessionID).Err()
 	if err != nil {
 		return fmt.Errorf("delete session: %w", err)
 	}
@@ -129,7 +152,7 @@ func (s *Store) DeleteAllUserSessions(ctx context.Context, userID int) error {
 		// Check if this session belongs to the user
 		id, err := s.redis.Get(ctx, key).Int()
 		if err == nil && id == userID {
-			s.redis.Del(ctx, key)
+			s.redis.Del(ctx, key, SessionMetaPrefix+strings.TrimPrefix(key, SessionPrefix))
 			deleted++
 		}
 	}
@@ -0,0 +1,206 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v3"
+)
+
+// Bundle format identification. Bump BundleVersion when the bundle's own
+// shape changes; configuration changes are covered by each template's
+// schema_version.
+const (
+	BundleFormat  = "inflight.quick-templates"
+	BundleVersion = 1
+)
+
+// maxNameLength is the length of quick_templates.name
+const maxNameLength = 255
+
+// ErrInvalidBundle is returned for a bundle that can't be read at all
+var ErrInvalidBundle = errors.New("invalid template bundle")
+
+// Bundle is a portable set of templates for moving them between
+// environments. It carries no database IDs: imported templates belong to the
+// importing user and are matched to existing ones by name.
+type Bundle struct {
+	Format     string           `yaml:"format" json:"format"`
+	Version    int              `yaml:"version" json:"version"`
+	ExportedAt time.Time        `yaml:"exported_at" json:"exported_at"`
+	ExportedBy string           `yaml:"exported_by,omitempty" json:"exported_by,omitempty"` // Username
+	Templates  []BundleTemplate `yaml:"templates" json:"templates"`
+}
+
+// BundleTemplate is a template as exported. Owner, revision and timestamps
+// describe the source and are ignored on import.
+type BundleTemplate struct {
+	Name              string      `yaml:"name" json:"name"`
+	Description       string      `yaml:"description" json:"description"`
+	Tags              []string    `yaml:"tags" json:"tags"`
+	IsShared          bool        `yaml:"is_shared" json:"is_shared"`
+	SchemaVersion     int         `yaml:"schema_version" json:"schema_version"`
+	ConfigurationData interface{} `yaml:"configuration_data" json:"configuration_data"` // Includes the parameters declaration
+	Owner             string      `yaml:"owner,omitempty" json:"owner,omitempty"`       // Username
+	Revision          int         `yaml:"revision,omitempty" json:"revision,omitempty"`
+	CreatedAt         *time.Time  `yaml:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt         *time.Time  `yaml:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// ParseBundle decodes and checks a YAML or JSON bundle. Unknown fields are
+// rejected so that typos don't silently drop part of a template.
+func ParseBundle(data []byte) (*Bundle, error) {
+	var b Bundle
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&b); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: bundle is empty", ErrInvalidBundle)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	if b.Format != BundleFormat {
+		return nil, fmt.Errorf("%w: format must be %q", ErrInvalidBundle, BundleFormat)
+	}
+	if b.Version < 1 || b.Version > BundleVersion {
+		return nil, fmt.Errorf("%w: unsupported bundle version %d", ErrInvalidBundle, b.Version)
+	}
+	if len(b.Templates) == 0 {
+		return nil, fmt.Errorf("%w: no templates", ErrInvalidBundle)
+	}
+
+	for i := range b.Templates {
+		t := &b.Templates[i]
+		t.Name = strings.TrimSpace(t.Name)
+		if t.Name == "" {
+			return nil, fmt.Errorf("%w: template %d has no name", ErrInvalidBundle, i)
+		}
+		if utf8.RuneCountInString(t.Name) > maxNameLength {
+			return nil, fmt.Errorf("%w: template %q: name is longer than %d characters", ErrInvalidBundle, t.Name, maxNameLength)
+		}
+	}
+	return &b, nil
+}
+
+// EncodeYAML encodes the bundle as YAML
+func (b *Bundle) EncodeYAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(*b); err != nil {
+		return nil, fmt.Errorf("encode bundle: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encode bundle: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Export bundles templates the user can view, in the order of ids. Without
+// ids it bundles every template the user owns, by name. A missing or hidden
+// ID fails the whole export with ErrNotFound.
+func (s *Store) Export(ctx context.Context, userID int, ids []int) (*Bundle, error) {
+	query := `
+		SELECT ` + templateColumns + `, (SELECT username FROM users WHERE id = t.user_id)
+		FROM quick_templates t
+	`
+	var args []interface{}
+	if len(ids) > 0 {
+		query += `WHERE t.id = ANY($1) AND ` + canView("$2") + ` ORDER BY array_position($1, t.id)`
+		args = []interface{}{pq.Array(ids), userID}
+	} else {
+		query += `WHERE t.user_id = $1 ORDER BY lower(t.name), t.id`
+		args = []interface{}{userID}
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("export templates: %w", err)
+	}
+	defer rows.Close()
+
+	bundle := &Bundle{
+		Format:     BundleFormat,
+		Version:    BundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Templates:  []BundleTemplate{},
+	}
+	found := make(map[int]bool, len(ids))
+	for rows.Next() {
+		var t QuickTemplate
+		var owner sql.NullString
+		if err := rows.Scan(append(templateFields(&t), &owner)...); err != nil {
+			return nil, fmt.Errorf("scan template: %w", err)
+		}
+		found[t.ID] = true
+
+		var configuration interface{}
+		if len(t.ConfigurationData) > 0 {
+			if err := json.Unmarshal(t.ConfigurationData, &configuration); err != nil {
+				return nil, fmt.Errorf("export template %d: %w", t.ID, err)
+			}
+		}
+		tags := t.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		created := t.CreatedAt
+		bundle.Templates = append(bundle.Templates, BundleTemplate{
+			Name:              t.Name,
+			Description:       t.Description,
+			Tags:              tags,
+			IsShared:          t.IsShared,
+			SchemaVersion:     t.SchemaVersion,
+			ConfigurationData: configuration,
+			Owner:             owner.String,
+			Revision:          t.Revision,
+			CreatedAt:         &created,
+			UpdatedAt:         t.UpdatedAt,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("export templates: %w", err)
+	}
+
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+		}
+	}
+	return bundle, nil
+}
+
+// ownedNames maps the names of the user's own templates to their IDs,
+// locking the rows when the import will write
+func ownedNames(ctx context.Context, tx *sql.Tx, userID int, lock bool) (map[string][]int, error) {
+	query := `SELECT id, name FROM quick_templates WHERE user_id = $1 ORDER BY id`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list owned templates: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[string][]int)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("scan template: %w", err)
+		}
+		names[name] = append(names[name], id)
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,146 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
+)
+
+// changeKeyFields identify a proposed change across revisions, in order of
+// preference. Changes without any of them are matched by their full content.
+var changeKeyFields = []string{"id", "key", "parameter", "path", "name"}
+
+// ChangesDiff is the difference between the proposed_changes of two revisions
+type ChangesDiff struct {
+	From      int               `json:"from"`
+	To        int               `json:"to"`
+	Added     []json.RawMessage `json:"added"`
+	Removed   []json.RawMessage `json:"removed"`
+	Modified  []ModifiedChange  `json:"modified"`
+	Unchanged int               `json:"unchanged"`
+}
+
+// ModifiedChange is a proposed change present in both revisions with different fields
+type ModifiedChange struct {
+	Key    string        `json:"key"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is one field of a proposed change that differs between revisions.
+// A nil From or To means the field is absent on that side.
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from,omitempty"`
+	To    json.RawMessage `json:"to,omitempty"`
+}
+
+// DiffProposedChanges compares the proposed_changes arrays of two configurations
+func DiffProposedChanges(from, to json.RawMessage) (*ChangesDiff, error) {
+	a, err := proposedChanges(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := proposedChanges(to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ChangesDiff{Added: []json.RawMessage{}, Removed: []json.RawMessage{}, Modified: []ModifiedChange{}}
+
+	remaining := make(map[string][]json.RawMessage)
+	for _, change := range a {
+		key := changeKey(change)
+		remaining[key] = append(remaining[key], change)
+	}
+
+	for _, change := range b {
+		key := changeKey(change)
+		previous := remaining[key]
+		if len(previous) == 0 {
+			diff.Added = append(diff.Added, change)
+			continue
+		}
+		remaining[key] = previous[1:]
+
+		fields := fieldChanges(previous[0], change)
+		if len(fields) == 0 {
+			diff.Unchanged++
+		} else {
+			diff.Modified = append(diff.Modified, ModifiedChange{Key: key, Fields: fields})
+		}
+	}
+
+	// Report removals in their original order
+	for _, change := range a {
+		key := changeKey(change)
+		if left := remaining[key]; len(left) > 0 && bytes.Equal(left[0], change) {
+			diff.Removed = append(diff.Removed, change)
+			remaining[key] = left[1:]
+		}
+	}
+
+	return diff, nil
+}
+
+// proposedChanges extracts the proposed_changes array of a configuration
+func proposedChanges(configuration json.RawMessage) ([]json.RawMessage, error) {
+	if len(configuration) == 0 {
+		return nil, nil
+	}
+	var config struct {
+		ProposedChanges []json.RawMessage `json:"proposed_changes"`
+	}
+	if err := json.Unmarshal(configuration, &config); err != nil {
+		return nil, fmt.Errorf("parse configuration: %w", err)
+	}
+	return config.ProposedChanges, nil
+}
+
+// changeKey identifies a change by its first key field, or by its canonical content
+func changeKey(change json.RawMessage) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(change, &fields); err == nil {
+		for _, name := range changeKeyFields {
+			if value, ok := fields[name]; ok && !bytes.Equal(value, []byte("null")) {
+				return name + "=" + string(templateschema.Canonical(value))
+			}
+		}
+	}
+	return string(templateschema.Canonical(change))
+}
+
+// fieldChanges lists the top-level fields that differ between two changes
+func fieldChanges(from, to json.RawMessage) []FieldChange {
+	var a, b map[string]json.RawMessage
+	if json.Unmarshal(from, &a) != nil || json.Unmarshal(to, &b) != nil {
+		// Not objects: compare as a whole
+		if bytes.Equal(templateschema.Canonical(from), templateschema.Canonical(to)) {
+			return nil
+		}
+		return []FieldChange{{From: from, To: to}}
+	}
+
+	names := make(map[string]bool)
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []FieldChange
+	for _, name := range sorted {
+		if !bytes.Equal(templateschema.Canonical(a[name]), templateschema.Canonical(b[name])) {
+			changes = append(changes, FieldChange{Field: name, From: a[name], To: b[name]})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,158 @@
+package templates
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
+)
+
+func TestDiffProposedChanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  string
+		added     []string
+		removed   []string
+		modified  []string // "key: field,field"
+		unchanged int
+	}{
+		{
+			name:      "formatting and key order are not changes",
+			from:      `{"proposed_changes": [{"id": 1, "value": "a", "note": "x"}]}`,
+			to:        `{"proposed_changes":[{"note":"x","value":"a","id":1}]}`,
+			unchanged: 1,
+		},
+		{
+			name:      "added and removed by id",
+			from:      `{"proposed_changes": [{"id": 1}, {"id": 2}]}`,
+			to:        `{"proposed_changes": [{"id": 2}, {"id": 3}]}`,
+			added:     []string{`{"id": 3}`},
+			removed:   []string{`{"id": 1}`},
+			unchanged: 1,
+		},
+		{
+			name:     "modified fields are listed in order",
+			from:     `{"proposed_changes": [{"id": 1, "value": 2, "old": true}]}`,
+			to:       `{"proposed_changes": [{"id": 1, "value": 3, "new": true}]}`,
+			modified: []string{"id=1: new,old,value"},
+		},
+		{
+			name:     "key is used without an id",
+			from:     `{"proposed_changes": [{"key": "cpu", "limit": 1}]}`,
+			to:       `{"proposed_changes": [{"key": "cpu", "limit": 2}]}`,
+			modified: []string{`key="cpu": limit`},
+		},
+		{
+			name:     "a null id falls back to the next key field",
+			from:     `{"proposed_changes": [{"id": null, "path": "/a", "v": 1}]}`,
+			to:       `{"proposed_changes": [{"id": null, "path": "/a", "v": 2}]}`,
+			modified: []string{`path="/a": v`},
+		},
+		{
+			name:    "changes without key fields match by content",
+			from:    `{"proposed_changes": [{"v": 1}]}`,
+			to:      `{"proposed_changes": [{"v": 2}]}`,
+			added:   []string{`{"v": 2}`},
+			removed: []string{`{"v": 1}`},
+		},
+		{
+			name:      "duplicates pair up one to one",
+			from:      `{"proposed_changes": [{"v": 1}, {"v": 1}]}`,
+			to:        `{"proposed_changes": [{"v": 1}]}`,
+			removed:   []string{`{"v": 1}`},
+			unchanged: 1,
+		},
+		{
+			name:  "empty from adds everything",
+			from:  ``,
+			to:    `{"proposed_changes": [{"id": 1}]}`,
+			added: []string{`{"id": 1}`},
+		},
+		{
+			name:      "non-object changes compare whole",
+			from:      `{"proposed_changes": ["a"]}`,
+			to:        `{"proposed_changes": ["a"]}`,
+			unchanged: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := DiffProposedChanges(json.RawMessage(tt.from), json.RawMessage(tt.to))
+			if err != nil {
+				t.Fatalf("DiffProposedChanges: %v", err)
+			}
+
+			if got := canonicalAll(diff.Added); !reflect.DeepEqual(got, canonicalStrings(tt.added)) {
+				t.Errorf("added = %v, want %v", got, tt.added)
+			}
+			if got := canonicalAll(diff.Removed); !reflect.DeepEqual(got, canonicalStrings(tt.removed)) {
+				t.Errorf("removed = %v, want %v", got, tt.removed)
+			}
+
+			modified := []string{}
+			for _, m := range diff.Modified {
+				var fields []string
+				for _, f := range m.Fields {
+					fields = append(fields, f.Field)
+				}
+				modified = append(modified, m.Key+": "+strings.Join(fields, ","))
+			}
+			if tt.modified == nil {
+				tt.modified = []string{}
+			}
+			if !reflect.DeepEqual(modified, tt.modified) {
+				t.Errorf("modified = %v, want %v", modified, tt.modified)
+			}
+
+			if diff.Unchanged != tt.unchanged {
+				t.Errorf("unchanged = %d, want %d", diff.Unchanged, tt.unchanged)
+			}
+		})
+	}
+}
+
+func TestDiffProposedChangesFieldValues(t *testing.T) {
+	diff, err := DiffProposedChanges(
+		json.RawMessage(`{"proposed_changes": [{"id": 1, "gone": 1, "value": 2}]}`),
+		json.RawMessage(`{"proposed_changes": [{"id": 1, "value": 3}]}`),
+	)
+	if err != nil {
+		t.Fatalf("DiffProposedChanges: %v", err)
+	}
+
+	want := []FieldChange{
+		{Field: "gone", From: json.RawMessage(`1`)},
+		{Field: "value", From: json.RawMessage(`2`), To: json.RawMessage(`3`)},
+	}
+	if len(diff.Modified) != 1 || !reflect.DeepEqual(diff.Modified[0].Fields, want) {
+		t.Errorf("Modified = %+v, want one change with fields %+v", diff.Modified, want)
+	}
+}
+
+func TestDiffProposedChangesInvalid(t *testing.T) {
+	invalid := []string{`{`, `{"proposed_changes": {}}`, `[]`}
+	for _, config := range invalid {
+		if _, err := DiffProposedChanges(json.RawMessage(config), nil); err == nil {
+			t.Errorf("DiffProposedChanges(%s) = nil error, want a parse error", config)
+		}
+	}
+}
+
+func canonicalAll(values []json.RawMessage) []string {
+	out := []string{}
+	for _, v := range values {
+		out = append(out, string(templateschema.Canonical(v)))
+	}
+	return out
+}
+
+func canonicalStrings(values []string) []string {
+	out := []string{}
+	for _, v := range values {
+		out = append(out, string(templateschema.Canonical(json.RawMessage(v))))
+	}
+	return out
+}
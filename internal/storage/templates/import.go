@@ -0,0 +1,248 @@
+package templates
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/bwburch/inflight-ui-service/internal/templateparams"
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
+)
+
+// Conflict strategies for a bundled template named like one the importing
+// user already owns
+const (
+	ConflictSkip      = "skip"      // Keep the existing template; the default
+	ConflictOverwrite = "overwrite" // Replace its content, as a new revision
+	ConflictRename    = "rename"    // Import alongside it as "Name (2)"
+)
+
+// Import actions
+const (
+	ImportCreate    = "create"
+	ImportOverwrite = "overwrite"
+	ImportRename    = "rename"
+	ImportSkip      = "skip"
+)
+
+// ImportItem is what an import does, or would do, with one bundled template
+type ImportItem struct {
+	Name       string                      `json:"name"` // As in the bundle
+	Action     string                      `json:"action"`
+	ImportedAs string                      `json:"imported_as,omitempty"` // The new name when renamed
+	TemplateID int                         `json:"template_id,omitempty"` // The template created or overwritten (the overwrite target in a dry run)
+	Errors     []templateschema.FieldError `json:"errors,omitempty"`      // JSON Pointers into the bundle
+}
+
+// ImportResult reports an import. Nothing is written when any template is
+// invalid or in a dry run.
+type ImportResult struct {
+	DryRun      bool         `json:"dry_run"`
+	Conflict    string       `json:"conflict"`
+	Templates   []ImportItem `json:"templates"`
+	Created     int          `json:"created"` // Renamed imports included
+	Overwritten int          `json:"overwritten"`
+	Skipped     int          `json:"skipped"`
+	Invalid     int          `json:"invalid"`
+}
+
+// importedTemplate is a bundled template ready to save at the current schema
+type importedTemplate struct {
+	configuration json.RawMessage
+	tags          []string
+}
+
+// Import creates the bundle's templates for the user, in one transaction.
+// Bundled configurations are upgraded to the current schema and validated
+// like any other save. A template named like one the user owns, or like an
+// earlier one in the bundle, is handled by the conflict strategy (overwriting
+// with a repeated name is invalid); templates shared with the user don't conflict.
+func (s *Store) Import(ctx context.Context, bundle *Bundle, userID int, conflict string, dryRun bool) (*ImportResult, error) {
+	switch conflict {
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return nil, fmt.Errorf("%w: unknown conflict strategy %q", ErrInvalidBundle, conflict)
+	}
+
+	var opts *sql.TxOptions
+	if dryRun {
+		opts = &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead}
+	}
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("import templates: %w", err)
+	}
+	defer tx.Rollback()
+
+	owned, err := ownedNames(ctx, tx, userID, !dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names renamed imports must avoid: the user's and the bundle's
+	taken := make(map[string]bool, len(owned)+len(bundle.Templates))
+	for name := range owned {
+		taken[name] = true
+	}
+	for _, t := range bundle.Templates {
+		taken[t.Name] = true
+	}
+
+	result := &ImportResult{DryRun: dryRun, Conflict: conflict, Templates: make([]ImportItem, len(bundle.Templates))}
+	prepared := make([]importedTemplate, len(bundle.Templates))
+	seen := make(map[string]bool, len(bundle.Templates))
+	for i, t := range bundle.Templates {
+		path := "/templates/" + strconv.Itoa(i)
+		item := &result.Templates[i]
+		item.Name = t.Name
+		prepared[i], item.Errors = prepareImport(t, path)
+
+		repeated := seen[t.Name]
+		seen[t.Name] = true
+
+		existing := owned[t.Name]
+		switch {
+		case repeated && conflict == ConflictRename:
+			item.Action = ImportRename
+			item.ImportedAs = uniqueName(t.Name, taken)
+			taken[item.ImportedAs] = true
+		case repeated:
+			item.Action = ImportSkip
+			item.Errors = append(item.Errors, templateschema.FieldError{
+				Path:    path + "/name",
+				Message: "appears more than once in the bundle",
+			})
+		case len(existing) == 0:
+			item.Action = ImportCreate
+		case conflict == ConflictSkip:
+			item.Action = ImportSkip
+		case conflict == ConflictRename:
+			item.Action = ImportRename
+			item.ImportedAs = uniqueName(t.Name, taken)
+			taken[item.ImportedAs] = true
+		case len(existing) > 1:
+			item.Action = ImportOverwrite
+			item.Errors = append(item.Errors, templateschema.FieldError{
+				Path:    path + "/name",
+				Message: fmt.Sprintf("matches %d of your templates; rename or delete the duplicates first", len(existing)),
+			})
+		default:
+			item.Action = ImportOverwrite
+			item.TemplateID = existing[0]
+		}
+
+		switch {
+		case len(item.Errors) > 0:
+			result.Invalid++
+		case item.Action == ImportSkip:
+			result.Skipped++
+		case item.Action == ImportOverwrite:
+			result.Overwritten++
+		default:
+			result.Created++
+		}
+	}
+
+	if dryRun || result.Invalid > 0 {
+		return result, nil
+	}
+
+	for i, t := range bundle.Templates {
+		item := &result.Templates[i]
+		switch item.Action {
+		case ImportCreate, ImportRename:
+			name := t.Name
+			if item.ImportedAs != "" {
+				name = item.ImportedAs
+			}
+			created, err := insertTemplate(ctx, tx, CreateTemplateInput{
+				UserID:            userID,
+				Name:              name,
+				Description:       t.Description,
+				ConfigurationData: prepared[i].configuration,
+				SchemaVersion:     templateschema.CurrentVersion,
+				IsShared:          t.IsShared,
+			}, prepared[i].tags)
+			if err != nil {
+				return nil, fmt.Errorf("import %q: %w", t.Name, err)
+			}
+			item.TemplateID = created.ID
+		case ImportOverwrite:
+			_, err := updateTemplate(ctx, tx, item.TemplateID, userID, UpdateTemplateInput{
+				Name:              t.Name,
+				Description:       t.Description,
+				ConfigurationData: prepared[i].configuration,
+				SchemaVersion:     templateschema.CurrentVersion,
+				IsShared:          t.IsShared,
+			}, prepared[i].tags)
+			if err != nil {
+				return nil, fmt.Errorf("import %q: %w", t.Name, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("import templates: %w", err)
+	}
+	return result, nil
+}
+
+// prepareImport upgrades and validates a bundled template, reporting problems
+// at JSON Pointers under path
+func prepareImport(t BundleTemplate, path string) (importedTemplate, []templateschema.FieldError) {
+	var errs []templateschema.FieldError
+	fail := func(at, message string) {
+		errs = append(errs, templateschema.FieldError{Path: path + at, Message: message})
+	}
+
+	tags, err := NormalizeTags(t.Tags)
+	if err != nil {
+		fail("/tags", err.Error())
+	}
+
+	configuration, err := json.Marshal(t.ConfigurationData)
+	if err != nil {
+		fail("/configuration_data", "cannot be represented as JSON")
+		return importedTemplate{}, errs
+	}
+	configuration, err = templateschema.Upgrade(configuration, t.SchemaVersion)
+	if err != nil {
+		fail("/schema_version", err.Error())
+		return importedTemplate{}, errs
+	}
+
+	var invalid *templateschema.ValidationError
+	err = templateschema.Validate(configuration)
+	if err == nil {
+		err = templateparams.Check(configuration)
+	}
+	if errors.As(err, &invalid) {
+		for _, e := range invalid.Errors {
+			fail("/configuration_data"+e.Path, e.Message)
+		}
+	} else if err != nil {
+		fail("/configuration_data", err.Error())
+	}
+
+	return importedTemplate{configuration: configuration, tags: tags}, errs
+}
+
+// uniqueName appends the first free counter to name, as "Name (2)", keeping
+// within the column length
+func uniqueName(name string, taken map[string]bool) string {
+	for n := 2; ; n++ {
+		suffix := " (" + strconv.Itoa(n) + ")"
+		base := name
+		for utf8.RuneCountInString(base)+len(suffix) > maxNameLength {
+			_, size := utf8.DecodeLastRuneInString(base)
+			base = base[:len(base)-size]
+		}
+		if candidate := base + suffix; !taken[candidate] {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Sort orders for List
+const (
+	SortCreated   = "created_at"
+	SortUpdated   = "updated_at"
+	SortName      = "name"
+	SortRelevance = "relevance" // Only with a search query; the default then
+)
+
+// MaxTags is the most tags a template can carry
+const MaxTags = 20
+
+var (
+	// ErrInvalidListOptions is returned for contradictory or unknown list filters and sort orders
+	ErrInvalidListOptions = errors.New("invalid list options")
+	// ErrInvalidTags is returned for tags that can't be normalised
+	ErrInvalidTags = errors.New("invalid tags")
+)
+
+// tagPattern is a normalised tag: lowercase letters, digits, spaces and _.:/-,
+// starting with a letter or digit
+var tagPattern = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}][\p{Ll}\p{Lo}\p{N} _.:/-]{0,49}$`)
+
+// ListOptions filters, sorts and paginates the templates a user can view
+type ListOptions struct {
+	Query         string     // Full-text search over name and description (websearch syntax)
+	OwnerID       *int       // Only templates owned by this user
+	Mine          bool       // Only the user's own templates
+	SharedOnly    bool       // Only templates others share with the user or the org
+	Tags          []string   // Only templates carrying all of these tags
+	CreatedAfter  *time.Time // Inclusive
+	CreatedBefore *time.Time // Exclusive
+	UpdatedAfter  *time.Time // Last modification (creation if never updated), inclusive
+	UpdatedBefore *time.Time // Exclusive
+	Sort          string     // SortCreated (default), SortUpdated, SortName or SortRelevance
+	Ascending     bool
+	Limit         int // 0 for no limit
+	Offset        int
+}
+
+// TagCount is a tag and how many templates visible to the user carry it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// List returns the templates a user can view (their own, those shared with
+// them or their groups, and those public to the org) matching the options,
+// and how many match in total
+func (s *Store) List(ctx context.Context, userID int, opts ListOptions) ([]QuickTemplate, int, error) {
+	if opts.Mine && opts.SharedOnly {
+		return nil, 0, fmt.Errorf("%w: mine and shared are exclusive", ErrInvalidListOptions)
+	}
+
+	args := []interface{}{userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where := []string{canView("$1")}
+	rank := ""
+	if opts.Query != "" {
+		query := "websearch_to_tsquery('english', " + arg(opts.Query) + ")"
+		where = append(where, "t.search_vector @@ "+query)
+		rank = "ts_rank_cd(t.search_vector, " + query + ")"
+	}
+	if opts.OwnerID != nil {
+		where = append(where, "t.user_id = "+arg(*opts.OwnerID))
+	}
+	if opts.Mine {
+		where = append(where, "t.user_id = $1")
+	}
+	if opts.SharedOnly {
+		where = append(where, "t.user_id <> $1")
+	}
+	if len(opts.Tags) > 0 {
+		tags, err := NormalizeTags(opts.Tags)
+		if err != nil {
+			return nil, 0, err
+		}
+		where = append(where, "t.tags @> "+arg(pq.Array(tags)))
+	}
+	if opts.CreatedAfter != nil {
+		where = append(where, "t.created_at >= "+arg(*opts.CreatedAfter))
+	}
+	if opts.CreatedBefore != nil {
+		where = append(where, "t.created_at < "+arg(*opts.CreatedBefore))
+	}
+	if opts.UpdatedAfter != nil {
+		where = append(where, "COALESCE(t.updated_at, t.created_at) >= "+arg(*opts.UpdatedAfter))
+	}
+	if opts.UpdatedBefore != nil {
+		where = append(where, "COALESCE(t.updated_at, t.created_at) < "+arg(*opts.UpdatedBefore))
+	}
+	filter := strings.Join(where, " AND ")
+
+	var orderBy string
+	switch opts.Sort {
+	case "":
+		orderBy = "t.created_at"
+		if rank != "" {
+			orderBy = rank
+		}
+	case SortCreated:
+		orderBy = "t.created_at"
+	case SortUpdated:
+		orderBy = "COALESCE(t.updated_at, t.created_at)"
+	case SortName:
+		orderBy = "lower(t.name)"
+	case SortRelevance:
+		if rank == "" {
+			return nil, 0, fmt.Errorf("%w: sorting by relevance needs a search query", ErrInvalidListOptions)
+		}
+		orderBy = rank
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown sort %q", ErrInvalidListOptions, opts.Sort)
+	}
+	direction := " DESC"
+	if opts.Ascending {
+		direction = " ASC"
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM quick_templates t WHERE `+filter, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count templates: %w", err)
+	}
+
+	query := `
+		SELECT ` + templateColumns + `, ` + accessLevel("$1") + `
+		FROM quick_templates t
+		WHERE ` + filter + `
+		ORDER BY ` + orderBy + direction + `, t.id` + direction
+	if opts.Limit > 0 {
+		query += " LIMIT " + arg(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		query += " OFFSET " + arg(opts.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []QuickTemplate{}
+	for rows.Next() {
+		var t QuickTemplate
+		if err := rows.Scan(append(templateFields(&t), &t.Access)...); err != nil {
+			return nil, 0, fmt.Errorf("scan template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, total, rows.Err()
+}
+
+// ListTags returns the tags on templates the user can view, most used first
+func (s *Store) ListTags(ctx context.Context, userID int) ([]TagCount, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tag, COUNT(*)
+		FROM quick_templates t, unnest(t.tags) AS tag
+		WHERE `+canView("$1")+`
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var tc TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return nil, fmt.Errorf("scan tag: %w", err)
+		}
+		tags = append(tags, tc)
+	}
+	return tags, rows.Err()
+}
+
+// NormalizeTags lowercases and trims tags, collapses inner whitespace, drops
+// duplicates and sorts them
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("%w: %q must be at most 50 letters, digits, spaces or _.:/- and start with a letter or digit", ErrInvalidTags, tag)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("%w: at most %d tags", ErrInvalidTags, MaxTags)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
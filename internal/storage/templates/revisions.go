@@ -0,0 +1,167 @@
+package templates
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
+)
+
+// Revision is an immutable snapshot of a template, recorded on every create,
+// update and restore
+type Revision struct {
+	TemplateID        int             `json:"template_id"`
+	Revision          int             `json:"revision"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	ConfigurationData json.RawMessage `json:"configuration_data,omitempty"` // Omitted from listings
+	SchemaVersion     int             `json:"schema_version"`
+	AuthorID          *int            `json:"author_id,omitempty"`
+	Author            *string         `json:"author,omitempty"`
+	RestoredFrom      *int            `json:"restored_from,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+}
+
+// visibleTemplate restricts a query on template_revisions r to templates the
+// user ($2) can view
+var visibleTemplate = `
+	JOIN quick_templates t ON t.id = r.template_id AND ` + canView("$2") + `
+	LEFT JOIN users a ON a.id = r.author_id`
+
+// ListRevisions returns a template's revisions, newest first, without their configuration
+func (s *Store) ListRevisions(ctx context.Context, templateID, userID int) ([]Revision, error) {
+	if _, err := s.Get(ctx, templateID, userID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT r.template_id, r.revision, r.name, COALESCE(r.description, ''), r.schema_version, r.author_id, a.username, r.restored_from, r.created_at
+		FROM template_revisions r` + visibleTemplate + `
+		WHERE r.template_id = $1
+		ORDER BY r.revision DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, templateID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var r Revision
+		if err := rows.Scan(&r.TemplateID, &r.Revision, &r.Name, &r.Description, &r.SchemaVersion, &r.AuthorID, &r.Author, &r.RestoredFrom, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan revision: %w", err)
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision retrieves one revision of a template with its configuration
+func (s *Store) GetRevision(ctx context.Context, templateID, revision, userID int) (*Revision, error) {
+	query := `
+		SELECT r.template_id, r.revision, r.name, COALESCE(r.description, ''), r.configuration_data, r.schema_version,
+		       r.author_id, a.username, r.restored_from, r.created_at
+		FROM template_revisions r` + visibleTemplate + `
+		WHERE r.template_id = $1 AND r.revision = $3
+	`
+
+	var r Revision
+	err := s.db.QueryRowContext(ctx, query, templateID, userID, revision).Scan(
+		&r.TemplateID, &r.Revision, &r.Name, &r.Description, &r.ConfigurationData, &r.SchemaVersion,
+		&r.AuthorID, &r.Author, &r.RestoredFrom, &r.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: no revision %d", ErrNotFound, revision)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get revision: %w", err)
+	}
+	return &r, nil
+}
+
+// DiffRevisions compares the proposed_changes of two revisions of a template
+func (s *Store) DiffRevisions(ctx context.Context, templateID, from, to, userID int) (*ChangesDiff, error) {
+	a, err := s.GetRevision(ctx, templateID, from, userID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.GetRevision(ctx, templateID, to, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := DiffProposedChanges(a.ConfigurationData, b.ConfigurationData)
+	if err != nil {
+		return nil, err
+	}
+	diff.From, diff.To = from, to
+	return diff, nil
+}
+
+// Restore makes an old revision's name, description and configuration the
+// template's content again, as a new revision. The owner and editors may restore;
+// a non-zero version must match the template's current version. A
+// configuration saved under an older schema is upgraded as it is restored.
+func (s *Store) Restore(ctx context.Context, templateID, revision, userID, version int) (*QuickTemplate, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("restore template: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE quick_templates t
+		SET name = r.name, description = r.description, configuration_data = r.configuration_data,
+		    schema_version = r.schema_version, current_revision = t.current_revision + 1, updated_at = NOW()
+		FROM template_revisions r
+		WHERE t.id = $1 AND ` + canEdit("$2") + ` AND r.template_id = t.id AND r.revision = $3
+		  AND ($4 = 0 OR t.version = $4)
+		RETURNING t.id, t.user_id, t.name, t.description, t.configuration_data, t.is_shared, t.current_revision, t.version, t.schema_version, t.tags, t.created_at, t.updated_at
+	`
+
+	var t QuickTemplate
+	err = tx.QueryRowContext(ctx, query, templateID, userID, revision, version).Scan(templateFields(&t)...)
+	if err == sql.ErrNoRows {
+		if err := s.missingOrStale(ctx, templateID, userID, version, "not editable by user"); errors.Is(err, ErrVersionMismatch) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w, not editable by user, or has no revision %d", ErrNotFound, revision)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("restore template: %w", err)
+	}
+	t.Access = accessOf(&t, userID)
+
+	if t.SchemaVersion < templateschema.CurrentVersion {
+		if err := upgradeTemplate(ctx, tx, &t); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := insertRevision(ctx, tx, &t, userID, &revision); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("restore template: %w", err)
+	}
+	return &t, nil
+}
+
+// insertRevision records the template's current content as its head revision
+func insertRevision(ctx context.Context, tx *sql.Tx, t *QuickTemplate, authorID int, restoredFrom *int) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO template_revisions (template_id, revision, name, description, configuration_data, schema_version, author_id, restored_from)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0), $8)
+	`, t.ID, t.Revision, t.Name, t.Description, t.ConfigurationData, t.SchemaVersion, authorID, restoredFrom)
+	if err != nil {
+		return fmt.Errorf("record revision: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package templates
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Access levels a user can have on a template
+const (
+	AccessOwner  = "owner"
+	AccessEditor = "editor" // May update and restore, but not delete or change sharing
+	AccessViewer = "viewer"
+)
+
+var (
+	// ErrInvalidShare is returned for a share grant that names no grantee, both, or an unknown level
+	ErrInvalidShare = errors.New("invalid share")
+	// ErrGranteeNotFound is returned when sharing with a user or group that doesn't exist
+	ErrGranteeNotFound = errors.New("user or group not found")
+)
+
+// Share grants a user, or every member of a group, access to a template
+type Share struct {
+	ID         int       `json:"id"`
+	TemplateID int       `json:"template_id"`
+	UserID     *int      `json:"user_id,omitempty"`
+	Username   *string   `json:"username,omitempty"`
+	GroupID    *int      `json:"group_id,omitempty"`
+	GroupName  *string   `json:"group_name,omitempty"`
+	Level      string    `json:"level"` // viewer | editor
+	GrantedBy  *int      `json:"granted_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ShareInput shares a template with exactly one of a user or a group
+type ShareInput struct {
+	UserID  *int
+	GroupID *int
+	Level   string
+}
+
+// granteeMatch matches shares s of template t granted to the user (%[1]s)
+// directly or through one of their groups
+const granteeMatch = `s.template_id = t.id AND (s.user_id = %[1]s OR s.group_id IN (SELECT group_id FROM group_members WHERE user_id = %[1]s))`
+
+// canView is the SQL condition for the user (a placeholder such as "$2")
+// being able to view template t: owner, public to org, or any share
+func canView(user string) string {
+	return fmt.Sprintf(`(t.user_id = %[1]s OR t.is_shared = TRUE OR EXISTS (SELECT 1 FROM template_shares s WHERE `+granteeMatch+`))`, user)
+}
+
+// canEdit is the SQL condition for the user being able to update template t:
+// owner or an editor share
+func canEdit(user string) string {
+	return fmt.Sprintf(`(t.user_id = %[1]s OR EXISTS (SELECT 1 FROM template_shares s WHERE `+granteeMatch+` AND s.level = 'editor'))`, user)
+}
+
+// accessLevel is the SQL expression for the user's access to template t,
+// assuming they can view it
+func accessLevel(user string) string {
+	return `CASE WHEN t.user_id = ` + user + ` THEN 'owner' WHEN ` + canEdit(user) + ` THEN 'editor' ELSE 'viewer' END`
+}
+
+// ListShares returns who a template is shared with, users before groups. Any
+// user who can view the template may list them.
+func (s *Store) ListShares(ctx context.Context, templateID, userID int) ([]Share, error) {
+	if _, err := s.Get(ctx, templateID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.id, s.template_id, s.user_id, u.username, s.group_id, g.name, s.level, s.granted_by, s.created_at
+		FROM template_shares s
+		LEFT JOIN users u ON u.id = s.user_id
+		LEFT JOIN groups g ON g.id = s.group_id
+		WHERE s.template_id = $1
+		ORDER BY s.group_id NULLS FIRST, u.username, g.name
+	`, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("list shares: %w", err)
+	}
+	defer rows.Close()
+
+	shares := []Share{}
+	for rows.Next() {
+		var sh Share
+		if err := rows.Scan(&sh.ID, &sh.TemplateID, &sh.UserID, &sh.Username, &sh.GroupID, &sh.GroupName, &sh.Level, &sh.GrantedBy, &sh.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan share: %w", err)
+		}
+		shares = append(shares, sh)
+	}
+	return shares, rows.Err()
+}
+
+// Share grants a user or group access to a template, or changes the level of
+// an existing grant. Only the owner may share.
+func (s *Store) Share(ctx context.Context, templateID, ownerID int, input ShareInput) (*Share, error) {
+	if (input.UserID == nil) == (input.GroupID == nil) {
+		return nil, fmt.Errorf("%w: exactly one of user_id and group_id is required", ErrInvalidShare)
+	}
+	if input.Level != AccessViewer && input.Level != AccessEditor {
+		return nil, fmt.Errorf("%w: level must be %s or %s", ErrInvalidShare, AccessViewer, AccessEditor)
+	}
+	if input.UserID != nil && *input.UserID == ownerID {
+		return nil, fmt.Errorf("%w: the owner already has access", ErrInvalidShare)
+	}
+
+	conflict := `(template_id, user_id) WHERE user_id IS NOT NULL`
+	if input.GroupID != nil {
+		conflict = `(template_id, group_id) WHERE group_id IS NOT NULL`
+	}
+
+	var sh Share
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO template_shares (template_id, user_id, group_id, level, granted_by)
+		SELECT t.id, $3, $4, $5, $2
+		FROM quick_templates t
+		WHERE t.id = $1 AND t.user_id = $2
+		ON CONFLICT `+conflict+` DO UPDATE SET level = EXCLUDED.level, granted_by = EXCLUDED.granted_by
+		RETURNING id, template_id, user_id, group_id, level, granted_by, created_at
+	`, templateID, ownerID, input.UserID, input.GroupID, input.Level).Scan(
+		&sh.ID, &sh.TemplateID, &sh.UserID, &sh.GroupID, &sh.Level, &sh.GrantedBy, &sh.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w or not owned by user", ErrNotFound)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return nil, ErrGranteeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("share template: %w", err)
+	}
+	return &sh, nil
+}
+
+// Unshare removes a share grant. Only the owner may unshare.
+func (s *Store) Unshare(ctx context.Context, templateID, shareID, ownerID int) error {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM template_shares s
+		USING quick_templates t
+		WHERE s.id = $1 AND s.template_id = $2 AND t.id = s.template_id AND t.user_id = $3
+	`, shareID, templateID, ownerID)
+	if err != nil {
+		return fmt.Errorf("unshare template: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("%w, not owned by user, or has no share %d", ErrNotFound, shareID)
+	}
+	return nil
+}
@@ -4,8 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when a template or revision doesn't exist or isn't visible to the user
+	ErrNotFound = errors.New("template not found")
+	// ErrVersionMismatch is returned when a template changed since the version the caller read
+	ErrVersionMismatch = errors.New("template was modified since it was read")
 )
 
 // QuickTemplate represents a saved workbench configuration template
@@ -15,9 +25,14 @@ type QuickTemplate struct {
 	Name              string          `json:"name"`
 	Description       string          `json:"description"`
 	ConfigurationData json.RawMessage `json:"configuration_data"` // {llm_provider_id, prompt_version_id, proposed_changes[]}
-	IsShared          bool            `json:"is_shared"`
+	IsShared          bool            `json:"is_shared"`          // Public to org: every user can view it
+	Revision          int             `json:"revision"`           // Head of the template's revision history
+	Version           int             `json:"version"`            // Bumped by every write to the row; served as the ETag
+	SchemaVersion     int             `json:"schema_version"`     // Configuration schema the template matches; 0 predates the schema
+	Tags              []string        `json:"tags"`
 	CreatedAt         time.Time       `json:"created_at"`
 	UpdatedAt         *time.Time      `json:"updated_at,omitempty"`
+	Access            string          `json:"access"` // The requesting user's access: owner, editor or viewer
 }
 
 // CreateTemplateInput represents input for creating a template
@@ -26,7 +41,9 @@ type CreateTemplateInput struct {
 	Name              string
 	Description       string
 	ConfigurationData json.RawMessage
+	SchemaVersion     int // Schema the configuration was validated against
 	IsShared          bool
+	Tags              []string
 }
 
 // UpdateTemplateInput represents input for updating a template
@@ -34,7 +51,10 @@ type UpdateTemplateInput struct {
 	Name              string
 	Description       string
 	ConfigurationData json.RawMessage
+	SchemaVersion     int // Schema the configuration was validated against
 	IsShared          bool
+	Tags              []string
+	Version           int // Expected current version; 0 skips the check
 }
 
 // Store provides database operations for quick templates
@@ -47,46 +67,28 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-// List returns all templates for a user (personal + shared)
-func (s *Store) List(ctx context.Context, userID int) ([]QuickTemplate, error) {
-	query := `
-		SELECT id, user_id, name, description, configuration_data, is_shared, created_at, updated_at
-		FROM quick_templates
-		WHERE user_id = $1 OR is_shared = TRUE
-		ORDER BY created_at DESC
-	`
+const templateColumns = `id, user_id, name, description, configuration_data, is_shared, current_revision, version, schema_version, tags, created_at, updated_at`
 
-	rows, err := s.db.QueryContext(ctx, query, userID)
-	if err != nil {
-		return nil, fmt.Errorf("list templates: %w", err)
+// templateFields are the scan destinations for templateColumns
+func templateFields(t *QuickTemplate) []interface{} {
+	return []interface{}{
+		&t.ID, &t.UserID, &t.Name, &t.Description, &t.ConfigurationData, &t.IsShared,
+		&t.Revision, &t.Version, &t.SchemaVersion, pq.Array(&t.Tags), &t.CreatedAt, &t.UpdatedAt,
 	}
-	defer rows.Close()
-
-	var templates []QuickTemplate
-	for rows.Next() {
-		var t QuickTemplate
-		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Description, &t.ConfigurationData, &t.IsShared, &t.CreatedAt, &t.UpdatedAt); err != nil {
-			return nil, fmt.Errorf("scan template: %w", err)
-		}
-		templates = append(templates, t)
-	}
-	return templates, rows.Err()
 }
 
-// Get retrieves a template by ID
+// Get retrieves a template by ID if the user can view it
 func (s *Store) Get(ctx context.Context, id int, userID int) (*QuickTemplate, error) {
 	query := `
-		SELECT id, user_id, name, description, configuration_data, is_shared, created_at, updated_at
-		FROM quick_templates
-		WHERE id = $1 AND (user_id = $2 OR is_shared = TRUE)
+		SELECT ` + templateColumns + `, ` + accessLevel("$2") + `
+		FROM quick_templates t
+		WHERE t.id = $1 AND ` + canView("$2") + `
 	`
 
 	var t QuickTemplate
-	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(
-		&t.ID, &t.UserID, &t.Name, &t.Description, &t.ConfigurationData, &t.IsShared, &t.CreatedAt, &t.UpdatedAt,
-	)
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(append(templateFields(&t), &t.Access)...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("template not found")
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("get template: %w", err)
@@ -94,57 +96,139 @@ func (s *Store) Get(ctx context.Context, id int, userID int) (*QuickTemplate, er
 	return &t, nil
 }
 
-// Create creates a new template
+// Create creates a new template as revision 1
 func (s *Store) Create(ctx context.Context, input CreateTemplateInput) (*QuickTemplate, error) {
+	tags, err := NormalizeTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create template: %w", err)
+	}
+	defer tx.Rollback()
+
+	t, err := insertTemplate(ctx, tx, input, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("create template: %w", err)
+	}
+	return t, nil
+}
+
+// insertTemplate inserts a template and its first revision within tx
+func insertTemplate(ctx context.Context, tx *sql.Tx, input CreateTemplateInput, tags []string) (*QuickTemplate, error) {
 	query := `
-		INSERT INTO quick_templates (user_id, name, description, configuration_data, is_shared)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, user_id, name, description, configuration_data, is_shared, created_at, updated_at
-	`
+		INSERT INTO quick_templates (user_id, name, description, configuration_data, schema_version, is_shared, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + templateColumns
 
 	var t QuickTemplate
-	err := s.db.QueryRowContext(ctx, query, input.UserID, input.Name, input.Description, input.ConfigurationData, input.IsShared).Scan(
-		&t.ID, &t.UserID, &t.Name, &t.Description, &t.ConfigurationData, &t.IsShared, &t.CreatedAt, &t.UpdatedAt,
-	)
+	err := tx.QueryRowContext(ctx, query, input.UserID, input.Name, input.Description, input.ConfigurationData, input.SchemaVersion, input.IsShared, pq.Array(tags)).Scan(templateFields(&t)...)
 	if err != nil {
 		return nil, fmt.Errorf("create template: %w", err)
 	}
+	t.Access = AccessOwner
+
+	if err := insertRevision(ctx, tx, &t, input.UserID, nil); err != nil {
+		return nil, err
+	}
 	return &t, nil
 }
 
-// Update updates a template
+// Update updates a template, recording the result as a new revision. The
+// owner and editors may update; only the owner can change is_shared. A
+// non-zero input.Version must match the template's current version.
 func (s *Store) Update(ctx context.Context, id int, userID int, input UpdateTemplateInput) (*QuickTemplate, error) {
+	tags, err := NormalizeTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("update template: %w", err)
+	}
+	defer tx.Rollback()
+
+	t, err := updateTemplate(ctx, tx, id, userID, input, tags)
+	if err == sql.ErrNoRows {
+		return nil, s.missingOrStale(ctx, id, userID, input.Version, "not editable by user")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("update template: %w", err)
+	}
+	return t, nil
+}
+
+// updateTemplate updates a template and records the new revision within tx.
+// It returns sql.ErrNoRows when the user can't edit the template or the
+// version is stale.
+func updateTemplate(ctx context.Context, tx *sql.Tx, id, userID int, input UpdateTemplateInput, tags []string) (*QuickTemplate, error) {
 	query := `
-		UPDATE quick_templates
-		SET name = $1, description = $2, configuration_data = $3, is_shared = $4, updated_at = NOW()
-		WHERE id = $5 AND user_id = $6
-		RETURNING id, user_id, name, description, configuration_data, is_shared, created_at, updated_at
-	`
+		UPDATE quick_templates t
+		SET name = $1, description = $2, configuration_data = $3, schema_version = $4,
+		    is_shared = CASE WHEN t.user_id = $7 THEN $5 ELSE t.is_shared END, tags = $9,
+		    current_revision = t.current_revision + 1, updated_at = NOW()
+		WHERE t.id = $6 AND ` + canEdit("$7") + ` AND ($8 = 0 OR t.version = $8)
+		RETURNING ` + templateColumns
 
 	var t QuickTemplate
-	err := s.db.QueryRowContext(ctx, query, input.Name, input.Description, input.ConfigurationData, input.IsShared, id, userID).Scan(
-		&t.ID, &t.UserID, &t.Name, &t.Description, &t.ConfigurationData, &t.IsShared, &t.CreatedAt, &t.UpdatedAt,
-	)
+	err := tx.QueryRowContext(ctx, query, input.Name, input.Description, input.ConfigurationData, input.SchemaVersion, input.IsShared, id, userID, input.Version, pq.Array(tags)).Scan(templateFields(&t)...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("template not found or not owned by user")
+		return nil, err
 	}
 	if err != nil {
 		return nil, fmt.Errorf("update template: %w", err)
 	}
+	t.Access = accessOf(&t, userID)
+
+	if err := insertRevision(ctx, tx, &t, userID, nil); err != nil {
+		return nil, err
+	}
 	return &t, nil
 }
 
-// Delete deletes a template
-func (s *Store) Delete(ctx context.Context, id int, userID int) error {
-	query := `DELETE FROM quick_templates WHERE id = $1 AND user_id = $2`
-	result, err := s.db.ExecContext(ctx, query, id, userID)
+// Delete deletes a template. Only the owner may delete; a non-zero version must
+// match the template's current version.
+func (s *Store) Delete(ctx context.Context, id int, userID int, version int) error {
+	query := `DELETE FROM quick_templates WHERE id = $1 AND user_id = $2 AND ($3 = 0 OR version = $3)`
+	result, err := s.db.ExecContext(ctx, query, id, userID, version)
 	if err != nil {
 		return fmt.Errorf("delete template: %w", err)
 	}
 
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return fmt.Errorf("template not found or not owned by user")
+		return s.missingOrStale(ctx, id, userID, version, "not owned by user")
 	}
 	return nil
 }
+
+// missingOrStale explains why a version-checked write to a template matched no row
+func (s *Store) missingOrStale(ctx context.Context, id, userID, version int, denied string) error {
+	if version != 0 {
+		var current int
+		err := s.db.QueryRowContext(ctx, `SELECT t.version FROM quick_templates t WHERE t.id = $1 AND `+canEdit("$2"), id, userID).Scan(&current)
+		if err == nil && current != version {
+			return ErrVersionMismatch
+		}
+	}
+	return fmt.Errorf("%w or %s", ErrNotFound, denied)
+}
+
+// accessOf is the user's access to a template they could write
+func accessOf(t *QuickTemplate, userID int) string {
+	if t.UserID == userID {
+		return AccessOwner
+	}
+	return AccessEditor
+}
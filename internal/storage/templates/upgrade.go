@@ -0,0 +1,151 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
+)
+
+// UpgradeResult reports a run of UpgradeConfigurations
+type UpgradeResult struct {
+	Upgraded []int         // Template IDs now at the current schema version
+	Failed   map[int]error // Templates left at their version, with the reason
+}
+
+// UpgradeConfigurations brings every template saved under an older
+// configuration schema to the current one, each as a new revision without an
+// author (only its schema_version changes when the configuration doesn't). Templates whose upgraded configuration still doesn't validate are
+// left untouched and reported; one edited concurrently is skipped and
+// validated by that edit instead.
+func (s *Store) UpgradeConfigurations(ctx context.Context) (*UpgradeResult, error) {
+	type stale struct {
+		id, version, schemaVersion int
+		configuration              json.RawMessage
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, version, schema_version, configuration_data
+		FROM quick_templates
+		WHERE schema_version < $1
+		ORDER BY id
+	`, templateschema.CurrentVersion)
+	if err != nil {
+		return nil, fmt.Errorf("list outdated templates: %w", err)
+	}
+	var outdated []stale
+	for rows.Next() {
+		var t stale
+		if err := rows.Scan(&t.id, &t.version, &t.schemaVersion, &t.configuration); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan template: %w", err)
+		}
+		outdated = append(outdated, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list outdated templates: %w", err)
+	}
+
+	result := &UpgradeResult{Upgraded: []int{}, Failed: map[int]error{}}
+	for _, t := range outdated {
+		configuration, err := upgradedConfiguration(t.configuration, t.schemaVersion)
+		if err != nil {
+			result.Failed[t.id] = err
+			continue
+		}
+
+		changed := !bytes.Equal(templateschema.Canonical(configuration), templateschema.Canonical(t.configuration))
+		upgraded, err := s.saveUpgrade(ctx, t.id, t.version, configuration, changed)
+		if err != nil {
+			return result, err
+		}
+		if upgraded {
+			result.Upgraded = append(result.Upgraded, t.id)
+		}
+	}
+	return result, nil
+}
+
+// saveUpgrade writes an upgraded configuration as a new revision, unless the
+// template changed since it was read. An unchanged configuration only has its
+// schema version recorded.
+func (s *Store) saveUpgrade(ctx context.Context, id, version int, configuration json.RawMessage, changed bool) (bool, error) {
+	if !changed {
+		result, err := s.db.ExecContext(ctx, `
+			UPDATE quick_templates SET schema_version = $1 WHERE id = $2 AND version = $3
+		`, templateschema.CurrentVersion, id, version)
+		if err != nil {
+			return false, fmt.Errorf("upgrade template %d: %w", id, err)
+		}
+		rows, _ := result.RowsAffected()
+		return rows > 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("upgrade template %d: %w", id, err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE quick_templates
+		SET configuration_data = $1, schema_version = $2,
+		    current_revision = current_revision + 1, updated_at = NOW()
+		WHERE id = $3 AND version = $4
+		RETURNING ` + templateColumns
+
+	var t QuickTemplate
+	err = tx.QueryRowContext(ctx, query, configuration, templateschema.CurrentVersion, id, version).Scan(templateFields(&t)...)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("upgrade template %d: %w", id, err)
+	}
+
+	if err := insertRevision(ctx, tx, &t, 0, nil); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("upgrade template %d: %w", id, err)
+	}
+	return true, nil
+}
+
+// upgradeTemplate upgrades a template's configuration in place within tx,
+// failing if the result doesn't match the current schema
+func upgradeTemplate(ctx context.Context, tx *sql.Tx, t *QuickTemplate) error {
+	configuration, err := upgradedConfiguration(t.ConfigurationData, t.SchemaVersion)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE quick_templates SET configuration_data = $1, schema_version = $2
+		WHERE id = $3
+		RETURNING version
+	`, configuration, templateschema.CurrentVersion, t.ID).Scan(&t.Version)
+	if err != nil {
+		return fmt.Errorf("upgrade template %d: %w", t.ID, err)
+	}
+	t.ConfigurationData = configuration
+	t.SchemaVersion = templateschema.CurrentVersion
+	return nil
+}
+
+// upgradedConfiguration runs the schema upgrade steps and validates the result
+func upgradedConfiguration(configuration json.RawMessage, from int) (json.RawMessage, error) {
+	upgraded, err := templateschema.Upgrade(configuration, from)
+	if err != nil {
+		return nil, err
+	}
+	if err := templateschema.Validate(upgraded); err != nil {
+		return nil, err
+	}
+	return upgraded, nil
+}
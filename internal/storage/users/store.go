@@ -4,57 +4,97 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/bwburch/inflight-ui-service/internal/scim"
+	"github.com/bwburch/inflight-ui-service/internal/storage/rbac"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// isAdminColumn derives the legacy is_admin flag from the RBAC admin role, held
+// globally or through a group (the users.is_admin column was dropped in migration 000012)
+const isAdminColumn = `EXISTS(
+			SELECT 1 FROM user_roles ur JOIN roles r ON ur.role_id = r.id
+			WHERE ur.user_id = users.id AND r.name = 'admin' AND ur.resource_type IS NULL
+			  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+			UNION ALL
+			SELECT 1 FROM group_roles gr
+			JOIN group_members gm ON gm.group_id = gr.group_id
+			JOIN roles r ON gr.role_id = r.id
+			WHERE gm.user_id = users.id AND r.name = 'admin'
+			  AND (gr.expires_at IS NULL OR gr.expires_at > NOW())
+		) AS is_admin`
+
+// userColumns is the column list scanned by scanUser
+const userColumns = `id, username, email, COALESCE(full_name, ''), external_id, ` + isAdminColumn + `,
+		is_active, email_verified_at, created_at, updated_at, last_login_at, COALESCE(password_hash, '')`
+
 // User represents a system user
 type User struct {
-	ID           int        `json:"id"`
-	Username     string     `json:"username"`
-	Email        string     `json:"email"`
-	FullName     string     `json:"full_name"`
-	IsAdmin      bool       `json:"is_admin"`
-	IsActive     bool       `json:"is_active"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    *time.Time `json:"updated_at"`
-	LastLoginAt  *time.Time `json:"last_login_at"`
-	PasswordHash string     `json:"-"` // Never expose in JSON
+	ID              int        `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	FullName        string     `json:"full_name"`
+	ExternalID      *string    `json:"external_id,omitempty"`
+	IsAdmin         bool       `json:"is_admin"`
+	IsActive        bool       `json:"is_active"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       *time.Time `json:"updated_at"`
+	LastLoginAt     *time.Time `json:"last_login_at"`
+	PasswordHash    string     `json:"-"` // Never expose in JSON
 }
 
 // CreateUserInput for creating new users
 type CreateUserInput struct {
-	Username string
-	Email    string
-	FullName string
-	Password string
-	Role     string
+	Username   string
+	Email      string
+	FullName   string
+	Password   string // Optional for externally provisioned users
+	ExternalID *string
+	IsActive   *bool // Defaults to true
 }
 
 // UpdateUserInput for updating users
 type UpdateUserInput struct {
-	Email    *string
-	FullName *string
-	Role     *string
-	IsActive *bool
+	Username   *string
+	Email      *string
+	FullName   *string
+	IsActive   *bool
+	ExternalID *string
+}
+
+// Invalidator is notified when a user changes so cached copies can be dropped
+type Invalidator interface {
+	InvalidateUser(ctx context.Context, userID int)
 }
 
+type nopInvalidator struct{}
+
+func (nopInvalidator) InvalidateUser(context.Context, int) {}
+
 // Store handles user persistence
 type Store struct {
-	db *sql.DB
+	db          *sql.DB
+	invalidator Invalidator
 }
 
 // NewStore creates a new user store
 func NewStore(db *sql.DB) *Store {
-	return &Store{db: db}
+	return &Store{db: db, invalidator: nopInvalidator{}}
+}
+
+// SetInvalidator registers the cache to notify when a user is updated or deleted
+func (s *Store) SetInvalidator(invalidator Invalidator) {
+	s.invalidator = invalidator
 }
 
 // List returns all users with optional filters
 func (s *Store) List(ctx context.Context, role string, isActive *bool, limit, offset int) ([]User, int, error) {
 	// Build query with filters
 	query := `
-		SELECT id, username, email, full_name, is_admin, is_active, created_at, updated_at, last_login_at
+		SELECT id, username, email, COALESCE(full_name, ''), external_id, ` + isAdminColumn + `, is_active, email_verified_at, created_at, updated_at, last_login_at
 		FROM users
 		WHERE 1=1
 	`
@@ -64,8 +104,12 @@ func (s *Store) List(ctx context.Context, role string, isActive *bool, limit, of
 	argCount := 1
 
 	if role != "" && role != "all" {
-		query += fmt.Sprintf(" AND role = $%d", argCount)
-		countQuery += fmt.Sprintf(" AND role = $%d", argCount)
+		roleFilter := fmt.Sprintf(` AND EXISTS(
+			SELECT 1 FROM user_roles ur JOIN roles r ON ur.role_id = r.id
+			WHERE ur.user_id = users.id AND r.name = $%d
+			  AND (ur.expires_at IS NULL OR ur.expires_at > NOW()))`, argCount)
+		query += roleFilter
+		countQuery += roleFilter
 		queryArgs = append(queryArgs, role)
 		countArgs = append(countArgs, role)
 		argCount++
@@ -109,101 +153,186 @@ func (s *Store) List(ctx context.Context, role string, isActive *bool, limit, of
 	var users []User
 	for rows.Next() {
 		var u User
-		err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.FullName, &u.IsAdmin, &u.IsActive,
-			&u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt)
+		err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.FullName, &u.ExternalID, &u.IsAdmin, &u.IsActive,
+			&u.EmailVerifiedAt, &u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt)
 		if err != nil {
 			return nil, 0, fmt.Errorf("scan user: %w", err)
 		}
 		users = append(users, u)
 	}
 
-	return users, total, nil
+	return users, total, rows.Err()
 }
 
-// Get returns a user by ID
-func (s *Store) Get(ctx context.Context, id int) (*User, error) {
-	query := `
-		SELECT id, username, email, full_name, is_admin, is_active, created_at, updated_at, last_login_at, password_hash
-		FROM users
-		WHERE id = $1
-	`
+// activeDirectRoles selects a user's active global role assignments, which
+// SCIM exposes as the user's groups
+const activeDirectRoles = `FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = users.id AND ur.resource_type IS NULL
+		  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())`
+
+// scimColumns maps SCIM User attributes onto the users table
+var scimColumns = scim.Columns{
+	"id":              {Expr: "users.id::TEXT"},
+	"externalid":      {Expr: "COALESCE(users.external_id, '')"},
+	"username":        {Expr: "users.username"},
+	"displayname":     {Expr: "COALESCE(users.full_name, '')"},
+	"name.formatted":  {Expr: "NULLIF(users.full_name, '')"},
+	"name.givenname":  {Expr: "CASE WHEN users.full_name <> '' THEN '' END"},
+	"name.familyname": {Expr: "CASE WHEN users.full_name <> '' THEN '' END"},
+	"emails":          {Expr: "NULLIF(users.email, '')"},
+	"emails.value":    {Expr: "NULLIF(users.email, '')"},
+	"active":          {Expr: "CASE WHEN users.is_active THEN 'true' ELSE 'false' END"},
+	"groups":          {Expr: "ur.role_id::TEXT", From: activeDirectRoles},
+	"groups.value":    {Expr: "ur.role_id::TEXT", From: activeDirectRoles},
+	"groups.display":  {Expr: "r.name", From: activeDirectRoles},
+}
 
-	var u User
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&u.ID, &u.Username, &u.Email, &u.FullName, &u.IsAdmin, &u.IsActive,
-		&u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt, &u.PasswordHash,
-	)
+// ListSCIM returns a page of the users matching a SCIM filter (nil for all),
+// ordered by ID, and the total number of matches
+func (s *Store) ListSCIM(ctx context.Context, filter scim.Filter, limit, offset int) ([]User, int, error) {
+	where, args := "TRUE", []interface{}{}
+	if filter != nil {
+		where, args = scim.SQL(filter, scimColumns, args)
+	}
 
-	if err == sql.ErrNoRows {
-		return nil, nil
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count users: %w", err)
 	}
+
+	query := fmt.Sprintf(`SELECT %s FROM users WHERE %s ORDER BY id LIMIT $%d OFFSET $%d`,
+		userColumns, where, len(args)+1, len(args)+2)
+	rows, err := s.db.QueryContext(ctx, query, append(args, limit, offset)...)
 	if err != nil {
-		return nil, fmt.Errorf("get user: %w", err)
+		return nil, 0, fmt.Errorf("query users: %w", err)
 	}
+	defer rows.Close()
 
-	return &u, nil
+	var users []User
+	for rows.Next() {
+		var u User
+		err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.FullName, &u.ExternalID, &u.IsAdmin, &u.IsActive,
+			&u.EmailVerifiedAt, &u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt, &u.PasswordHash)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+
+	return users, total, rows.Err()
+}
+
+// Get returns a user by ID
+func (s *Store) Get(ctx context.Context, id int) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+
+	u, err := scanUser(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	return u, nil
 }
 
-// GetByUsername returns a user by username (for login)
+// GetByUsername returns a user by username, compared case-insensitively
 func (s *Store) GetByUsername(ctx context.Context, username string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE LOWER(username) = LOWER($1)`
+
+	u, err := scanUser(s.db.QueryRowContext(ctx, query, strings.TrimSpace(username)))
+	if err != nil {
+		return nil, fmt.Errorf("get user by username: %w", err)
+	}
+	return u, nil
+}
+
+// GetByLogin returns a user by username or email (for login), compared
+// case-insensitively. A username match wins over an email match.
+func (s *Store) GetByLogin(ctx context.Context, login string) (*User, error) {
 	query := `
-		SELECT id, username, email, full_name, is_admin, is_active, created_at, updated_at, last_login_at, password_hash
+		SELECT ` + userColumns + `
 		FROM users
-		WHERE username = $1
+		WHERE LOWER(username) = LOWER($1) OR LOWER(email) = LOWER($1)
+		ORDER BY (LOWER(username) = LOWER($1)) DESC
+		LIMIT 1
 	`
 
-	var u User
-	err := s.db.QueryRowContext(ctx, query, username).Scan(
-		&u.ID, &u.Username, &u.Email, &u.FullName, &u.IsAdmin, &u.IsActive,
-		&u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt, &u.PasswordHash,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
+	u, err := scanUser(s.db.QueryRowContext(ctx, query, strings.TrimSpace(login)))
+	if err != nil {
+		return nil, fmt.Errorf("get user by login: %w", err)
 	}
+	return u, nil
+}
+
+// MarkEmailVerified records that the user's email address has been verified.
+// The email must still match the address the verification was issued for.
+func (s *Store) MarkEmailVerified(ctx context.Context, id int, email string) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND LOWER(email) = LOWER($2)
+	`, id, email)
 	if err != nil {
-		return nil, fmt.Errorf("get user by username: %w", err)
+		return fmt.Errorf("mark email verified: %w", err)
 	}
 
-	return &u, nil
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	s.invalidator.InvalidateUser(ctx, id)
+	return nil
 }
 
 // Create creates a new user
 func (s *Store) Create(ctx context.Context, input CreateUserInput) (*User, error) {
-	// Hash password
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, fmt.Errorf("hash password: %w", err)
+	// Hash password (externally provisioned users may not have one)
+	var passwordHash *string
+	if input.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("hash password: %w", err)
+		}
+		h := string(hash)
+		passwordHash = &h
+	}
+
+	isActive := true
+	if input.IsActive != nil {
+		isActive = *input.IsActive
 	}
 
 	query := `
-		INSERT INTO users (username, email, full_name, password_hash, role, is_active, created_at)
-		VALUES ($1, $2, $3, $4, $5, true, NOW())
-		RETURNING id, username, email, full_name, is_admin, is_active, created_at, updated_at, last_login_at
+		INSERT INTO users (username, email, full_name, password_hash, external_id, is_active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id
 	`
 
-	var u User
-	err = s.db.QueryRowContext(ctx, query,
-		input.Username, input.Email, input.FullName, string(passwordHash), input.Role,
-	).Scan(&u.ID, &u.Username, &u.Email, &u.FullName, &u.IsAdmin, &u.IsActive,
-		&u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt)
-
+	var id int
+	err := s.db.QueryRowContext(ctx, query,
+		strings.TrimSpace(input.Username), NormalizeEmail(input.Email), input.FullName, passwordHash, input.ExternalID, isActive,
+	).Scan(&id)
 	if err != nil {
 		return nil, fmt.Errorf("create user: %w", err)
 	}
 
-	return &u, nil
+	return s.Get(ctx, id)
 }
 
-// Update updates a user
+// Update updates a user. Deactivating the last active admin returns
+// rbac.ErrLastAdmin.
 func (s *Store) Update(ctx context.Context, id int, input UpdateUserInput) (*User, error) {
 	query := "UPDATE users SET updated_at = NOW()"
 	args := []interface{}{}
 	argCount := 1
 
+	if input.Username != nil {
+		query += fmt.Sprintf(", username = $%d", argCount)
+		args = append(args, strings.TrimSpace(*input.Username))
+		argCount++
+	}
 	if input.Email != nil {
-		query += fmt.Sprintf(", email = $%d", argCount)
-		args = append(args, *input.Email)
+		// Changing the address invalidates any previous verification
+		query += fmt.Sprintf(", email = $%d, email_verified_at = CASE WHEN email = $%d THEN email_verified_at ELSE NULL END", argCount, argCount)
+		args = append(args, NormalizeEmail(*input.Email))
 		argCount++
 	}
 	if input.FullName != nil {
@@ -211,48 +340,62 @@ func (s *Store) Update(ctx context.Context, id int, input UpdateUserInput) (*Use
 		args = append(args, *input.FullName)
 		argCount++
 	}
-	if input.Role != nil {
-		query += fmt.Sprintf(", role = $%d", argCount)
-		args = append(args, *input.Role)
-		argCount++
-	}
 	if input.IsActive != nil {
 		query += fmt.Sprintf(", is_active = $%d", argCount)
 		args = append(args, *input.IsActive)
 		argCount++
 	}
+	if input.ExternalID != nil {
+		// An empty externalId detaches the user from the identity provider
+		query += fmt.Sprintf(", external_id = NULLIF($%d, '')", argCount)
+		args = append(args, *input.ExternalID)
+		argCount++
+	}
 
-	query += fmt.Sprintf(" WHERE id = $%d RETURNING id, username, email, full_name, is_admin, is_active, created_at, updated_at, last_login_at", argCount)
+	query += fmt.Sprintf(" WHERE id = $%d", argCount)
 	args = append(args, id)
 
-	var u User
-	err := s.db.QueryRowContext(ctx, query, args...).Scan(
-		&u.ID, &u.Username, &u.Email, &u.FullName, &u.IsAdmin, &u.IsActive,
-		&u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
+	var rows int64
+	if input.IsActive != nil && !*input.IsActive {
+		affected, err := rbac.ExecPreservingAdmin(ctx, s.db, query, args...)
+		if err == rbac.ErrLastAdmin {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("update user: %w", err)
+		}
+		rows = affected
+	} else {
+		result, err := s.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("update user: %w", err)
+		}
+		rows, _ = result.RowsAffected()
 	}
-	if err != nil {
-		return nil, fmt.Errorf("update user: %w", err)
+	if rows == 0 {
+		return nil, nil
 	}
 
-	return &u, nil
+	s.invalidator.InvalidateUser(ctx, id)
+	return s.Get(ctx, id)
 }
 
-// Delete deletes a user
+// Delete deletes a user. Deleting the last active admin returns
+// rbac.ErrLastAdmin.
 func (s *Store) Delete(ctx context.Context, id int) error {
-	result, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	rows, err := rbac.ExecPreservingAdmin(ctx, s.db, "DELETE FROM users WHERE id = $1", id)
+	if err == rbac.ErrLastAdmin {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("delete user: %w", err)
 	}
 
-	rows, _ := result.RowsAffected()
 	if rows == 0 {
 		return sql.ErrNoRows
 	}
 
+	s.invalidator.InvalidateUser(ctx, id)
 	return nil
 }
 
@@ -276,12 +419,38 @@ func (s *Store) UpdatePassword(ctx context.Context, id int, newPassword string)
 		return sql.ErrNoRows
 	}
 
+	s.invalidator.InvalidateUser(ctx, id)
 	return nil
 }
 
 // UpdateLastLogin updates the last login timestamp
 func (s *Store) UpdateLastLogin(ctx context.Context, id int) error {
-	_, err := s.db.ExecContext(ctx,
-		"UPDATE users SET last_login_at = NOW() WHERE id = $1", id)
-	return err
+	if _, err := s.db.ExecContext(ctx,
+		"UPDATE users SET last_login_at = NOW() WHERE id = $1", id); err != nil {
+		return err
+	}
+
+	s.invalidator.InvalidateUser(ctx, id)
+	return nil
+}
+
+// NormalizeEmail returns the canonical (trimmed, lowercase) form of an email address
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// scanUser scans a row selected with userColumns, returning nil if there is no row
+func scanUser(row *sql.Row) (*User, error) {
+	var u User
+	err := row.Scan(
+		&u.ID, &u.Username, &u.Email, &u.FullName, &u.ExternalID, &u.IsAdmin, &u.IsActive,
+		&u.EmailVerifiedAt, &u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt, &u.PasswordHash,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
 }
@@ -0,0 +1,89 @@
+package verifications
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	TokenDuration = 48 * time.Hour
+	TokenPrefix   = "email_verification:"
+)
+
+// Token is a single-use email verification token bound to an address
+type Token struct {
+	Token     string    `json:"-"`
+	UserID    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Store handles verification token persistence in Redis
+type Store struct {
+	redis *redis.Client
+}
+
+// NewStore creates a new verification token store
+func NewStore(redisClient *redis.Client) *Store {
+	return &Store{redis: redisClient}
+}
+
+// Create issues a new verification token for a user's email address
+func (s *Store) Create(ctx context.Context, userID int, email string) (*Token, error) {
+	value, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate verification token: %w", err)
+	}
+
+	token := &Token{
+		Token:     value,
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(TokenDuration),
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return nil, fmt.Errorf("encode verification token: %w", err)
+	}
+
+	if err := s.redis.Set(ctx, TokenPrefix+value, data, TokenDuration).Err(); err != nil {
+		return nil, fmt.Errorf("store verification token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Consume retrieves and deletes a token (single use); returns nil if unknown or expired
+func (s *Store) Consume(ctx context.Context, value string) (*Token, error) {
+	data, err := s.redis.GetDel(ctx, TokenPrefix+value).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get verification token: %w", err)
+	}
+
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("decode verification token: %w", err)
+	}
+	token.Token = value
+
+	return &token, nil
+}
+
+// generateToken generates a cryptographically secure random token
+func generateToken() (string, error) {
+	b := make([]byte, 32) // 256 bits
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
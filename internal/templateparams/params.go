@@ -0,0 +1,249 @@
+// Package templateparams lets a quick template declare typed parameters,
+// reference them from configuration values as {{name}}, and render the
+// template with arguments into a concrete configuration
+package templateparams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
+)
+
+// Parameter types
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+// Parameter is a typed value a template's configuration references
+type Parameter struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	Description string            `json:"description,omitempty"`
+	Default     json.RawMessage   `json:"default,omitempty"` // Parameters without one are required
+	Enum        []json.RawMessage `json:"enum,omitempty"`
+	Minimum     *float64          `json:"minimum,omitempty"`    // integer and number
+	Maximum     *float64          `json:"maximum,omitempty"`    // integer and number
+	MinLength   *int              `json:"min_length,omitempty"` // string
+	MaxLength   *int              `json:"max_length,omitempty"` // string
+	Pattern     string            `json:"pattern,omitempty"`    // string, RE2
+}
+
+// Required reports whether rendering needs an argument for the parameter
+func (p Parameter) Required() bool {
+	return p.Default == nil
+}
+
+// reference matches {{name}} inside a string value; a value that is exactly
+// one reference is replaced by the typed argument instead of its text
+var (
+	reference      = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	wholeReference = regexp.MustCompile(`^\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}$`)
+)
+
+// Parse returns the parameters a configuration declares
+func Parse(configuration json.RawMessage) ([]Parameter, error) {
+	var config struct {
+		Parameters []Parameter `json:"parameters"`
+	}
+	if err := json.Unmarshal(configuration, &config); err != nil {
+		return nil, fmt.Errorf("parse parameters: %w", err)
+	}
+	return config.Parameters, nil
+}
+
+// Check verifies what the JSON Schema can't: parameter names are unique,
+// constraints fit the type and each other, defaults and allowed values satisfy
+// them, and every reference names a declared parameter. Violations are
+// returned as a *templateschema.ValidationError.
+func Check(configuration json.RawMessage) error {
+	params, err := Parse(configuration)
+	if err != nil {
+		return err
+	}
+
+	var errs []templateschema.FieldError
+	fail := func(path, format string, args ...interface{}) {
+		errs = append(errs, templateschema.FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	declared := make(map[string]int, len(params))
+	for i, p := range params {
+		path := "/parameters/" + strconv.Itoa(i)
+		if first, ok := declared[p.Name]; ok {
+			fail(path+"/name", "duplicates parameter %d", first)
+			continue
+		}
+		declared[p.Name] = i
+
+		numeric := p.Type == TypeInteger || p.Type == TypeNumber
+		if !numeric && (p.Minimum != nil || p.Maximum != nil) {
+			fail(path, "minimum and maximum only apply to integer and number parameters")
+		}
+		if p.Type != TypeString && (p.MinLength != nil || p.MaxLength != nil || p.Pattern != "") {
+			fail(path, "min_length, max_length and pattern only apply to string parameters")
+		}
+		if p.Minimum != nil && p.Maximum != nil && *p.Minimum > *p.Maximum {
+			fail(path+"/minimum", "must not exceed maximum")
+		}
+		if p.MinLength != nil && p.MaxLength != nil && *p.MinLength > *p.MaxLength {
+			fail(path+"/min_length", "must not exceed max_length")
+		}
+		if p.Pattern != "" {
+			if _, err := regexp.Compile(p.Pattern); err != nil {
+				fail(path+"/pattern", "is not a valid regular expression: %v", err)
+				continue
+			}
+		}
+
+		for j, allowed := range p.Enum {
+			for _, msg := range p.check(allowed, false) {
+				fail(path+"/enum/"+strconv.Itoa(j), "%s", msg)
+			}
+		}
+		if p.Default != nil {
+			for _, msg := range p.check(p.Default, true) {
+				fail(path+"/default", "%s", msg)
+			}
+		}
+	}
+
+	config, err := templateschema.Decode(configuration)
+	if err != nil {
+		return fmt.Errorf("parse configuration: %w", err)
+	}
+	walk(config, "", func(value string, path string) {
+		for _, match := range reference.FindAllStringSubmatch(value, -1) {
+			if _, ok := declared[match[1]]; !ok {
+				fail(path, "references undeclared parameter %q", match[1])
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return &templateschema.ValidationError{Version: templateschema.CurrentVersion, Errors: errs}
+	}
+	return nil
+}
+
+// check lists how a value violates the parameter's type and constraints
+func (p Parameter) check(raw json.RawMessage, withEnum bool) []string {
+	value, err := templateschema.Decode(raw)
+	if err != nil {
+		return []string{"is not valid JSON"}
+	}
+
+	switch p.Type {
+	case TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return []string{"must be a string"}
+		}
+		var msgs []string
+		length := len([]rune(s))
+		if p.MinLength != nil && length < *p.MinLength {
+			msgs = append(msgs, fmt.Sprintf("must be at least %d characters long", *p.MinLength))
+		}
+		if p.MaxLength != nil && length > *p.MaxLength {
+			msgs = append(msgs, fmt.Sprintf("must be at most %d characters long", *p.MaxLength))
+		}
+		if p.Pattern != "" {
+			if re, err := regexp.Compile(p.Pattern); err == nil && !re.MatchString(s) {
+				msgs = append(msgs, fmt.Sprintf("must match %s", p.Pattern))
+			}
+		}
+		if withEnum {
+			msgs = append(msgs, p.checkEnum(raw)...)
+		}
+		return msgs
+	case TypeInteger, TypeNumber:
+		n, ok := value.(json.Number)
+		if !ok {
+			return []string{"must be " + article(p.Type)}
+		}
+		f, err := n.Float64()
+		if err != nil || (p.Type == TypeInteger && (f != math.Trunc(f) || math.IsInf(f, 0))) {
+			return []string{"must be " + article(p.Type)}
+		}
+		var msgs []string
+		if p.Minimum != nil && f < *p.Minimum {
+			msgs = append(msgs, "must be at least "+strconv.FormatFloat(*p.Minimum, 'f', -1, 64))
+		}
+		if p.Maximum != nil && f > *p.Maximum {
+			msgs = append(msgs, "must be at most "+strconv.FormatFloat(*p.Maximum, 'f', -1, 64))
+		}
+		if withEnum {
+			msgs = append(msgs, p.checkEnum(raw)...)
+		}
+		return msgs
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return []string{"must be a boolean"}
+		}
+		if withEnum {
+			return p.checkEnum(raw)
+		}
+		return nil
+	}
+	return []string{fmt.Sprintf("has unknown type %q", p.Type)}
+}
+
+func (p Parameter) checkEnum(raw json.RawMessage) []string {
+	if len(p.Enum) == 0 {
+		return nil
+	}
+	parts := make([]string, len(p.Enum))
+	for i, allowed := range p.Enum {
+		if bytes.Equal(templateschema.Canonical(allowed), templateschema.Canonical(raw)) {
+			return nil
+		}
+		parts[i] = string(templateschema.Canonical(allowed))
+	}
+	return []string{"must be one of " + strings.Join(parts, ", ")}
+}
+
+func article(typ string) string {
+	if typ == TypeInteger {
+		return "an integer"
+	}
+	return "a " + typ
+}
+
+// walk calls fn for every string value in the configuration outside the
+// parameters declaration, with its JSON Pointer
+func walk(value interface{}, path string, fn func(value, path string)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if path == "" && key == "parameters" {
+				continue
+			}
+			walk(v[key], path+"/"+escape(key), fn)
+		}
+	case []interface{}:
+		for i, child := range v {
+			walk(child, path+"/"+strconv.Itoa(i), fn)
+		}
+	case string:
+		fn(v, path)
+	}
+}
+
+// escape encodes an object key as a JSON Pointer reference token
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
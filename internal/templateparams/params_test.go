@@ -0,0 +1,207 @@
+package templateparams
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		paths  []string // Paths of the expected errors, in order
+	}{
+		{
+			name: "valid parameters and references",
+			config: `{"parameters": [
+				{"name": "replicas", "type": "integer", "default": 2, "minimum": 1, "maximum": 10},
+				{"name": "env", "type": "string", "enum": ["dev", "prod"]}
+			], "proposed_changes": [{"path": "/replicas", "value": "{{replicas}}", "note": "for {{ env }}"}]}`,
+		},
+		{
+			name:   "no parameters",
+			config: `{"proposed_changes": []}`,
+		},
+		{
+			name: "duplicate name",
+			config: `{"parameters": [
+				{"name": "env", "type": "string"},
+				{"name": "env", "type": "string"}
+			]}`,
+			paths: []string{"/parameters/1/name"},
+		},
+		{
+			name:   "numeric bounds on a string",
+			config: `{"parameters": [{"name": "env", "type": "string", "minimum": 1}]}`,
+			paths:  []string{"/parameters/0"},
+		},
+		{
+			name:   "length bounds on an integer",
+			config: `{"parameters": [{"name": "n", "type": "integer", "max_length": 3}]}`,
+			paths:  []string{"/parameters/0"},
+		},
+		{
+			name:   "minimum above maximum",
+			config: `{"parameters": [{"name": "n", "type": "number", "minimum": 5, "maximum": 1}]}`,
+			paths:  []string{"/parameters/0/minimum"},
+		},
+		{
+			name:   "min_length above max_length",
+			config: `{"parameters": [{"name": "s", "type": "string", "min_length": 5, "max_length": 1}]}`,
+			paths:  []string{"/parameters/0/min_length"},
+		},
+		{
+			name:   "invalid pattern",
+			config: `{"parameters": [{"name": "s", "type": "string", "pattern": "("}]}`,
+			paths:  []string{"/parameters/0/pattern"},
+		},
+		{
+			name:   "enum value of the wrong type",
+			config: `{"parameters": [{"name": "n", "type": "integer", "enum": [1, "two"]}]}`,
+			paths:  []string{"/parameters/0/enum/1"},
+		},
+		{
+			name:   "default outside enum",
+			config: `{"parameters": [{"name": "env", "type": "string", "enum": ["dev"], "default": "prod"}]}`,
+			paths:  []string{"/parameters/0/default"},
+		},
+		{
+			name:   "fractional integer default",
+			config: `{"parameters": [{"name": "n", "type": "integer", "default": 1.5}]}`,
+			paths:  []string{"/parameters/0/default"},
+		},
+		{
+			name:   "default violating bounds and pattern",
+			config: `{"parameters": [{"name": "s", "type": "string", "max_length": 2, "pattern": "^[a-z]+$", "default": "ABC"}]}`,
+			paths:  []string{"/parameters/0/default", "/parameters/0/default"},
+		},
+		{
+			name:   "undeclared reference",
+			config: `{"parameters": [], "proposed_changes": [{"value": "{{missing}}"}]}`,
+			paths:  []string{"/proposed_changes/0/value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(json.RawMessage(tt.config))
+			if tt.paths == nil {
+				if err != nil {
+					t.Fatalf("Check = %v, want nil", err)
+				}
+				return
+			}
+
+			var verr *templateschema.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Check = %v, want a *templateschema.ValidationError", err)
+			}
+			var paths []string
+			for _, e := range verr.Errors {
+				paths = append(paths, e.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("error paths = %v, want %v (%v)", paths, tt.paths, verr.Errors)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	config := `{
+		"parameters": [
+			{"name": "replicas", "type": "integer", "default": 2, "minimum": 1},
+			{"name": "env", "type": "string", "enum": ["dev", "prod"]},
+			{"name": "debug", "type": "boolean", "default": false}
+		],
+		"proposed_changes": [
+			{"path": "/replicas", "value": "{{replicas}}"},
+			{"path": "/debug", "value": "{{ debug }}"},
+			{"path": "/name", "value": "api-{{env}}-{{replicas}}"}
+		]
+	}`
+
+	tests := []struct {
+		name      string
+		args      string
+		want      string
+		arguments string
+		paths     []string // Paths of the expected argument errors, in order
+	}{
+		{
+			name: "defaults fill omitted arguments",
+			args: `{"env": "dev"}`,
+			want: `{"proposed_changes": [
+				{"path": "/replicas", "value": 2},
+				{"path": "/debug", "value": false},
+				{"path": "/name", "value": "api-dev-2"}
+			]}`,
+			arguments: `{"replicas": 2, "env": "dev", "debug": false}`,
+		},
+		{
+			name: "arguments keep their JSON type",
+			args: `{"env": "prod", "replicas": 12, "debug": true}`,
+			want: `{"proposed_changes": [
+				{"path": "/replicas", "value": 12},
+				{"path": "/debug", "value": true},
+				{"path": "/name", "value": "api-prod-12"}
+			]}`,
+			arguments: `{"replicas": 12, "env": "prod", "debug": true}`,
+		},
+		{
+			name:  "missing required argument",
+			args:  `{}`,
+			paths: []string{"/env"},
+		},
+		{
+			name:  "unknown arguments are listed first",
+			args:  `{"env": "dev", "zone": "a", "region": "b"}`,
+			paths: []string{"/region", "/zone"},
+		},
+		{
+			name:  "every invalid argument is reported",
+			args:  `{"env": "staging", "replicas": 0, "debug": "yes"}`,
+			paths: []string{"/replicas", "/env", "/debug"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+				t.Fatalf("bad test args: %v", err)
+			}
+
+			rendered, err := Render(json.RawMessage(config), args)
+			if tt.paths != nil {
+				var aerr *ArgumentError
+				if !errors.As(err, &aerr) {
+					t.Fatalf("Render = %v, want an *ArgumentError", err)
+				}
+				var paths []string
+				for _, e := range aerr.Errors {
+					paths = append(paths, e.Path)
+				}
+				if !reflect.DeepEqual(paths, tt.paths) {
+					t.Errorf("error paths = %v, want %v (%v)", paths, tt.paths, aerr.Errors)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Render = %v, want nil", err)
+			}
+			if got, want := templateschema.Canonical(rendered.Configuration), templateschema.Canonical(json.RawMessage(tt.want)); string(got) != string(want) {
+				t.Errorf("configuration = %s, want %s", got, want)
+			}
+			arguments, _ := json.Marshal(rendered.Arguments)
+			if got, want := templateschema.Canonical(arguments), templateschema.Canonical(json.RawMessage(tt.arguments)); string(got) != string(want) {
+				t.Errorf("arguments = %s, want %s", got, want)
+			}
+		})
+	}
+}
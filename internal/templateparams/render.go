@@ -0,0 +1,138 @@
+package templateparams
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/bwburch/inflight-ui-service/internal/templateschema"
+)
+
+// ArgumentError lists every problem with the arguments supplied for rendering.
+// Paths are JSON Pointers into the arguments object.
+type ArgumentError struct {
+	Errors []templateschema.FieldError `json:"errors"`
+}
+
+func (e *ArgumentError) Error() string {
+	first := e.Errors[0]
+	msg := fmt.Sprintf("invalid arguments: %s %s", first.Path, first.Message)
+	if len(e.Errors) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Errors)-1)
+	}
+	return msg
+}
+
+// Rendered is a configuration with its parameters substituted
+type Rendered struct {
+	Configuration json.RawMessage            `json:"configuration"`
+	Arguments     map[string]json.RawMessage `json:"arguments"` // Every parameter's value, defaults included
+}
+
+// Render checks the arguments against the configuration's parameters and
+// substitutes them for its references. The parameters declaration is dropped
+// from the result. A string that is exactly one reference takes the
+// argument's JSON type; references inside longer strings are replaced by the
+// argument's text.
+func Render(configuration json.RawMessage, args map[string]json.RawMessage) (*Rendered, error) {
+	params, err := Parse(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []templateschema.FieldError
+	fail := func(name, message string) {
+		errs = append(errs, templateschema.FieldError{Path: "/" + escape(name), Message: message})
+	}
+
+	declared := make(map[string]bool, len(params))
+	for _, p := range params {
+		declared[p.Name] = true
+	}
+	unknown := make([]string, 0)
+	for name := range args {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		fail(name, "is not a parameter of this template")
+	}
+
+	resolved := make(map[string]json.RawMessage, len(params))
+	values := make(map[string]interface{}, len(params))
+	for _, p := range params {
+		raw, ok := args[p.Name]
+		if !ok {
+			if p.Required() {
+				fail(p.Name, "is required")
+				continue
+			}
+			raw = p.Default
+		}
+		if msgs := p.check(raw, true); len(msgs) > 0 {
+			for _, msg := range msgs {
+				fail(p.Name, msg)
+			}
+			continue
+		}
+		resolved[p.Name] = raw
+		values[p.Name], _ = templateschema.Decode(raw)
+	}
+	if len(errs) > 0 {
+		return nil, &ArgumentError{Errors: errs}
+	}
+
+	config, err := templateschema.Decode(configuration)
+	if err != nil {
+		return nil, fmt.Errorf("parse configuration: %w", err)
+	}
+	if obj, ok := config.(map[string]interface{}); ok {
+		delete(obj, "parameters")
+	}
+
+	rendered, err := json.Marshal(substitute(config, values))
+	if err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	return &Rendered{Configuration: rendered, Arguments: resolved}, nil
+}
+
+// substitute replaces references in every string value
+func substitute(value interface{}, values map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = substitute(child, values)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = substitute(child, values)
+		}
+		return v
+	case string:
+		if match := wholeReference.FindStringSubmatch(v); match != nil {
+			return values[match[1]]
+		}
+		return reference.ReplaceAllStringFunc(v, func(ref string) string {
+			return text(values[reference.FindStringSubmatch(ref)[1]])
+		})
+	}
+	return value
+}
+
+// text is an argument's form inside a longer string
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
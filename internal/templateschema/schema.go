@@ -0,0 +1,350 @@
+// Package templateschema defines the versioned JSON Schema for quick template
+// configuration_data, validates configurations against it and upgrades
+// configurations saved under older versions
+package templateschema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CurrentVersion is the schema version new and updated configurations must match
+const CurrentVersion = 2
+
+//go:embed schemas/*.json
+var files embed.FS
+
+// schemas holds the parsed schema of every version, indexed by version
+var schemas = map[int]*node{}
+
+func init() {
+	if len(steps) != CurrentVersion {
+		panic(fmt.Sprintf("templateschema: %d upgrade steps for schema version %d", len(steps), CurrentVersion))
+	}
+	for version := 1; version <= CurrentVersion; version++ {
+		data, err := Schema(version)
+		if err != nil {
+			panic(err)
+		}
+		n, err := parseNode(data, "")
+		if err != nil {
+			panic(fmt.Sprintf("templateschema: v%d: %v", version, err))
+		}
+		schemas[version] = n
+	}
+}
+
+// Schema returns the JSON Schema document of a version
+func Schema(version int) (json.RawMessage, error) {
+	data, err := files.ReadFile(fmt.Sprintf("schemas/v%d.json", version))
+	if err != nil {
+		return nil, fmt.Errorf("no template configuration schema v%d", version)
+	}
+	return data, nil
+}
+
+// FieldError is one violation of the schema. Path is a JSON Pointer into the
+// configuration ("" for the configuration itself).
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every violation found in a configuration
+type ValidationError struct {
+	Version int          `json:"schema_version"`
+	Errors  []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	first := e.Errors[0]
+	msg := fmt.Sprintf("configuration does not match schema v%d: %s %s", e.Version, pathOrRoot(first.Path), first.Message)
+	if len(e.Errors) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Errors)-1)
+	}
+	return msg
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "configuration"
+	}
+	return path
+}
+
+// Validate checks a configuration against the current schema, returning a
+// *ValidationError listing every violation
+func Validate(data json.RawMessage) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &ValidationError{Version: CurrentVersion, Errors: []FieldError{{Message: "is required"}}}
+	}
+
+	value, err := Decode(data)
+	if err != nil {
+		return &ValidationError{Version: CurrentVersion, Errors: []FieldError{{Message: "is not valid JSON: " + err.Error()}}}
+	}
+
+	var errs []FieldError
+	schemas[CurrentVersion].validate(value, "", &errs)
+	if len(errs) > 0 {
+		return &ValidationError{Version: CurrentVersion, Errors: errs}
+	}
+	return nil
+}
+
+// Decode parses a single JSON value keeping numbers as json.Number, so
+// integers can be told apart and large ones keep their precision
+func Decode(data json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the value")
+	}
+	return value, nil
+}
+
+// node is the subset of JSON Schema the configuration schemas use. Schemas
+// using any other keyword fail to load rather than being half-enforced.
+type node struct {
+	types                []string
+	properties           map[string]*node
+	required             []string
+	additionalProperties *bool
+	items                *node
+	enum                 []json.RawMessage
+	minimum              *float64
+	maximum              *float64
+	minLength            *int
+	maxLength            *int
+	pattern              *regexp.Regexp
+	minItems             *int
+	minProperties        *int
+}
+
+// annotations are keywords that document a schema without constraining it
+var annotations = map[string]bool{"$schema": true, "$id": true, "title": true, "description": true}
+
+func parseNode(data json.RawMessage, path string) (*node, error) {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return nil, fmt.Errorf("%s: %w", pathOrRoot(path), err)
+	}
+
+	n := &node{}
+	for keyword, value := range keywords {
+		var err error
+		switch keyword {
+		case "type":
+			var single string
+			if json.Unmarshal(value, &single) == nil {
+				n.types = []string{single}
+			} else {
+				err = json.Unmarshal(value, &n.types)
+			}
+		case "properties":
+			var props map[string]json.RawMessage
+			if err = json.Unmarshal(value, &props); err == nil {
+				n.properties = make(map[string]*node, len(props))
+				for name, prop := range props {
+					if n.properties[name], err = parseNode(prop, path+"/"+escape(name)); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case "required":
+			err = json.Unmarshal(value, &n.required)
+		case "additionalProperties":
+			err = json.Unmarshal(value, &n.additionalProperties)
+		case "items":
+			n.items, err = parseNode(value, path+"/items")
+		case "enum":
+			err = json.Unmarshal(value, &n.enum)
+		case "minimum":
+			err = json.Unmarshal(value, &n.minimum)
+		case "maximum":
+			err = json.Unmarshal(value, &n.maximum)
+		case "minLength":
+			err = json.Unmarshal(value, &n.minLength)
+		case "maxLength":
+			err = json.Unmarshal(value, &n.maxLength)
+		case "pattern":
+			var expr string
+			if err = json.Unmarshal(value, &expr); err == nil {
+				n.pattern, err = regexp.Compile(expr)
+			}
+		case "minItems":
+			err = json.Unmarshal(value, &n.minItems)
+		case "minProperties":
+			err = json.Unmarshal(value, &n.minProperties)
+		default:
+			if !annotations[keyword] {
+				return nil, fmt.Errorf("%s: unsupported keyword %q", pathOrRoot(path), keyword)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", pathOrRoot(path), keyword, err)
+		}
+	}
+	return n, nil
+}
+
+func (n *node) validate(value interface{}, path string, errs *[]FieldError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(n.types) > 0 && !matchesAnyType(value, n.types) {
+		fail("must be %s, not %s", strings.Join(n.types, " or "), typeName(value))
+		return
+	}
+
+	if len(n.enum) > 0 {
+		encoded, _ := json.Marshal(value)
+		found := false
+		for _, allowed := range n.enum {
+			if bytes.Equal(Canonical(allowed), Canonical(encoded)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %s", joinRaw(n.enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if n.minProperties != nil && len(v) < *n.minProperties {
+			fail("must have at least %s", plural(*n.minProperties, "property", "properties"))
+		}
+		for _, name := range n.required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, FieldError{Path: path + "/" + escape(name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := n.properties[name]; ok {
+				prop.validate(v[name], path+"/"+escape(name), errs)
+			} else if n.additionalProperties != nil && !*n.additionalProperties {
+				*errs = append(*errs, FieldError{Path: path + "/" + escape(name), Message: "is not a known field"})
+			}
+		}
+	case []interface{}:
+		if n.minItems != nil && len(v) < *n.minItems {
+			fail("must have at least %s", plural(*n.minItems, "item", "items"))
+		}
+		if n.items != nil {
+			for i, item := range v {
+				n.items.validate(item, path+"/"+strconv.Itoa(i), errs)
+			}
+		}
+	case string:
+		if n.minLength != nil && len([]rune(v)) < *n.minLength {
+			fail("must be at least %s long", plural(*n.minLength, "character", "characters"))
+		}
+		if n.maxLength != nil && len([]rune(v)) > *n.maxLength {
+			fail("must be at most %s long", plural(*n.maxLength, "character", "characters"))
+		}
+		if n.pattern != nil && !n.pattern.MatchString(v) {
+			fail("must match %s", n.pattern)
+		}
+	case json.Number:
+		if f, err := v.Float64(); err == nil && n.minimum != nil && f < *n.minimum {
+			fail("must be at least %s", strconv.FormatFloat(*n.minimum, 'f', -1, 64))
+		}
+		if f, err := v.Float64(); err == nil && n.maximum != nil && f > *n.maximum {
+			fail("must be at most %s", strconv.FormatFloat(*n.maximum, 'f', -1, 64))
+		}
+	}
+}
+
+// typeName is the JSON Schema type of a decoded value
+func typeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if isInteger(v) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func matchesAnyType(value interface{}, types []string) bool {
+	actual := typeName(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// isInteger reports whether a number has no fractional part (1.0 counts, as in JSON Schema)
+func isInteger(n json.Number) bool {
+	if _, err := n.Int64(); err == nil {
+		return true
+	}
+	f, err := n.Float64()
+	return err == nil && f == math.Trunc(f) && !math.IsInf(f, 0)
+}
+
+// escape encodes a property name as a JSON Pointer reference token
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+// Canonical re-encodes JSON so equal values compare equal regardless of
+// whitespace and key order. Invalid JSON is returned unchanged.
+func Canonical(data json.RawMessage) []byte {
+	v, err := Decode(data)
+	if err != nil {
+		return data
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return strconv.Itoa(n) + " " + many
+}
+
+func joinRaw(values []json.RawMessage) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = string(Canonical(v))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,241 @@
+package templateschema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		from    int
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "current version is returned as is",
+			data: `{"proposed_changes": []}`,
+			from: CurrentVersion,
+			want: `{"proposed_changes": []}`,
+		},
+		{
+			name: "v1 carries over to v2 unchanged",
+			data: `{"llm_provider_id": 3, "proposed_changes": [{"path": "/a"}]}`,
+			from: 1,
+			want: `{"llm_provider_id": 3, "proposed_changes": [{"path": "/a"}]}`,
+		},
+		{
+			name: "large numbers keep their precision",
+			data: `{"proposed_changes": [{"value": 12345678901234567890}]}`,
+			from: 1,
+			want: `{"proposed_changes": [{"value": 12345678901234567890}]}`,
+		},
+		{
+			name: "unversioned bare array is wrapped",
+			data: `[{"path": "/a"}]`,
+			from: 0,
+			want: `{"proposed_changes": [{"path": "/a"}]}`,
+		},
+		{
+			name: "unversioned empty configuration gets no changes",
+			data: ``,
+			from: 0,
+			want: `{"proposed_changes": []}`,
+		},
+		{
+			name: "unversioned null proposed_changes becomes empty",
+			data: `{"proposed_changes": null, "prompt_version_id": "p1"}`,
+			from: 0,
+			want: `{"proposed_changes": [], "prompt_version_id": "p1"}`,
+		},
+		{
+			name:    "unversioned scalar is rejected",
+			data:    `"changes"`,
+			from:    0,
+			wantErr: true,
+		},
+		{
+			name:    "unknown version",
+			data:    `{"proposed_changes": []}`,
+			from:    CurrentVersion + 1,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			data:    `{"proposed_changes": [`,
+			from:    1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Upgrade(json.RawMessage(tt.data), tt.from)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Upgrade = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Upgrade: %v", err)
+			}
+			if string(Canonical(got)) != string(Canonical(json.RawMessage(tt.want))) {
+				t.Errorf("Upgrade = %s, want %s", got, tt.want)
+			}
+			if err := Validate(got); err != nil {
+				t.Errorf("upgraded configuration does not validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		paths  []string // Paths of the expected errors, in order
+	}{
+		{
+			name:   "minimal",
+			config: `{"proposed_changes": []}`,
+		},
+		{
+			name: "every field",
+			config: `{
+				"llm_provider_id": 2,
+				"prompt_version_id": "v7",
+				"proposed_changes": [{"path": "/replicas", "value": "{{replicas}}"}],
+				"parameters": [{"name": "replicas", "type": "integer", "default": 1, "minimum": 1, "enum": [1, 2]}]
+			}`,
+		},
+		{
+			name:   "nullable selection",
+			config: `{"llm_provider_id": null, "proposed_changes": []}`,
+		},
+		{
+			name:   "empty",
+			config: ``,
+			paths:  []string{""},
+		},
+		{
+			name:   "not JSON",
+			config: `{"proposed_changes": [}`,
+			paths:  []string{""},
+		},
+		{
+			name:   "trailing data",
+			config: `{"proposed_changes": []} {}`,
+			paths:  []string{""},
+		},
+		{
+			name:   "not an object",
+			config: `[]`,
+			paths:  []string{""},
+		},
+		{
+			name:   "missing proposed_changes",
+			config: `{}`,
+			paths:  []string{"/proposed_changes"},
+		},
+		{
+			name:   "unknown field",
+			config: `{"proposed_changes": [], "extra": 1}`,
+			paths:  []string{"/extra"},
+		},
+		{
+			name:   "wrong type",
+			config: `{"proposed_changes": {}}`,
+			paths:  []string{"/proposed_changes"},
+		},
+		{
+			name:   "empty change object",
+			config: `{"proposed_changes": [{"path": "/a"}, {}]}`,
+			paths:  []string{"/proposed_changes/1"},
+		},
+		{
+			name:   "integer below minimum",
+			config: `{"llm_provider_id": 0, "proposed_changes": []}`,
+			paths:  []string{"/llm_provider_id"},
+		},
+		{
+			name:   "fractional id",
+			config: `{"llm_provider_id": 1.5, "proposed_changes": []}`,
+			paths:  []string{"/llm_provider_id"},
+		},
+		{
+			name:   "empty string id",
+			config: `{"prompt_version_id": "", "proposed_changes": []}`,
+			paths:  []string{"/prompt_version_id"},
+		},
+		{
+			name: "invalid parameter declaration",
+			config: `{"proposed_changes": [], "parameters": [
+				{"name": "1bad", "type": "date", "enum": [], "unknown": true},
+				{"type": "string"}
+			]}`,
+			paths: []string{
+				"/parameters/0/enum",
+				"/parameters/0/name",
+				"/parameters/0/type",
+				"/parameters/0/unknown",
+				"/parameters/1/name",
+			},
+		},
+		{
+			name:   "every violation is reported",
+			config: `{"llm_provider_id": true, "proposed_changes": "none", "z": 1}`,
+			paths:  []string{"/llm_provider_id", "/proposed_changes", "/z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(json.RawMessage(tt.config))
+			if tt.paths == nil {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate = %v, want a *ValidationError", err)
+			}
+			if verr.Version != CurrentVersion {
+				t.Errorf("Version = %d, want %d", verr.Version, CurrentVersion)
+			}
+			var paths []string
+			for _, e := range verr.Errors {
+				paths = append(paths, e.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("error paths = %v, want %v (%v)", paths, tt.paths, verr.Errors)
+			}
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"b": 1, "a": [true, null]}`, `{"a":[true,null],"b":1}`},
+		{` "text" `, `"text"`},
+		{`1.50`, `1.50`},
+		{`12345678901234567890`, `12345678901234567890`},
+		{`{"a":`, `{"a":`}, // Invalid JSON is returned unchanged
+		{``, ``},
+	}
+
+	for _, tt := range tests {
+		if got := string(Canonical(json.RawMessage(tt.data))); got != tt.want {
+			t.Errorf("Canonical(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package templateschema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Step rewrites a decoded configuration saved under one schema version into
+// the shape of the next
+type Step func(config interface{}) (interface{}, error)
+
+// steps[n] upgrades a configuration from version n to n+1. To evolve the
+// schema, add schemas/v{N+1}.json, append the step from N and bump
+// CurrentVersion; stored templates are upgraded at startup.
+var steps = []Step{
+	upgradeUnversioned,
+	upgradeV1,
+}
+
+// Upgrade rewrites a configuration saved under an older schema version into
+// the current one. The result still has to be validated: a step only
+// reshapes what it understands.
+func Upgrade(data json.RawMessage, from int) (json.RawMessage, error) {
+	if from < 0 || from > CurrentVersion {
+		return nil, fmt.Errorf("unknown template configuration schema v%d", from)
+	}
+	if from == CurrentVersion {
+		return data, nil
+	}
+
+	var config interface{}
+	if len(data) > 0 {
+		var err error
+		if config, err = Decode(data); err != nil {
+			return nil, fmt.Errorf("parse configuration: %w", err)
+		}
+	}
+
+	for version := from; version < CurrentVersion; version++ {
+		var err error
+		if config, err = steps[version](config); err != nil {
+			return nil, fmt.Errorf("upgrade configuration from v%d: %w", version, err)
+		}
+	}
+
+	upgraded, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	return upgraded, nil
+}
+
+// upgradeUnversioned brings configurations saved before the schema existed
+// (version 0) to v1. The earliest templates stored the proposed_changes
+// array on its own; others may lack it or hold null.
+func upgradeUnversioned(config interface{}) (interface{}, error) {
+	switch c := config.(type) {
+	case nil:
+		return map[string]interface{}{"proposed_changes": []interface{}{}}, nil
+	case []interface{}:
+		return map[string]interface{}{"proposed_changes": c}, nil
+	case map[string]interface{}:
+		if c["proposed_changes"] == nil {
+			c["proposed_changes"] = []interface{}{}
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("configuration is %s, not an object", typeName(config))
+}
+
+// upgradeV1 brings v1 configurations to v2, which only adds the optional
+// parameters declaration
+func upgradeV1(config interface{}) (interface{}, error) {
+	return config, nil
+}
@@ -0,0 +1,8 @@
+-- Migration: Add external identity reference for SCIM provisioning
+-- Description: Stores the identity provider's externalId so provisioned users can be matched on subsequent syncs
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_external_id ON users(external_id) WHERE external_id IS NOT NULL;
+
+COMMENT ON COLUMN users.external_id IS 'Identity provider externalId (SCIM) - NULL for locally managed users';
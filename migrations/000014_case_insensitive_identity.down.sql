@@ -0,0 +1,8 @@
+-- Rollback: Restore case-sensitive lookup indexes and drop email verification
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
+
+DROP INDEX IF EXISTS idx_users_email_lower;
+DROP INDEX IF EXISTS idx_users_username_lower;
+CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
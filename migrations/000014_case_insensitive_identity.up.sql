@@ -0,0 +1,17 @@
+-- Migration: Case-insensitive usernames/emails and email verification
+-- Description: Normalises identity fields, enforces case-insensitive uniqueness and tracks email verification
+-- NOTE: Fails if existing rows differ only by case - resolve those duplicates manually before upgrading
+
+-- Normalise existing values (emails are stored lowercase, usernames keep their display case)
+UPDATE users SET email = LOWER(TRIM(email)), username = TRIM(username);
+
+-- Case-insensitive uniqueness (replaces the plain lookup indexes)
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (LOWER(username));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email));
+
+-- Email verification
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+COMMENT ON COLUMN users.email_verified_at IS 'When the current email address was verified - reset to NULL when the email changes';
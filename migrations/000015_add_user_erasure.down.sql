@@ -0,0 +1,4 @@
+-- Rollback: Remove erasure tracking
+
+DROP INDEX IF EXISTS idx_permission_audit_changed_by;
+ALTER TABLE users DROP COLUMN IF EXISTS erased_at;
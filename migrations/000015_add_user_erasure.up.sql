@@ -0,0 +1,8 @@
+-- Migration: Track personal data erasure
+-- Description: Erased users keep their row (anonymised) so audit and grant references stay valid
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS erased_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_permission_audit_changed_by ON permission_audit(changed_by);
+
+COMMENT ON COLUMN users.erased_at IS 'When personal data was erased (row anonymised, retained for referential integrity)';
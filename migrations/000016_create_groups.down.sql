@@ -0,0 +1,12 @@
+-- Rollback migration for user groups
+
+DROP TRIGGER IF EXISTS update_groups_updated_at ON groups;
+
+DROP INDEX IF EXISTS idx_group_roles_role;
+DROP INDEX IF EXISTS idx_group_roles_group;
+DROP INDEX IF EXISTS idx_group_members_group;
+DROP INDEX IF EXISTS idx_group_members_user;
+
+DROP TABLE IF EXISTS group_roles;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
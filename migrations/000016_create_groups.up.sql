@@ -0,0 +1,45 @@
+-- Migration: Create user groups (teams)
+-- Description: Groups have members and can be assigned roles; members inherit the group's roles
+
+CREATE TABLE IF NOT EXISTS groups (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(100) UNIQUE NOT NULL,
+  description TEXT,
+  created_by INTEGER REFERENCES users(id),
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Group membership (many-to-many)
+CREATE TABLE IF NOT EXISTS group_members (
+  id SERIAL PRIMARY KEY,
+  group_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  added_at TIMESTAMP DEFAULT NOW(),
+  added_by INTEGER REFERENCES users(id),
+  UNIQUE(group_id, user_id)
+);
+
+-- Group-role assignments (many-to-many)
+CREATE TABLE IF NOT EXISTS group_roles (
+  id SERIAL PRIMARY KEY,
+  group_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  assigned_at TIMESTAMP DEFAULT NOW(),
+  assigned_by INTEGER REFERENCES users(id),
+  expires_at TIMESTAMP,  -- Optional: time-limited group role assignments
+  UNIQUE(group_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_members_user ON group_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_group_members_group ON group_members(group_id);
+CREATE INDEX IF NOT EXISTS idx_group_roles_group ON group_roles(group_id);
+CREATE INDEX IF NOT EXISTS idx_group_roles_role ON group_roles(role_id);
+
+DROP TRIGGER IF EXISTS update_groups_updated_at ON groups;
+CREATE TRIGGER update_groups_updated_at BEFORE UPDATE ON groups
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE groups IS 'User groups (teams) for assigning roles to many users at once';
+COMMENT ON TABLE group_members IS 'Maps users to groups (many-to-many)';
+COMMENT ON TABLE group_roles IS 'Maps roles to groups (many-to-many) - members inherit these roles';
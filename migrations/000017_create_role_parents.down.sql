@@ -0,0 +1,18 @@
+-- Rollback migration for role inheritance
+
+-- Flatten inherited permissions back into each child role before dropping the hierarchy
+WITH RECURSIVE ancestors AS (
+  SELECT role_id, parent_role_id FROM role_parents
+  UNION
+  SELECT a.role_id, rp.parent_role_id
+  FROM ancestors a
+  JOIN role_parents rp ON rp.role_id = a.parent_role_id
+)
+INSERT INTO role_permissions (role_id, permission_id)
+  SELECT a.role_id, prp.permission_id
+  FROM ancestors a
+  JOIN role_permissions prp ON prp.role_id = a.parent_role_id
+ON CONFLICT (role_id, permission_id) DO NOTHING;
+
+DROP INDEX IF EXISTS idx_role_parents_parent;
+DROP TABLE IF EXISTS role_parents;
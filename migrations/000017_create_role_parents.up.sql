@@ -0,0 +1,32 @@
+-- Migration: Role inheritance
+-- Description: A role inherits every permission of its parent roles (transitively)
+
+CREATE TABLE IF NOT EXISTS role_parents (
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  parent_role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  created_at TIMESTAMP DEFAULT NOW(),
+  created_by INTEGER REFERENCES users(id),
+  PRIMARY KEY (role_id, parent_role_id),
+  CHECK (role_id <> parent_role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_parents_parent ON role_parents(parent_role_id);
+
+COMMENT ON TABLE role_parents IS 'Role hierarchy - a role inherits its parents'' permissions; cycles are rejected by the application';
+
+-- ANALYST inherits VIEWER: analyst was seeded with every view permission, so the
+-- duplicated grants are replaced by the parent link.
+-- OPERATOR is left unlinked: viewer also carries admin-category view permissions
+-- (users.view, roles.view) that operator was deliberately not given.
+INSERT INTO role_parents (role_id, parent_role_id)
+  SELECT child.id, parent.id
+  FROM roles child, roles parent
+  WHERE child.name = 'analyst' AND parent.name = 'viewer'
+ON CONFLICT DO NOTHING;
+
+DELETE FROM role_permissions rp
+  USING roles child, roles parent, role_permissions prp
+  WHERE child.name = 'analyst' AND parent.name = 'viewer'
+    AND rp.role_id = child.id
+    AND prp.role_id = parent.id
+    AND prp.permission_id = rp.permission_id;
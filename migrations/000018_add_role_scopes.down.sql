@@ -0,0 +1,12 @@
+-- Rollback migration for resource-scoped role assignments
+-- Scoped grants cannot be represented without the scope columns and are removed
+
+DELETE FROM user_roles WHERE resource_type IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_user_roles_scope;
+DROP INDEX IF EXISTS idx_user_roles_unique_scope;
+ALTER TABLE user_roles ADD CONSTRAINT user_roles_user_id_role_id_key UNIQUE (user_id, role_id);
+
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_scope_complete;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS resource_id;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS resource_type;
@@ -0,0 +1,20 @@
+-- Migration: Resource-scoped role assignments
+-- Description: A user role can be limited to resources of one type whose ID matches a glob
+-- (e.g. resource_type 'service', resource_id 'payments-*'). NULL scope = global grant.
+
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS resource_type VARCHAR(50);
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS resource_id VARCHAR(255);
+
+ALTER TABLE user_roles ADD CONSTRAINT user_roles_scope_complete
+  CHECK ((resource_type IS NULL) = (resource_id IS NULL));
+
+-- A role may now be held once globally and once per distinct scope
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_user_id_role_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_roles_unique_scope
+  ON user_roles (user_id, role_id, (COALESCE(resource_type, '')), (COALESCE(resource_id, '')));
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_scope ON user_roles(resource_type)
+  WHERE resource_type IS NOT NULL;
+
+COMMENT ON COLUMN user_roles.resource_type IS 'Scope resource type (e.g. service, environment); NULL for global grants';
+COMMENT ON COLUMN user_roles.resource_id IS 'Scope resource ID or glob (* and ?); NULL for global grants';
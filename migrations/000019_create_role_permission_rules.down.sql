@@ -0,0 +1,4 @@
+-- Rollback migration for wildcard and deny permission rules
+
+DROP INDEX IF EXISTS idx_role_permission_rules_role;
+DROP TABLE IF EXISTS role_permission_rules;
@@ -0,0 +1,23 @@
+-- Migration: Wildcard and deny permission rules
+-- Description: Roles can carry permission patterns ('apm_mappings.*', '*.view', '*')
+-- that allow or deny every matching permission, including ones added later.
+-- A matching deny always overrides any allow.
+
+CREATE TABLE IF NOT EXISTS role_permission_rules (
+  id SERIAL PRIMARY KEY,
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  pattern VARCHAR(100) NOT NULL,
+  effect VARCHAR(10) NOT NULL DEFAULT 'allow' CHECK (effect IN ('allow', 'deny')),
+  created_at TIMESTAMP DEFAULT NOW(),
+  created_by INTEGER REFERENCES users(id),
+  UNIQUE(role_id, pattern, effect)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_permission_rules_role ON role_permission_rules(role_id);
+
+COMMENT ON TABLE role_permission_rules IS 'Wildcard allow/deny permission patterns per role (deny overrides allow)';
+
+-- ADMIN: allow everything, so permissions added after seeding are covered too
+INSERT INTO role_permission_rules (role_id, pattern, effect)
+  SELECT id, '*', 'allow' FROM roles WHERE name = 'admin'
+ON CONFLICT (role_id, pattern, effect) DO NOTHING;
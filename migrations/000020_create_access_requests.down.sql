@@ -0,0 +1,11 @@
+-- Rollback migration for just-in-time access requests
+
+DROP TRIGGER IF EXISTS update_access_requests_updated_at ON access_requests;
+
+DROP INDEX IF EXISTS idx_role_approvers_user;
+DROP INDEX IF EXISTS idx_access_requests_role;
+DROP INDEX IF EXISTS idx_access_requests_user;
+DROP INDEX IF EXISTS idx_access_requests_status;
+
+DROP TABLE IF EXISTS access_requests;
+DROP TABLE IF EXISTS role_approvers;
@@ -0,0 +1,39 @@
+-- Migration: Just-in-time access requests
+-- Description: Users request a role for a limited time; designated approvers
+-- approve (creating an expiring user_roles row) or deny
+
+CREATE TABLE IF NOT EXISTS role_approvers (
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  added_at TIMESTAMP DEFAULT NOW(),
+  added_by INTEGER REFERENCES users(id),
+  PRIMARY KEY (role_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS access_requests (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+  justification TEXT NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'approved', 'denied', 'cancelled')),
+  decided_by INTEGER REFERENCES users(id),
+  decided_at TIMESTAMP,
+  decision_note TEXT,
+  expires_at TIMESTAMP,  -- When the granted role lapses (set on approval)
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_requests_status ON access_requests(status);
+CREATE INDEX IF NOT EXISTS idx_access_requests_user ON access_requests(user_id);
+CREATE INDEX IF NOT EXISTS idx_access_requests_role ON access_requests(role_id);
+CREATE INDEX IF NOT EXISTS idx_role_approvers_user ON role_approvers(user_id);
+
+DROP TRIGGER IF EXISTS update_access_requests_updated_at ON access_requests;
+CREATE TRIGGER update_access_requests_updated_at BEFORE UPDATE ON access_requests
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE role_approvers IS 'Users allowed to approve access requests for a role';
+COMMENT ON TABLE access_requests IS 'Time-limited role elevation requests and their decisions';
@@ -0,0 +1,7 @@
+-- Rollback migration for archiving expired role assignments
+
+ALTER TABLE user_roles DROP COLUMN IF EXISTS expiry_warned_days;
+
+DROP INDEX IF EXISTS idx_user_roles_expires;
+DROP INDEX IF EXISTS idx_user_roles_archive_user;
+DROP TABLE IF EXISTS user_roles_archive;
@@ -0,0 +1,24 @@
+-- Migration: Archive expired role assignments
+-- Description: The expiry job moves lapsed user_roles rows here and records which
+-- "expiring soon" warning was last sent for each assignment
+
+CREATE TABLE IF NOT EXISTS user_roles_archive (
+  id SERIAL PRIMARY KEY,
+  user_role_id INTEGER NOT NULL,  -- user_roles.id of the archived row
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  resource_type VARCHAR(50),
+  resource_id VARCHAR(255),
+  assigned_at TIMESTAMP,
+  assigned_by INTEGER REFERENCES users(id),
+  expires_at TIMESTAMP NOT NULL,
+  archived_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_archive_user ON user_roles_archive(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_roles_expires ON user_roles(expires_at) WHERE expires_at IS NOT NULL;
+
+-- Smallest "expiring in N days" threshold already notified for this assignment
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS expiry_warned_days INTEGER;
+
+COMMENT ON TABLE user_roles_archive IS 'Expired role assignments removed from user_roles by the expiry job';
@@ -0,0 +1,3 @@
+-- Rollback migration for tracking undeclared permissions
+
+ALTER TABLE permissions DROP COLUMN IF EXISTS undeclared_since;
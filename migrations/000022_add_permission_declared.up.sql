@@ -0,0 +1,7 @@
+-- Migration: Track permissions no longer declared in code
+-- Description: The startup registry sync sets undeclared_since on permissions the
+-- services no longer declare, and clears it when they are declared again
+
+ALTER TABLE permissions ADD COLUMN IF NOT EXISTS undeclared_since TIMESTAMP;
+
+COMMENT ON COLUMN permissions.undeclared_since IS 'When the permission registry stopped declaring this permission (NULL = declared)';
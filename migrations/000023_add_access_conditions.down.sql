@@ -0,0 +1,5 @@
+-- Rollback migration for attribute-based access conditions
+
+ALTER TABLE role_permissions DROP COLUMN IF EXISTS conditions;
+ALTER TABLE user_roles_archive DROP COLUMN IF EXISTS conditions;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS conditions;
@@ -0,0 +1,11 @@
+-- Migration: Attribute-based access conditions
+-- Description: Role assignments and role permission grants can carry conditions
+-- (time windows, source CIDRs, MFA, session age) that must hold for the
+-- request being authorized. NULL means the grant applies unconditionally.
+
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS conditions JSONB;
+ALTER TABLE user_roles_archive ADD COLUMN IF NOT EXISTS conditions JSONB;
+ALTER TABLE role_permissions ADD COLUMN IF NOT EXISTS conditions JSONB;
+
+COMMENT ON COLUMN user_roles.conditions IS 'Request conditions the assignment only applies under (NULL = always)';
+COMMENT ON COLUMN role_permissions.conditions IS 'Request conditions the grant only applies under (NULL = always)';
@@ -0,0 +1,5 @@
+-- Rollback migration for separation-of-duties constraints
+
+DROP INDEX IF EXISTS idx_sod_constraint_roles_role;
+DROP TABLE IF EXISTS sod_constraint_roles;
+DROP TABLE IF EXISTS sod_constraints;
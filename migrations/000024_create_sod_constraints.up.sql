@@ -0,0 +1,21 @@
+-- Migration: Separation-of-duties constraints
+-- Description: A constraint names a set of mutually exclusive roles; nobody may
+-- hold more than one of them at once, directly, through a group or by inheritance
+
+CREATE TABLE IF NOT EXISTS sod_constraints (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(100) UNIQUE NOT NULL,
+  description TEXT,
+  created_at TIMESTAMP DEFAULT NOW(),
+  created_by INTEGER REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS sod_constraint_roles (
+  constraint_id INTEGER NOT NULL REFERENCES sod_constraints(id) ON DELETE CASCADE,
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  PRIMARY KEY (constraint_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sod_constraint_roles_role ON sod_constraint_roles(role_id);
+
+COMMENT ON TABLE sod_constraints IS 'Separation-of-duties constraints: sets of roles no user may hold together';
@@ -0,0 +1,9 @@
+-- Rollback migration for access review campaigns
+
+DROP TRIGGER IF EXISTS update_access_review_campaigns_updated_at ON access_review_campaigns;
+DROP INDEX IF EXISTS idx_access_review_reviewers_user;
+DROP INDEX IF EXISTS idx_access_review_items_campaign;
+DROP INDEX IF EXISTS idx_access_review_campaigns_status;
+DROP TABLE IF EXISTS access_review_items;
+DROP TABLE IF EXISTS access_review_reviewers;
+DROP TABLE IF EXISTS access_review_campaigns;
@@ -0,0 +1,58 @@
+-- Migration: Access review campaigns
+-- Description: A campaign snapshots the user_roles entries for a role or a
+-- group's members; reviewers certify or revoke each one, and unreviewed
+-- entries can be expired when the campaign closes
+
+CREATE TABLE IF NOT EXISTS access_review_campaigns (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(200) NOT NULL,
+  description TEXT,
+  role_id INTEGER REFERENCES roles(id) ON DELETE SET NULL,
+  group_id INTEGER REFERENCES groups(id) ON DELETE SET NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed')),
+  due_at TIMESTAMP,
+  expire_unreviewed BOOLEAN NOT NULL DEFAULT false,  -- Expire pending entries on close
+  created_by INTEGER REFERENCES users(id),
+  created_at TIMESTAMP DEFAULT NOW(),
+  closed_by INTEGER REFERENCES users(id),
+  closed_at TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS access_review_reviewers (
+  campaign_id INTEGER NOT NULL REFERENCES access_review_campaigns(id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  PRIMARY KEY (campaign_id, user_id)
+);
+
+-- One row per user_roles entry in scope when the campaign opened. The
+-- assignment is copied so the evidence survives the entry being removed.
+CREATE TABLE IF NOT EXISTS access_review_items (
+  id SERIAL PRIMARY KEY,
+  campaign_id INTEGER NOT NULL REFERENCES access_review_campaigns(id) ON DELETE CASCADE,
+  user_role_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  resource_type VARCHAR(50),
+  resource_id VARCHAR(255),
+  assigned_at TIMESTAMP,
+  assigned_by INTEGER REFERENCES users(id),
+  expires_at TIMESTAMP,
+  decision VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (decision IN ('pending', 'certified', 'revoked', 'expired')),
+  decided_by INTEGER REFERENCES users(id),
+  decided_at TIMESTAMP,
+  note TEXT,
+  UNIQUE (campaign_id, user_role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_review_campaigns_status ON access_review_campaigns(status);
+CREATE INDEX IF NOT EXISTS idx_access_review_items_campaign ON access_review_items(campaign_id, decision);
+CREATE INDEX IF NOT EXISTS idx_access_review_reviewers_user ON access_review_reviewers(user_id);
+
+DROP TRIGGER IF EXISTS update_access_review_campaigns_updated_at ON access_review_campaigns;
+CREATE TRIGGER update_access_review_campaigns_updated_at BEFORE UPDATE ON access_review_campaigns
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE access_review_campaigns IS 'Periodic certification of role assignments';
+COMMENT ON TABLE access_review_items IS 'Role assignments under review and the decision on each';
@@ -0,0 +1,8 @@
+-- Rollback migration for template revisions
+
+DROP TRIGGER IF EXISTS template_revisions_immutable ON template_revisions;
+DROP FUNCTION IF EXISTS prevent_template_revision_update();
+
+ALTER TABLE quick_templates DROP COLUMN IF EXISTS current_revision;
+
+DROP TABLE IF EXISTS template_revisions;
@@ -0,0 +1,46 @@
+-- Migration: Template revisions
+-- Description: Every create, update and restore of a quick template appends an
+-- immutable revision (author, timestamp, full configuration); the template row
+-- holds the head
+
+CREATE TABLE IF NOT EXISTS template_revisions (
+  id SERIAL PRIMARY KEY,
+  template_id INTEGER NOT NULL REFERENCES quick_templates(id) ON DELETE CASCADE,
+  revision INTEGER NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  description TEXT,
+  configuration_data JSONB NOT NULL,
+  author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  restored_from INTEGER,  -- Revision this one restored, if any
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (template_id, revision)
+);
+
+ALTER TABLE quick_templates ADD COLUMN IF NOT EXISTS current_revision INTEGER NOT NULL DEFAULT 1;
+
+-- Existing templates start at revision 1
+INSERT INTO template_revisions (template_id, revision, name, description, configuration_data, author_id, created_at)
+SELECT id, 1, name, description, configuration_data, user_id, COALESCE(updated_at, created_at)
+FROM quick_templates
+ON CONFLICT (template_id, revision) DO NOTHING;
+
+-- Revisions are history: only the author_id cleanup on user deletion may change one
+CREATE OR REPLACE FUNCTION prevent_template_revision_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.template_id <> OLD.template_id OR NEW.revision <> OLD.revision OR NEW.name <> OLD.name
+       OR NEW.description IS DISTINCT FROM OLD.description
+       OR NEW.configuration_data <> OLD.configuration_data
+       OR NEW.restored_from IS DISTINCT FROM OLD.restored_from
+       OR NEW.created_at <> OLD.created_at THEN
+        RAISE EXCEPTION 'template revisions are immutable';
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS template_revisions_immutable ON template_revisions;
+CREATE TRIGGER template_revisions_immutable BEFORE UPDATE ON template_revisions
+    FOR EACH ROW EXECUTE FUNCTION prevent_template_revision_update();
+
+COMMENT ON TABLE template_revisions IS 'Immutable history of quick template configurations';
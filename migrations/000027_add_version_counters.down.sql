@@ -0,0 +1,13 @@
+-- Rollback migration for version counters
+
+DROP TRIGGER IF EXISTS touch_role_on_parent_change ON role_parents;
+DROP TRIGGER IF EXISTS touch_role_on_rule_change ON role_permission_rules;
+DROP TRIGGER IF EXISTS touch_role_on_permission_change ON role_permissions;
+DROP FUNCTION IF EXISTS touch_role_and_descendants();
+
+DROP TRIGGER IF EXISTS increment_roles_version ON roles;
+DROP TRIGGER IF EXISTS increment_quick_templates_version ON quick_templates;
+DROP FUNCTION IF EXISTS increment_version();
+
+ALTER TABLE roles DROP COLUMN IF EXISTS version;
+ALTER TABLE quick_templates DROP COLUMN IF EXISTS version;
@@ -0,0 +1,60 @@
+-- Migration: Version counters for optimistic concurrency
+-- Description: quick_templates and roles carry a version that every update
+-- increments; the API exposes it as an ETag. A role's version also moves when
+-- its permissions, rules or parents change, or those of a role it inherits.
+
+ALTER TABLE quick_templates ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION increment_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS increment_quick_templates_version ON quick_templates;
+CREATE TRIGGER increment_quick_templates_version BEFORE UPDATE ON quick_templates
+    FOR EACH ROW EXECUTE FUNCTION increment_version();
+
+DROP TRIGGER IF EXISTS increment_roles_version ON roles;
+CREATE TRIGGER increment_roles_version BEFORE UPDATE ON roles
+    FOR EACH ROW EXECUTE FUNCTION increment_version();
+
+-- Touch the changed role and every role inheriting from it
+CREATE OR REPLACE FUNCTION touch_role_and_descendants()
+RETURNS TRIGGER AS $$
+DECLARE
+    target INTEGER;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target := OLD.role_id;
+    ELSE
+        target := NEW.role_id;
+    END IF;
+
+    UPDATE roles SET updated_at = NOW()
+    WHERE id IN (
+        WITH RECURSIVE affected AS (
+            SELECT target AS id
+            UNION
+            SELECT rp.role_id FROM role_parents rp JOIN affected a ON rp.parent_role_id = a.id
+        )
+        SELECT id FROM affected
+    );
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS touch_role_on_permission_change ON role_permissions;
+CREATE TRIGGER touch_role_on_permission_change AFTER INSERT OR UPDATE OR DELETE ON role_permissions
+    FOR EACH ROW EXECUTE FUNCTION touch_role_and_descendants();
+
+DROP TRIGGER IF EXISTS touch_role_on_rule_change ON role_permission_rules;
+CREATE TRIGGER touch_role_on_rule_change AFTER INSERT OR UPDATE OR DELETE ON role_permission_rules
+    FOR EACH ROW EXECUTE FUNCTION touch_role_and_descendants();
+
+DROP TRIGGER IF EXISTS touch_role_on_parent_change ON role_parents;
+CREATE TRIGGER touch_role_on_parent_change AFTER INSERT OR UPDATE OR DELETE ON role_parents
+    FOR EACH ROW EXECUTE FUNCTION touch_role_and_descendants();
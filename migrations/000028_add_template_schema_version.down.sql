@@ -0,0 +1,6 @@
+-- Rollback migration for template configuration schema version
+
+DROP INDEX IF EXISTS idx_templates_schema_version;
+
+ALTER TABLE template_revisions DROP COLUMN IF EXISTS schema_version;
+ALTER TABLE quick_templates DROP COLUMN IF EXISTS schema_version;
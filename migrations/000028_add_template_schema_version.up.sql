@@ -0,0 +1,11 @@
+-- Migration: Template configuration schema version
+-- Description: Records the configuration schema version each template (and
+-- each revision) was validated against. Existing rows are version 0 (saved
+-- before the schema existed) and are upgraded by the service at startup.
+
+ALTER TABLE quick_templates ADD COLUMN IF NOT EXISTS schema_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE template_revisions ADD COLUMN IF NOT EXISTS schema_version INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_templates_schema_version ON quick_templates(schema_version);
+
+COMMENT ON COLUMN quick_templates.schema_version IS 'Version of the configuration_data JSON Schema the template matches';
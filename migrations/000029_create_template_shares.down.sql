@@ -0,0 +1,5 @@
+-- Rollback migration for template share grants
+
+DROP TABLE IF EXISTS template_shares;
+
+COMMENT ON COLUMN quick_templates.is_shared IS NULL;
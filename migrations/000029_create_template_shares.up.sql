@@ -0,0 +1,22 @@
+-- Migration: Template share grants
+-- Description: A template can be shared with specific users or groups as a
+-- viewer or editor. is_shared remains the "public to org" option: everyone
+-- can view the template.
+
+CREATE TABLE IF NOT EXISTS template_shares (
+  id SERIAL PRIMARY KEY,
+  template_id INTEGER NOT NULL REFERENCES quick_templates(id) ON DELETE CASCADE,
+  user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+  group_id INTEGER REFERENCES groups(id) ON DELETE CASCADE,
+  level VARCHAR(10) NOT NULL CHECK (level IN ('viewer', 'editor')),
+  granted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  CHECK ((user_id IS NULL) <> (group_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_template_shares_user ON template_shares(template_id, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_template_shares_group ON template_shares(template_id, group_id) WHERE group_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_template_shares_grantee_user ON template_shares(user_id);
+CREATE INDEX IF NOT EXISTS idx_template_shares_grantee_group ON template_shares(group_id);
+
+COMMENT ON COLUMN quick_templates.is_shared IS 'Public to org: every user can view the template';
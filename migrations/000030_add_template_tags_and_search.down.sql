@@ -0,0 +1,8 @@
+-- Rollback migration for template tags and full-text search
+
+DROP INDEX IF EXISTS idx_templates_updated;
+DROP INDEX IF EXISTS idx_templates_search;
+DROP INDEX IF EXISTS idx_templates_tags;
+
+ALTER TABLE quick_templates DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE quick_templates DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,15 @@
+-- Migration: Template tags and full-text search
+-- Description: Templates carry normalised tags and a generated tsvector over
+-- name (weighted higher) and description for the template picker's search
+
+ALTER TABLE quick_templates ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE quick_templates ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+  GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+  ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_templates_tags ON quick_templates USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_templates_search ON quick_templates USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_templates_updated ON quick_templates (COALESCE(updated_at, created_at) DESC);
@@ -0,0 +1,12 @@
+-- Rollback migration for group role assignments in access reviews
+
+DELETE FROM access_review_items WHERE group_role_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_access_review_items_group_role;
+ALTER TABLE access_review_items DROP CONSTRAINT IF EXISTS access_review_items_one_assignment;
+
+ALTER TABLE access_review_items DROP COLUMN IF EXISTS group_id;
+ALTER TABLE access_review_items DROP COLUMN IF EXISTS group_role_id;
+
+ALTER TABLE access_review_items ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE access_review_items ALTER COLUMN user_role_id SET NOT NULL;
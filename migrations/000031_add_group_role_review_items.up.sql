@@ -0,0 +1,17 @@
+-- Migration: Group role assignments in access reviews
+-- Description: A review item is either a user_roles entry or a group_roles
+-- entry; group items are copied with the group so the evidence survives the
+-- assignment being removed
+
+ALTER TABLE access_review_items ALTER COLUMN user_role_id DROP NOT NULL;
+ALTER TABLE access_review_items ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE access_review_items ADD COLUMN IF NOT EXISTS group_role_id INTEGER;
+ALTER TABLE access_review_items ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES groups(id) ON DELETE CASCADE;
+
+ALTER TABLE access_review_items DROP CONSTRAINT IF EXISTS access_review_items_one_assignment;
+ALTER TABLE access_review_items ADD CONSTRAINT access_review_items_one_assignment
+  CHECK ((user_role_id IS NOT NULL AND user_id IS NOT NULL AND group_role_id IS NULL AND group_id IS NULL)
+      OR (user_role_id IS NULL AND user_id IS NULL AND group_role_id IS NOT NULL AND group_id IS NOT NULL));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_access_review_items_group_role ON access_review_items(campaign_id, group_role_id);
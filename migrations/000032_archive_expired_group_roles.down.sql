@@ -0,0 +1,7 @@
+-- Rollback migration for archiving expired group role assignments
+
+ALTER TABLE group_roles DROP COLUMN IF EXISTS expiry_warned_days;
+
+DROP INDEX IF EXISTS idx_group_roles_expires;
+DROP INDEX IF EXISTS idx_group_roles_archive_group;
+DROP TABLE IF EXISTS group_roles_archive;
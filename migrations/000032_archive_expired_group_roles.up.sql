@@ -0,0 +1,23 @@
+-- Migration: Archive expired group role assignments
+-- Description: The expiry job moves lapsed group_roles rows here, like
+-- user_roles_archive, and records which "expiring soon" warning was last sent
+-- to the group's members
+
+CREATE TABLE IF NOT EXISTS group_roles_archive (
+  id SERIAL PRIMARY KEY,
+  group_role_id INTEGER NOT NULL,  -- group_roles.id of the archived row
+  group_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  assigned_at TIMESTAMP,
+  assigned_by INTEGER REFERENCES users(id),
+  expires_at TIMESTAMP NOT NULL,
+  archived_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_roles_archive_group ON group_roles_archive(group_id);
+CREATE INDEX IF NOT EXISTS idx_group_roles_expires ON group_roles(expires_at) WHERE expires_at IS NOT NULL;
+
+-- Smallest "expiring in N days" threshold already notified for this assignment
+ALTER TABLE group_roles ADD COLUMN IF NOT EXISTS expiry_warned_days INTEGER;
+
+COMMENT ON TABLE group_roles_archive IS 'Expired group role assignments removed from group_roles by the expiry job';